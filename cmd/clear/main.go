@@ -1,14 +1,48 @@
 package main
 
 import (
-	"github.com/kubecube-io/kubecube-e2e/e2e"
+	"flag"
 	"os"
+	"strings"
+
+	"github.com/kubecube-io/kubecube-e2e/e2e"
 
 	"github.com/kubecube-io/kubecube/pkg/clog"
 )
 
+var (
+	sweep     = flag.Bool("sweep", false, "find and delete e2e objects in all clusters by e2e labels instead of the resources named in config")
+	dryRun    = flag.Bool("dry-run", false, "print the sweep plan without deleting, implies -sweep")
+	olderThan = flag.Duration("older-than", 0, "only sweep objects created earlier than this duration, e.g. 24h, implies -sweep, required unless -dry-run")
+	clusters  = flag.String("cluster", "", "comma separated clusters to sweep, empty means pivot and all member clusters, implies -sweep")
+)
+
 // 兜底资源清理脚本
 func main() {
+	flag.Parse()
+
+	if *sweep || *dryRun || *olderThan > 0 || len(*clusters) > 0 {
+		// 不限制创建时间会删除正在运行的测试的对象，删除时必须显式指定 -older-than
+		if !*dryRun && *olderThan <= 0 {
+			clog.Error("sweep deletes objects of running tests without -older-than, specify -older-than (e.g. 24h) or -dry-run")
+			os.Exit(1)
+		}
+		opts := e2e.SweepOptions{DryRun: *dryRun, OlderThan: *olderThan, Out: os.Stdout}
+		for _, c := range strings.Split(*clusters, ",") {
+			if c = strings.TrimSpace(c); len(c) > 0 {
+				opts.Clusters = append(opts.Clusters, c)
+			}
+		}
+		if err := e2e.Sweep(opts); err != nil {
+			clog.Error(err.Error())
+			os.Exit(1)
+		}
+		if !*dryRun {
+			clog.Info("e2e objects swept")
+		}
+		return
+	}
+
 	if err := e2e.Clear(); err != nil {
 		clog.Error(err.Error())
 		os.Exit(1)
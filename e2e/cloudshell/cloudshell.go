@@ -36,12 +36,11 @@ import (
 	"github.com/kubecube-io/kubecube-e2e/e2e/framework"
 )
 
-func checkCloudShell(user string) framework.TestResp {
-	httpHelper := framework.NewSingleHttpHelper()
+func checkCloudShell(tc *framework.TestContext) framework.TestResp {
 	nodeList := &v1.NodeList{}
-	err := framework.TargetClusterClient.Cache().List(context.Background(), nodeList)
-	framework.ExpectNoError(err)
-	framework.ExpectNotEqual(len(nodeList.Items), 0)
+	err := tc.TargetClusterClient.Cache().List(context.Background(), nodeList)
+	tc.ExpectNoError(err)
+	tc.ExpectNotEqual(len(nodeList.Items), 0)
 	var nodename string
 	for _, node := range nodeList.Items {
 		for _, condition := range node.Status.Conditions {
@@ -53,13 +52,13 @@ func checkCloudShell(user string) framework.TestResp {
 	}
 	stop := make(chan struct{}, 1)
 	ginkgo.By("请求接口api/v1/extends/cloudShell/clusters/{clusterName}获取sessionId")
-	sessionUrl := fmt.Sprintf("%s/api/v1/extends/cloudShell/clusters/%s", framework.ConsoleHost, framework.TargetClusterName)
-	response, err := httpHelper.RequestByUser(http.MethodGet, sessionUrl, "", user, nil)
-	framework.ExpectNoError(err)
+	sessionUrl := fmt.Sprintf("%s/api/v1/extends/cloudShell/clusters/%s", tc.ConsoleHost, tc.TargetClusterName)
+	response, err := tc.HttpHelper.RequestByUser(http.MethodGet, sessionUrl, "", tc.User, nil)
+	tc.ExpectNoError(err)
 	defer response.Body.Close()
 	body, err := io.ReadAll(response.Body)
 	clog.Info("session msg: %s", body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 
 	if !framework.IsSuccess(response.StatusCode) {
 		clog.Warn("res code %d", response.StatusCode)
@@ -68,26 +67,26 @@ func checkCloudShell(user string) framework.TestResp {
 
 	var sessionInfo map[string]interface{}
 	err = json.Unmarshal(body, &sessionInfo)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	sessionId, ok := sessionInfo["id"].(string)
-	framework.ExpectEqual(ok, true)
-	client, err := websocket2.NewClient(framework.ConsoleHost+"/api/sockjs", sessionId, stop)
-	framework.ExpectNoError(err)
+	tc.ExpectEqual(ok, true)
+	client, err := websocket2.NewClient(tc.ConsoleHost+"/api/sockjs", sessionId, stop, tc.WaitInterval, tc.WaitTimeout)
+	tc.ExpectNoError(err)
 	message, err := websocket2.GetOpData("kubectl get node " + nodename + " \r").GetWriteMessage()
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	err = client.WriteMessage([]string{message})
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	b := &backoff2.ExponentialBackOff{
-		InitialInterval:     framework.WaitInterval,
+		InitialInterval:     tc.WaitInterval,
 		RandomizationFactor: backoff2.DefaultRandomizationFactor,
 		Multiplier:          backoff2.DefaultMultiplier,
 		MaxInterval:         backoff2.DefaultMaxInterval,
-		MaxElapsedTime:      framework.WaitTimeout,
+		MaxElapsedTime:      tc.WaitTimeout,
 		Clock:               backoff2.SystemClock,
 	}
 	b.Reset()
 	ctx := context.Background()
-	timeoutCtx, cancelFunc := context.WithTimeout(ctx, time.Second*framework.WaitTimeout)
+	timeoutCtx, cancelFunc := context.WithTimeout(ctx, time.Second*tc.WaitTimeout)
 	defer cancelFunc()
 	err = backoff2.Retry(func() error {
 		var res []string
@@ -107,21 +106,19 @@ func checkCloudShell(user string) framework.TestResp {
 		}
 		return err
 	}, b, timeoutCtx)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	return framework.SucceedResp
 }
 
 var multiUserTest = framework.MultiUserTest{
 	TestName:        "CloudShell检查",
 	ContinueIfError: false,
-	Skipfunc: func() bool {
-		return !framework.CloudShellEnabled
-	},
-	ErrorFunc:  framework.PermissionErrorFunc,
-	AfterEach:  nil,
-	BeforeEach: nil,
-	InitStep:   nil,
-	FinalStep:  nil,
+	Capabilities:    []string{framework.CapabilityCloudShell},
+	ErrorFunc:       framework.PermissionErrorFunc,
+	AfterEach:       nil,
+	BeforeEach:      nil,
+	InitStep:        nil,
+	FinalStep:       nil,
 	Steps: []framework.MultiUserTestStep{
 		{
 			Name:        "CloudShell检查",
@@ -26,27 +26,17 @@ import (
 	"github.com/kubecube-io/kubecube-e2e/e2e/framework"
 	v1 "github.com/kubecube-io/kubecube/pkg/apis/cluster/v1"
 	"github.com/kubecube-io/kubecube/pkg/clog"
-	"github.com/kubecube-io/kubecube/pkg/multicluster/client"
-)
-
-var (
-	pivotClient client.Client
-	httpHelper  *framework.HttpHelper
 )
 
 func init() {
 	framework.RegisterByDefault(multiUserTest)
 }
 
-func initParam() {
-	pivotClient = framework.PivotClusterClient
-	httpHelper = framework.NewSingleHttpHelper()
-}
-
 var multiUserTest = framework.MultiUserTest{
 	TestName:        "[集群信息]集群列表检查检查",
 	ContinueIfError: false,
 	SkipUsers:       []string{},
+	Labels:          []string{"smoke"},
 	Skipfunc:        nil,
 	ErrorFunc:       framework.PermissionErrorFunc,
 	AfterEach:       nil,
@@ -68,32 +58,31 @@ var multiUserTest = framework.MultiUserTest{
 	},
 }
 
-func listCluster(user string) framework.TestResp {
-	initParam()
+func listCluster(tc *framework.TestContext) framework.TestResp {
 	urlOfListCluster := "%s/api/v1/cube/clusters/info"
-	urlOfListCluster = fmt.Sprintf(urlOfListCluster, framework.KubecubeHost)
-	resp, err := httpHelper.RequestByUser(http.MethodGet, urlOfListCluster, "", user, nil)
-	framework.ExpectNoError(err)
+	urlOfListCluster = fmt.Sprintf(urlOfListCluster, tc.KubecubeHost)
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodGet, urlOfListCluster, "", tc.User, nil)
+	tc.ExpectNoError(err)
 	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	clog.Info("get cluster list resp, data: %s", string(body))
 	var clusterResMap map[string]interface{}
 	err = json.Unmarshal(body, &clusterResMap)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	clusterList := v1.ClusterList{}
-	err = pivotClient.Direct().List(context.Background(), &clusterList)
-	framework.ExpectNoError(err)
+	err = tc.PivotClusterClient.Direct().List(context.Background(), &clusterList)
+	tc.ExpectNoError(err)
 	total, ok := clusterResMap["total"]
-	framework.ExpectEqual(ok, true)
-	framework.ExpectEqual(float64(len(clusterList.Items)), total)
+	tc.ExpectEqual(ok, true)
+	tc.ExpectEqual(float64(len(clusterList.Items)), total)
 	items, ok := clusterResMap["items"].([]interface{})
-	framework.ExpectEqual(ok, true)
+	tc.ExpectEqual(ok, true)
 	for _, item := range items {
 		item, ok := item.(map[string]interface{})
-		framework.ExpectEqual(ok, true)
+		tc.ExpectEqual(ok, true)
 		name, ok := item["clusterName"].(string)
-		framework.ExpectEqual(ok, true)
+		tc.ExpectEqual(ok, true)
 		nameCheck := false
 		for _, cluster := range clusterList.Items {
 			if name == cluster.Name {
@@ -101,7 +90,7 @@ func listCluster(user string) framework.TestResp {
 				break
 			}
 		}
-		framework.ExpectEqual(nameCheck, true)
+		tc.ExpectEqual(nameCheck, true)
 	}
 	return framework.SucceedResp
 }
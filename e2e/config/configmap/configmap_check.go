@@ -18,7 +18,6 @@ package configmap
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -26,80 +25,56 @@ import (
 
 	v1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
 	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/kubecube-io/kubecube-e2e/e2e/framework"
 	"github.com/kubecube-io/kubecube/pkg/clog"
-	"github.com/kubecube-io/kubecube/pkg/multicluster/client"
 )
 
-var (
-	clusterName string
-	httpHelper  *framework.HttpHelper
-	namespace   string
-	cli         client.Client
-
-	cmName  string
-	podName string
-
-	bodyOfCreateCM []byte
-)
-
-func initParam() {
-	clusterName = framework.TargetClusterName
-	httpHelper = framework.NewSingleHttpHelper()
-	namespace = framework.NamespaceName
-	cli = framework.TargetClusterClient
-	cmName = "e2e-cm-test"
+const (
+	cmName  = "e2e-cm-test"
 	podName = "e2e-pod-cm-test"
-}
+)
 
-func createCM(user string) framework.TestResp {
+func createCM(tc *framework.TestContext) framework.TestResp {
 	clog.Info("=========createCM========")
-	initParam()
-	cmNameByUser := user + "-" + cmName
-	postJsonOfCreateCM := `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"%s","namespace":"%s"},"data":{"key1":"value1","key2":"value2"}}`
-	postJsonOfCreateCM = fmt.Sprintf(postJsonOfCreateCM, cmNameByUser, namespace)
-	urlOfCreateCM := "%s/api/v1/cube/proxy/clusters/%s/api/v1/namespaces/%s/configmaps"
-	urlOfCreateCM = fmt.Sprintf(urlOfCreateCM, framework.KubecubeHost, clusterName, namespace)
-	respOfCreateCM, err := httpHelper.RequestByUser(http.MethodPost, urlOfCreateCM, postJsonOfCreateCM, user, nil)
-	framework.ExpectNoError(err)
-	defer respOfCreateCM.Body.Close()
-	bodyOfCreateCM, err = io.ReadAll(respOfCreateCM.Body)
-	framework.ExpectNoError(err)
-	clog.Info(string(bodyOfCreateCM))
-
-	if !framework.IsSuccess(respOfCreateCM.StatusCode) {
-		clog.Warn("res code %d", respOfCreateCM.StatusCode)
-		return framework.NewTestResp(errors.New("fail to create cm"), respOfCreateCM.StatusCode)
+	cmNameByUser := tc.User + "-" + cmName
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: cmNameByUser, Namespace: tc.NamespaceName},
+		Data:       map[string]string{"key1": "value1", "key2": "value2"},
+	}
+	err := tc.TargetProxyClient().Create(context.Background(), cm)
+	if err != nil {
+		clog.Warn("fail to create cm: %v", err)
+		return framework.NewTestRespWithAPIError(err)
 	}
 
 	checkOfCreateCM := &v1.ConfigMap{}
-	err = cli.Direct().Get(context.Background(), ctrlclient.ObjectKey{
-		Namespace: namespace,
+	err = tc.TargetClusterClient.Direct().Get(context.Background(), ctrlclient.ObjectKey{
+		Namespace: tc.NamespaceName,
 		Name:      cmNameByUser,
 	}, checkOfCreateCM)
-	framework.ExpectNoError(err, "new configmap should be retrieved")
+	tc.ExpectNoError(err, "new configmap should be retrieved")
 
 	return framework.SucceedResp
 }
 
-func createPodAndCheck(user string) framework.TestResp {
+func createPodAndCheck(tc *framework.TestContext) framework.TestResp {
 	clog.Info("=========createPodAndCheck========")
-	initParam()
-	podNameByUser := user + "-" + podName
-	cmNameByUser := user + "-" + cmName
+	podNameByUser := tc.User + "-" + podName
+	cmNameByUser := tc.User + "-" + cmName
 	postJsonOfCreatePodWithCM := `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"%s","namespace":"%s"},"spec":{"imagePullSecrets": [{"name": "%s"}],"containers":[{"name":"mypod","image":"%s","imagePullPolicy": "IfNotPresent","env":[{"name":"KEY1","valueFrom":{"configMapKeyRef":{"name":"%s","key":"key1"}}}],"command":["sleep","2m"],"resources":{"limits":{"cpu":"100m","memory":"128Mi"},"requests":{"cpu":"100m","memory":"128Mi"}}}]}}`
-	postJsonOfCreatePodWithCM = fmt.Sprintf(postJsonOfCreatePodWithCM, podNameByUser, namespace, framework.ImagePullSecret, framework.TestImage, cmNameByUser)
+	postJsonOfCreatePodWithCM = fmt.Sprintf(postJsonOfCreatePodWithCM, podNameByUser, tc.NamespaceName, tc.ImagePullSecret, tc.TestImage, cmNameByUser)
 	urlOfCreatePodWithCM := "%s/api/v1/cube/proxy/clusters/%s/api/v1/namespaces/%s/pods"
-	urlOfCreatePodWithCM = fmt.Sprintf(urlOfCreatePodWithCM, framework.KubecubeHost, clusterName, namespace)
-	respOfCreatePodWithCM, err := httpHelper.RequestByUser(http.MethodPost, urlOfCreatePodWithCM, postJsonOfCreatePodWithCM, user, nil)
-	framework.ExpectNoError(err)
+	urlOfCreatePodWithCM = fmt.Sprintf(urlOfCreatePodWithCM, tc.KubecubeHost, tc.TargetClusterName, tc.NamespaceName)
+	respOfCreatePodWithCM, err := tc.HttpHelper.RequestByUser(http.MethodPost, urlOfCreatePodWithCM, postJsonOfCreatePodWithCM, tc.User, nil)
+	tc.ExpectNoError(err)
 	defer respOfCreatePodWithCM.Body.Close()
 
 	body, err := io.ReadAll(respOfCreatePodWithCM.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	clog.Info(string(body))
 
 	if !framework.IsSuccess(respOfCreatePodWithCM.StatusCode) {
@@ -108,9 +83,9 @@ func createPodAndCheck(user string) framework.TestResp {
 	}
 
 	checkOfCreatePodWithCM := &v1.Pod{}
-	err = wait.Poll(framework.WaitInterval, framework.WaitTimeout, func() (done bool, err error) {
-		err = cli.Direct().Get(context.Background(), ctrlclient.ObjectKey{
-			Namespace: namespace,
+	err = wait.Poll(tc.WaitInterval, tc.WaitTimeout, func() (done bool, err error) {
+		err = tc.TargetClusterClient.Direct().Get(context.Background(), ctrlclient.ObjectKey{
+			Namespace: tc.NamespaceName,
 			Name:      podNameByUser,
 		}, checkOfCreatePodWithCM)
 		if err != nil || checkOfCreatePodWithCM.Status.Phase != "Running" {
@@ -122,84 +97,68 @@ func createPodAndCheck(user string) framework.TestResp {
 		return true, nil
 	})
 
-	framework.ExpectNoError(err, "pod should be created")
+	tc.ExpectNoError(err, "pod should be created")
 
 	return framework.SucceedResp
 }
 
-func updateConfigMap(user string) framework.TestResp {
+func updateConfigMap(tc *framework.TestContext) framework.TestResp {
 	clog.Info("=========updateConfigMap========")
-	initParam()
-
-	cmNameByUser := user + "-" + cmName
-	var newCM map[string]interface{}
-	err := json.Unmarshal(bodyOfCreateCM, &newCM)
-	framework.ExpectNoError(err)
-	newCM["data"].(map[string]interface{})["key2"] = "newValue"
-
-	postJsonOfUpdateCM, _ := json.Marshal(newCM)
-	urlOfUpdateCM := "%s/api/v1/cube/proxy/clusters/%s/api/v1/namespaces/%s/configmaps/%s"
-	urlOfUpdateCM = fmt.Sprintf(urlOfUpdateCM, framework.KubecubeHost, clusterName, namespace, cmNameByUser)
-	respOfUpdateCM, err := httpHelper.RequestByUser(http.MethodPut, urlOfUpdateCM, string(postJsonOfUpdateCM), user, nil)
-	defer respOfUpdateCM.Body.Close()
-	framework.ExpectNoError(err)
-
-	body, err := io.ReadAll(respOfUpdateCM.Body)
-	framework.ExpectNoError(err)
-	clog.Info(string(body))
-
-	if !framework.IsSuccess(respOfUpdateCM.StatusCode) {
-		clog.Warn("res code %d", respOfUpdateCM.StatusCode)
-		return framework.NewTestResp(errors.New("fail to update cm"), respOfUpdateCM.StatusCode)
+	cmNameByUser := tc.User + "-" + cmName
+	cli := tc.TargetProxyClient()
+	cm := &v1.ConfigMap{}
+	err := cli.Get(context.Background(), ctrlclient.ObjectKey{
+		Namespace: tc.NamespaceName,
+		Name:      cmNameByUser,
+	}, cm)
+	if err != nil {
+		clog.Warn("fail to get cm: %v", err)
+		return framework.NewTestRespWithAPIError(err)
+	}
+	cm.Data["key2"] = "newValue"
+	err = cli.Update(context.Background(), cm)
+	if err != nil {
+		clog.Warn("fail to update cm: %v", err)
+		return framework.NewTestRespWithAPIError(err)
 	}
 
 	checkOfUpdateCM := &v1.ConfigMap{}
-	err = cli.Direct().Get(context.Background(), ctrlclient.ObjectKey{
-		Namespace: namespace,
+	err = tc.TargetClusterClient.Direct().Get(context.Background(), ctrlclient.ObjectKey{
+		Namespace: tc.NamespaceName,
 		Name:      cmNameByUser,
 	}, checkOfUpdateCM)
-	framework.ExpectNoError(err, "new configmap should be retrieved")
-	framework.ExpectEqual(checkOfUpdateCM.Data["key2"], "newValue", "new value should be updated")
+	tc.ExpectNoError(err, "new configmap should be retrieved")
+	tc.ExpectEqual(checkOfUpdateCM.Data["key2"], "newValue", "new value should be updated")
 
 	return framework.SucceedResp
 }
 
-func deleteConfigMap(user string) framework.TestResp {
+func deleteConfigMap(tc *framework.TestContext) framework.TestResp {
 	clog.Info("=========deleteConfigMap========")
-	initParam()
-	cmNameByUser := user + "-" + cmName
-	urlOfDeleteCM := "%s/api/v1/cube/proxy/clusters/%s/api/v1/namespaces/%s/configmaps/%s"
-	urlOfDeleteCM = fmt.Sprintf(urlOfDeleteCM, framework.KubecubeHost, clusterName, namespace, cmNameByUser)
-	respOfDeleteCM, err := httpHelper.RequestByUser(http.MethodDelete, urlOfDeleteCM, "", user, nil)
-	defer respOfDeleteCM.Body.Close()
-	framework.ExpectNoError(err)
-
-	body, err := io.ReadAll(respOfDeleteCM.Body)
-	framework.ExpectNoError(err)
-	clog.Info(string(body))
-
-	if !framework.IsSuccess(respOfDeleteCM.StatusCode) {
-		clog.Warn("res code %d", respOfDeleteCM.StatusCode)
-		return framework.NewTestResp(errors.New("fail to delete cm"), respOfDeleteCM.StatusCode)
+	cmNameByUser := tc.User + "-" + cmName
+	cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: cmNameByUser, Namespace: tc.NamespaceName}}
+	err := tc.TargetProxyClient().Delete(context.Background(), cm)
+	if err != nil {
+		clog.Warn("fail to delete cm: %v", err)
+		return framework.NewTestRespWithAPIError(err)
 	}
 
 	checkOfDeleteCM := &v1.ConfigMap{}
-	err = cli.Direct().Get(context.Background(), ctrlclient.ObjectKey{
-		Namespace: namespace,
+	err = tc.TargetClusterClient.Direct().Get(context.Background(), ctrlclient.ObjectKey{
+		Namespace: tc.NamespaceName,
 		Name:      cmNameByUser,
 	}, checkOfDeleteCM)
-	framework.ExpectEqual(kerrors.IsNotFound(err), true, "CM should be deleted")
+	tc.ExpectEqual(kerrors.IsNotFound(err), true, "CM should be deleted")
 	return framework.SucceedResp
 }
 
-func clearPod(user string) framework.TestResp {
+func clearPod(tc *framework.TestContext) framework.TestResp {
 	clog.Info("=========clearpod========")
-	initParam()
-	podNameByUser := user + "-" + podName
+	podNameByUser := tc.User + "-" + podName
 	urlOfDeletePodWithCM := "%s/api/v1/cube/proxy/clusters/%s/api/v1/namespaces/%s/pods/%s"
-	urlOfDeletePodWithCM = fmt.Sprintf(urlOfDeletePodWithCM, framework.KubecubeHost, clusterName, namespace, podNameByUser)
-	_, err := httpHelper.Delete(urlOfDeletePodWithCM)
-	framework.ExpectNoError(err, "should be deleted")
+	urlOfDeletePodWithCM = fmt.Sprintf(urlOfDeletePodWithCM, tc.KubecubeHost, tc.TargetClusterName, tc.NamespaceName, podNameByUser)
+	_, err := tc.HttpHelper.Delete(urlOfDeletePodWithCM)
+	tc.ExpectNoError(err, "should be deleted")
 	return framework.SucceedResp
 }
 
@@ -207,6 +166,7 @@ var multiUserTest = framework.MultiUserTest{
 	TestName:        "[配置][9387667]ConfigMap检查",
 	ContinueIfError: false,
 	SkipUsers:       []string{},
+	Labels:          []string{"smoke"},
 	Skipfunc:        nil,
 	ErrorFunc:       framework.PermissionErrorFunc,
 	AfterEach:       nil,
@@ -31,18 +31,17 @@ import (
 	"github.com/kubecube-io/kubecube/pkg/clog"
 )
 
-func createDockerConfigJsonSecret(user string) framework.TestResp {
-	initParam()
-	secretNameWithUser := framework.NameWithUser(secretName, user)
+func createDockerConfigJsonSecret(tc *framework.TestContext) framework.TestResp {
+	secretNameWithUser := tc.NameWithUser(secretName)
 	postJsonOfCreateSecret := `{"apiVersion":"v1","kind":"Secret","metadata":{"name":"%s","namespace":"%s"},"data":{".dockerconfigjson":"eyAiYXV0aHMiOiB7ICJoYXJib3IuY2xvdWQubmV0ZWFzZS5jb20iOiB7ICJhdXRoIjogImMydHBabVk2U2tSRllYY3lNMEJxWm1SWEl6Yz0iIH0gfSwgIkh0dHBIZWFkZXJzIjogeyAiVXNlci1BZ2VudCI6ICJEb2NrZXItQ2xpZW50LzE5LjAzLjEzIChsaW51eCkiIH0gfQo="},"type":"kubernetes.io/dockerconfigjson"}`
-	postJsonOfCreateSecret = fmt.Sprintf(postJsonOfCreateSecret, secretNameWithUser, namespace)
+	postJsonOfCreateSecret = fmt.Sprintf(postJsonOfCreateSecret, secretNameWithUser, tc.NamespaceName)
 	urlOfCreateSecret := "%s/api/v1/cube/proxy/clusters/%s/api/v1/namespaces/%s/secrets"
-	urlOfCreateSecret = fmt.Sprintf(urlOfCreateSecret, framework.KubecubeHost, clusterName, namespace)
-	respOfCreateSecret, err := httpHelper.RequestByUser(http.MethodPost, urlOfCreateSecret, postJsonOfCreateSecret, user, nil)
-	framework.ExpectNoError(err)
+	urlOfCreateSecret = fmt.Sprintf(urlOfCreateSecret, tc.KubecubeHost, tc.TargetClusterName, tc.NamespaceName)
+	respOfCreateSecret, err := tc.HttpHelper.RequestByUser(http.MethodPost, urlOfCreateSecret, postJsonOfCreateSecret, tc.User, nil)
+	tc.ExpectNoError(err)
 	defer respOfCreateSecret.Body.Close()
 	body, err := io.ReadAll(respOfCreateSecret.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	clog.Info("create secret resp %+v", string(body))
 
 	if !framework.IsSuccess(respOfCreateSecret.StatusCode) {
@@ -53,18 +52,17 @@ func createDockerConfigJsonSecret(user string) framework.TestResp {
 	return framework.SucceedResp
 }
 
-func createPod(user string) framework.TestResp {
-	initParam()
-	podNameWithUser := framework.NameWithUser(podName, user)
+func createPod(tc *framework.TestContext) framework.TestResp {
+	podNameWithUser := tc.NameWithUser(podName)
 	postJsonOfCreatePodWithSecret := `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"%s","namespace":"%s"},"spec":{"imagePullSecrets":[{"name":"e2e-test-docker-secret"},{"name":"%s"}],"affinity":{"nodeAffinity":{"requiredDuringSchedulingIgnoredDuringExecution":{"nodeSelectorTerms":[{"matchExpressions":[{"key":"node.kubecube.io/tenant","operator":"In","values":["share"]}]}]}}},"containers":[{"name":"mypod","image":"%s","imagePullPolicy": "IfNotPresent","command":[],"resources":{"limits":{"cpu":"100m","memory":"128Mi"},"requests":{"cpu":"100m","memory":"128Mi"}}}]}}`
-	postJsonOfCreatePodWithSecret = fmt.Sprintf(postJsonOfCreatePodWithSecret, podNameWithUser, namespace, framework.ImagePullSecret, framework.TestImage)
+	postJsonOfCreatePodWithSecret = fmt.Sprintf(postJsonOfCreatePodWithSecret, podNameWithUser, tc.NamespaceName, tc.ImagePullSecret, tc.TestImage)
 	urlOfCreatePodWithSecret := "%s/api/v1/cube/proxy/clusters/%s/api/v1/namespaces/%s/pods"
-	urlOfCreatePodWithSecret = fmt.Sprintf(urlOfCreatePodWithSecret, framework.KubecubeHost, clusterName, namespace)
-	respOfCreatePodWithSecret, err := httpHelper.RequestByUser(http.MethodPost, urlOfCreatePodWithSecret, postJsonOfCreatePodWithSecret, user, nil)
-	framework.ExpectNoError(err)
+	urlOfCreatePodWithSecret = fmt.Sprintf(urlOfCreatePodWithSecret, tc.KubecubeHost, tc.TargetClusterName, tc.NamespaceName)
+	respOfCreatePodWithSecret, err := tc.HttpHelper.RequestByUser(http.MethodPost, urlOfCreatePodWithSecret, postJsonOfCreatePodWithSecret, tc.User, nil)
+	tc.ExpectNoError(err)
 	defer respOfCreatePodWithSecret.Body.Close()
 	body, err := io.ReadAll(respOfCreatePodWithSecret.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	clog.Info("create pod resp %+v", string(body))
 
 	if !framework.IsSuccess(respOfCreatePodWithSecret.StatusCode) {
@@ -73,9 +71,9 @@ func createPod(user string) framework.TestResp {
 	}
 
 	checkOfCreatePodWithSecret := &v1.Pod{}
-	err = wait.Poll(framework.WaitInterval, framework.WaitTimeout, func() (done bool, err error) {
-		err = cli.Direct().Get(context.Background(), ctrlclient.ObjectKey{
-			Namespace: namespace,
+	err = wait.Poll(tc.WaitInterval, tc.WaitTimeout, func() (done bool, err error) {
+		err = tc.TargetClusterClient.Direct().Get(context.Background(), ctrlclient.ObjectKey{
+			Namespace: tc.NamespaceName,
 			Name:      podNameWithUser,
 		}, checkOfCreatePodWithSecret)
 		if err != nil {
@@ -87,28 +85,26 @@ func createPod(user string) framework.TestResp {
 		return true, nil
 	})
 
-	framework.ExpectNoError(err, "pod should be created")
+	tc.ExpectNoError(err, "pod should be created")
 
 	return framework.SucceedResp
 }
 
-func deletePod(user string) framework.TestResp {
-	initParam()
-	podNameWithUser := framework.NameWithUser(podName, user)
+func deletePod(tc *framework.TestContext) framework.TestResp {
+	podNameWithUser := tc.NameWithUser(podName)
 	urlOfDeletePodWithSecret := "%s/api/v1/cube/proxy/clusters/%s/api/v1/namespaces/%s/pods/%s"
-	urlOfDeletePodWithSecret = fmt.Sprintf(urlOfDeletePodWithSecret, framework.KubecubeHost, clusterName, namespace, podNameWithUser)
-	_, err := httpHelper.Delete(urlOfDeletePodWithSecret)
-	framework.ExpectNoError(err)
+	urlOfDeletePodWithSecret = fmt.Sprintf(urlOfDeletePodWithSecret, tc.KubecubeHost, tc.TargetClusterName, tc.NamespaceName, podNameWithUser)
+	_, err := tc.HttpHelper.Delete(urlOfDeletePodWithSecret)
+	tc.ExpectNoError(err)
 	return framework.SucceedResp
 }
 
-func deleteSecret(user string) framework.TestResp {
-	initParam()
-	secretNameWithUser := framework.NameWithUser(secretName, user)
+func deleteSecret(tc *framework.TestContext) framework.TestResp {
+	secretNameWithUser := tc.NameWithUser(secretName)
 	urlOfDeleteSecret := "%s/api/v1/cube/proxy/clusters/%s/api/v1/namespaces/%s/secrets/%s"
-	urlOfDeleteSecret = fmt.Sprintf(urlOfDeleteSecret, framework.KubecubeHost, clusterName, namespace, secretNameWithUser)
-	_, err := httpHelper.Delete(urlOfDeleteSecret)
-	framework.ExpectNoError(err)
+	urlOfDeleteSecret = fmt.Sprintf(urlOfDeleteSecret, tc.KubecubeHost, tc.TargetClusterName, tc.NamespaceName, secretNameWithUser)
+	_, err := tc.HttpHelper.Delete(urlOfDeleteSecret)
+	tc.ExpectNoError(err)
 	return framework.SucceedResp
 }
 
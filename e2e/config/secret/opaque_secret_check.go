@@ -31,17 +31,16 @@ import (
 	"github.com/kubecube-io/kubecube-e2e/e2e/framework"
 )
 
-func createOpaqueSecret(user string) framework.TestResp {
-	initParam()
-	secretNameWithUser := framework.NameWithUser(opaqueSecretName, user)
+func createOpaqueSecret(tc *framework.TestContext) framework.TestResp {
+	secretNameWithUser := tc.NameWithUser(opaqueSecretName)
 	postJsonOfCreateSecret := `{"apiVersion":"v1","kind":"Secret","metadata":{"name":"%s","namespace":"%s"},"type":"Opaque","data":{"username":"YWRtaW4=","password":"MTIzNDU2"}}`
-	postJsonOfCreateSecret = fmt.Sprintf(postJsonOfCreateSecret, secretNameWithUser, namespace)
+	postJsonOfCreateSecret = fmt.Sprintf(postJsonOfCreateSecret, secretNameWithUser, tc.NamespaceName)
 	urlOfCreateSecret := "%s/api/v1/cube/proxy/clusters/%s/api/v1/namespaces/%s/secrets"
-	urlOfCreateSecret = fmt.Sprintf(urlOfCreateSecret, framework.KubecubeHost, clusterName, namespace)
-	respOfCreateSecret, err := httpHelper.RequestByUser(http.MethodPost, urlOfCreateSecret, postJsonOfCreateSecret, user, nil)
+	urlOfCreateSecret = fmt.Sprintf(urlOfCreateSecret, tc.KubecubeHost, tc.TargetClusterName, tc.NamespaceName)
+	respOfCreateSecret, err := tc.HttpHelper.RequestByUser(http.MethodPost, urlOfCreateSecret, postJsonOfCreateSecret, tc.User, nil)
 	defer respOfCreateSecret.Body.Close()
 	_, err = io.ReadAll(respOfCreateSecret.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 
 	if !framework.IsSuccess(respOfCreateSecret.StatusCode) {
 		clog.Warn("res code %d", respOfCreateSecret.StatusCode)
@@ -49,26 +48,25 @@ func createOpaqueSecret(user string) framework.TestResp {
 	}
 
 	checkOfCreateSecret := &v1.Secret{}
-	err = cli.Direct().Get(context.Background(), client2.ObjectKey{
-		Namespace: namespace,
+	err = tc.TargetClusterClient.Direct().Get(context.Background(), client2.ObjectKey{
+		Namespace: tc.NamespaceName,
 		Name:      secretNameWithUser,
 	}, checkOfCreateSecret)
-	framework.ExpectNoError(err, "secret should be created")
+	tc.ExpectNoError(err, "secret should be created")
 	return framework.SucceedResp
 }
 
-func createPodWithSecretVolume(user string) framework.TestResp {
-	initParam()
-	podNameWithUser := framework.NameWithUser(opaqueSecretPodName, user)
-	secretNameWithUser := framework.NameWithUser(opaqueSecretName, user)
+func createPodWithSecretVolume(tc *framework.TestContext) framework.TestResp {
+	podNameWithUser := tc.NameWithUser(opaqueSecretPodName)
+	secretNameWithUser := tc.NameWithUser(opaqueSecretName)
 	postJsonOfCreatePodWithSecret := `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"%s","namespace":"%s"},"spec":{"imagePullSecrets": [{"name": "%s"}],"affinity":{"nodeAffinity":{"requiredDuringSchedulingIgnoredDuringExecution":{"nodeSelectorTerms":[{"matchExpressions":[{"key":"node.kubecube.io/tenant","operator":"In","values":["share"]}]}]}}},"containers":[{"name":"mypod","image":"%s","imagePullPolicy": "IfNotPresent","command":[],"resources":{"limits":{"cpu":"100m","memory":"128Mi"},"requests":{"cpu":"100m","memory":"128Mi"}},"volumeMounts":[{"name":"foo","mountPath":"/mnt/secret"}]}],"volumes":[{"name":"foo","secret":{"secretName":"%s","optional":false}}]}}`
-	postJsonOfCreatePodWithSecret = fmt.Sprintf(postJsonOfCreatePodWithSecret, podNameWithUser, namespace, framework.ImagePullSecret, framework.TestImage, secretNameWithUser)
+	postJsonOfCreatePodWithSecret = fmt.Sprintf(postJsonOfCreatePodWithSecret, podNameWithUser, tc.NamespaceName, tc.ImagePullSecret, tc.TestImage, secretNameWithUser)
 	urlOfCreatePodWithSecret := "%s/api/v1/cube/proxy/clusters/%s/api/v1/namespaces/%s/pods"
-	urlOfCreatePodWithSecret = fmt.Sprintf(urlOfCreatePodWithSecret, framework.KubecubeHost, clusterName, namespace)
-	respOfCreatePodWithSecret, err := httpHelper.RequestByUser(http.MethodPost, urlOfCreatePodWithSecret, postJsonOfCreatePodWithSecret, user, nil)
+	urlOfCreatePodWithSecret = fmt.Sprintf(urlOfCreatePodWithSecret, tc.KubecubeHost, tc.TargetClusterName, tc.NamespaceName)
+	respOfCreatePodWithSecret, err := tc.HttpHelper.RequestByUser(http.MethodPost, urlOfCreatePodWithSecret, postJsonOfCreatePodWithSecret, tc.User, nil)
 	defer respOfCreatePodWithSecret.Body.Close()
 	_, err = io.ReadAll(respOfCreatePodWithSecret.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 
 	if !framework.IsSuccess(respOfCreatePodWithSecret.StatusCode) {
 		clog.Warn("res code %d", respOfCreatePodWithSecret.StatusCode)
@@ -76,9 +74,9 @@ func createPodWithSecretVolume(user string) framework.TestResp {
 	}
 
 	checkOfCreatePodWithSecret := &v1.Pod{}
-	err = wait.Poll(framework.WaitInterval, framework.WaitTimeout, func() (done bool, err error) {
-		err = cli.Direct().Get(context.Background(), client2.ObjectKey{
-			Namespace: namespace,
+	err = wait.Poll(tc.WaitInterval, tc.WaitTimeout, func() (done bool, err error) {
+		err = tc.TargetClusterClient.Direct().Get(context.Background(), client2.ObjectKey{
+			Namespace: tc.NamespaceName,
 			Name:      podNameWithUser,
 		}, checkOfCreatePodWithSecret)
 		if err != nil {
@@ -89,22 +87,21 @@ func createPodWithSecretVolume(user string) framework.TestResp {
 		}
 		return true, nil
 	})
-	framework.ExpectNoError(err, "pod should be created")
+	tc.ExpectNoError(err, "pod should be created")
 	return framework.SucceedResp
 }
 
-func createPodWithSecretEnv(user string) framework.TestResp {
-	initParam()
-	podNameWithUser := framework.NameWithUser(opaqueSecretEnvPodName, user)
-	secretNameWithUser := framework.NameWithUser(opaqueSecretName, user)
+func createPodWithSecretEnv(tc *framework.TestContext) framework.TestResp {
+	podNameWithUser := tc.NameWithUser(opaqueSecretEnvPodName)
+	secretNameWithUser := tc.NameWithUser(opaqueSecretName)
 	postJsonOfCreatePodWithSecretENV := `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"%s","namespace":"%s"},"spec":{"imagePullSecrets": [{"name": "%s"}],"affinity":{"nodeAffinity":{"requiredDuringSchedulingIgnoredDuringExecution":{"nodeSelectorTerms":[{"matchExpressions":[{"key":"node.kubecube.io/tenant","operator":"In","values":["share"]}]}]}}},"containers":[{"name":"mypod","image":"%s","env":[{"name":"SECRET_USERNAME","valueFrom":{"secretKeyRef":{"name":"%s","key":"username","optional":false}}},{"name":"SECRET_PASSWORD","valueFrom":{"secretKeyRef":{"name":"%s","key":"password","optional":false}}}],"command":["sleep","2m"],"resources":{"limits":{"cpu":"100m","memory":"128Mi"},"requests":{"cpu":"100m","memory":"128Mi"}}}]}}`
-	postJsonOfCreatePodWithSecretENV = fmt.Sprintf(postJsonOfCreatePodWithSecretENV, podNameWithUser, namespace, framework.ImagePullSecret, framework.TestImage, secretNameWithUser, secretNameWithUser)
+	postJsonOfCreatePodWithSecretENV = fmt.Sprintf(postJsonOfCreatePodWithSecretENV, podNameWithUser, tc.NamespaceName, tc.ImagePullSecret, tc.TestImage, secretNameWithUser, secretNameWithUser)
 	urlOfCreatePodWithSecretENV := "%s/api/v1/cube/proxy/clusters/%s/api/v1/namespaces/%s/pods"
-	urlOfCreatePodWithSecretENV = fmt.Sprintf(urlOfCreatePodWithSecretENV, framework.KubecubeHost, clusterName, namespace)
-	respOfCreatePodWithSecretENV, err := httpHelper.RequestByUser(http.MethodPost, urlOfCreatePodWithSecretENV, postJsonOfCreatePodWithSecretENV, user, nil)
+	urlOfCreatePodWithSecretENV = fmt.Sprintf(urlOfCreatePodWithSecretENV, tc.KubecubeHost, tc.TargetClusterName, tc.NamespaceName)
+	respOfCreatePodWithSecretENV, err := tc.HttpHelper.RequestByUser(http.MethodPost, urlOfCreatePodWithSecretENV, postJsonOfCreatePodWithSecretENV, tc.User, nil)
 	defer respOfCreatePodWithSecretENV.Body.Close()
 	_, err = io.ReadAll(respOfCreatePodWithSecretENV.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 
 	if !framework.IsSuccess(respOfCreatePodWithSecretENV.StatusCode) {
 		clog.Warn("res code %d", respOfCreatePodWithSecretENV.StatusCode)
@@ -112,9 +109,9 @@ func createPodWithSecretEnv(user string) framework.TestResp {
 	}
 
 	checkOfCreatePodWithSecretENV := &v1.Pod{}
-	err = wait.Poll(framework.WaitInterval, framework.WaitTimeout, func() (done bool, err error) {
-		err = cli.Direct().Get(context.Background(), client2.ObjectKey{
-			Namespace: namespace,
+	err = wait.Poll(tc.WaitInterval, tc.WaitTimeout, func() (done bool, err error) {
+		err = tc.TargetClusterClient.Direct().Get(context.Background(), client2.ObjectKey{
+			Namespace: tc.NamespaceName,
 			Name:      podNameWithUser,
 		}, checkOfCreatePodWithSecretENV)
 		if err != nil {
@@ -126,39 +123,36 @@ func createPodWithSecretEnv(user string) framework.TestResp {
 		return true, nil
 	})
 
-	framework.ExpectNoError(err, "pod should be created")
+	tc.ExpectNoError(err, "pod should be created")
 
 	return framework.SucceedResp
 }
 
-func deletePodWithSecretVolume(user string) framework.TestResp {
-	initParam()
-	podNameWithUser := framework.NameWithUser(opaqueSecretEnvPodName, user)
+func deletePodWithSecretVolume(tc *framework.TestContext) framework.TestResp {
+	podNameWithUser := tc.NameWithUser(opaqueSecretEnvPodName)
 	urlOfDeletePodWithSecret := "%s/api/v1/cube/proxy/clusters/%s/api/v1/namespaces/%s/pods/%s"
-	urlOfDeletePodWithSecret = fmt.Sprintf(urlOfDeletePodWithSecret, framework.KubecubeHost, clusterName, namespace, podNameWithUser)
-	_, err := httpHelper.Delete(urlOfDeletePodWithSecret)
-	framework.ExpectNoError(err)
+	urlOfDeletePodWithSecret = fmt.Sprintf(urlOfDeletePodWithSecret, tc.KubecubeHost, tc.TargetClusterName, tc.NamespaceName, podNameWithUser)
+	_, err := tc.HttpHelper.Delete(urlOfDeletePodWithSecret)
+	tc.ExpectNoError(err)
 	return framework.SucceedResp
 }
 
-func deletePodWithSecretEnv(user string) framework.TestResp {
-	initParam()
-	podNameWithUser := framework.NameWithUser(opaqueSecretEnvPodName, user)
+func deletePodWithSecretEnv(tc *framework.TestContext) framework.TestResp {
+	podNameWithUser := tc.NameWithUser(opaqueSecretEnvPodName)
 	urlOfDeletePodWithSecretENV := "%s/api/v1/cube/proxy/clusters/%s/api/v1/namespaces/%s/pods/%s"
-	urlOfDeletePodWithSecretENV = fmt.Sprintf(urlOfDeletePodWithSecretENV, framework.KubecubeHost, clusterName, namespace, podNameWithUser)
-	_, err := httpHelper.Delete(urlOfDeletePodWithSecretENV)
-	framework.ExpectNoError(err)
+	urlOfDeletePodWithSecretENV = fmt.Sprintf(urlOfDeletePodWithSecretENV, tc.KubecubeHost, tc.TargetClusterName, tc.NamespaceName, podNameWithUser)
+	_, err := tc.HttpHelper.Delete(urlOfDeletePodWithSecretENV)
+	tc.ExpectNoError(err)
 
 	return framework.SucceedResp
 }
 
-func deleteOpaqueSecret(user string) framework.TestResp {
-	initParam()
-	secretNameWithUser := framework.NameWithUser(opaqueSecretName, user)
+func deleteOpaqueSecret(tc *framework.TestContext) framework.TestResp {
+	secretNameWithUser := tc.NameWithUser(opaqueSecretName)
 	urlOfDeleteSecret := "%s/api/v1/cube/proxy/clusters/%s/api/v1/namespaces/%s/secrets/%s"
-	urlOfDeleteSecret = fmt.Sprintf(urlOfDeleteSecret, framework.KubecubeHost, clusterName, namespace, secretNameWithUser)
-	_, err := httpHelper.Delete(urlOfDeleteSecret)
-	framework.ExpectNoError(err)
+	urlOfDeleteSecret = fmt.Sprintf(urlOfDeleteSecret, tc.KubecubeHost, tc.TargetClusterName, tc.NamespaceName, secretNameWithUser)
+	_, err := tc.HttpHelper.Delete(urlOfDeleteSecret)
+	tc.ExpectNoError(err)
 	return framework.SucceedResp
 }
 
@@ -18,15 +18,9 @@ package secret
 
 import (
 	"github.com/kubecube-io/kubecube-e2e/e2e/framework"
-	"github.com/kubecube-io/kubecube/pkg/multicluster/client"
 )
 
-var (
-	clusterName string
-	httpHelper  *framework.HttpHelper
-	namespace   string
-	cli         client.Client
-
+const (
 	secretName             = "e2e-test-docker-secret"
 	podName                = "e2e-pod-docker-secret-test"
 	opaqueSecretName       = "e2e-test-opaque-secret"
@@ -34,13 +28,6 @@ var (
 	opaqueSecretEnvPodName = "e2e-pod-secret-env-test"
 )
 
-func initParam() {
-	clusterName = framework.TargetClusterName
-	httpHelper = framework.NewSingleHttpHelper()
-	namespace = framework.NamespaceName
-	cli = framework.TargetClusterClient
-}
-
 func init() {
 	framework.RegisterByDefault(multiUserOpaqueSecretTest)
 	framework.RegisterByDefault(multiUserDockerConfigJsonSecretTest)
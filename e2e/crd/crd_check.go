@@ -26,7 +26,6 @@ import (
 
 	"github.com/kubecube-io/kubecube-e2e/e2e/framework"
 	"github.com/kubecube-io/kubecube/pkg/clog"
-	"github.com/kubecube-io/kubecube/pkg/multicluster/client"
 	v1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -35,38 +34,26 @@ import (
 	client2 "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-var (
-	clusterName string
-	httpHelper  *framework.HttpHelper
-	namespace   string
-	cli         client.Client
-
-	checkOfCreateCR *unstructured.Unstructured
-
+const (
 	crd      = "crontabs.stable.%s.com"
 	crdGroup = "stable.%s.com"
 	cr       = "crd-nginx"
-)
 
-func initParam() {
-	clusterName = framework.TargetClusterName
-	httpHelper = framework.NewSingleHttpHelper()
-	namespace = framework.NamespaceName
-	cli = framework.TargetClusterClient
-}
+	// checkOfCreateCRKey 保存创建成功的 CR，供更新步骤使用
+	checkOfCreateCRKey = "checkOfCreateCR"
+)
 
-func createCRD(user string) framework.TestResp {
-	initParam()
-	crdWithUser := fmt.Sprintf(crd, user)
-	crdGroupWithUser := fmt.Sprintf(crdGroup, user)
+func createCRD(tc *framework.TestContext) framework.TestResp {
+	crdWithUser := fmt.Sprintf(crd, tc.User)
+	crdGroupWithUser := fmt.Sprintf(crdGroup, tc.User)
 	postJsonOfCRD := `{"apiVersion":"apiextensions.k8s.io/v1","kind":"CustomResourceDefinition","metadata":{"name":"%s"},"spec":{"group":"%s","versions":[{"name":"v1","served":true,"storage":true,"schema":{"openAPIV3Schema":{"type":"object","properties":{"spec":{"type":"object","properties":{"cronSpec":{"type":"string"},"image":{"type":"string"},"replicas":{"type":"integer"}}}}}}}],"scope":"Namespaced","names":{"plural":"crontabs","singular":"crontab","kind":"CronTab","shortNames":["ct"]}}}`
 	postJsonOfCRD = fmt.Sprintf(postJsonOfCRD, crdWithUser, crdGroupWithUser)
 	urlOfCreateCRD := "%s/api/v1/cube/proxy/clusters/%s/apis/apiextensions.k8s.io/v1/customresourcedefinitions"
-	urlOfCreateCRD = fmt.Sprintf(urlOfCreateCRD, framework.KubecubeHost, clusterName)
-	respOfCreateCRD, err := httpHelper.RequestByUser(http.MethodPost, urlOfCreateCRD, postJsonOfCRD, user, nil)
+	urlOfCreateCRD = fmt.Sprintf(urlOfCreateCRD, tc.KubecubeHost, tc.TargetClusterName)
+	respOfCreateCRD, err := tc.HttpHelper.RequestByUser(http.MethodPost, urlOfCreateCRD, postJsonOfCRD, tc.User, nil)
 	defer respOfCreateCRD.Body.Close()
 	body, err := io.ReadAll(respOfCreateCRD.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 
 	if !framework.IsSuccess(respOfCreateCRD.StatusCode) && http.StatusConflict != respOfCreateCRD.StatusCode {
 		clog.Warn("res code %d, res data: %s", respOfCreateCRD.StatusCode, string(body))
@@ -74,62 +61,63 @@ func createCRD(user string) framework.TestResp {
 	}
 
 	checkOfCreateCRD := &v1.CustomResourceDefinition{}
-	err = cli.Direct().Get(context.Background(), client2.ObjectKey{
-		Namespace: namespace,
+	err = tc.TargetClusterClient.Direct().Get(context.Background(), client2.ObjectKey{
+		Namespace: tc.NamespaceName,
 		Name:      crdWithUser,
 	}, checkOfCreateCRD)
-	framework.ExpectNoError(err, "new CRD should be retrieved")
+	tc.ExpectNoError(err, "new CRD should be retrieved")
 	return framework.SucceedResp
 }
 
-func createCR(user string) framework.TestResp {
-	initParam()
-	crdGroupWithUser := fmt.Sprintf(crdGroup, user)
-	crWithUser := framework.NameWithUser(cr, user)
-	checkOfCreateCR = &unstructured.Unstructured{}
+func createCR(tc *framework.TestContext) framework.TestResp {
+	crdGroupWithUser := fmt.Sprintf(crdGroup, tc.User)
+	crWithUser := tc.NameWithUser(cr)
+	checkOfCreateCR := &unstructured.Unstructured{}
 	checkOfCreateCR.SetGroupVersionKind(schema.GroupVersionKind{
 		Group:   crdGroupWithUser,
 		Version: "v1",
 		Kind:    "CronTab",
 	})
-	checkOfCreateCR.SetNamespace(namespace)
+	checkOfCreateCR.SetNamespace(tc.NamespaceName)
 	checkOfCreateCR.SetName(crWithUser)
-	_ = cli.Direct().Delete(context.Background(), checkOfCreateCR)
+	_ = tc.TargetClusterClient.Direct().Delete(context.Background(), checkOfCreateCR)
 	postJsonOfCreateCR := `{"apiVersion":"%s/v1","kind":"CronTab","metadata":{"labels":{"system/project-project1":"true","system/tenant":"tenant1"},"name":"%s","namespace":"%s"},"spec":{"image":"%s","cronSpec":"*****/5"}}`
-	postJsonOfCreateCR = fmt.Sprintf(postJsonOfCreateCR, crdGroupWithUser, crWithUser, namespace, framework.TestImage)
+	postJsonOfCreateCR = fmt.Sprintf(postJsonOfCreateCR, crdGroupWithUser, crWithUser, tc.NamespaceName, tc.TestImage)
 	urlOfCreateCR := "%s/api/v1/cube/proxy/clusters/%s/apis/%s/v1/namespaces/%s/crontabs"
-	urlOfCreateCR = fmt.Sprintf(urlOfCreateCR, framework.KubecubeHost, clusterName, crdGroupWithUser, namespace)
-	respOfCreateCR, err := httpHelper.RequestByUser(http.MethodPost, urlOfCreateCR, postJsonOfCreateCR, user, nil)
+	urlOfCreateCR = fmt.Sprintf(urlOfCreateCR, tc.KubecubeHost, tc.TargetClusterName, crdGroupWithUser, tc.NamespaceName)
+	respOfCreateCR, err := tc.HttpHelper.RequestByUser(http.MethodPost, urlOfCreateCR, postJsonOfCreateCR, tc.User, nil)
 	defer respOfCreateCR.Body.Close()
 	_, err = io.ReadAll(respOfCreateCR.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	if !framework.IsSuccess(respOfCreateCR.StatusCode) {
 		clog.Warn("res code %d", respOfCreateCR.StatusCode)
 		return framework.NewTestResp(errors.New("fail to create cr"), respOfCreateCR.StatusCode)
 	}
-	err = cli.Direct().Get(context.Background(), client2.ObjectKey{
-		Namespace: namespace,
+	err = tc.TargetClusterClient.Direct().Get(context.Background(), client2.ObjectKey{
+		Namespace: tc.NamespaceName,
 		Name:      crWithUser,
 	}, checkOfCreateCR)
-	framework.ExpectNoError(err, "new CR should be retrieved")
-	framework.ExpectEqual(checkOfCreateCR.Object["spec"].(map[string]interface{})["image"], framework.TestImage, "image should be same")
+	tc.ExpectNoError(err, "new CR should be retrieved")
+	tc.ExpectEqual(checkOfCreateCR.Object["spec"].(map[string]interface{})["image"], tc.TestImage, "image should be same")
+	tc.SetValue(checkOfCreateCRKey, checkOfCreateCR)
 	return framework.SucceedResp
 }
 
-func updateCR(user string) framework.TestResp {
-	initParam()
-	crdGroupWithUser := fmt.Sprintf(crdGroup, user)
-	crWithUser := framework.NameWithUser(cr, user)
-	imageNew := framework.NameWithUser(framework.TestImage, user)
+func updateCR(tc *framework.TestContext) framework.TestResp {
+	crdGroupWithUser := fmt.Sprintf(crdGroup, tc.User)
+	crWithUser := tc.NameWithUser(cr)
+	imageNew := tc.NameWithUser(tc.TestImage)
+	checkOfCreateCR, ok := tc.Value(checkOfCreateCRKey).(*unstructured.Unstructured)
+	tc.ExpectEqual(ok, true, "cr should be created before update")
 	checkOfCreateCR.Object["spec"].(map[string]interface{})["image"] = imageNew
 
 	postJsonOfUpdateCR, _ := json.Marshal(checkOfCreateCR.Object)
 	urlOfUpdateCR := "%s/api/v1/cube/proxy/clusters/%s/apis/%s/v1/namespaces/%s/crontabs/%s"
-	urlOfUpdateCR = fmt.Sprintf(urlOfUpdateCR, framework.KubecubeHost, clusterName, crdGroupWithUser, namespace, crWithUser)
-	respOfUpdateCR, err := httpHelper.RequestByUser(http.MethodPut, urlOfUpdateCR, string(postJsonOfUpdateCR), user, nil)
+	urlOfUpdateCR = fmt.Sprintf(urlOfUpdateCR, tc.KubecubeHost, tc.TargetClusterName, crdGroupWithUser, tc.NamespaceName, crWithUser)
+	respOfUpdateCR, err := tc.HttpHelper.RequestByUser(http.MethodPut, urlOfUpdateCR, string(postJsonOfUpdateCR), tc.User, nil)
 	defer respOfUpdateCR.Body.Close()
 	_, err = io.ReadAll(respOfUpdateCR.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 
 	if !framework.IsSuccess(respOfUpdateCR.StatusCode) {
 		clog.Warn("res code %d", respOfUpdateCR.StatusCode)
@@ -142,25 +130,24 @@ func updateCR(user string) framework.TestResp {
 		Version: "v1",
 		Kind:    "CronTab",
 	})
-	err = cli.Direct().Get(context.Background(), client2.ObjectKey{
-		Namespace: namespace,
+	err = tc.TargetClusterClient.Direct().Get(context.Background(), client2.ObjectKey{
+		Namespace: tc.NamespaceName,
 		Name:      crWithUser,
 	}, checkOfUpdateCR)
-	framework.ExpectNoError(err, "new CR should be retrieved")
-	framework.ExpectEqual(checkOfUpdateCR.Object["spec"].(map[string]interface{})["image"], imageNew, "image should be same")
+	tc.ExpectNoError(err, "new CR should be retrieved")
+	tc.ExpectEqual(checkOfUpdateCR.Object["spec"].(map[string]interface{})["image"], imageNew, "image should be same")
 	return framework.SucceedResp
 }
 
-func deleteCR(user string) framework.TestResp {
-	initParam()
-	crdGroupWithUser := fmt.Sprintf(crdGroup, user)
-	crWithUser := framework.NameWithUser(cr, user)
+func deleteCR(tc *framework.TestContext) framework.TestResp {
+	crdGroupWithUser := fmt.Sprintf(crdGroup, tc.User)
+	crWithUser := tc.NameWithUser(cr)
 	urlOfDeleteCR := "%s/api/v1/cube/proxy/clusters/%s/apis/%s/v1/namespaces/%s/crontabs/%s"
-	urlOfDeleteCR = fmt.Sprintf(urlOfDeleteCR, framework.KubecubeHost, clusterName, crdGroupWithUser, namespace, crWithUser)
-	respOfDeleteCR, err := httpHelper.RequestByUser(http.MethodDelete, urlOfDeleteCR, "", user, nil)
+	urlOfDeleteCR = fmt.Sprintf(urlOfDeleteCR, tc.KubecubeHost, tc.TargetClusterName, crdGroupWithUser, tc.NamespaceName, crWithUser)
+	respOfDeleteCR, err := tc.HttpHelper.RequestByUser(http.MethodDelete, urlOfDeleteCR, "", tc.User, nil)
 	defer respOfDeleteCR.Body.Close()
 	_, err = io.ReadAll(respOfDeleteCR.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 
 	if !framework.IsSuccess(respOfDeleteCR.StatusCode) {
 		clog.Warn("res code %d", respOfDeleteCR.StatusCode)
@@ -173,23 +160,22 @@ func deleteCR(user string) framework.TestResp {
 		Version: "v1",
 		Kind:    "CronTab",
 	})
-	err = cli.Direct().Get(context.Background(), client2.ObjectKey{
-		Namespace: namespace,
+	err = tc.TargetClusterClient.Direct().Get(context.Background(), client2.ObjectKey{
+		Namespace: tc.NamespaceName,
 		Name:      crWithUser,
 	}, checkOfDeleteCR)
-	framework.ExpectEqual(kerrors.IsNotFound(err), true, "CR should be deleted")
+	tc.ExpectEqual(kerrors.IsNotFound(err), true, "CR should be deleted")
 	return framework.SucceedResp
 }
 
-func deleteCRD(user string) framework.TestResp {
-	initParam()
-	crdWithUser := fmt.Sprintf(crd, user)
+func deleteCRD(tc *framework.TestContext) framework.TestResp {
+	crdWithUser := fmt.Sprintf(crd, tc.User)
 	urlOfDeleteCRD := "%s/api/v1/cube/proxy/clusters/%s/apis/apiextensions.k8s.io/v1/customresourcedefinitions/%s"
-	urlOfDeleteCRD = fmt.Sprintf(urlOfDeleteCRD, framework.KubecubeHost, clusterName, crdWithUser)
-	respOfDeleteCRD, err := httpHelper.RequestByUser(http.MethodDelete, urlOfDeleteCRD, "", user, nil)
+	urlOfDeleteCRD = fmt.Sprintf(urlOfDeleteCRD, tc.KubecubeHost, tc.TargetClusterName, crdWithUser)
+	respOfDeleteCRD, err := tc.HttpHelper.RequestByUser(http.MethodDelete, urlOfDeleteCRD, "", tc.User, nil)
 	defer respOfDeleteCRD.Body.Close()
 	_, err = io.ReadAll(respOfDeleteCRD.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 
 	if !framework.IsSuccess(respOfDeleteCRD.StatusCode) {
 		clog.Warn("res code %d", respOfDeleteCRD.StatusCode)
@@ -197,9 +183,9 @@ func deleteCRD(user string) framework.TestResp {
 	}
 
 	checkOfDeleteCRD := &v1.CustomResourceDefinition{}
-	err = wait.Poll(framework.WaitInterval, framework.WaitTimeout, func() (done bool, err error) {
-		err = cli.Direct().Get(context.Background(), client2.ObjectKey{
-			Namespace: namespace,
+	err = wait.Poll(tc.WaitInterval, tc.WaitTimeout, func() (done bool, err error) {
+		err = tc.TargetClusterClient.Direct().Get(context.Background(), client2.ObjectKey{
+			Namespace: tc.NamespaceName,
 			Name:      crdWithUser,
 		}, checkOfDeleteCRD)
 		if !kerrors.IsNotFound(err) {
@@ -207,7 +193,7 @@ func deleteCRD(user string) framework.TestResp {
 		}
 		return true, nil
 	})
-	framework.ExpectNoError(err, "CRD should be deleted")
+	tc.ExpectNoError(err, "CRD should be deleted")
 	return framework.SucceedResp
 }
 
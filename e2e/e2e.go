@@ -18,28 +18,21 @@ package e2e
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"os"
-	"strings"
 	"testing"
-	"time"
 
 	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
 	"github.com/kubecube-io/kubecube/pkg/clients"
 	"github.com/kubecube-io/kubecube/pkg/clog"
 	"github.com/kubecube-io/kubecube/pkg/utils/constants"
-	"github.com/kubecube-io/kubecube/pkg/utils/md5util"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	v1 "k8s.io/api/core/v1"
-	rbacv1 "k8s.io/api/rbac/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
-	"k8s.io/apimachinery/pkg/util/wait"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
@@ -56,311 +49,148 @@ var (
 
 var isMaster bool
 
-func RunE2ETests(t *testing.T) {
+func RunE2ETests(t *testing.T, tc *framework.TestContext, reporters ...Reporter) {
 	RegisterFailHandler(Fail)
 
-	RunSpecs(t, "E2e Suite")
+	framework.CreateTestExamples(tc)
+	RunSpecsWithDefaultAndCustomReporters(t, "E2e Suite", reporters)
 }
 
-// InitAll 初始化参数
-func InitAll() error {
-	// Read config and init global v
-	err := framework.InitGlobalV()
+// InitAll 初始化参数，返回本次运行的测试上下文
+func InitAll() (*framework.TestContext, error) {
+	// Read config and init test context
+	tc, err := framework.InitGlobalV()
 	if err != nil {
 		clog.Info(err.Error())
-		return err
+		return nil, err
 	}
 
 	err = framework.InitMultiConfig()
 	if err != nil {
 		clog.Info(err.Error())
-		return err
+		return nil, err
 	}
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
-	return nil
+	return tc, nil
 }
 
-// Start 执行 e2e 测试的前置步骤
-func Start() error {
-	if !isMaster {
-		return waitUntilResourceInited()
-	}
-
-	clearResources()
-	err := initializeResources()
-	if err != nil {
-		markAllResourceInitFailed()
+// Start 执行 e2e 测试的前置步骤，集群预检查失败时不再初始化资源
+func Start(tc *framework.TestContext) error {
+	if err := tc.RunPreflight(); err != nil {
 		return err
 	}
 
-	markAllResourceInited()
-	return nil
-}
-
-// End 清理测试数据
-func End() error {
 	if !isMaster {
-		markAllTestInThisWorkerFinished()
-		return nil
-	} else {
-		waitUntilTestsInAllWorkersFinished()
-	}
-
-	err := clearResources()
-	if err != nil {
-		return err
-	}
-
-	markAllResourceCleared()
-	return nil
-}
-
-func Clear() error {
-	// init client-go client
-	clients.InitCubeClientSetWithOpts(nil)
-
-	err := loadConfigFromCm()
-	if err != nil {
-		clog.Error(err.Error())
-		return err
+		err := waitUntilResourceInited(tc)
+		if err != nil {
+			return err
+		}
+		return login(tc)
 	}
 
-	// Read config and init global v
-	err = framework.InitGlobalV()
+	err := markResourceIniting(tc)
 	if err != nil {
-		clog.Error(err.Error())
 		return err
 	}
-	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
-
-	err = clearResources()
+	clearResources(tc)
+	err = initializeResources(tc)
 	if err != nil {
+		markAllResourceInitFailed(tc)
 		return err
 	}
 
-	return clearTempResources()
+	markAllResourceInited(tc)
+	return login(tc)
 }
 
-func deleteUserInKubecube(ctx context.Context, cli client.Client, namespace string, username string) error {
-	// 解除公共权限绑定
-	clusterRoleBinding := rbacv1.ClusterRoleBinding{}
-	clusterRoleBinding.Name = framework.TenantAdmin + "-in-cluster"
-	clog.Info("[clusterRoleBinding] delete clusterRoleBinding %v", clusterRoleBinding.Name)
-	err := cli.Delete(ctx, &clusterRoleBinding)
-	if err != nil && !kerrors.IsNotFound(err) {
-		clog.Info("delete clusterRoleBinding fail, %v", err)
-		return err
-	}
-
-	// 解除专用权限绑定
-	roleBinding := rbacv1.RoleBinding{}
-	roleBinding.Name = framework.TenantAdmin + "-in-" + namespace
-	roleBinding.Namespace = namespace
-	clog.Info("[roleBinding] delete roleBinding %v, namespace %v", roleBinding.Name, roleBinding.Namespace)
-	err = cli.Delete(ctx, &roleBinding)
-	if err != nil && !kerrors.IsNotFound(err) {
-		clog.Info("delete roleBinding fail, %v", err)
-		return err
-	}
-
-	// 删除用户
-	user := userv1.User{}
-	user.Name = username
-	err = cli.Delete(ctx, &user)
-	if err != nil && !kerrors.IsNotFound(err) {
-		clog.Info("delete user fail, %v", err)
-		return err
+// login 登录所有测试用户，登录失败时不再执行测试
+func login(tc *framework.TestContext) error {
+	err := tc.Login()
+	if err != nil {
+		return fmt.Errorf("preflight login failed: %v", err)
 	}
 	return nil
 }
 
-func waitUntilResourceInited() error {
-	cm := &v1.ConfigMap{}
-	err := wait.Poll(framework.WaitInterval, time.Minute*10, func() (done bool, err error) {
-		err = framework.PivotClusterClient.Direct().Get(context.Background(), types.NamespacedName{
-			Namespace: framework.KubeCubeSystem,
-			Name:      framework.KubeCubeE2ECM,
-		}, cm)
+// End 输出测试报告并清理测试数据，exitCode 为本进程测试的退出码。
+// worker 将结果发布给 master，master 等待所有 worker 结束后汇总结果，任一 worker 失败时返回错误
+func End(tc *framework.TestContext, exitCode int) error {
+	for _, s := range tc.HttpHelper.SessionStates() {
+		clog.Info("session of %s(%s): logged in %v, relogins %d, last error %q", s.Role, s.Username, s.LoggedIn, s.Relogins, s.LastError)
+	}
+	// 删除被中断或未执行完的测试创建的对象
+	tc.Tracker.CleanupAll()
+	if !isMaster {
+		err := publishWorkerResult(tc, exitCode)
 		if err != nil {
-			clog.Error("fail to test resource by cm due to %s", err.Error())
-			return false, err
-		}
-
-		if cm.Labels[framework.ResourceFailed] == "true" {
-			clog.Warn("resource failed")
-			return false, errors.New("resourceFailed")
+			clog.Error("fail to publish worker result due to %s", err.Error())
+			markWorkerFailed(tc, fmt.Errorf("failed to publish result: %v", err))
+		} else {
+			markAllTestInThisWorkerFinished(tc)
 		}
+		writeReports(tc)
+		return err
+	}
 
-		if cm.Labels[framework.ResourceReady] == "true" {
-			clog.Info("resource ready")
-			return true, nil
-		}
+	workers, dead := waitUntilTestsInAllWorkersFinished(tc)
+	workersErr := collectWorkerResults(tc, workers, dead)
+	writeReports(tc)
 
-		clog.Info("resource not ready, waiting")
-		return false, nil
-	})
+	err := clearResources(tc)
 	if err != nil {
 		return err
 	}
 
-	cm = &v1.ConfigMap{
-		TypeMeta: metav1.TypeMeta{
-			Kind:       "ConfigMap",
-			APIVersion: "v1",
-		},
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      getCmName(),
-			Namespace: framework.KubeCubeSystem,
-			Labels: map[string]string{
-				framework.KubeCubeE2ECM: framework.KubeCubeE2ECM,
-			},
-		},
-	}
-	err = framework.PivotClusterClient.Direct().Delete(context.Background(), cm)
-	if err != nil && !kerrors.IsNotFound(err) {
-		clog.Error("fail to clear cm with kubecube-e2e-config label when worker is going to test due to %s", err.Error())
-		return err
-	}
-	err = framework.PivotClusterClient.Direct().Create(context.Background(), cm)
+	err = clearKeys(tc)
 	if err != nil {
-		clog.Error("fail to create cm with kubecube-e2e-config label when worker is going to test due to %s", err.Error())
 		return err
 	}
 
-	clog.Info("resource initialized, worker is going to test")
-
-	return nil
+	markAllResourceCleared(tc)
+	return workersErr
 }
 
-func markAllResourceInited() {
-	cm := &v1.ConfigMap{}
-	err := framework.PivotClusterClient.Direct().Get(context.Background(), types.NamespacedName{
-		Namespace: framework.KubeCubeSystem,
-		Name:      framework.KubeCubeE2ECM,
-	}, cm)
-	if err != nil {
-		clog.Error("fail to get kubecube-e2e-config label when markAllResourceInited due to %s", err.Error())
-		return
-	}
+func Clear() error {
+	// init client-go client
+	clients.InitCubeClientSetWithOpts(nil)
 
-	if len(cm.Labels) == 0 {
-		cm.Labels = make(map[string]string)
-	}
-	cm.Labels[framework.ResourceReady] = "true"
-	cm.Labels[framework.ResourceFailed] = "false"
-	err = framework.PivotClusterClient.Direct().Update(context.Background(), cm)
+	err := loadConfigFromCm()
 	if err != nil {
-		clog.Error("fail to update kubecube-e2e-config label when markAllResourceInited due to %s", err.Error())
-		return
+		clog.Error(err.Error())
+		return err
 	}
 
-	clog.Info("master initialized all resources, master is going to test")
-}
-
-func markAllResourceInitFailed() {
-	cm := &v1.ConfigMap{}
-	err := framework.PivotClusterClient.Direct().Get(context.Background(), types.NamespacedName{
-		Namespace: framework.KubeCubeSystem,
-		Name:      framework.KubeCubeE2ECM,
-	}, cm)
+	// Read config and init test context
+	tc, err := framework.InitGlobalV()
 	if err != nil {
-		clog.Error("fail to get kubecube-e2e-config label when markAllResourceInitFailed due to %s", err.Error())
-		return
+		clog.Error(err.Error())
+		return err
 	}
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
-	if len(cm.Labels) == 0 {
-		cm.Labels = make(map[string]string)
-	}
-	cm.Labels[framework.ResourceFailed] = "true"
-	err = framework.PivotClusterClient.Direct().Update(context.Background(), cm)
+	err = clearResources(tc)
 	if err != nil {
-		clog.Error("fail to update kubecube-e2e-config label when markAllResourceInitFailed due to %s", err.Error())
-		return
+		return err
 	}
-}
-
-func waitUntilTestsInAllWorkersFinished() {
-	cmList := &v1.ConfigMapList{}
-	err := wait.Poll(framework.WaitInterval, time.Minute*20, func() (done bool, err error) {
-		err = framework.PivotClusterClient.Direct().List(context.Background(), cmList,
-			client.InNamespace(framework.KubeCubeSystem),
-			client.MatchingLabels{
-				framework.KubeCubeE2ECM: framework.KubeCubeE2ECM,
-			})
-
-		if err != nil {
-			clog.Warn("fail to watch cm list by labels due to %s", err.Error())
-			return false, nil
-		}
-
-		if len(cmList.Items) == 0 {
-			clog.Info("cm list with labels is empty")
-			return true, nil
-		}
 
-		clog.Info("%d workers running, waiting", len(cmList.Items))
-		return false, nil
-	})
+	err = clearKeys(tc)
 	if err != nil {
-		clog.Error("error when waitUntilTestsInAllWorkersFinished, %s", err.Error())
+		return err
 	}
 
-	clog.Info("all tests of workers finished, master is going to clean resources")
+	return clearTempResources()
 }
 
-func markAllTestInThisWorkerFinished() {
-	cm := &v1.ConfigMap{
-		TypeMeta: metav1.TypeMeta{
-			Kind:       "ConfigMap",
-			APIVersion: "v1",
-		},
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      getCmName(),
-			Namespace: framework.KubeCubeSystem,
-			Labels: map[string]string{
-				framework.KubeCubeE2ECM: framework.KubeCubeE2ECM,
-			},
-		},
-	}
-
-	err := framework.PivotClusterClient.Direct().Delete(context.Background(), cm)
+// clearKeys 删除 e2e 为测试用户创建的 Key，需在所有 worker 结束后执行
+func clearKeys(tc *framework.TestContext) error {
+	clog.Info("[After] delete e2e keys")
+	err := tc.PivotClusterClient.Direct().DeleteAllOf(context.Background(), &userv1.Key{}, client.MatchingLabels{framework.E2EKeyLabel: "true"})
 	if err != nil && !kerrors.IsNotFound(err) {
-		clog.Error("fail to delete cm with label when markAllTestInThisWorkerFinished")
-		return
-	}
-
-	clog.Info("worker finished test and marked")
-}
-
-func markAllResourceCleared() {
-	cm := &v1.ConfigMap{}
-	err := framework.PivotClusterClient.Direct().Get(context.Background(), types.NamespacedName{
-		Namespace: framework.KubeCubeSystem,
-		Name:      framework.KubeCubeE2ECM,
-	}, cm)
-	if err != nil {
-		clog.Error("fail to get kubecube-e2e-config label when markAllResourceCleared due to %s", err.Error())
-		return
-	}
-
-	if len(cm.Labels) == 0 {
-		cm.Labels = make(map[string]string)
-	}
-	cm.Labels[framework.ResourceReady] = "false"
-	cm.Labels[framework.ResourceFailed] = "false"
-	err = framework.PivotClusterClient.Direct().Update(context.Background(), cm)
-	if err != nil {
-		clog.Error("fail to update kubecube-e2e-config label when markAllResourceCleared due to %s", err.Error())
-		return
+		clog.Info("[After] delete e2e keys fail, %v", err)
+		return err
 	}
-
-	clog.Info("master cleared all resources")
-}
-
-func getCmName() string {
-	s := framework.KubeCubeE2ECM + "-" + strings.Join(framework.TestUser, "-")
-	return strings.ToLower(s)
+	return nil
 }
 
 func loadConfigFromCm() error {
@@ -429,102 +259,3 @@ func clearTempResources() error {
 	clog.Info("worker cm deleted")
 	return nil
 }
-
-func createUser(accountId string, accountPassword string) error {
-	user := &userv1.User{}
-	err := framework.PivotClusterClient.Direct().Get(context.Background(), types.NamespacedName{Name: accountId}, user)
-	if err != nil {
-		if kerrors.IsNotFound(err) {
-			user.Name = accountId
-			user.Spec.Password = md5util.GetMD5Salt(accountPassword)
-			user.Spec.LoginType = userv1.NormalLogin
-			user.Spec.State = userv1.NormalState
-			annotations := make(map[string]string)
-			annotations["kubecube.io/sync"] = "true"
-			user.Annotations = annotations
-			user.Spec.DisplayName = accountId
-			err = framework.PivotClusterClient.Direct().Create(context.Background(), user)
-			return err
-		} else {
-			return err
-		}
-	}
-	return nil
-}
-
-func createTenantAdminRoleBindings(username string, tenant string) error {
-	ctx := context.Background()
-	rolebindng := &rbacv1.RoleBinding{}
-	rolebindng.Name = username + "-in-kubecube-tenant-" + tenant
-	rolebindng.Namespace = "kubecube-tenant-" + tenant
-	clusterrole := rbacv1.RoleRef{
-		Kind: "ClusterRole",
-		Name: constants.TenantAdmin,
-	}
-	user := rbacv1.Subject{
-		Kind: "User",
-		Name: username,
-	}
-	rolebindng.Subjects = []rbacv1.Subject{user}
-	rolebindng.RoleRef = clusterrole
-	annotations := make(map[string]string)
-	annotations[constants.SyncAnnotation] = "true"
-	rolebindng.Annotations = annotations
-	labels := make(map[string]string)
-	labels[constants.RbacLabel] = "true"
-	labels[constants.TenantLabel] = tenant
-	rolebindng.Labels = labels
-	return framework.PivotClusterClient.Direct().Create(ctx, rolebindng)
-}
-
-func createProjectAdminRoleBindings(username string, tenant string, project string) error {
-	ctx := context.Background()
-	rolebindng := &rbacv1.RoleBinding{}
-	rolebindng.Name = username + "-in-kubecube-project-" + project
-	rolebindng.Namespace = "kubecube-project-" + project
-	clusterrole := rbacv1.RoleRef{
-		Kind: "ClusterRole",
-		Name: constants.ProjectAdmin,
-	}
-	user := rbacv1.Subject{
-		Kind: "User",
-		Name: username,
-	}
-	rolebindng.Subjects = []rbacv1.Subject{user}
-	rolebindng.RoleRef = clusterrole
-	annotations := make(map[string]string)
-	annotations[constants.SyncAnnotation] = "true"
-	rolebindng.Annotations = annotations
-	labels := make(map[string]string)
-	labels[constants.RbacLabel] = "true"
-	labels[constants.TenantLabel] = tenant
-	labels[constants.ProjectLabel] = project
-	rolebindng.Labels = labels
-	return framework.PivotClusterClient.Direct().Create(ctx, rolebindng)
-}
-
-func createProjectViewerRoleBindings(username string, tenant string, project string) error {
-	ctx := context.Background()
-	rolebindng := &rbacv1.RoleBinding{}
-	rolebindng.Name = username + "-in-kubecube-project-" + project
-	rolebindng.Namespace = "kubecube-project-" + project
-	clusterrole := rbacv1.RoleRef{
-		Kind: "ClusterRole",
-		Name: constants.Reviewer,
-	}
-	user := rbacv1.Subject{
-		Kind: "User",
-		Name: username,
-	}
-	rolebindng.Subjects = []rbacv1.Subject{user}
-	rolebindng.RoleRef = clusterrole
-	annotations := make(map[string]string)
-	annotations[constants.SyncAnnotation] = "true"
-	rolebindng.Annotations = annotations
-	labels := make(map[string]string)
-	labels[constants.RbacLabel] = "true"
-	labels[constants.TenantLabel] = tenant
-	labels[constants.ProjectLabel] = project
-	rolebindng.Labels = labels
-	return framework.PivotClusterClient.Direct().Create(ctx, rolebindng)
-}
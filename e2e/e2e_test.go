@@ -47,6 +47,14 @@ var (
 	runUsingDefault = flag.Bool("runDefault", false, "run using default output config")
 	master          = flag.Bool("master", false, "whether to init and clear resource")
 	runningUser     = flag.String("runAs", "admin", "run using default output config")
+	junitReport     = flag.String("junitReport", "", "path of junit xml report, empty means no report")
+	jsonReport      = flag.String("jsonReport", "", "path of json result report, empty means no report")
+	dryRun          = flag.Bool("dryRun", false, "print the execution plan without touching clusters")
+	dryRunJSON      = flag.String("dryRunJSON", "", "path of json execution plan written in dry run mode, - means stdout")
+	concurrentRoles = flag.Bool("concurrentRoles", false, "run all roles of -runAs concurrently in this process")
+	selectExpr      = flag.String("select", "", "select tests by expression of module, id, step, role and label, e.g. 'module=存储 && role=projectAdmin'")
+
+	testContext *framework.TestContext
 )
 
 // entrance
@@ -74,32 +82,84 @@ func TestMain(m *testing.M) {
 		}
 	}
 	clog.Info("running user %+v", framework.TestUser)
+	framework.ConcurrentRoles = *concurrentRoles
+
+	selector, err := framework.ParseSelector(*selectExpr)
+	if err != nil {
+		clog.Error("invalid select expression: %v", err)
+		os.Exit(1)
+	}
+	framework.TestSelector = selector
+	if selector != nil {
+		clog.Info("select tests by %s", *selectExpr)
+	}
+
+	if *dryRun {
+		if err := printPlan(*dryRunJSON); err != nil {
+			clog.Error(err.Error())
+			os.Exit(1)
+		}
+		return
+	}
 
 	isMaster = *master
 
-	if err := InitAll(); err != nil {
+	tc, err := InitAll()
+	if err != nil {
 		clog.Error(err.Error())
 		os.Exit(1)
 	}
+	testContext = tc
 
-	if err := Start(); err != nil {
+	if err := Start(tc); err != nil {
 		clog.Error(err.Error())
-		err := End()
+		err := End(tc, 1)
 		if err != nil {
 			clog.Error(err.Error())
 		}
 		os.Exit(1)
 	}
 	rand.Seed(time.Now().UnixNano())
-	m.Run()
-	err := End()
+	code := m.Run()
+	err = End(tc, code)
 	if err != nil {
 		clog.Error(err.Error())
 		os.Exit(1)
 	}
+	os.Exit(code)
+}
+
+// printPlan 只读取配置计算执行计划，文本输出到标准输出，jsonPath 不为空时输出 JSON
+func printPlan(jsonPath string) error {
+	tc, err := framework.InitDryRunContext()
+	if err != nil {
+		return err
+	}
+	if err = framework.InitMultiConfig(); err != nil {
+		return err
+	}
+	plan := framework.BuildExecutionPlan(tc)
+	if len(jsonPath) == 0 {
+		_, err = os.Stdout.Write(plan.Text())
+		return err
+	}
+	data, err := plan.JSON()
+	if err != nil {
+		return err
+	}
+	if jsonPath == "-" {
+		_, err = os.Stdout.Write(append(data, '\n'))
+		return err
+	}
+	if _, err = os.Stdout.Write(plan.Text()); err != nil {
+		return err
+	}
+	return os.WriteFile(jsonPath, data, 0o644)
 }
 
 // start e2e test
 func TestE2E(t *testing.T) {
-	RunE2ETests(t)
+	// 结果在 End 中输出，master 会先合并 worker 的结果
+	resultReporter = framework.NewResultReporter(testContext, *junitReport, *jsonReport)
+	RunE2ETests(t, testContext, resultReporter)
 }
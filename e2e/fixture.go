@@ -0,0 +1,41 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	_ "embed"
+	"os"
+
+	"github.com/kubecube-io/kubecube-e2e/e2e/framework"
+)
+
+// defaultFixture 内置的测试前置资源，运行镜像中只有测试二进制，因此需要编译进去
+//
+//go:embed mock/fixture.yaml
+var defaultFixture []byte
+
+// loadFixture 读取 e2eInit.fixture 指定的 fixture，未配置时使用内置的 mock/fixture.yaml
+func loadFixture(tc *framework.TestContext) (*framework.Fixture, error) {
+	if len(tc.FixtureFile) == 0 {
+		return framework.LoadFixture("mock/fixture.yaml", defaultFixture, tc.Config)
+	}
+	data, err := os.ReadFile(tc.FixtureFile)
+	if err != nil {
+		return nil, err
+	}
+	return framework.LoadFixture(tc.FixtureFile, data, tc.Config)
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fixtures
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/template"
+
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+
+	"github.com/kubecube-io/kubecube-e2e/e2e/framework"
+)
+
+//go:embed testdata
+var builtin embed.FS
+
+// Values 渲染清单模板的命名字段
+type Values struct {
+	// Name 资源名，通常为 tc.NameWithUser 的结果
+	Name            string
+	User            string
+	Namespace       string
+	Image           string
+	ImagePullSecret string
+	StorageClass    string
+	NodeHostName    string
+	// Vars 测试特有的字段，如存储声明名、resourceVersion，模板中以 {{ .Vars.key }} 引用
+	Vars map[string]string
+}
+
+// For 返回以当前测试上下文填充的字段，name 为资源名
+func For(tc *framework.TestContext, name string) Values {
+	return Values{
+		Name:            name,
+		User:            tc.User,
+		Namespace:       tc.NamespaceName,
+		Image:           tc.TestImage,
+		ImagePullSecret: tc.ImagePullSecret,
+		StorageClass:    tc.StorageClass,
+		NodeHostName:    tc.NodeHostName,
+	}
+}
+
+// With 返回设置了 Vars[key] 的副本
+func (v Values) With(key, value string) Values {
+	vars := make(map[string]string, len(v.Vars)+1)
+	for k, val := range v.Vars {
+		vars[k] = val
+	}
+	vars[key] = value
+	v.Vars = vars
+	return v
+}
+
+var funcs = template.FuncMap{
+	"quote": strconv.Quote,
+}
+
+// Render 渲染 testdata 中名为 file 的 yaml 或 json 模板，返回 json 格式的清单。
+// config.yaml 中配置了 testdata.dir 且目录中存在同名文件时使用该文件；
+// testdata.validate 不为 false 时以测试集群的 OpenAPI schema 校验清单
+func Render(tc *framework.TestContext, file string, v Values) (string, error) {
+	data, err := load(tc.TestdataDir, file)
+	if err != nil {
+		return "", err
+	}
+	tmpl, err := template.New(file).Funcs(funcs).Option("missingkey=error").Parse(string(data))
+	if err != nil {
+		return "", fmt.Errorf("parse testdata %s failed: %v", file, err)
+	}
+	var rendered bytes.Buffer
+	if err = tmpl.Execute(&rendered, v); err != nil {
+		return "", fmt.Errorf("render testdata %s failed: %v", file, err)
+	}
+	out, err := utilyaml.ToJSON(rendered.Bytes())
+	if err != nil {
+		return "", fmt.Errorf("convert testdata %s to json failed: %v", file, err)
+	}
+
+	if tc.ValidateTestdata && tc.TargetClusterClient != nil {
+		obj := make(map[string]interface{})
+		if err = json.Unmarshal(out, &obj); err != nil {
+			return "", fmt.Errorf("decode testdata %s failed: %v", file, err)
+		}
+		if err = validate(tc, obj); err != nil {
+			return "", fmt.Errorf("testdata %s is invalid: %v", file, err)
+		}
+	}
+	return string(out), nil
+}
+
+// MustRender 渲染并校验清单，失败时当前步骤失败
+func MustRender(tc *framework.TestContext, file string, v Values) string {
+	out, err := Render(tc, file, v)
+	tc.ExpectNoError(err)
+	return out
+}
+
+// load 读取模板，dir 中存在同名文件时优先使用
+func load(dir, file string) ([]byte, error) {
+	if len(dir) > 0 {
+		data, err := os.ReadFile(filepath.Join(dir, file))
+		if err == nil {
+			return data, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("read testdata %s failed: %v", file, err)
+		}
+	}
+	data, err := builtin.ReadFile("testdata/" + file)
+	if err != nil {
+		return nil, fmt.Errorf("read testdata %s failed: %v", file, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fixtures
+
+import (
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/kubecube-io/kubecube-e2e/e2e/framework"
+)
+
+// removedKinds 已从 kubernetes v1.27 中移除的 API，schemaFile 中没有其模型，校验时跳过
+var removedKinds = map[schema.GroupVersionKind]bool{
+	{Group: "batch", Version: "v1beta1", Kind: "CronJob"}:                       true,
+	{Group: "autoscaling", Version: "v2beta1", Kind: "HorizontalPodAutoscaler"}: true,
+}
+
+func sampleValues() Values {
+	return Values{
+		Name:            "demo-admin",
+		User:            "admin",
+		Namespace:       "kubecube-e2e",
+		Image:           "nginx:latest",
+		ImagePullSecret: "registry-secret",
+		StorageClass:    "local-path",
+		NodeHostName:    "node-1",
+		Vars: map[string]string{
+			"accessMode":      "ReadWriteOnce",
+			"claim":           "data",
+			"claim1":          "data-1",
+			"claim2":          "data-2",
+			"clusterIP":       "10.96.0.10",
+			"host":            "demo.example.com",
+			"resourceVersion": "1024",
+			"service":         "demo",
+			"storage":         "1Gi",
+			"targetKind":      "Deployment",
+			"uid":             "0b6f0a2e-3c1f-4d6e-9a55-2f1b7c1f0e6d",
+		},
+	}
+}
+
+// TestRenderBuiltin 以示例字段渲染所有内置清单并以 schemaFile 校验
+func TestRenderBuiltin(t *testing.T) {
+	tc := schemaContext(t)
+	files, err := fs.Glob(builtin, "testdata/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no builtin testdata")
+	}
+	for _, file := range files {
+		file := strings.TrimPrefix(file, "testdata/")
+		t.Run(file, func(t *testing.T) {
+			out, err := Render(tc, file, sampleValues())
+			if err != nil {
+				t.Fatal(err)
+			}
+			obj := make(map[string]interface{})
+			if err = json.Unmarshal([]byte(out), &obj); err != nil {
+				t.Fatalf("decode rendered %s: %v", file, err)
+			}
+			apiVersion, _ := obj["apiVersion"].(string)
+			kind, _ := obj["kind"].(string)
+			gvk := schema.FromAPIVersionAndKind(apiVersion, kind)
+			if _, ok := schemas[tc.TargetClusterName].kinds[gvk]; !ok && !removedKinds[gvk] {
+				t.Fatalf("no openapi schema of %s in %s", gvk, schemaFile)
+			}
+			if err = validate(tc, obj); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestRenderMissingValue(t *testing.T) {
+	tc := &framework.TestContext{Config: &framework.Config{}}
+	v := sampleValues()
+	v.Vars = nil
+	if _, err := Render(tc, "pvc.yaml", v); err == nil || !strings.Contains(err.Error(), "render testdata pvc.yaml failed") {
+		t.Fatalf("expected render error, got %v", err)
+	}
+	if _, err := Render(tc, "missing.yaml", v); err == nil || !strings.Contains(err.Error(), "read testdata missing.yaml failed") {
+		t.Fatalf("expected read error, got %v", err)
+	}
+}
+
+func TestRenderTestdataDir(t *testing.T) {
+	dir := t.TempDir()
+	override := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{ quote .Name }}\n"
+	if err := os.WriteFile(filepath.Join(dir, "service.yaml"), []byte(override), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tc := &framework.TestContext{Config: &framework.Config{TestdataDir: dir}}
+
+	out, err := Render(tc, "service.yaml", Values{Name: "demo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"demo"}}`; out != want {
+		t.Fatalf("Render() = %s, want %s", out, want)
+	}
+
+	// 目录中没有的文件使用内置清单
+	out, err = Render(tc, "pvc.yaml", sampleValues())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, `"kind":"PersistentVolumeClaim"`) {
+		t.Fatalf("Render() = %s, want builtin pvc", out)
+	}
+}
@@ -0,0 +1,230 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fixtures
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/kube-openapi/pkg/util/proto"
+
+	"github.com/kubecube-io/kubecube-e2e/e2e/framework"
+)
+
+const gvkExtension = "x-kubernetes-group-version-kind"
+
+// clusterSchema 一个集群的 OpenAPI v2 模型及 GVK 到模型的索引
+type clusterSchema struct {
+	models proto.Models
+	kinds  map[schema.GroupVersionKind]proto.Schema
+}
+
+var (
+	schemaMu sync.Mutex
+	// schemas 各集群的 OpenAPI 模型，key 为集群名，首次校验该集群的清单时读取
+	schemas = make(map[string]*clusterSchema)
+)
+
+// validate 以测试集群的 OpenAPI schema 校验对象：未知字段、类型不符与缺少必填字段都视为错误。
+// 集群中没有该 GVK 的模型时（如 API 已下线）只输出警告，由请求的结果决定步骤是否成功
+func validate(tc *framework.TestContext, obj map[string]interface{}) error {
+	apiVersion, _ := obj["apiVersion"].(string)
+	kind, _ := obj["kind"].(string)
+	if len(apiVersion) == 0 || len(kind) == 0 {
+		return fmt.Errorf("apiVersion and kind are required")
+	}
+	gvk := schema.FromAPIVersionAndKind(apiVersion, kind)
+
+	s, err := loadSchema(tc)
+	if err != nil {
+		return err
+	}
+	model, ok := s.kinds[gvk]
+	if !ok {
+		clog.Warn("no openapi schema of %s in cluster %s, skip validation", gvk, tc.TargetClusterName)
+		return nil
+	}
+	v := &validator{}
+	v.visit(model, "", obj)
+	if len(v.errs) > 0 {
+		sort.Strings(v.errs)
+		return fmt.Errorf("%s does not match openapi schema of cluster %s: %s", gvk, tc.TargetClusterName, strings.Join(v.errs, "; "))
+	}
+	return nil
+}
+
+func loadSchema(tc *framework.TestContext) (*clusterSchema, error) {
+	schemaMu.Lock()
+	defer schemaMu.Unlock()
+	if s, ok := schemas[tc.TargetClusterName]; ok {
+		return s, nil
+	}
+	doc, err := tc.TargetClusterClient.CacheDiscovery().OpenAPISchema()
+	if err != nil {
+		return nil, fmt.Errorf("get openapi schema of cluster %s failed: %v", tc.TargetClusterName, err)
+	}
+	models, err := proto.NewOpenAPIData(doc)
+	if err != nil {
+		return nil, fmt.Errorf("parse openapi schema of cluster %s failed: %v", tc.TargetClusterName, err)
+	}
+	s := newClusterSchema(models)
+	schemas[tc.TargetClusterName] = s
+	return s, nil
+}
+
+func newClusterSchema(models proto.Models) *clusterSchema {
+	s := &clusterSchema{models: models, kinds: make(map[schema.GroupVersionKind]proto.Schema)}
+	for _, name := range models.ListModels() {
+		model := models.LookupModel(name)
+		gvks, _ := model.GetExtensions()[gvkExtension].([]interface{})
+		for _, item := range gvks {
+			gvk, ok := extensionGVK(item)
+			if ok {
+				s.kinds[gvk] = model
+			}
+		}
+	}
+	return s
+}
+
+// extensionGVK 解析 x-kubernetes-group-version-kind 中的一项，yaml.v2 解析出的 key 为 interface{}
+func extensionGVK(item interface{}) (schema.GroupVersionKind, bool) {
+	fields := make(map[string]string, 3)
+	switch m := item.(type) {
+	case map[interface{}]interface{}:
+		for k, v := range m {
+			fields[fmt.Sprint(k)], _ = v.(string)
+		}
+	case map[string]interface{}:
+		for k, v := range m {
+			fields[k], _ = v.(string)
+		}
+	default:
+		return schema.GroupVersionKind{}, false
+	}
+	gvk := schema.GroupVersionKind{Group: fields["group"], Version: fields["version"], Kind: fields["kind"]}
+	return gvk, len(gvk.Version) > 0 && len(gvk.Kind) > 0
+}
+
+// validator 按 schema 递归检查 json 解码后的值，null 视为未设置
+type validator struct {
+	path  string
+	value interface{}
+	errs  []string
+}
+
+func (v *validator) visit(s proto.Schema, path string, value interface{}) {
+	parent, parentValue := v.path, v.value
+	v.path, v.value = path, value
+	s.Accept(v)
+	v.path, v.value = parent, parentValue
+}
+
+func (v *validator) errorf(format string, args ...interface{}) {
+	path := strings.TrimPrefix(v.path, ".")
+	if len(path) == 0 {
+		path = "<root>"
+	}
+	v.errs = append(v.errs, path+": "+fmt.Sprintf(format, args...))
+}
+
+func (v *validator) VisitKind(k *proto.Kind) {
+	m, ok := v.value.(map[string]interface{})
+	if !ok {
+		v.errorf("expected object, got %T", v.value)
+		return
+	}
+	for key, value := range m {
+		if value == nil {
+			continue
+		}
+		field, ok := k.Fields[key]
+		if !ok {
+			v.errorf("unknown field %q", key)
+			continue
+		}
+		v.visit(field, v.path+"."+key, value)
+	}
+	for _, key := range k.RequiredFields {
+		if m[key] == nil {
+			v.errorf("missing required field %q", key)
+		}
+	}
+}
+
+func (v *validator) VisitMap(m *proto.Map) {
+	values, ok := v.value.(map[string]interface{})
+	if !ok {
+		v.errorf("expected object, got %T", v.value)
+		return
+	}
+	for key, value := range values {
+		if value != nil {
+			v.visit(m.SubType, v.path+"."+key, value)
+		}
+	}
+}
+
+func (v *validator) VisitArray(a *proto.Array) {
+	items, ok := v.value.([]interface{})
+	if !ok {
+		v.errorf("expected array, got %T", v.value)
+		return
+	}
+	for i, item := range items {
+		if item != nil {
+			v.visit(a.SubType, fmt.Sprintf("%s[%d]", v.path, i), item)
+		}
+	}
+}
+
+// VisitPrimitive 检查基本类型，string 同时接受数字，如 Quantity 与 IntOrString
+func (v *validator) VisitPrimitive(p *proto.Primitive) {
+	switch p.Type {
+	case "string":
+		switch v.value.(type) {
+		case string, float64:
+			return
+		}
+	case "integer":
+		if n, ok := v.value.(float64); ok && n == math.Trunc(n) {
+			return
+		}
+	case "number":
+		if _, ok := v.value.(float64); ok {
+			return
+		}
+	case "boolean":
+		if _, ok := v.value.(bool); ok {
+			return
+		}
+	default:
+		return
+	}
+	v.errorf("expected %s, got %v", p.GetName(), v.value)
+}
+
+func (v *validator) VisitReference(r proto.Reference) {
+	r.SubSchema().Accept(v)
+}
+
+func (v *validator) VisitArbitrary(*proto.Arbitrary) {}
@@ -0,0 +1,225 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fixtures
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	openapi_v2 "github.com/google/gnostic/openapiv2"
+	"k8s.io/kube-openapi/pkg/util/proto"
+
+	"github.com/kubecube-io/kubecube-e2e/e2e/framework"
+)
+
+// schemaFile 由 kubernetes v1.27.4 的 api/openapi-spec/swagger.json 裁剪而来，
+// 只保留内置清单用到的资源类型及其引用的定义，并去掉了 description
+const schemaFile = "schema/kubernetes-v1.27.4.json"
+
+// schemaContext 返回 OpenAPI 模型取自 schemaFile 的测试上下文
+func schemaContext(t *testing.T) *framework.TestContext {
+	t.Helper()
+	const cluster = "schema-test"
+	data, err := os.ReadFile(schemaFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := openapi_v2.ParseDocument(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	models, err := proto.NewOpenAPIData(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schemaMu.Lock()
+	schemas[cluster] = newClusterSchema(models)
+	schemaMu.Unlock()
+	t.Cleanup(func() {
+		schemaMu.Lock()
+		delete(schemas, cluster)
+		schemaMu.Unlock()
+	})
+	return &framework.TestContext{Config: &framework.Config{TargetClusterName: cluster}}
+}
+
+func TestExtensionGVK(t *testing.T) {
+	cases := []struct {
+		name string
+		item interface{}
+		want string
+		ok   bool
+	}{
+		{
+			name: "yaml map",
+			item: map[interface{}]interface{}{"group": "apps", "version": "v1", "kind": "Deployment"},
+			want: "apps/v1, Kind=Deployment",
+			ok:   true,
+		},
+		{
+			name: "json map of core group",
+			item: map[string]interface{}{"group": "", "version": "v1", "kind": "Pod"},
+			want: "/v1, Kind=Pod",
+			ok:   true,
+		},
+		{
+			name: "missing kind",
+			item: map[string]interface{}{"group": "apps", "version": "v1"},
+		},
+		{
+			name: "not a map",
+			item: "apps/v1",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			gvk, ok := extensionGVK(c.item)
+			if ok != c.ok {
+				t.Fatalf("ok = %v, want %v", ok, c.ok)
+			}
+			if ok && gvk.String() != c.want {
+				t.Fatalf("gvk = %s, want %s", gvk, c.want)
+			}
+		})
+	}
+}
+
+func deployment(spec map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "demo", "labels": map[string]interface{}{"app": "demo"}},
+		"spec":       spec,
+	}
+}
+
+func podTemplate(container map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"selector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "demo"}},
+		"template": map[string]interface{}{
+			"spec": map[string]interface{}{"containers": []interface{}{container}},
+		},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tc := schemaContext(t)
+
+	validSpec := podTemplate(map[string]interface{}{
+		"name":  "demo",
+		"image": "nginx",
+		"ports": []interface{}{map[string]interface{}{"containerPort": float64(80)}},
+		"resources": map[string]interface{}{
+			"limits": map[string]interface{}{"cpu": float64(1), "memory": "128Mi"},
+		},
+	})
+	validSpec["replicas"] = float64(2)
+	validSpec["strategy"] = map[string]interface{}{
+		"rollingUpdate": map[string]interface{}{"maxSurge": "25%", "maxUnavailable": float64(0)},
+	}
+	validSpec["paused"] = nil
+
+	cases := []struct {
+		name string
+		obj  map[string]interface{}
+		// errs 为空时校验应通过，否则错误信息应包含其中每一项
+		errs []string
+	}{
+		{
+			name: "valid",
+			obj:  deployment(validSpec),
+		},
+		{
+			name: "missing apiVersion",
+			obj:  map[string]interface{}{"kind": "Deployment"},
+			errs: []string{"apiVersion and kind are required"},
+		},
+		{
+			name: "unknown kind is skipped",
+			obj:  map[string]interface{}{"apiVersion": "batch/v1beta1", "kind": "CronJob", "spec": "anything"},
+		},
+		{
+			name: "unknown field",
+			obj: deployment(map[string]interface{}{
+				"selector": map[string]interface{}{},
+				"template": map[string]interface{}{},
+				"replica":  float64(1),
+			}),
+			errs: []string{`spec: unknown field "replica"`},
+		},
+		{
+			name: "wrong types",
+			obj: deployment(map[string]interface{}{
+				"selector": map[string]interface{}{"matchLabels": []interface{}{"app"}},
+				"template": map[string]interface{}{},
+				"replicas": float64(1.5),
+				"paused":   "true",
+			}),
+			errs: []string{
+				"spec.paused: expected boolean, got true",
+				"spec.replicas: expected integer (int32), got 1.5",
+				"spec.selector.matchLabels: expected object, got []interface {}",
+			},
+		},
+		{
+			name: "missing required fields",
+			obj:  deployment(podTemplate(map[string]interface{}{"image": "nginx", "ports": []interface{}{map[string]interface{}{}}})),
+			errs: []string{
+				`spec.template.spec.containers[0]: missing required field "name"`,
+				`spec.template.spec.containers[0].ports[0]: missing required field "containerPort"`,
+			},
+		},
+		{
+			name: "null is unset",
+			obj: map[string]interface{}{
+				"apiVersion": "apps/v1",
+				"kind":       "Deployment",
+				"metadata":   map[string]interface{}{"name": "demo", "labels": nil},
+				"spec":       nil,
+			},
+		},
+		{
+			name: "object expected",
+			obj: map[string]interface{}{
+				"apiVersion": "apps/v1",
+				"kind":       "Deployment",
+				"spec":       "replicas: 1",
+			},
+			errs: []string{"spec: expected object, got string"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validate(tc, c.obj)
+			if len(c.errs) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected error containing %q", c.errs)
+			}
+			for _, want := range c.errs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not contain %q", err, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/onsi/ginkgo"
+)
+
+// ConcurrentRoles 为 true 时同一进程内各角色并发执行测试，每个步骤生成一个用例，
+// 用例中每个角色在独立的 goroutine 中以各自的 TestContext 执行该步骤
+var ConcurrentRoles bool
+
+// roleFailure 并发执行时某个角色的断言失败
+type roleFailure struct {
+	message  string
+	location string
+}
+
+// panicFail 将断言失败转为 roleFailure panic，由执行步骤的 goroutine 收集：
+// 并发执行角色时交给所在角色的 goroutine，执行可重试的步骤时交给 runWithPolicy 重试
+func panicFail(message string, callerSkip ...int) {
+	skip := 0
+	if len(callerSkip) > 0 {
+		skip = callerSkip[0]
+	}
+	location := ""
+	if _, file, line, ok := runtime.Caller(skip + 1); ok {
+		location = fmt.Sprintf("%s:%d", file, line)
+	}
+	panic(roleFailure{message: message, location: location})
+}
+
+// roleRun 一个角色执行一个测试的状态
+type roleRun struct {
+	ctx      *TestContext
+	graph    *stepGraph
+	selected map[string]struct{}
+}
+
+// runRolesConcurrently 在独立的 goroutine 中为每个角色执行 fn，返回各角色的失败信息
+func runRolesConcurrently(runs []*roleRun, fn func(r *roleRun)) []string {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		failures []string
+	)
+	for _, r := range runs {
+		wg.Add(1)
+		go func(r *roleRun) {
+			defer wg.Done()
+			defer func() {
+				if e := recover(); e != nil {
+					mu.Lock()
+					failures = append(failures, fmt.Sprintf("[%s] %s", r.ctx.Role, failureOf(e)))
+					mu.Unlock()
+				}
+			}()
+			fn(r)
+		}(r)
+	}
+	wg.Wait()
+	sort.Strings(failures)
+	return failures
+}
+
+func failureOf(e interface{}) string {
+	switch f := e.(type) {
+	case roleFailure:
+		if len(f.location) == 0 {
+			return f.message
+		}
+		return f.message + "\n" + f.location
+	case string:
+		if f == ginkgo.GINKGO_PANIC {
+			// 步骤直接调用了 ginkgo.Fail，失败信息已由 ginkgo 记录
+			return "failed"
+		}
+	}
+	return fmt.Sprintf("panic: %v", e)
+}
+
+// generateConcurrentTestExample 为测试生成并发执行所有角色的用例，用例名为 "角色1,角色2 : 步骤"
+func generateConcurrentTestExample(tc *TestContext, test MultiUserTest, users []string) {
+	_ = ginkgo.Describe(test.TestName, func() {
+		var runs []*roleRun
+		for _, user := range users {
+			if reason, skipped := testSkipReason(tc, test, user, test.Skipfunc); skipped {
+				clog.Info("test %s is not generated for user %s: %s", test.TestName, user, reason)
+				continue
+			}
+			graph := newStepGraph(test)
+			selected := selectSteps(test, user, graph.deps)
+			if len(selected) == 0 {
+				clog.Info("test %s is not selected for user %s", test.TestName, user)
+				continue
+			}
+			runs = append(runs, &roleRun{ctx: tc.ForUser(user).withFailHandler(panicFail), graph: graph, selected: selected})
+		}
+		if len(runs) == 0 {
+			return
+		}
+
+		if reason, unmet := tc.requirementSkipReason(test); unmet {
+			clog.Info("test %s is skipped: %s", test.TestName, reason)
+			skipTestExample(test, runs, reason)
+			return
+		}
+
+		recordHAR(tc)
+
+		total := 0
+		for _, step := range test.Steps {
+			for _, r := range runs {
+				if _, ok := r.selected[step.Name]; ok {
+					total++
+					break
+				}
+			}
+		}
+		if test.InitStep != nil {
+			total++
+		}
+		if test.FinalStep != nil {
+			total++
+		}
+		scope := clusterScope(tc, test.TestName)
+		beginTrack, cleanupTracked := trackTest(tc, scope, total)
+		takeSnapshot, diffSnapshot := snapshotTest(tc, test, scope, total)
+		ginkgo.BeforeEach(beginTrack)
+		ginkgo.BeforeEach(takeSnapshot)
+
+		// 只要还有角色未失败就执行测试的 BeforeEach 与 AfterEach
+		anyRunning := func() bool {
+			for _, r := range runs {
+				if !r.graph.anyBlocked() {
+					return true
+				}
+			}
+			return false
+		}
+		ginkgo.BeforeEach(func() {
+			if anyRunning() && test.BeforeEach != nil {
+				test.BeforeEach()
+			}
+		})
+		ginkgo.AfterEach(func() {
+			if anyRunning() && test.AfterEach != nil {
+				test.AfterEach()
+			}
+		})
+		ginkgo.AfterEach(cleanupTracked)
+		ginkgo.AfterEach(diffSnapshot)
+
+		ginkgo.Context("测试用例", func() {
+			if test.InitStep != nil {
+				step := test.InitStep
+				ginkgo.It(roleNames(runs)+" : "+step.Name, func() {
+					failIfAny(runRolesConcurrently(runs, func(r *roleRun) {
+						runUserHook(r.ctx, step, "init")
+					}))
+				})
+			}
+
+			for _, s := range test.Steps {
+				step := s
+				var stepRuns []*roleRun
+				for _, r := range runs {
+					if _, ok := r.selected[step.Name]; ok {
+						stepRuns = append(stepRuns, r)
+					}
+				}
+				if len(stepRuns) == 0 {
+					continue
+				}
+				ginkgo.It(roleNames(stepRuns)+" : "+step.Name, func() {
+					var (
+						mu      sync.Mutex
+						skipped []string
+					)
+					failures := runRolesConcurrently(stepRuns, func(r *roleRun) {
+						if reason, ok := runUserStep(r.ctx, test, step, r.graph, test.ErrorFunc); ok {
+							mu.Lock()
+							skipped = append(skipped, fmt.Sprintf("[%s] %s", r.ctx.Role, reason))
+							mu.Unlock()
+						}
+					})
+					failIfAny(failures)
+					if len(skipped) == len(stepRuns) {
+						sort.Strings(skipped)
+						ginkgo.Skip(strings.Join(skipped, "\n"))
+					}
+				})
+			}
+
+			if test.FinalStep != nil {
+				step := test.FinalStep
+				ginkgo.It(roleNames(runs)+" : "+step.Name, func() {
+					failIfAny(runRolesConcurrently(runs, func(r *roleRun) {
+						runUserHook(r.ctx, step, "final")
+					}))
+				})
+			}
+		})
+	})
+}
+
+func roleNames(runs []*roleRun) string {
+	names := make([]string, 0, len(runs))
+	for _, r := range runs {
+		names = append(names, r.ctx.Role)
+	}
+	return strings.Join(names, ",")
+}
+
+func failIfAny(failures []string) {
+	if len(failures) > 0 {
+		ginkgo.Fail(strings.Join(failures, "\n\n"), 1)
+	}
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+func TestRunRolesConcurrently(t *testing.T) {
+	cfg := &Config{KubecubeHost: "http://127.0.0.1", Roles: testRoles()}
+	tc := &TestContext{Config: cfg, HttpHelper: NewHttpHelper(cfg)}
+	var runs []*roleRun
+	for _, role := range cfg.Roles.Names() {
+		runs = append(runs, &roleRun{ctx: tc.ForUser(role).withFailHandler(panicFail)})
+	}
+
+	// 角色执行期间其他 goroutine 中不属于步骤的断言仍交给全局的失败处理函数，不会被当作某个角色的失败
+	var outside []string
+	gomega.RegisterFailHandler(func(message string, _ ...int) { outside = append(outside, message) })
+	t.Cleanup(func() { gomega.RegisterFailHandler(nil) })
+	started, release := make(chan struct{}), make(chan struct{})
+	go func() {
+		<-started
+		tc.ExpectEqual("tracker", "snapshot")
+		close(release)
+	}()
+
+	failures := runRolesConcurrently(runs, func(r *roleRun) {
+		switch r.ctx.Role {
+		case UserAdmin:
+			close(started)
+			<-release
+		case "tenant-admin":
+			r.ctx.ExpectNoError(errors.New("forbidden"))
+		case "viewer":
+			r.ctx.ExpectEqual(403, 200)
+		}
+	})
+
+	if len(outside) != 1 {
+		t.Fatal("assertion outside of roles is not reported to the global fail handler")
+	}
+	if len(failures) != 2 {
+		t.Fatalf("failures = %q, want 2", failures)
+	}
+	for i, prefix := range []string{"[tenant-admin] ", "[viewer] "} {
+		if !strings.HasPrefix(failures[i], prefix) || !strings.Contains(failures[i], "concurrent_test.go") {
+			t.Errorf("failure %q should start with %q and locate the assertion", failures[i], prefix)
+		}
+	}
+}
+
+func TestFailureOf(t *testing.T) {
+	cases := []struct {
+		name string
+		e    interface{}
+		want string
+	}{
+		{name: "role failure", e: roleFailure{message: "expected 200", location: "step.go:10"}, want: "expected 200\nstep.go:10"},
+		{name: "role failure without location", e: roleFailure{message: "expected 200"}, want: "expected 200"},
+		{name: "other panic", e: errors.New("nil map"), want: "panic: nil map"},
+	}
+	var got, want []string
+	for _, c := range cases {
+		got = append(got, failureOf(c.e))
+		want = append(want, c.want)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("failureOf() = %q, want %q", got, want)
+	}
+}
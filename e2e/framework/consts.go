@@ -24,8 +24,19 @@ const (
 
 	MultiConfig = "multiConfig.yaml"
 
-	ResourceReady  = "resourceReady"
-	ResourceFailed = "resourceFailed"
-
 	WorkerNum = "workerNum"
+
+	// E2EKeyLabel 标记 e2e 为测试用户创建的 Key，便于清理
+	E2EKeyLabel = "kubecube-e2e-key"
+	// E2EFixtureLabel 标记由 fixture 创建或更新的对象，master 异常退出后由 cmd/clear -sweep 清理
+	E2EFixtureLabel = "kubecube-e2e-fixture"
 )
+
+// ArtifactLabels 框架为创建的对象打上的标签，带有任一标签的对象都是 e2e 的产物
+var ArtifactLabels = []string{
+	"e2e-run",
+	"e2e-framework",
+	RunIDLabel,
+	E2EKeyLabel,
+	E2EFixtureLabel,
+}
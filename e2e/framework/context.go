@@ -0,0 +1,216 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/conversion"
+	"github.com/kubecube-io/kubecube/pkg/multicluster"
+	"github.com/kubecube-io/kubecube/pkg/multicluster/client"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// TestContext 测试上下文，持有配置、集群客户端、http 客户端以及当前执行用户，
+// 测试步骤只通过 TestContext 读取环境信息，不再依赖包级全局变量
+type TestContext struct {
+	*Config
+
+	// PivotClusterClient communicate with pivot cluster
+	PivotClusterClient client.Client
+	PivotConvertClient ctrlclient.Client
+
+	// TargetClusterClient communicate with target cluster
+	TargetClusterClient client.Client
+	TargetConvertClient ctrlclient.Client
+	// targets 所有测试集群的客户端，key 为集群名，ForCluster 从中选择 TargetClusterClient
+	targets map[string]*targetClients
+
+	HttpHelper *HttpHelper
+	// Matrix 收集各角色执行步骤的结果，用于生成权限矩阵
+	Matrix *MatrixRecorder
+	// Tracker 记录测试中经框架创建的对象，测试结束后清理，-dryRun 时为空
+	Tracker *ResourceTracker
+	// Preflight 各测试集群的预检查结果，RunPreflight 之前与 -dryRun 时为空
+	Preflight Preflight
+
+	// RunID 本次运行的唯一标识
+	RunID string
+	// Role 当前执行用户的角色，如 admin、tenantAdmin
+	Role string
+	// User 当前执行用户的用户名
+	User string
+
+	values *stepValues
+	// ctx 步骤的上下文，步骤超时后取消
+	ctx context.Context
+	// failHandler 步骤内断言失败的处理函数，并发执行角色或重试步骤时将失败交给执行步骤的 goroutine，为空时使用 ginkgo.Fail
+	failHandler func(message string, callerSkip ...int)
+}
+
+// targetClients 一个测试集群的客户端
+type targetClients struct {
+	cluster client.Client
+	convert ctrlclient.Client
+}
+
+// stepValues 步骤间共享的数据，同一用户的各步骤上下文共用
+type stepValues struct {
+	mu sync.Mutex
+	m  map[string]interface{}
+}
+
+// NewTestContext 根据配置创建测试上下文，multicluster 管理器需已启动
+func NewTestContext(cfg *Config) (*TestContext, error) {
+	tc := newTestContext(cfg)
+	tc.Tracker = NewResourceTracker(tc.RunID, cfg)
+
+	cli, err := multicluster.Interface().GetClient(cfg.PivotClusterName)
+	if err != nil {
+		return nil, fmt.Errorf("get pivot client failed: %v", err)
+	}
+	cli = tc.Tracker.Wrap(cfg.PivotClusterName, cli)
+	tc.PivotClusterClient = cli
+	convertor, err := conversion.NewVersionConvertor(cli.CacheDiscovery(), cli.RESTMapper())
+	if err != nil {
+		clog.Error("init client convert error, error: %s", err.Error())
+		return nil, err
+	}
+	tc.PivotConvertClient = conversion.WrapClient(cli.Direct(), convertor, true)
+
+	tc.targets = make(map[string]*targetClients, len(cfg.TargetClusters))
+	for _, cluster := range cfg.TargetClusters {
+		if cluster == cfg.PivotClusterName {
+			tc.targets[cluster] = &targetClients{cluster: tc.PivotClusterClient, convert: tc.PivotConvertClient}
+			continue
+		}
+		cli, err = multicluster.Interface().GetClient(cluster)
+		if cli == nil {
+			return nil, fmt.Errorf("get target client of %s failed: %v", cluster, err)
+		}
+		cli = tc.Tracker.Wrap(cluster, cli)
+		convertor, err = conversion.NewVersionConvertor(cli.CacheDiscovery(), cli.RESTMapper())
+		if err != nil {
+			clog.Error("init client convert error, error: %s", err.Error())
+			return nil, err
+		}
+		tc.targets[cluster] = &targetClients{cluster: cli, convert: conversion.WrapClient(cli.Direct(), convertor, true)}
+	}
+	target := tc.targets[cfg.TargetClusterName]
+	tc.TargetClusterClient, tc.TargetConvertClient = target.cluster, target.convert
+
+	tc.HttpHelper = NewHttpHelper(cfg)
+	tc.HttpHelper.Tracker = tc.Tracker
+	return tc, nil
+}
+
+// newTestContext 创建不包含集群客户端的测试上下文
+func newTestContext(cfg *Config) *TestContext {
+	return &TestContext{
+		Config: cfg,
+		Matrix: NewMatrixRecorder(),
+		RunID:  string(uuid.NewUUID()),
+		values: &stepValues{m: make(map[string]interface{})},
+	}
+}
+
+// Login 准备凭证并登录所有角色，需在测试用户创建之后调用，任一角色登录失败都会返回错误
+func (tc *TestContext) Login() error {
+	if configurer, ok := GetLoginMap(tc.LoginType).(LoginConfigurer); ok {
+		err := configurer.Configure(tc.Config)
+		if err != nil {
+			return fmt.Errorf("configure login %s failed: %v", tc.LoginType, err)
+		}
+	}
+	err := tc.HttpHelper.Provision(tc.LoginType, tc.PivotClusterClient.Direct())
+	if err != nil {
+		return err
+	}
+	return tc.HttpHelper.Login(tc.LoginType)
+}
+
+// ForUser 返回以指定角色执行的测试上下文副本，副本拥有独立的步骤间共享数据
+func (tc *TestContext) ForUser(role string) *TestContext {
+	return &TestContext{
+		Config:              tc.Config,
+		PivotClusterClient:  tc.PivotClusterClient,
+		PivotConvertClient:  tc.PivotConvertClient,
+		TargetClusterClient: tc.TargetClusterClient,
+		TargetConvertClient: tc.TargetConvertClient,
+		targets:             tc.targets,
+		HttpHelper:          tc.HttpHelper,
+		Matrix:              tc.Matrix,
+		Tracker:             tc.Tracker,
+		Preflight:           tc.Preflight,
+		RunID:               tc.RunID,
+		Role:                role,
+		User:                tc.GetUser(role),
+		values:              &stepValues{m: make(map[string]interface{})},
+	}
+}
+
+// ForCluster 返回以 cluster 为测试集群的上下文副本，TargetClusterName、TargetClusterClient 与 CubeResourceQuota 指向该集群
+func (tc *TestContext) ForCluster(cluster string) *TestContext {
+	clusterCtx := *tc
+	clusterCtx.Config = tc.Config.forCluster(cluster)
+	if target, ok := tc.targets[cluster]; ok {
+		clusterCtx.TargetClusterClient, clusterCtx.TargetConvertClient = target.cluster, target.convert
+	}
+	return &clusterCtx
+}
+
+// forStep 返回执行单个步骤的上下文副本，步骤设置了超时时间时 WaitTimeout 使用该时间，步骤间共享数据不变
+func (tc *TestContext) forStep(policy StepPolicy) *TestContext {
+	stepCtx := *tc
+	if policy.Timeout > 0 {
+		cfg := *tc.Config
+		cfg.WaitTimeout = policy.Timeout
+		stepCtx.Config = &cfg
+	}
+	return &stepCtx
+}
+
+// Context 返回步骤的上下文，步骤设置了 Timeout 时超时后被取消，耗时较长的步骤应在其取消后尽快返回
+func (tc *TestContext) Context() context.Context {
+	if tc.ctx == nil {
+		return context.Background()
+	}
+	return tc.ctx
+}
+
+// NameWithUser 返回带当前用户后缀的资源名
+func (tc *TestContext) NameWithUser(name string) string {
+	return NameWithUser(name, tc.User)
+}
+
+// SetValue 保存步骤间需要传递的数据
+func (tc *TestContext) SetValue(key string, value interface{}) {
+	tc.values.mu.Lock()
+	defer tc.values.mu.Unlock()
+	tc.values.m[key] = value
+}
+
+// Value 读取之前步骤保存的数据，不存在时返回 nil
+func (tc *TestContext) Value(key string) interface{} {
+	tc.values.mu.Lock()
+	defer tc.values.mu.Unlock()
+	return tc.values.m[key]
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"fmt"
+	"sync"
+)
+
+// usesDependsOn 测试中是否有步骤声明了 DependsOn，未声明时每个步骤依赖上一个步骤
+func usesDependsOn(test MultiUserTest) bool {
+	for _, step := range test.Steps {
+		if len(step.DependsOn) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// withConfiguredDependsOn 返回使用 multiConfig.yaml 中配置的 dependsOn 替换代码中的依赖后的测试副本
+func withConfiguredDependsOn(test MultiUserTest) MultiUserTest {
+	configured, ok := ToTestMap[test.TestName]
+	if !ok {
+		return test
+	}
+	steps := make([]MultiUserTestStep, len(test.Steps))
+	for i, step := range test.Steps {
+		if c := configured[step.Name]; c.dependsOnSet {
+			step.DependsOn = c.DependsOn
+		}
+		steps[i] = step
+	}
+	test.Steps = steps
+	return test
+}
+
+// validateDependsOn 检查 DependsOn 引用的步骤存在且位于当前步骤之前，保证依赖无环且按顺序执行
+func validateDependsOn(test MultiUserTest) error {
+	before := make(map[string]struct{})
+	for _, step := range test.Steps {
+		for _, dep := range step.DependsOn {
+			if _, ok := before[dep]; !ok {
+				return fmt.Errorf("step %s of test %s depends on %s which is not a previous step", step.Name, test.TestName, dep)
+			}
+		}
+		before[step.Name] = struct{}{}
+	}
+	return nil
+}
+
+// stepState 已执行步骤的状态
+type stepState struct {
+	// blocked 步骤未成功完成，依赖它的步骤需要跳过
+	blocked bool
+	reason  string
+}
+
+// stepGraph 一个用户执行一个测试时的步骤依赖关系与执行状态
+type stepGraph struct {
+	deps map[string][]string
+	// explicit 测试声明了 DependsOn，依赖的步骤失败时总是跳过，不受 ContinueIfError 影响
+	explicit bool
+
+	mu     sync.Mutex
+	states map[string]stepState
+}
+
+// newStepGraph 按 multiConfig.yaml 覆盖后的依赖创建步骤依赖关系
+func newStepGraph(test MultiUserTest) *stepGraph {
+	test = withConfiguredDependsOn(test)
+	g := &stepGraph{deps: make(map[string][]string), states: make(map[string]stepState), explicit: usesDependsOn(test)}
+	for i, step := range test.Steps {
+		switch {
+		case g.explicit:
+			g.deps[step.Name] = step.DependsOn
+		case i > 0:
+			g.deps[step.Name] = []string{test.Steps[i-1].Name}
+		}
+	}
+	return g
+}
+
+// blockedBy 返回步骤需要跳过的原因，依赖的步骤未执行时不跳过
+func (g *stepGraph) blockedBy(step string) (string, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, dep := range g.deps[step] {
+		if s, ok := g.states[dep]; ok && s.blocked {
+			return fmt.Sprintf("depends on %q which %s", dep, s.reason), true
+		}
+	}
+	return "", false
+}
+
+// finish 记录步骤的执行状态，blocked 为 true 时 reason 说明原因
+func (g *stepGraph) finish(step string, blocked bool, reason string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.states[step] = stepState{blocked: blocked, reason: reason}
+}
+
+// anyBlocked 是否已有步骤未成功完成
+func (g *stepGraph) anyBlocked() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, s := range g.states {
+		if s.blocked {
+			return true
+		}
+	}
+	return false
+}
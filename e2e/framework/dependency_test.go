@@ -0,0 +1,247 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func steps(names ...string) []MultiUserTestStep {
+	out := make([]MultiUserTestStep, 0, len(names))
+	for _, name := range names {
+		out = append(out, MultiUserTestStep{Name: name})
+	}
+	return out
+}
+
+func TestNewStepGraph(t *testing.T) {
+	cases := []struct {
+		name         string
+		test         MultiUserTest
+		wantDeps     map[string][]string
+		wantExplicit bool
+	}{
+		{
+			name:     "implicit chain",
+			test:     MultiUserTest{Steps: steps("create", "get", "delete")},
+			wantDeps: map[string][]string{"get": {"create"}, "delete": {"get"}},
+		},
+		{
+			name:     "single step",
+			test:     MultiUserTest{Steps: steps("create")},
+			wantDeps: map[string][]string{},
+		},
+		{
+			name: "explicit",
+			test: MultiUserTest{Steps: []MultiUserTestStep{
+				{Name: "create"},
+				{Name: "get", DependsOn: []string{"create"}},
+				{Name: "audit"},
+				{Name: "delete", DependsOn: []string{"create", "get"}},
+			}},
+			wantDeps:     map[string][]string{"create": nil, "get": {"create"}, "audit": nil, "delete": {"create", "get"}},
+			wantExplicit: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			g := newStepGraph(c.test)
+			if g.explicit != c.wantExplicit {
+				t.Errorf("explicit = %v, want %v", g.explicit, c.wantExplicit)
+			}
+			if !reflect.DeepEqual(g.deps, c.wantDeps) {
+				t.Errorf("deps = %v, want %v", g.deps, c.wantDeps)
+			}
+		})
+	}
+}
+
+func TestConfiguredDependsOn(t *testing.T) {
+	test := MultiUserTest{TestName: "[1]deps", Steps: []MultiUserTestStep{
+		{Name: "create"},
+		{Name: "get", DependsOn: []string{"create"}},
+		{Name: "audit", DependsOn: []string{"create"}},
+		{Name: "delete", DependsOn: []string{"create"}},
+	}}
+	out, err := yaml.Marshal(MultiUserTestConfig{TestMap: []MultiUserTest{test}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), "dependsOn:") {
+		t.Fatalf("output config has no dependsOn:\n%s", out)
+	}
+
+	var cfg MultiUserTestConfig
+	err = yaml.Unmarshal([]byte(`
+testMap:
+  - testName: "[1]deps"
+    steps:
+      - name: audit
+        dependsOn: []
+      - name: delete
+        dependsOn: [create, get]
+`), &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := make(map[string]MultiUserTestStep)
+	for _, step := range cfg.TestMap[0].Steps {
+		m[step.Name] = step
+	}
+	ToTestMap[test.TestName] = m
+	t.Cleanup(func() { delete(ToTestMap, test.TestName) })
+
+	g := newStepGraph(test)
+	want := map[string][]string{"create": nil, "get": {"create"}, "audit": {}, "delete": {"create", "get"}}
+	if !reflect.DeepEqual(g.deps, want) {
+		t.Fatalf("deps = %v, want %v", g.deps, want)
+	}
+	if len(test.Steps[3].DependsOn) != 1 {
+		t.Fatalf("steps of the registered test changed: %v", test.Steps[3].DependsOn)
+	}
+
+	m["get"] = MultiUserTestStep{Name: "get", DependsOn: []string{"delete"}, dependsOnSet: true}
+	if err := validateDependsOn(withConfiguredDependsOn(test)); err == nil || !strings.Contains(err.Error(), "step get of test [1]deps depends on delete") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestValidateDependsOn(t *testing.T) {
+	cases := []struct {
+		name    string
+		steps   []MultiUserTestStep
+		wantErr string
+	}{
+		{name: "no dependsOn", steps: steps("a", "b")},
+		{name: "previous steps", steps: []MultiUserTestStep{
+			{Name: "a"}, {Name: "b", DependsOn: []string{"a"}}, {Name: "c", DependsOn: []string{"a", "b"}},
+		}},
+		{name: "unknown step", steps: []MultiUserTestStep{
+			{Name: "a"}, {Name: "b", DependsOn: []string{"x"}},
+		}, wantErr: "step b of test t depends on x which is not a previous step"},
+		{name: "later step", steps: []MultiUserTestStep{
+			{Name: "a", DependsOn: []string{"b"}}, {Name: "b"},
+		}, wantErr: "step a of test t depends on b"},
+		{name: "itself", steps: []MultiUserTestStep{
+			{Name: "a", DependsOn: []string{"a"}},
+		}, wantErr: "step a of test t depends on a"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateDependsOn(MultiUserTest{TestName: "t", Steps: c.steps})
+			if len(c.wantErr) == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Fatalf("err = %v, want %q", err, c.wantErr)
+			}
+		})
+	}
+}
+
+// runGraph 按 runUserStep 的方式依次执行步骤：依赖被阻塞的步骤跳过并阻塞依赖它的步骤，
+// failed 中的步骤执行失败，返回被跳过的步骤及原因
+func runGraph(g *stepGraph, test MultiUserTest, failed map[string]bool) map[string]string {
+	skipped := make(map[string]string)
+	for _, step := range test.Steps {
+		if reason, blocked := g.blockedBy(step.Name); blocked {
+			g.finish(step.Name, true, "was skipped ("+reason+")")
+			skipped[step.Name] = reason
+			continue
+		}
+		g.finish(step.Name, failed[step.Name], "failed")
+	}
+	return skipped
+}
+
+func TestStepGraphBlockedBy(t *testing.T) {
+	explicit := MultiUserTest{Steps: []MultiUserTestStep{
+		{Name: "create"},
+		{Name: "get", DependsOn: []string{"create"}},
+		{Name: "update", DependsOn: []string{"get"}},
+		{Name: "audit"},
+		{Name: "delete", DependsOn: []string{"create"}},
+	}}
+	cases := []struct {
+		name   string
+		test   MultiUserTest
+		failed map[string]bool
+		want   map[string]string
+	}{
+		{
+			name: "nothing failed",
+			test: explicit,
+			want: map[string]string{},
+		},
+		{
+			name:   "transitive",
+			test:   explicit,
+			failed: map[string]bool{"create": true},
+			want: map[string]string{
+				"get":    `depends on "create" which failed`,
+				"update": `depends on "get" which was skipped (depends on "create" which failed)`,
+				"delete": `depends on "create" which failed`,
+			},
+		},
+		{
+			name:   "independent steps keep running",
+			test:   explicit,
+			failed: map[string]bool{"get": true, "audit": true},
+			want:   map[string]string{"update": `depends on "get" which failed`},
+		},
+		{
+			name:   "implicit chain",
+			test:   MultiUserTest{Steps: steps("a", "b", "c")},
+			failed: map[string]bool{"a": true},
+			want: map[string]string{
+				"b": `depends on "a" which failed`,
+				"c": `depends on "b" which was skipped (depends on "a" which failed)`,
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			g := newStepGraph(c.test)
+			got := runGraph(g, c.test, c.failed)
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("skipped %v, want %v", got, c.want)
+			}
+			if g.anyBlocked() != (len(c.failed) > 0) {
+				t.Fatalf("anyBlocked = %v", g.anyBlocked())
+			}
+		})
+	}
+}
+
+func TestStepGraphNotRunDependency(t *testing.T) {
+	// 未执行（如未被 -select 选中）的依赖不阻塞步骤
+	g := newStepGraph(MultiUserTest{Steps: steps("a", "b")})
+	if reason, blocked := g.blockedBy("b"); blocked {
+		t.Fatalf("blocked by step not run: %s", reason)
+	}
+	g.finish("a", false, "")
+	if _, blocked := g.blockedBy("b"); blocked {
+		t.Fatal("blocked by successful step")
+	}
+}
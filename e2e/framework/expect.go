@@ -17,6 +17,7 @@ limitations under the License.
 package framework
 
 import (
+	"github.com/onsi/ginkgo"
 	"github.com/onsi/gomega"
 )
 
@@ -60,3 +61,69 @@ func ExpectHaveKey(actual interface{}, key interface{}, explain ...interface{})
 func ExpectEmpty(actual interface{}, explain ...interface{}) {
 	gomega.ExpectWithOffset(1, actual).To(gomega.BeEmpty(), explain...)
 }
+
+// 步骤内的断言应使用 TestContext 的方法：并发执行角色或重试步骤时，断言失败只交给执行该步骤的角色处理，
+// 不影响同一时间在其他 goroutine 中执行的断言
+
+// gomega 返回上下文的断言实例，未设置 failHandler 时与包级断言一致，失败时使当前用例失败
+func (tc *TestContext) gomega() gomega.Gomega {
+	if tc.failHandler == nil {
+		return gomega.Default
+	}
+	return gomega.NewGomega(tc.failHandler)
+}
+
+// withFailHandler 返回断言失败交给 fail 处理的上下文副本
+func (tc *TestContext) withFailHandler(fail func(message string, callerSkip ...int)) *TestContext {
+	c := *tc
+	c.failHandler = fail
+	return &c
+}
+
+// Fail 使当前步骤失败
+func (tc *TestContext) Fail(message string) {
+	if tc.failHandler == nil {
+		ginkgo.Fail(message, 1)
+	}
+	tc.failHandler(message, 1)
+}
+
+// ExpectEqual expects the specified two are the same, otherwise the step fails
+func (tc *TestContext) ExpectEqual(actual interface{}, extra interface{}, explain ...interface{}) {
+	tc.gomega().ExpectWithOffset(1, actual).To(gomega.Equal(extra), explain...)
+}
+
+// ExpectNotEqual expects the specified two are not the same, otherwise the step fails
+func (tc *TestContext) ExpectNotEqual(actual interface{}, extra interface{}, explain ...interface{}) {
+	tc.gomega().ExpectWithOffset(1, actual).NotTo(gomega.Equal(extra), explain...)
+}
+
+// ExpectError expects an error happens, otherwise the step fails
+func (tc *TestContext) ExpectError(err error, explain ...interface{}) {
+	tc.gomega().ExpectWithOffset(1, err).To(gomega.HaveOccurred(), explain...)
+}
+
+// ExpectNoError checks if "err" is set, and if so, fails the step while logging the error.
+func (tc *TestContext) ExpectNoError(err error, explain ...interface{}) {
+	tc.ExpectNoErrorWithOffset(1, err, explain...)
+}
+
+// ExpectNoErrorWithOffset checks if "err" is set, and if so, fails the step while logging the error at "offset" levels above its caller
+func (tc *TestContext) ExpectNoErrorWithOffset(offset int, err error, explain ...interface{}) {
+	tc.gomega().ExpectWithOffset(1+offset, err).NotTo(gomega.HaveOccurred(), explain...)
+}
+
+// ExpectConsistOf expects actual contains precisely the extra elements.  The ordering of the elements does not matter.
+func (tc *TestContext) ExpectConsistOf(actual interface{}, extra interface{}, explain ...interface{}) {
+	tc.gomega().ExpectWithOffset(1, actual).To(gomega.ConsistOf(extra), explain...)
+}
+
+// ExpectHaveKey expects the actual map has the key in the keyset
+func (tc *TestContext) ExpectHaveKey(actual interface{}, key interface{}, explain ...interface{}) {
+	tc.gomega().ExpectWithOffset(1, actual).To(gomega.HaveKey(key), explain...)
+}
+
+// ExpectEmpty expects actual is empty
+func (tc *TestContext) ExpectEmpty(actual interface{}, explain ...interface{}) {
+	tc.gomega().ExpectWithOffset(1, actual).To(gomega.BeEmpty(), explain...)
+}
@@ -0,0 +1,227 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	expectPass = "pass"
+	expectFail = "fail"
+)
+
+// Expectation 角色执行某一步骤的预期结果，在 multiConfig.yaml 中可以写为：
+//
+//	admin: pass                                 # 期望成功
+//	user: 403                                   # 期望失败且状态码为 403
+//	tenantAdmin: fail                           # 期望失败，由测试的 ErrorFunc 判断
+//	projectAdmin: {status: 403, message: deny}  # 期望失败，状态码为 403 且错误信息匹配正则 deny
+type Expectation struct {
+	Pass bool `yaml:"pass,omitempty"`
+	// StatusCode 期望的 http 状态码，为 0 时不检查
+	StatusCode int `yaml:"status,omitempty"`
+	// Message 错误信息需匹配的正则，为空时不检查
+	Message string `yaml:"message,omitempty"`
+	// message 加载配置或注册测试时编译的 Message
+	message *regexp.Regexp
+}
+
+// compile 编译 Message，加载配置与注册测试时调用，避免每次 Check 重复编译
+func (e *Expectation) compile() error {
+	if len(e.Message) == 0 {
+		e.message = nil
+		return nil
+	}
+	re, err := regexp.Compile(e.Message)
+	if err != nil {
+		return fmt.Errorf("invalid message pattern %q: %v", e.Message, err)
+	}
+	e.message = re
+	return nil
+}
+
+// compileExpectations 编译步骤中所有预期的 Message
+func compileExpectations(test string, step MultiUserTestStep) error {
+	for role, e := range step.Expect {
+		if err := e.compile(); err != nil {
+			return fmt.Errorf("test %s step %s role %s: %v", test, step.Name, role, err)
+		}
+		step.Expect[role] = e
+	}
+	return nil
+}
+
+// Detailed 是否声明了状态码或错误信息，未声明时失败的步骤交给测试的 ErrorFunc 判断
+func (e Expectation) Detailed() bool {
+	return e.StatusCode != 0 || len(e.Message) > 0
+}
+
+// String 与 multiConfig.yaml 中的简写一致
+func (e Expectation) String() string {
+	switch {
+	case e.Pass && !e.Detailed():
+		return expectPass
+	case !e.Pass && !e.Detailed():
+		return expectFail
+	case !e.Pass && len(e.Message) == 0:
+		return strconv.Itoa(e.StatusCode)
+	}
+	s := expectFail
+	if e.Pass {
+		s = expectPass
+	}
+	if e.StatusCode != 0 {
+		s += fmt.Sprintf(" status=%d", e.StatusCode)
+	}
+	if len(e.Message) > 0 {
+		s += fmt.Sprintf(" message=%q", e.Message)
+	}
+	return s
+}
+
+// Check 判断步骤结果是否符合预期，status 为步骤最后一次请求的状态码
+func (e Expectation) Check(resp TestResp, status int) error {
+	if e.Pass && resp.Err != nil {
+		return fmt.Errorf("expect pass but got error: %v", resp.Err)
+	}
+	if !e.Pass && resp.Err == nil {
+		return fmt.Errorf("expect %s but step succeeded", e)
+	}
+	if e.StatusCode != 0 && e.StatusCode != status {
+		return fmt.Errorf("expect status code %d but got %d", e.StatusCode, status)
+	}
+	if len(e.Message) > 0 {
+		re := e.message
+		if re == nil {
+			if err := e.compile(); err != nil {
+				return err
+			}
+			re = e.message
+		}
+		message := ""
+		if resp.Err != nil {
+			message = resp.Err.Error()
+		}
+		if data, ok := resp.Data.(string); ok {
+			message += "\n" + data
+		}
+		if !re.MatchString(message) {
+			return fmt.Errorf("expect message matching %q but got %q", e.Message, message)
+		}
+	}
+	return nil
+}
+
+func (e *Expectation) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.MappingNode {
+		type plain Expectation
+		if err := value.Decode((*plain)(e)); err != nil {
+			return err
+		}
+		// 未配置 pass 时与状态码的简写一致，由状态码推断是否期望成功
+		if !hasKey(value, "pass") {
+			e.Pass = IsSuccess(e.StatusCode)
+		} else if e.StatusCode != 0 && e.Pass != IsSuccess(e.StatusCode) {
+			return fmt.Errorf("line %d: status %d conflicts with pass: %v", value.Line, e.StatusCode, e.Pass)
+		}
+		if err := e.compile(); err != nil {
+			return fmt.Errorf("line %d: %v", value.Line, err)
+		}
+		return nil
+	}
+	if value.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: expectation should be pass, fail, a status code or a mapping", value.Line)
+	}
+	switch value.Value {
+	case expectPass, "true":
+		*e = Expectation{Pass: true}
+		return nil
+	case expectFail, "false":
+		*e = Expectation{}
+		return nil
+	}
+	code, err := strconv.Atoi(value.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: invalid expectation %q", value.Line, value.Value)
+	}
+	*e = Expectation{StatusCode: code, Pass: IsSuccess(code)}
+	return nil
+}
+
+// hasKey 返回 mapping 节点中是否配置了 key
+func hasKey(node *yaml.Node, key string) bool {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (e Expectation) MarshalYAML() (interface{}, error) {
+	switch {
+	case !e.Detailed():
+		return e.String(), nil
+	case len(e.Message) == 0 && e.Pass == IsSuccess(e.StatusCode):
+		return e.StatusCode, nil
+	}
+	type plain Expectation
+	return plain(e), nil
+}
+
+// Expectation 返回角色在某一步骤的预期结果，Expect 优先于 ExpectPass，
+// 都未声明时按 ExpectAs 查找，仍未找到时期望失败
+func (r *RoleRegistry) Expectation(step MultiUserTestStep, name string) Expectation {
+	seen := make(map[string]struct{})
+	for len(name) > 0 {
+		if e, ok := step.Expect[name]; ok {
+			return e
+		}
+		if pass, ok := step.ExpectPass[name]; ok {
+			return Expectation{Pass: pass}
+		}
+		seen[name] = struct{}{}
+		role, ok := r.Get(name)
+		if !ok {
+			return Expectation{}
+		}
+		if _, ok := seen[role.ExpectAs]; ok {
+			return Expectation{}
+		}
+		name = role.ExpectAs
+	}
+	return Expectation{}
+}
+
+// respStatus 返回步骤结果对应的状态码，依次取 TestResp.Data、apiserver 错误与最后一次请求的状态码
+func respStatus(tc *TestContext, resp TestResp) int {
+	if code, ok := resp.Data.(int); ok {
+		return code
+	}
+	var status apierrors.APIStatus
+	if resp.Err != nil && errors.As(resp.Err, &status) {
+		return int(status.Status().Code)
+	}
+	return tc.HttpHelper.LastStatus(tc.User)
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestExpectationUnmarshalYAML(t *testing.T) {
+	cases := []struct {
+		name    string
+		yaml    string
+		want    Expectation
+		wantErr string
+	}{
+		{name: "pass", yaml: "pass", want: Expectation{Pass: true}},
+		{name: "true", yaml: "true", want: Expectation{Pass: true}},
+		{name: "fail", yaml: "fail", want: Expectation{}},
+		{name: "false", yaml: "false", want: Expectation{}},
+		{name: "error status", yaml: "403", want: Expectation{StatusCode: 403}},
+		{name: "success status", yaml: "201", want: Expectation{Pass: true, StatusCode: 201}},
+		{name: "mapping", yaml: "{status: 403, message: deny}", want: Expectation{StatusCode: 403, Message: "deny"}},
+		{name: "mapping with pass", yaml: "{pass: true, status: 200}", want: Expectation{Pass: true, StatusCode: 200}},
+		{name: "mapping success status without pass", yaml: "{status: 201}", want: Expectation{Pass: true, StatusCode: 201}},
+		{name: "mapping message without pass", yaml: "{message: deny}", want: Expectation{Message: "deny"}},
+		{name: "mapping success status with pass false", yaml: "{pass: false, status: 201}", wantErr: "line 1: status 201 conflicts with pass: false"},
+		{name: "mapping error status with pass true", yaml: "{pass: true, status: 403}", wantErr: "line 1: status 403 conflicts with pass: true"},
+		{name: "unknown scalar", yaml: "maybe", wantErr: `line 1: invalid expectation "maybe"`},
+		{name: "sequence", yaml: "[pass]", wantErr: "line 1: expectation should be"},
+		{name: "invalid message pattern", yaml: "\n{status: 403, message: \"deny(\"}", wantErr: `line 2: invalid message pattern "deny("`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var got Expectation
+			err := yaml.Unmarshal([]byte(c.yaml), &got)
+			if len(c.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("err = %v, want %q", err, c.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Pass != c.want.Pass || got.StatusCode != c.want.StatusCode || got.Message != c.want.Message {
+				t.Fatalf("got %+v, want %+v", got, c.want)
+			}
+			if (got.message != nil) != (len(c.want.Message) > 0) {
+				t.Fatalf("message pattern compiled = %v", got.message != nil)
+			}
+		})
+	}
+}
+
+func TestExpectationMarshalYAML(t *testing.T) {
+	cases := []struct {
+		expect Expectation
+		want   string
+	}{
+		{expect: Expectation{Pass: true}, want: "pass"},
+		{expect: Expectation{}, want: "fail"},
+		{expect: Expectation{StatusCode: 403}, want: "403"},
+		{expect: Expectation{Pass: true, StatusCode: 201}, want: "201"},
+		{expect: Expectation{StatusCode: 403, Message: "deny"}, want: "status: 403\nmessage: deny"},
+	}
+	for _, c := range cases {
+		t.Run(c.want, func(t *testing.T) {
+			out, err := yaml.Marshal(c.expect)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSpace(string(out)); got != c.want {
+				t.Fatalf("got %q, want %q", got, c.want)
+			}
+			var back Expectation
+			if err = yaml.Unmarshal(out, &back); err != nil {
+				t.Fatal(err)
+			}
+			if back.String() != c.expect.String() {
+				t.Fatalf("round trip %s != %s", back, c.expect)
+			}
+		})
+	}
+}
+
+func TestExpectationCheck(t *testing.T) {
+	forbidden := errors.New(`admission webhook denied the request: quota exceeded`)
+	compiled := func(e Expectation) Expectation {
+		if err := e.compile(); err != nil {
+			t.Fatal(err)
+		}
+		return e
+	}
+	cases := []struct {
+		name    string
+		expect  Expectation
+		resp    TestResp
+		status  int
+		wantErr string
+	}{
+		{name: "pass", expect: Expectation{Pass: true}, resp: SucceedResp, status: 200},
+		{name: "pass but failed", expect: Expectation{Pass: true}, resp: NewTestResp(forbidden, nil), status: 403,
+			wantErr: "expect pass but got error"},
+		{name: "fail", expect: Expectation{}, resp: NewTestResp(forbidden, nil), status: 403},
+		{name: "fail but succeeded", expect: Expectation{}, resp: SucceedResp, status: 200,
+			wantErr: "expect fail but step succeeded"},
+		{name: "status matches", expect: Expectation{StatusCode: 403}, resp: NewTestResp(forbidden, nil), status: 403},
+		{name: "status differs", expect: Expectation{StatusCode: 403}, resp: NewTestResp(forbidden, nil), status: 404,
+			wantErr: "expect status code 403 but got 404"},
+		{name: "status of successful step", expect: Expectation{Pass: true, StatusCode: 201}, resp: SucceedResp, status: 200,
+			wantErr: "expect status code 201 but got 200"},
+		{name: "message matches error", expect: compiled(Expectation{Message: "quota (exceeded|overload)"}), resp: NewTestResp(forbidden, nil), status: 403},
+		{name: "message matches data", expect: compiled(Expectation{Message: "(?m)^forbidden by policy$"}),
+			resp: NewTestResp(errors.New("request failed"), "forbidden by policy"), status: 403},
+		{name: "message differs", expect: compiled(Expectation{StatusCode: 403, Message: "overload"}), resp: NewTestResp(forbidden, nil), status: 403,
+			wantErr: `expect message matching "overload"`},
+		{name: "message not compiled", expect: Expectation{Message: "denied"}, resp: NewTestResp(forbidden, nil), status: 403},
+		{name: "invalid message not compiled", expect: Expectation{Message: "denied("}, resp: NewTestResp(forbidden, nil), status: 403,
+			wantErr: `invalid message pattern "denied("`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.expect.Check(c.resp, c.status)
+			if len(c.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Fatalf("err = %v, want %q", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestCompileExpectations(t *testing.T) {
+	step := MultiUserTestStep{Name: "create", Expect: map[string]Expectation{
+		UserAdmin: {Message: "quota"},
+		"viewer":  {StatusCode: 403},
+	}}
+	if err := compileExpectations("test", step); err != nil {
+		t.Fatal(err)
+	}
+	if step.Expect[UserAdmin].message == nil {
+		t.Fatal("message pattern of admin is not compiled")
+	}
+
+	step.Expect["viewer"] = Expectation{Message: "("}
+	err := compileExpectations("test", step)
+	if err == nil || !strings.Contains(err.Error(), "test test step create role viewer") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestRoleRegistryExpectation(t *testing.T) {
+	roles, err := NewRoleRegistry([]*Role{
+		{Name: UserAdmin, Username: "admin"},
+		{Name: "auditor", Username: "auditor", ExpectAs: "viewer"},
+		{Name: "viewer", Username: "viewer"},
+		{Name: "loop-a", Username: "a", ExpectAs: "loop-b"},
+		{Name: "loop-b", Username: "b", ExpectAs: "loop-a"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	step := MultiUserTestStep{
+		ExpectPass: map[string]bool{UserAdmin: true, "viewer": true},
+		Expect:     map[string]Expectation{UserAdmin: {StatusCode: 403}},
+	}
+	cases := []struct {
+		role string
+		want string
+	}{
+		{role: UserAdmin, want: "403"},
+		{role: "viewer", want: "pass"},
+		{role: "auditor", want: "pass"},
+		{role: "loop-a", want: "fail"},
+		{role: "unknown", want: "fail"},
+	}
+	for _, c := range cases {
+		if got := roles.Expectation(step, c.role).String(); got != c.want {
+			t.Errorf("role %s: got %s, want %s", c.role, got, c.want)
+		}
+	}
+}
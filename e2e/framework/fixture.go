@@ -0,0 +1,361 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/multicluster"
+	mcclient "github.com/kubecube-io/kubecube/pkg/multicluster/client"
+	"github.com/kubecube-io/kubecube/pkg/utils/md5util"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// FixtureClusterAnnotation 对象所在的集群，pivot（默认）、target 或集群名
+	FixtureClusterAnnotation = "e2e.kubecube.io/cluster"
+	// FixtureSyncToAnnotation 对象由 KubeCube 同步到的集群，创建后等待对象出现在该集群中
+	FixtureSyncToAnnotation = "e2e.kubecube.io/sync-to"
+
+	fixtureAnnotationPrefix = "e2e.kubecube.io/"
+
+	FixtureClusterPivot  = "pivot"
+	FixtureClusterTarget = "target"
+)
+
+// FixtureData 渲染 fixture 模板的数据，可以直接引用 Config 中的字段，如 {{ .TenantName }}
+type FixtureData struct {
+	*Config
+	// Users 需要创建的非内置用户
+	Users []FixtureUser
+	// Targets 所有测试集群，每个集群需要各自的命名空间与配额
+	Targets []FixtureTarget
+	// PivotIsTarget 管控集群同时是测试集群
+	PivotIsTarget bool
+}
+
+// FixtureTarget 一个测试集群
+type FixtureTarget struct {
+	Name string
+	// CubeResourceQuota 测试租户在该集群中的 CubeResourceQuota 名
+	CubeResourceQuota string
+}
+
+// FixtureUser 非内置角色对应的用户
+type FixtureUser struct {
+	Role     string
+	Username string
+	// Password 加盐 md5 后的密码，与 KubeCube 中保存的格式一致
+	Password string
+	Bindings []RoleBinding
+}
+
+var fixtureFuncs = template.FuncMap{
+	"b64enc":  func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+	"quote":   strconv.Quote,
+	"lower":   strings.ToLower,
+	"md5salt": md5util.GetMD5Salt,
+}
+
+// FixtureObject fixture 中的一个对象
+type FixtureObject struct {
+	// Cluster 对象所在的集群
+	Cluster string
+	// SyncTo 对象由 KubeCube 同步到的集群，为空时不等待同步
+	SyncTo string
+	Object *unstructured.Unstructured
+}
+
+func (o *FixtureObject) String() string {
+	name := o.Object.GetName()
+	if ns := o.Object.GetNamespace(); len(ns) > 0 {
+		name = ns + "/" + name
+	}
+	return fmt.Sprintf("%s %s in %s", o.Object.GetKind(), name, o.Cluster)
+}
+
+// Fixture 由 yaml 声明的测试前置资源，按声明顺序创建，按相反的顺序删除，
+// 因此被依赖的对象需要写在前面
+type Fixture struct {
+	Name    string
+	Objects []*FixtureObject
+}
+
+// LoadFixture 以测试配置渲染 fixture 模板并解析其中的对象，空文档会被忽略
+func LoadFixture(name string, data []byte, cfg *Config) (*Fixture, error) {
+	tmpl, err := template.New(name).Funcs(fixtureFuncs).Option("missingkey=error").Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("parse fixture %s failed: %v", name, err)
+	}
+	var rendered bytes.Buffer
+	if err = tmpl.Execute(&rendered, newFixtureData(cfg)); err != nil {
+		return nil, fmt.Errorf("render fixture %s failed: %v", name, err)
+	}
+
+	f := &Fixture{Name: name}
+	decoder := utilyaml.NewYAMLOrJSONDecoder(&rendered, 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		err = decoder.Decode(&obj.Object)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decode fixture %s failed: %v", name, err)
+		}
+		if len(obj.Object) == 0 {
+			continue
+		}
+		if len(obj.GetKind()) == 0 || len(obj.GetName()) == 0 {
+			return nil, fmt.Errorf("object %d of fixture %s has no kind or name", len(f.Objects), name)
+		}
+
+		fo := &FixtureObject{Cluster: FixtureClusterPivot, Object: obj}
+		annotations := obj.GetAnnotations()
+		for k, v := range annotations {
+			switch k {
+			case FixtureClusterAnnotation:
+				fo.Cluster = v
+			case FixtureSyncToAnnotation:
+				fo.SyncTo = v
+			}
+			if strings.HasPrefix(k, fixtureAnnotationPrefix) {
+				delete(annotations, k)
+			}
+		}
+		if len(annotations) == 0 {
+			annotations = nil
+		}
+		obj.SetAnnotations(annotations)
+		labels := obj.GetLabels()
+		if labels == nil {
+			labels = make(map[string]string, 1)
+		}
+		labels[E2EFixtureLabel] = "true"
+		obj.SetLabels(labels)
+		f.Objects = append(f.Objects, fo)
+	}
+	return f, nil
+}
+
+func newFixtureData(cfg *Config) FixtureData {
+	data := FixtureData{Config: cfg}
+	for _, cluster := range cfg.TargetClusters {
+		data.Targets = append(data.Targets, FixtureTarget{Name: cluster, CubeResourceQuota: cubeResourceQuotaName(cluster, cfg.TenantName)})
+		if cluster == cfg.PivotClusterName {
+			data.PivotIsTarget = true
+		}
+	}
+	if cfg.Roles == nil {
+		return data
+	}
+	for _, role := range cfg.Roles.Roles() {
+		if role.Builtin {
+			continue
+		}
+		data.Users = append(data.Users, FixtureUser{
+			Role:     role.Name,
+			Username: role.Username,
+			Password: md5util.GetMD5Salt(role.Password),
+			Bindings: role.Bindings,
+		})
+	}
+	return data
+}
+
+// Apply 按顺序创建或更新 fixture 中的对象并等待就绪，已存在的对象会被更新为声明的内容。
+// 对象都带有 E2EFixtureLabel 标签，master 未执行 Teardown 就退出时由 cmd/clear -sweep 清理
+func (f *Fixture) Apply(tc *TestContext) error {
+	for _, o := range f.Objects {
+		cli, err := tc.clusterClient(o.Cluster)
+		if err != nil {
+			return err
+		}
+		clog.Info("[fixture] apply %s", o)
+		// 依赖的对象可能尚未由 KubeCube 创建完成（如租户空间），失败时在超时前重试
+		var lastErr error
+		err = wait.PollImmediate(tc.WaitInterval, tc.WaitTimeout, func() (bool, error) {
+			lastErr = createOrUpdate(cli.Direct(), o.Object)
+			return lastErr == nil, nil
+		})
+		if err != nil {
+			return fmt.Errorf("apply %s failed: %v", o, lastErr)
+		}
+		if err = tc.waitFixtureReady(o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Teardown 按相反的顺序删除 fixture 中的对象并等待删除完成，删除失败时继续删除其余对象
+func (f *Fixture) Teardown(tc *TestContext) error {
+	var errs []error
+	for i := len(f.Objects) - 1; i >= 0; i-- {
+		o := f.Objects[i]
+		cli, err := tc.clusterClient(o.Cluster)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		clog.Info("[fixture] delete %s", o)
+		obj := o.Object.DeepCopy()
+		err = cli.Direct().Delete(context.Background(), obj, ctrlclient.PropagationPolicy(metav1.DeletePropagationBackground))
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("delete %s failed: %v", o, err))
+			continue
+		}
+		err = wait.PollImmediate(tc.WaitInterval, tc.WaitTimeout, func() (bool, error) {
+			err := cli.Direct().Get(context.Background(), ctrlclient.ObjectKeyFromObject(obj), obj)
+			return apierrors.IsNotFound(err), nil
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("wait %s deleted failed: %v", o, err))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// createOrUpdate 对象不存在时创建，存在时将声明的字段与标签、注解合并到已有对象后更新
+func createOrUpdate(cli ctrlclient.Client, desired *unstructured.Unstructured) error {
+	ctx := context.Background()
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(desired.GroupVersionKind())
+	err := cli.Get(ctx, ctrlclient.ObjectKeyFromObject(desired), existing)
+	if apierrors.IsNotFound(err) {
+		return cli.Create(ctx, desired.DeepCopy())
+	}
+	if err != nil {
+		return err
+	}
+
+	merged := existing.DeepCopy()
+	for k, v := range desired.Object {
+		if k == "metadata" || k == "status" {
+			continue
+		}
+		merged.Object[k] = v
+	}
+	merged.SetLabels(mergeStringMap(existing.GetLabels(), desired.GetLabels()))
+	merged.SetAnnotations(mergeStringMap(existing.GetAnnotations(), desired.GetAnnotations()))
+	if equality.Semantic.DeepEqual(merged, existing) {
+		return nil
+	}
+	return cli.Update(ctx, merged)
+}
+
+func mergeStringMap(base, override map[string]string) map[string]string {
+	if len(override) == 0 {
+		return base
+	}
+	merged := make(map[string]string, len(base)+len(override))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range override {
+		merged[k] = v
+	}
+	return merged
+}
+
+// waitFixtureReady 等待对象就绪：命名空间为 Active，包含 Ready condition 的对象为 True，
+// 声明了同步集群的对象出现在该集群中
+func (tc *TestContext) waitFixtureReady(o *FixtureObject) error {
+	cli, err := tc.clusterClient(o.Cluster)
+	if err != nil {
+		return err
+	}
+	obj := o.Object.DeepCopy()
+	err = wait.PollImmediate(tc.WaitInterval, tc.WaitTimeout, func() (bool, error) {
+		if err := cli.Direct().Get(context.Background(), ctrlclient.ObjectKeyFromObject(obj), obj); err != nil {
+			return false, nil
+		}
+		return fixtureObjectReady(obj), nil
+	})
+	if err != nil {
+		return fmt.Errorf("wait %s ready failed: %v", o, err)
+	}
+	if len(o.SyncTo) == 0 {
+		return nil
+	}
+
+	syncCli, err := tc.clusterClient(o.SyncTo)
+	if err != nil {
+		return err
+	}
+	err = wait.PollImmediate(tc.WaitInterval, tc.WaitTimeout, func() (bool, error) {
+		synced := &unstructured.Unstructured{}
+		synced.SetGroupVersionKind(obj.GroupVersionKind())
+		err := syncCli.Direct().Get(context.Background(), ctrlclient.ObjectKeyFromObject(obj), synced)
+		return err == nil, nil
+	})
+	if err != nil {
+		return fmt.Errorf("wait %s synced to %s failed: %v", o, o.SyncTo, err)
+	}
+	return nil
+}
+
+func fixtureObjectReady(obj *unstructured.Unstructured) bool {
+	if obj.GetKind() == "Namespace" {
+		phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+		return phase == "Active"
+	}
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if ok && condition["type"] == "Ready" {
+			return condition["status"] == "True"
+		}
+	}
+	return true
+}
+
+// clusterClient 返回集群对应的客户端，cluster 可以是 pivot、target（当前测试集群）或集群名
+func (tc *TestContext) clusterClient(cluster string) (mcclient.Client, error) {
+	switch cluster {
+	case "", FixtureClusterPivot, tc.PivotClusterName:
+		return tc.PivotClusterClient, nil
+	case FixtureClusterTarget, tc.TargetClusterName:
+		return tc.TargetClusterClient, nil
+	}
+	if target, ok := tc.targets[cluster]; ok {
+		return target.cluster, nil
+	}
+	cli, err := multicluster.Interface().GetClient(cluster)
+	if err != nil {
+		return nil, fmt.Errorf("get client of cluster %s failed: %v", cluster, err)
+	}
+	return cli, nil
+}
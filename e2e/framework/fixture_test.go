@@ -0,0 +1,79 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"os"
+	"testing"
+)
+
+// TestLoadFixtureLabels 内置 fixture 的每个对象都带有 ArtifactLabels 中的标签，master 异常退出后可以被 cmd/clear -sweep 找到
+func TestLoadFixtureLabels(t *testing.T) {
+	data, err := os.ReadFile("../mock/fixture.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	roles, err := NewRoleRegistry([]*Role{
+		{Name: UserAdmin, Username: "admin", Password: "admin-pw", Builtin: true},
+		{Name: UserTenantAdmin, Username: "tenant", Password: "tenant-pw", Bindings: []RoleBinding{{Scope: BindingScopeTenant, ClusterRole: "tenant-admin"}}},
+		{Name: UserProjectAdmin, Username: "project", Password: "project-pw", Bindings: []RoleBinding{{Scope: BindingScopeProject, ClusterRole: "project-admin"}}},
+		{Name: "auditor", Username: "auditor", Password: "auditor-pw", Bindings: []RoleBinding{{Scope: BindingScopeCluster, ClusterRole: "reviewer"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{
+		PivotClusterName: "pivot-cluster",
+		TargetClusters:   []string{"pivot-cluster", "member1"},
+		TenantName:       "e2e-tenant",
+		ProjectName:      "e2e-project",
+		NamespaceName:    "e2e-ns",
+		ImagePullSecret:  "e2e-registry",
+		Roles:            roles,
+	}
+	f, err := LoadFixture("fixture.yaml", data, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kinds := make(map[string]int)
+	for _, o := range f.Objects {
+		kinds[o.Object.GetKind()]++
+		labels := o.Object.GetLabels()
+		if labels[E2EFixtureLabel] != "true" {
+			t.Errorf("%s has no %s label: %v", o, E2EFixtureLabel, labels)
+		}
+		found := false
+		for _, label := range ArtifactLabels {
+			if _, ok := labels[label]; ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("%s has none of artifact labels %v", o, ArtifactLabels)
+		}
+	}
+	for _, kind := range []string{"Tenant", "Project", "User", "RoleBinding", "ClusterRoleBinding", "CubeResourceQuota", "Namespace", "ResourceQuota", "Secret"} {
+		if kinds[kind] == 0 {
+			t.Errorf("fixture has no %s, got %v", kind, kinds)
+		}
+	}
+	if kinds["User"] != 3 {
+		t.Errorf("fixture has %d users, want 3 non-builtin users", kinds["User"])
+	}
+}
@@ -18,28 +18,25 @@ package framework
 
 import (
 	"context"
-	"encoding/base64"
 	"fmt"
-	"github.com/kubecube-io/kubecube/pkg/conversion"
 	"os"
 	"time"
 
 	e2econstants "github.com/kubecube-io/kubecube-e2e/util/constants"
 	"github.com/kubecube-io/kubecube/pkg/clog"
 	"github.com/kubecube-io/kubecube/pkg/multicluster"
-	"github.com/kubecube-io/kubecube/pkg/multicluster/client"
 	"github.com/spf13/viper"
-	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	controllerruntime "sigs.k8s.io/controller-runtime"
-	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-var (
+// Config 测试配置，由 config.yaml 读取
+type Config struct {
 	// cluster
+	// TargetClusterName 当前测试集群，默认为 TargetClusters 中的第一个，ForCluster 返回其他集群的上下文
 	TargetClusterName string
-	PivotClusterName  string
+	// TargetClusters 所有测试集群，测试在每个集群中依次执行
+	TargetClusters   []string
+	PivotClusterName string
 	// quota
 	CubeResourceQuota string
 	// host
@@ -49,23 +46,18 @@ var (
 	TenantName    string
 	ProjectName   string
 	NamespaceName string
-	// user
-	Admin                string
-	AdminPassword        string
-	TenantAdmin          string
-	TenantAdminPassword  string
-	ProjectAdmin         string
-	ProjectAdminPassword string
-	User                 string
-	UserPassword         string
+	// Roles 参与测试的角色
+	Roles *RoleRegistry
 	// timeout
 	WaitInterval       time.Duration
 	WaitTimeout        time.Duration
 	HttpRequestTimeout time.Duration
-	// pv
-	PVEnabled bool
-	// CloudShellEnabled cloudShell
-	CloudShellEnabled bool
+	// coordination master 与 worker 通过 Lease 协调
+	LeaseDuration  time.Duration
+	InitTimeout    time.Duration
+	WorkersTimeout time.Duration
+	// Capabilities config.yaml 中显式开启或关闭的能力，未配置的能力由预检查探测
+	Capabilities map[string]bool
 	// workload
 	CronJobEnable     bool
 	DaemonSetEnable   bool
@@ -82,24 +74,34 @@ var (
 	Username string
 	Password string
 	Email    string
-	// PivotClusterClient communicate with pivot cluster
-	PivotClusterClient client.Client
-	PivotConvertClient ctrlclient.Client
-
-	// TargetClusterClient communicate with target cluster
-	TargetClusterClient client.Client
-	TargetConvertClient ctrlclient.Client
 
 	KubeCubeSystem string
 	KubeCubeE2ECM  string
 	LoginType      string
-)
+	// login
+	LDAP LDAPConfig
+	OIDC OIDCConfig
+	// ArtifactsDir 测试产物目录，为空时不输出
+	ArtifactsDir string
+	// HAREnabled 为每个用例输出 HAR 文件
+	HAREnabled bool
+	// SnapshotMode 测试前后共享资源不一致时的处理方式：off、warn 或 fail
+	SnapshotMode string
+	// SnapshotKinds 测试前后需要比较的资源类型
+	SnapshotKinds []SnapshotKind
+	// FixtureFile 测试前置资源的 fixture 文件，为空时使用内置的 mock/fixture.yaml
+	FixtureFile string
+	// TestdataDir 覆盖 fixtures 包内置清单模板的目录，目录中的同名文件优先使用
+	TestdataDir string
+	// ValidateTestdata 发送前以测试集群的 OpenAPI schema 校验渲染后的清单
+	ValidateTestdata bool
+}
 
-// InitGlobalV 初始化全局变量
-func InitGlobalV() error {
+// InitGlobalV 读取配置并初始化测试上下文
+func InitGlobalV() (*TestContext, error) {
 	err := readEnvConfig()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	clogConfig := clog.Config{
 		LogFile:         "/etc/logs/cube.log",
@@ -115,103 +117,168 @@ func InitGlobalV() error {
 	if v != nil {
 		err = v.Unmarshal(&clogConfig)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 	clog.InitCubeLoggerWithOpts(&clogConfig)
 
+	cfg, err := newConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = StartMultiCluster(); err != nil {
+		return nil, err
+	}
+
+	return NewTestContext(cfg)
+}
+
+// StartMultiCluster 启动 multicluster 管理器，同步 KubeCube 纳管的所有集群
+func StartMultiCluster() error {
+	restCfg := controllerruntime.GetConfigOrDie()
+	mgr, err := multicluster.NewSyncMgrWithDefaultSetting(restCfg, false)
+	if err != nil {
+		return err
+	}
+	return mgr.Start(context.Background())
+}
+
+// InitDryRunContext 只读取配置创建测试上下文，不连接集群也不登录，用于 -dryRun 生成执行计划
+func InitDryRunContext() (*TestContext, error) {
+	err := readEnvConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := newConfig()
+	if err != nil {
+		return nil, err
+	}
+	return newTestContext(cfg), nil
+}
+
+// newConfig 将 viper 中的配置读取为 Config
+func newConfig() (*Config, error) {
+	cfg := &Config{}
 	// host
-	KubecubeHost = viper.GetString("host.kubecubeHost")
-	ConsoleHost = viper.GetString("host.consoleHost")
+	cfg.KubecubeHost = viper.GetString("host.kubecubeHost")
+	cfg.ConsoleHost = viper.GetString("host.consoleHost")
 	// cluster
-	TargetClusterName = viper.GetString("e2eInit.targetCluster")
-	PivotClusterName = viper.GetString("e2eInit.pivotCluster")
+	cfg.TargetClusters = viper.GetStringSlice("e2eInit.targetClusters")
+	if len(cfg.TargetClusters) == 0 {
+		cfg.TargetClusters = []string{viper.GetString("e2eInit.targetCluster")}
+	}
+	cfg.TargetClusterName = cfg.TargetClusters[0]
+	cfg.PivotClusterName = viper.GetString("e2eInit.pivotCluster")
 	// tenant
-	TenantName = viper.GetString("e2eInit.tenant")
-	ProjectName = viper.GetString("e2eInit.project")
-	NamespaceName = viper.GetString("e2eInit.namespace")
+	cfg.TenantName = viper.GetString("e2eInit.tenant")
+	cfg.ProjectName = viper.GetString("e2eInit.project")
+	cfg.NamespaceName = viper.GetString("e2eInit.namespace")
+	cfg.FixtureFile = viper.GetString("e2eInit.fixture")
 	// user
-	Admin = viper.GetString("e2eInit.multiuser.admin")
-	AdminPassword = viper.GetString("e2eInit.multiuser.adminPassword")
-	TenantAdmin = viper.GetString("e2eInit.multiuser.tenantAdmin")
-	TenantAdminPassword = viper.GetString("e2eInit.multiuser.tenantAdminPassword")
-	ProjectAdmin = viper.GetString("e2eInit.multiuser.projectAdmin")
-	ProjectAdminPassword = viper.GetString("e2eInit.multiuser.projectAdminPassword")
-	User = viper.GetString("e2eInit.multiuser.user")
-	UserPassword = viper.GetString("e2eInit.multiuser.userPassword")
+	roles, err := loadRoles()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Roles = roles
 	// timeout
-	WaitInterval = time.Duration(viper.GetInt("timeout.waitInterval")) * time.Second
-	WaitTimeout = time.Duration(viper.GetInt("timeout.waitTimeout")) * time.Second
-	HttpRequestTimeout = time.Duration(viper.GetInt("timeout.httpRequestTimeout")) * time.Second
-	// pv
-	PVEnabled = viper.GetBool("pv.enabled")
-
-	CubeResourceQuota = TargetClusterName + "." + TenantName
+	cfg.WaitInterval = time.Duration(viper.GetInt("timeout.waitInterval")) * time.Second
+	cfg.WaitTimeout = time.Duration(viper.GetInt("timeout.waitTimeout")) * time.Second
+	cfg.HttpRequestTimeout = time.Duration(viper.GetInt("timeout.httpRequestTimeout")) * time.Second
+	// coordination
+	cfg.LeaseDuration = secondsOrDefault("coordination.leaseDuration", 30*time.Second)
+	cfg.InitTimeout = secondsOrDefault("coordination.initTimeout", 10*time.Minute)
+	cfg.WorkersTimeout = secondsOrDefault("coordination.workersTimeout", 20*time.Minute)
+	cfg.Capabilities = make(map[string]bool)
+	for name, key := range map[string]string{CapabilityPV: "pv.enabled", CapabilityCloudShell: "cloudshell.enabled"} {
+		if viper.IsSet(key) {
+			cfg.Capabilities[name] = viper.GetBool(key)
+		}
+	}
 
-	CloudShellEnabled = viper.GetBool("cloudshell.enabled")
+	cfg.CubeResourceQuota = cubeResourceQuotaName(cfg.TargetClusterName, cfg.TenantName)
 	// workload
-	CronJobEnable = viper.GetBool("workload.cronjob")
-	DaemonSetEnable = viper.GetBool("workload.daemonSet")
-	DeploymentEnable = viper.GetBool("workload.deployment")
-	JobEnable = viper.GetBool("workload.job")
-	StatefulSetEnable = viper.GetBool("workload.statefulSet")
-	NodeHostName = viper.GetString("workload.nodeHostName")
-	NodeHostIp = viper.GetString("workload.nodeHostIp")
-	TestImage = viper.GetString("image.testImage")
-	StorageClass = viper.GetString("workload.storageClass")
-	if TestImage == "" {
-		return fmt.Errorf("test image value can not be empty")
+	cfg.CronJobEnable = viper.GetBool("workload.cronjob")
+	cfg.DaemonSetEnable = viper.GetBool("workload.daemonSet")
+	cfg.DeploymentEnable = viper.GetBool("workload.deployment")
+	cfg.JobEnable = viper.GetBool("workload.job")
+	cfg.StatefulSetEnable = viper.GetBool("workload.statefulSet")
+	cfg.NodeHostName = viper.GetString("workload.nodeHostName")
+	cfg.NodeHostIp = viper.GetString("workload.nodeHostIp")
+	cfg.TestImage = viper.GetString("image.testImage")
+	cfg.StorageClass = viper.GetString("workload.storageClass")
+	if cfg.TestImage == "" {
+		return nil, fmt.Errorf("test image value can not be empty")
 	}
-	if StorageClass == "" {
-		StorageClass = "localstorage-class"
+	if cfg.StorageClass == "" {
+		cfg.StorageClass = "localstorage-class"
 	}
-	ImagePullSecret = "harbor-qingzhou"
-	Registry = viper.GetString("hub.registry")
-	Username = viper.GetString("hub.username")
-	Password = viper.GetString("hub.password")
-	Email = viper.GetString("hub.email")
+	cfg.ImagePullSecret = "harbor-qingzhou"
+	cfg.Registry = viper.GetString("hub.registry")
+	cfg.Username = viper.GetString("hub.username")
+	cfg.Password = viper.GetString("hub.password")
+	cfg.Email = viper.GetString("hub.email")
 
-	KubeCubeSystem = viper.GetString("sys.namespace")
-	KubeCubeE2ECM = viper.GetString("sys.cm-name")
-	LoginType = viper.GetString("sys.login-type")
-	if len(LoginType) == 0 {
-		LoginType = e2econstants.GeneralLoginType
+	cfg.KubeCubeSystem = viper.GetString("sys.namespace")
+	cfg.KubeCubeE2ECM = viper.GetString("sys.cm-name")
+	cfg.LoginType = viper.GetString("sys.login-type")
+	if len(cfg.LoginType) == 0 {
+		cfg.LoginType = e2econstants.GeneralLoginType
 	}
-
-	cfg := controllerruntime.GetConfigOrDie()
-	mgr, err := multicluster.NewSyncMgrWithDefaultSetting(cfg, false)
-	if err != nil {
-		return err
+	cfg.ArtifactsDir = viper.GetString("artifacts.dir")
+	cfg.HAREnabled = viper.GetBool("artifacts.har") && len(cfg.ArtifactsDir) > 0
+	cfg.SnapshotMode = viper.GetString("snapshot.mode")
+	switch cfg.SnapshotMode {
+	case "":
+		cfg.SnapshotMode = SnapshotWarn
+	case SnapshotOff, SnapshotWarn, SnapshotFail:
+	default:
+		return nil, fmt.Errorf("unknown snapshot mode %q", cfg.SnapshotMode)
 	}
-	err = mgr.Start(context.Background())
-	if err != nil {
-		return err
+	if err = viper.UnmarshalKey("snapshot.kinds", &cfg.SnapshotKinds); err != nil {
+		return nil, err
 	}
-
-	cli, err := multicluster.Interface().GetClient(PivotClusterName)
-	if err != nil {
-		return fmt.Errorf("get pivot client failed: %v", err)
+	if len(cfg.SnapshotKinds) == 0 {
+		cfg.SnapshotKinds = DefaultSnapshotKinds
 	}
-	PivotClusterClient = cli
-	convertor, err := conversion.NewVersionConvertor(PivotClusterClient.CacheDiscovery(), PivotClusterClient.RESTMapper())
-	if err != nil {
-		clog.Error("init client convert error, error: %s", err.Error())
-		return nil
+	cfg.TestdataDir = viper.GetString("testdata.dir")
+	cfg.ValidateTestdata = !viper.IsSet("testdata.validate") || viper.GetBool("testdata.validate")
+	if err = viper.UnmarshalKey("login.ldap", &cfg.LDAP); err != nil {
+		return nil, err
 	}
-	PivotConvertClient = conversion.WrapClient(PivotClusterClient.Direct(), convertor, true)
+	if err = viper.UnmarshalKey("login.oidc", &cfg.OIDC); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
 
-	cli2, err := multicluster.Interface().GetClient(TargetClusterName)
-	if cli2 == nil {
-		return fmt.Errorf("get tatget client failed: %v", err)
+// cubeResourceQuotaName 测试租户在集群中的 CubeResourceQuota 名
+func cubeResourceQuotaName(cluster, tenant string) string {
+	return cluster + "." + tenant
+}
+
+// forCluster 返回以 cluster 为测试集群的配置副本
+func (c *Config) forCluster(cluster string) *Config {
+	cfg := *c
+	cfg.TargetClusterName = cluster
+	cfg.CubeResourceQuota = cubeResourceQuotaName(cluster, c.TenantName)
+	return &cfg
+}
+
+// secondsOrDefault 读取以秒为单位的配置，未配置时返回默认值
+func secondsOrDefault(key string, def time.Duration) time.Duration {
+	if seconds := viper.GetInt(key); seconds > 0 {
+		return time.Duration(seconds) * time.Second
 	}
-	TargetClusterClient = cli2
-	convertor, err = conversion.NewVersionConvertor(TargetClusterClient.CacheDiscovery(), TargetClusterClient.RESTMapper())
-	if err != nil {
-		clog.Error("init client convert error, error: %s", err.Error())
-		return nil
+	return def
+}
+
+// GetUser 根据角色获取对应的用户名，未知角色返回默认角色的用户名
+func (c *Config) GetUser(role string) string {
+	if r, ok := c.Roles.Get(role); ok {
+		return r.Username
 	}
-	TargetConvertClient = conversion.WrapClient(TargetClusterClient.Direct(), convertor, true)
-	return nil
+	return c.Roles.Default().Username
 }
 
 // readEnvConfig read params from config
@@ -237,41 +304,3 @@ func readEnvConfig() error {
 
 	return nil
 }
-
-func CreateSecret() error {
-	auth := Username + ":" + Password
-	auth = base64.StdEncoding.EncodeToString([]byte(auth))
-	data := `{"auths":{"%s":{"username":"%s","password":"%s","email":"%s","auth":"%s"}}}`
-	data = fmt.Sprintf(data, Registry, Username, Password, Email, auth)
-	secret := corev1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      ImagePullSecret,
-			Namespace: NamespaceName,
-		},
-		Type: corev1.SecretTypeDockerConfigJson,
-		Data: map[string][]byte{
-			corev1.DockerConfigJsonKey: []byte(data),
-		},
-	}
-	err := TargetClusterClient.Direct().Create(context.TODO(), &secret)
-	if err != nil && !errors.IsAlreadyExists(err) {
-		clog.Error("create secret in target cluster fail, secret: %+v, err: %s", secret, err.Error())
-		return err
-	}
-	secret = corev1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      ImagePullSecret,
-			Namespace: NamespaceName,
-		},
-		Type: corev1.SecretTypeDockerConfigJson,
-		Data: map[string][]byte{
-			corev1.DockerConfigJsonKey: []byte(data),
-		},
-	}
-	err = PivotClusterClient.Direct().Create(context.TODO(), &secret)
-	if err != nil && !errors.IsAlreadyExists(err) {
-		clog.Error("create secret in pivot cluster fail, secret: %+v, err: %s", secret, err.Error())
-		return err
-	}
-	return nil
-}
@@ -25,7 +25,7 @@ type TestResp struct {
 	Data interface{}
 }
 
-type TestFunc func(user string) TestResp
+type TestFunc func(tc *TestContext) TestResp
 
 var SucceedResp = TestResp{
 	Err:  nil,
@@ -50,36 +50,31 @@ func NewTestRespWithErr(err error) TestResp {
 	}
 }
 
-func DefaultErrorFunc(resp TestResp) {
+func DefaultErrorFunc(tc *TestContext, resp TestResp) {
 	if resp.Err != nil {
 		clog.Info(resp.Err.Error())
 	}
-	ExpectError(resp.Err, "should be error")
+	tc.ExpectError(resp.Err, "should be error")
 }
 
-func DefaultSkipFunc() bool {
+func DefaultSkipFunc(tc *TestContext) bool {
 	return false
 }
 
-func PermissionErrorFunc(resp TestResp) {
+func PermissionErrorFunc(tc *TestContext, resp TestResp) {
 	clog.Info("res code %v", resp)
-	ExpectEqual(resp.Data, 403)
+	tc.ExpectEqual(resp.Data, 403)
 }
 
 func NameWithUser(name, user string) string {
 	return name + "-" + user
 }
 
+// RegisterByDefault 注册测试，测试用例在 CreateTestExamples 时生成
 func RegisterByDefault(test MultiUserTest) {
 	err := RegisterTestAndSteps(test)
 	if err != nil {
 		clog.Error("fail to register test steps with err %s \n", err.Error())
 		return
 	}
-
-	err = CreateTestExample(test)
-	if err != nil {
-		clog.Error("fail to create tests with err %s \n", err.Error())
-		return
-	}
 }
@@ -27,10 +27,13 @@ import (
 
 var (
 	httpclient   = http.DefaultClient
-	generalLogin = &GeneralLogin{}
+	generalLogin = &GeneralLogin{loginType: "normal"}
 )
 
-type GeneralLogin struct{}
+// GeneralLogin 通过 KubeCube 登录接口以用户名密码登录，loginType 决定由 KubeCube 本地校验还是交由 LDAP 校验
+type GeneralLogin struct {
+	loginType string
+}
 
 func init() {
 	Register(constants.GeneralLoginType, generalLogin)
@@ -40,18 +43,18 @@ func (g *GeneralLogin) AuthHeader() string {
 	return constants.AuthorizationHeader
 }
 
-func (g *GeneralLogin) LoginByUser(user *AuthUser) error {
+func (g *GeneralLogin) LoginByUser(host string, user *AuthUser) error {
 	postBody := map[string]string{
 		"name":      user.Username,
 		"password":  user.Password,
-		"loginType": "normal",
+		"loginType": g.loginType,
 	}
 	postBodyJson, err := json.Marshal(postBody)
 	if err != nil {
 		clog.Error("login fail, marshal post body fail, %v", err)
 		return err
 	}
-	url := fmt.Sprintf("%s/%s", KubecubeHost, "/api/v1/cube/login")
+	url := fmt.Sprintf("%s/%s", host, "/api/v1/cube/login")
 	req, err := BuildRequest(http.MethodPost, url, string(postBodyJson), nil)
 	if err != nil {
 		clog.Error("login fail, error: %v", err)
@@ -62,6 +65,11 @@ func (g *GeneralLogin) LoginByUser(user *AuthUser) error {
 		clog.Error("login fail, error: %v", err)
 		return err
 	}
+	defer resp.Body.Close()
+	if !IsSuccess(resp.StatusCode) {
+		clog.Error("login fail, status code: %d", resp.StatusCode)
+		return loginStatusError(resp.StatusCode, fmt.Errorf("login fail, status code: %d", resp.StatusCode))
+	}
 	cookies := resp.Cookies()
 	if len(cookies) < 1 {
 		clog.Error("get cookie error")
@@ -0,0 +1,537 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/onsi/ginkgo"
+)
+
+const (
+	harVersion     = "1.2"
+	harCreatorName = "kubecube-e2e"
+	// harRedacted 敏感信息替换后的值
+	harRedacted = "REDACTED"
+	// harMaxBodySize 单个请求或响应记录的最大 body 长度，超出部分截断
+	harMaxBodySize = 1 << 20
+)
+
+// harRedactedHeaders 需要脱敏的请求头与响应头
+var harRedactedHeaders = map[string]struct{}{
+	"Authorization": {},
+	"Cookie":        {},
+	"Set-Cookie":    {},
+	"X-Auth-Token":  {},
+}
+
+// harRedactedParams 需要脱敏的 query 参数与表单字段
+var harRedactedParams = map[string]struct{}{
+	"accesskey": {},
+	"secretkey": {},
+	"token":     {},
+	"password":  {},
+}
+
+// harRedactedFields 需要脱敏的 JSON 字段，不区分大小写，Secret 的 data 与 stringData 整体脱敏
+var harRedactedFields = map[string]struct{}{
+	"password":          {},
+	"bindpassword":      {},
+	"accesskey":         {},
+	"secretkey":         {},
+	"token":             {},
+	"access_token":      {},
+	"id_token":          {},
+	"refresh_token":     {},
+	"client_secret":     {},
+	".dockerconfigjson": {},
+	".dockercfg":        {},
+}
+
+type harLog struct {
+	Log harContent `json:"log"`
+}
+
+type harContent struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Pages   []harPage  `json:"pages"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harPage struct {
+	StartedDateTime time.Time      `json:"startedDateTime"`
+	ID              string         `json:"id"`
+	Title           string         `json:"title"`
+	PageTimings     harPageTimings `json:"pageTimings"`
+	Comment         string         `json:"comment,omitempty"`
+}
+
+type harPageTimings struct {
+	OnContentLoad float64 `json:"onContentLoad"`
+	OnLoad        float64 `json:"onLoad"`
+}
+
+type harEntry struct {
+	PageRef         string      `json:"pageref"`
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	// User 发起请求的用户，HAR 规范允许以下划线开头的自定义字段
+	User  string `json:"_user,omitempty"`
+	Error string `json:"_error,omitempty"`
+}
+
+type harRequest struct {
+	Method      string       `json:"method"`
+	URL         string       `json:"url"`
+	HTTPVersion string       `json:"httpVersion"`
+	Cookies     []harNameVal `json:"cookies"`
+	Headers     []harNameVal `json:"headers"`
+	QueryString []harNameVal `json:"queryString"`
+	PostData    *harPostData `json:"postData,omitempty"`
+	HeadersSize int          `json:"headersSize"`
+	BodySize    int          `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int          `json:"status"`
+	StatusText  string       `json:"statusText"`
+	HTTPVersion string       `json:"httpVersion"`
+	Cookies     []harNameVal `json:"cookies"`
+	Headers     []harNameVal `json:"headers"`
+	Content     harBody      `json:"content"`
+	RedirectURL string       `json:"redirectURL"`
+	HeadersSize int          `json:"headersSize"`
+	BodySize    int          `json:"bodySize"`
+}
+
+type harNameVal struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harBody struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// harRecord 录制中的请求，响应 body 在被读取时才写入
+type harRecord struct {
+	entry harEntry
+	body  *harCapture
+	// waitEnd 收到响应头的时间
+	waitEnd time.Time
+}
+
+type harUserKey struct{}
+
+// withHARUser 在请求上下文中记录发起请求的用户
+func withHARUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, harUserKey{}, user)
+}
+
+// HARRecorder 记录经过的 http 请求并输出为 HAR 文件，
+// 请求头中的凭证与 query 中的密钥会被脱敏
+type HARRecorder struct {
+	next http.RoundTripper
+
+	mu      sync.Mutex
+	title   string
+	started time.Time
+	records []*harRecord
+}
+
+// NewHARRecorder 创建录制器，next 为空时使用跳过证书校验的默认 Transport
+func NewHARRecorder(next http.RoundTripper) *HARRecorder {
+	if next == nil {
+		next = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	}
+	return &HARRecorder{next: next, started: time.Now()}
+}
+
+// Start 丢弃之前的记录，开始录制名为 title 的页面
+func (r *HARRecorder) Start(title string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.title = title
+	r.started = time.Now()
+	r.records = nil
+}
+
+// RoundTrip 转发请求并记录请求与响应
+func (r *HARRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	record := &harRecord{entry: harEntry{
+		PageRef:         "page_1",
+		StartedDateTime: time.Now(),
+		Request:         harRequestOf(req),
+	}}
+	if user, ok := req.Context().Value(harUserKey{}).(string); ok {
+		record.entry.User = user
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		data, err := io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(data))
+		record.entry.Request.BodySize = len(data)
+		record.entry.Request.PostData = &harPostData{
+			MimeType: req.Header.Get("Content-Type"),
+			Text:     truncate(harRedactBody(req.Header.Get("Content-Type"), data), harMaxBodySize),
+		}
+	}
+
+	// WroteRequest 可能在 Transport 的写协程中回调
+	var wrote atomic.Int64
+	trace := &httptrace.ClientTrace{
+		WroteRequest: func(httptrace.WroteRequestInfo) { wrote.Store(time.Now().UnixNano()) },
+	}
+	resp, err := r.next.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	record.waitEnd = time.Now()
+	wroteRequest := record.waitEnd
+	if n := wrote.Load(); n > 0 {
+		wroteRequest = time.Unix(0, n)
+	}
+	record.entry.Timings.Send = milliseconds(wroteRequest.Sub(record.entry.StartedDateTime))
+	record.entry.Timings.Wait = milliseconds(record.waitEnd.Sub(wroteRequest))
+
+	if err != nil {
+		record.entry.Error = err.Error()
+		record.entry.Response = harResponse{HTTPVersion: "HTTP/1.1", Cookies: []harNameVal{}, Headers: []harNameVal{}, HeadersSize: -1, BodySize: -1}
+	} else {
+		record.entry.Response = harResponseOf(resp)
+		record.body = &harCapture{ReadCloser: resp.Body}
+		resp.Body = record.body
+	}
+
+	r.mu.Lock()
+	r.records = append(r.records, record)
+	r.mu.Unlock()
+	return resp, err
+}
+
+// Save 将当前记录写入 path，comment 写入页面备注
+func (r *HARRecorder) Save(path, comment string) error {
+	r.mu.Lock()
+	content := harContent{
+		Version: harVersion,
+		Creator: harCreator{Name: harCreatorName, Version: harVersion},
+		Pages: []harPage{{
+			StartedDateTime: r.started,
+			ID:              "page_1",
+			Title:           r.title,
+			PageTimings:     harPageTimings{OnContentLoad: -1, OnLoad: -1},
+			Comment:         comment,
+		}},
+		Entries: make([]harEntry, 0, len(r.records)),
+	}
+	for _, record := range r.records {
+		content.Entries = append(content.Entries, record.finish())
+	}
+	r.mu.Unlock()
+
+	data, err := json.MarshalIndent(harLog{Log: content}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// finish 补全响应 body 与耗时
+func (record *harRecord) finish() harEntry {
+	entry := record.entry
+	if record.body != nil {
+		text, size, truncated, end := record.body.snapshot()
+		if !truncated {
+			text = harRedactBody(entry.Response.Content.MimeType, []byte(text))
+		}
+		entry.Response.Content.Text = text
+		entry.Response.Content.Size = size
+		entry.Response.BodySize = size
+		if truncated {
+			entry.Response.Content.Comment = "truncated"
+		}
+		if !end.IsZero() {
+			entry.Timings.Receive = milliseconds(end.Sub(record.waitEnd))
+		}
+	}
+	entry.Time = entry.Timings.Send + entry.Timings.Wait + entry.Timings.Receive
+	return entry
+}
+
+// harCapture 在调用方读取响应 body 时同时保存内容，不会额外阻塞流式响应
+type harCapture struct {
+	io.ReadCloser
+
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	size      int
+	truncated bool
+	end       time.Time
+}
+
+func (c *harCapture) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.mu.Lock()
+	c.size += n
+	if room := harMaxBodySize - c.buf.Len(); n > room {
+		c.buf.Write(p[:room])
+		c.truncated = true
+	} else {
+		c.buf.Write(p[:n])
+	}
+	if err != nil && c.end.IsZero() {
+		c.end = time.Now()
+	}
+	c.mu.Unlock()
+	return n, err
+}
+
+func (c *harCapture) Close() error {
+	c.mu.Lock()
+	if c.end.IsZero() {
+		c.end = time.Now()
+	}
+	c.mu.Unlock()
+	return c.ReadCloser.Close()
+}
+
+func (c *harCapture) snapshot() (string, int, bool, time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buf.String(), c.size, c.truncated, c.end
+}
+
+func harRequestOf(req *http.Request) harRequest {
+	u := *req.URL
+	query := u.Query()
+	out := harRequest{
+		Method:      req.Method,
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []harNameVal{},
+		Headers:     harHeaders(req.Header),
+		QueryString: []harNameVal{},
+		HeadersSize: -1,
+	}
+	for name, values := range query {
+		if _, ok := harRedactedParams[strings.ToLower(name)]; ok {
+			for i := range values {
+				values[i] = harRedacted
+			}
+		}
+		for _, v := range values {
+			out.QueryString = append(out.QueryString, harNameVal{Name: name, Value: v})
+		}
+	}
+	u.RawQuery = query.Encode()
+	u.User = nil
+	out.URL = u.String()
+	for _, c := range req.Cookies() {
+		out.Cookies = append(out.Cookies, harNameVal{Name: c.Name, Value: harRedacted})
+	}
+	return out
+}
+
+// harRedactBody 返回脱敏后的 body：JSON 按字段名脱敏，表单按参数名脱敏，其他内容原样返回
+func harRedactBody(mimeType string, data []byte) string {
+	if strings.HasPrefix(mimeType, "application/x-www-form-urlencoded") {
+		form, err := url.ParseQuery(string(data))
+		if err != nil {
+			return string(data)
+		}
+		for name, values := range form {
+			if _, ok := harRedactedParams[strings.ToLower(name)]; ok {
+				for i := range values {
+					values[i] = harRedacted
+				}
+			}
+		}
+		return form.Encode()
+	}
+
+	var v interface{}
+	if len(bytes.TrimSpace(data)) == 0 || json.Unmarshal(data, &v) != nil {
+		return string(data)
+	}
+	if !harRedactJSON(v) {
+		return string(data)
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return string(data)
+	}
+	return string(out)
+}
+
+// harRedactJSON 递归脱敏 JSON 中的敏感字段，返回是否修改
+func harRedactJSON(v interface{}) bool {
+	changed := false
+	switch v := v.(type) {
+	case map[string]interface{}:
+		secret := v["kind"] == "Secret"
+		for key, value := range v {
+			_, sensitive := harRedactedFields[strings.ToLower(key)]
+			if secret && (key == "data" || key == "stringData") {
+				if data, ok := value.(map[string]interface{}); ok {
+					for k := range data {
+						data[k] = harRedacted
+						changed = true
+					}
+					continue
+				}
+			}
+			if sensitive && value != nil {
+				v[key] = harRedacted
+				changed = true
+				continue
+			}
+			if harRedactJSON(value) {
+				changed = true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if harRedactJSON(item) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+func harResponseOf(resp *http.Response) harResponse {
+	out := harResponse{
+		Status:      resp.StatusCode,
+		StatusText:  http.StatusText(resp.StatusCode),
+		HTTPVersion: resp.Proto,
+		Cookies:     []harNameVal{},
+		Headers:     harHeaders(resp.Header),
+		Content:     harBody{MimeType: resp.Header.Get("Content-Type")},
+		RedirectURL: resp.Header.Get("Location"),
+		HeadersSize: -1,
+	}
+	for _, c := range resp.Cookies() {
+		out.Cookies = append(out.Cookies, harNameVal{Name: c.Name, Value: harRedacted})
+	}
+	return out
+}
+
+func harHeaders(header http.Header) []harNameVal {
+	out := []harNameVal{}
+	for name, values := range header {
+		_, redact := harRedactedHeaders[http.CanonicalHeaderKey(name)]
+		for _, v := range values {
+			if redact {
+				v = harRedacted
+			}
+			out = append(out, harNameVal{Name: name, Value: v})
+		}
+	}
+	return out
+}
+
+var harFileNameReplacer = regexp.MustCompile(`[^\p{L}\p{N}._-]+`)
+
+// harSeq 用例序号，保证同名用例的文件不会相互覆盖
+var harSeq atomic.Int64
+
+// HARFileName 将用例名转换为文件名
+func HARFileName(seq int64, spec string) string {
+	name := []rune(strings.Trim(harFileNameReplacer.ReplaceAllString(spec, "_"), "_"))
+	if len(name) > 100 {
+		name = name[:100]
+	}
+	return fmt.Sprintf("%04d-%s.har", seq, string(name))
+}
+
+// recordHAR 为 Describe 中的每个用例录制 http 请求，用例结束后写入 artifacts 目录
+func recordHAR(tc *TestContext) {
+	recorder := tc.HttpHelper.Recorder
+	if recorder == nil {
+		return
+	}
+	ginkgo.BeforeEach(func() {
+		recorder.Start(ginkgo.CurrentGinkgoTestDescription().FullTestText)
+	})
+	ginkgo.AfterEach(func() {
+		desc := ginkgo.CurrentGinkgoTestDescription()
+		comment := "passed"
+		if desc.Failed {
+			comment = "failed"
+		}
+		path := filepath.Join(tc.ArtifactsDir, "har", HARFileName(harSeq.Add(1), desc.FullTestText))
+		if err := recorder.Save(path, comment); err != nil {
+			clog.Warn("save har of %s failed: %v", desc.FullTestText, err)
+		}
+	})
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+func milliseconds(d time.Duration) float64 {
+	if d < 0 {
+		return 0
+	}
+	return float64(d) / float64(time.Millisecond)
+}
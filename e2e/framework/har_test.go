@@ -0,0 +1,101 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestHARRedactBody(t *testing.T) {
+	cases := []struct {
+		name     string
+		mimeType string
+		body     string
+		want     string
+	}{
+		{
+			name:     "login",
+			mimeType: "application/json",
+			body:     `{"name":"admin","password":"admin-pw","loginType":"normal"}`,
+			want:     `{"name":"admin","password":"REDACTED","loginType":"normal"}`,
+		},
+		{
+			name:     "nested token fields",
+			mimeType: "application/json",
+			body:     `{"data":{"Token":"abc","user":{"accessKey":"ak","secretKey":"sk"}},"list":[{"access_token":"t1","id_token":"t2"}]}`,
+			want:     `{"data":{"Token":"REDACTED","user":{"accessKey":"REDACTED","secretKey":"REDACTED"}},"list":[{"access_token":"REDACTED","id_token":"REDACTED"}]}`,
+		},
+		{
+			name:     "secret data",
+			mimeType: "application/json",
+			body:     `{"apiVersion":"v1","kind":"Secret","metadata":{"name":"s"},"data":{"username":"YWRtaW4="},"stringData":{"config":"x"},"type":"Opaque"}`,
+			want:     `{"apiVersion":"v1","kind":"Secret","metadata":{"name":"s"},"data":{"username":"REDACTED"},"stringData":{"config":"REDACTED"},"type":"Opaque"}`,
+		},
+		{
+			name: "docker config secret in list",
+			body: `{"kind":"SecretList","items":[{"kind":"Secret","type":"kubernetes.io/dockerconfigjson","data":{".dockerconfigjson":"eyJhdXRocyI6e319"}}]}`,
+			want: `{"kind":"SecretList","items":[{"kind":"Secret","type":"kubernetes.io/dockerconfigjson","data":{".dockerconfigjson":"REDACTED"}}]}`,
+		},
+		{
+			name: "docker config outside secret",
+			body: `{"spec":{".dockerconfigjson":"eyJhdXRocyI6e319"}}`,
+			want: `{"spec":{".dockerconfigjson":"REDACTED"}}`,
+		},
+		{
+			name: "configmap data is kept",
+			body: `{"kind":"ConfigMap","data":{"password-policy":"strict"}}`,
+			want: `{"kind":"ConfigMap","data":{"password-policy":"strict"}}`,
+		},
+		{
+			name:     "form",
+			mimeType: "application/x-www-form-urlencoded",
+			body:     "username=alice&password=alice-pw&state=s1",
+			want:     "password=REDACTED&state=s1&username=alice",
+		},
+		{
+			name: "not json",
+			body: "password=alice-pw",
+			want: "password=alice-pw",
+		},
+		{
+			name: "nothing to redact keeps formatting",
+			body: "{\n  \"name\": \"ns\"\n}",
+			want: "{\n  \"name\": \"ns\"\n}",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := harRedactBody(c.mimeType, []byte(c.body))
+			if c.mimeType == "application/x-www-form-urlencoded" || !json.Valid([]byte(c.want)) || c.want == c.body {
+				if got != c.want {
+					t.Fatalf("got %s, want %s", got, c.want)
+				}
+				return
+			}
+			var gotV, wantV interface{}
+			if err := json.Unmarshal([]byte(got), &gotV); err != nil {
+				t.Fatalf("redacted body is not json: %s", got)
+			}
+			_ = json.Unmarshal([]byte(c.want), &wantV)
+			if !reflect.DeepEqual(gotV, wantV) {
+				t.Fatalf("got %s, want %s", got, c.want)
+			}
+		})
+	}
+}
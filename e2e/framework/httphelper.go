@@ -17,45 +17,59 @@ limitations under the License.
 package framework
 
 import (
+	"context"
 	"crypto/tls"
+	"fmt"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/kubecube-io/kubecube/pkg/clog"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 type HttpHelper struct {
-	Admin        AuthUser
-	TenantAdmin  AuthUser
-	ProjectAdmin AuthUser
-	User         AuthUser
-	Client       http.Client
-	AuthHeader   string
-}
-
-var (
-	httphelper *HttpHelper
-	once       sync.Once
-)
+	Host string
+	// Users 各角色的登录信息，key 为角色名
+	Users map[string]*AuthUser
+	// Roles 角色顺序，与配置一致
+	Roles       []string
+	DefaultUser string
+	Client      http.Client
+	AuthHeader  string
+	// LoginTimeout 单个用户登录失败时重试的最长时间
+	LoginTimeout time.Duration
+	// Recorder 录制请求，未开启 HAR 输出时为空
+	Recorder *HARRecorder
+	// Tracker 记录经 KubeCube proxy 创建的对象，为空时不记录
+	Tracker *ResourceTracker
 
-// single mode
-func NewSingleHttpHelper() *HttpHelper {
-	if httphelper != nil {
-		return httphelper
-	}
-	once.Do(func() {
-		httphelper = NewHttpHelper().Login(LoginType)
-	})
-	return httphelper
+	loginFunc LoginByUser
+	// mu 保护 Users 中的凭证与 sessions
+	mu       sync.Mutex
+	sessions map[string]*SessionState
+	// lastStatus 各用户最近一次请求的状态码
+	lastStatus map[string]int
+	// loginMu 每个角色一把锁，同一用户同时只有一个登录在进行
+	loginMu map[string]*sync.Mutex
 }
 
-func NewHttpHelper() *HttpHelper {
+func NewHttpHelper(cfg *Config) *HttpHelper {
 	h := &HttpHelper{
-		Admin:        AuthUser{Username: Admin, Password: AdminPassword},
-		TenantAdmin:  AuthUser{Username: TenantAdmin, Password: TenantAdminPassword},
-		ProjectAdmin: AuthUser{Username: ProjectAdmin, Password: ProjectAdminPassword},
-		User:         AuthUser{Username: User, Password: UserPassword},
+		Host:         cfg.KubecubeHost,
+		Users:        make(map[string]*AuthUser),
+		DefaultUser:  cfg.Roles.Default().Username,
+		LoginTimeout: cfg.WaitTimeout,
+		sessions:     make(map[string]*SessionState),
+		loginMu:      make(map[string]*sync.Mutex),
+		lastStatus:   make(map[string]int),
+	}
+	for _, role := range cfg.Roles.Roles() {
+		h.Users[role.Name] = &AuthUser{Username: role.Username, Password: role.Password}
+		h.Roles = append(h.Roles, role.Name)
+		h.sessions[role.Name] = &SessionState{Role: role.Name, Username: role.Username}
+		h.loginMu[role.Name] = &sync.Mutex{}
 	}
 
 	tr := &http.Transport{
@@ -65,55 +79,160 @@ func NewHttpHelper() *HttpHelper {
 		Transport: tr,
 		Timeout:   30 * time.Second,
 	}
+	if cfg.HAREnabled {
+		h.Recorder = NewHARRecorder(tr)
+		h.Client.Transport = h.Recorder
+	}
 	return h
 }
 
-func (h *HttpHelper) Login(login string) *HttpHelper {
+// Login 登录所有角色，任一角色登录失败时返回错误
+func (h *HttpHelper) Login(login string) error {
 	loginFunc := GetLoginMap(login)
-	_ = loginFunc.LoginByUser(&h.Admin)
-	_ = loginFunc.LoginByUser(&h.TenantAdmin)
-	_ = loginFunc.LoginByUser(&h.ProjectAdmin)
-	_ = loginFunc.LoginByUser(&h.User)
+	if loginFunc == nil {
+		return fmt.Errorf("login type %s not registered", login)
+	}
+	h.loginFunc = loginFunc
 	h.AuthHeader = loginFunc.AuthHeader()
-	return h
+
+	// 各角色并发登录，避免登录失败时逐个等待重试超时
+	errs := make([]error, len(h.Roles))
+	var wg sync.WaitGroup
+	for i, role := range h.Roles {
+		wg.Add(1)
+		go func(i int, role string) {
+			defer wg.Done()
+			if err := h.loginRole(role); err != nil {
+				errs[i] = fmt.Errorf("role %s login failed: %v", role, err)
+			}
+		}(i, role)
+	}
+	wg.Wait()
+	return utilerrors.NewAggregate(errs)
+}
+
+// Provision 登录方式需要预先准备凭证时，为所有用户创建凭证
+func (h *HttpHelper) Provision(login string, cli client.Client) error {
+	provisioner, ok := GetLoginMap(login).(CredentialProvisioner)
+	if !ok {
+		return nil
+	}
+	for _, role := range h.Roles {
+		err := provisioner.Provision(context.Background(), cli, h.Users[role])
+		if err != nil {
+			return fmt.Errorf("provision credential for role %s failed: %v", role, err)
+		}
+	}
+	return nil
+}
+
+// roleByName 根据用户名查找角色名
+func (h *HttpHelper) roleByName(username string) (string, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, role := range h.Roles {
+		if h.Users[role].Username == username {
+			return role, true
+		}
+	}
+	return "", false
 }
 
 // get
 func (h *HttpHelper) Get(urlVal string, header map[string]string) (*http.Response, error) {
-	return h.RequestByUser(http.MethodGet, urlVal, "", Admin, header)
+	return h.RequestByUser(http.MethodGet, urlVal, "", h.DefaultUser, header)
 }
 
 // post
 func (h *HttpHelper) Post(urlVal, body string, header map[string]string) (*http.Response, error) {
-	return h.RequestByUser(http.MethodPost, urlVal, body, Admin, header)
+	return h.RequestByUser(http.MethodPost, urlVal, body, h.DefaultUser, header)
 }
 
 // delete
 func (h *HttpHelper) Delete(urlVal string) (*http.Response, error) {
-	return h.RequestByUser(http.MethodDelete, urlVal, "", Admin, nil)
+	return h.RequestByUser(http.MethodDelete, urlVal, "", h.DefaultUser, nil)
 }
 
 // put
 func (h *HttpHelper) Put(urlVal, body string, header map[string]string) (*http.Response, error) {
-	return h.RequestByUser(http.MethodPut, urlVal, body, Admin, header)
+	return h.RequestByUser(http.MethodPut, urlVal, body, h.DefaultUser, header)
 }
 
 func (h *HttpHelper) Patch(urlVal, body string, header map[string]string) (*http.Response, error) {
-	return h.RequestByUser(http.MethodPatch, urlVal, body, Admin, header)
+	return h.RequestByUser(http.MethodPatch, urlVal, body, h.DefaultUser, header)
 }
 
 // default request by admin
 func (h *HttpHelper) Request(method, urlVal, data string, header map[string]string) (*http.Response, error) {
-	return h.RequestByUser(method, urlVal, data, Admin, header)
+	return h.RequestByUser(method, urlVal, data, h.DefaultUser, header)
 }
 
 // request by user
 func (h *HttpHelper) RequestByUser(method, urlVal, data, user string, header map[string]string) (*http.Response, error) {
-	req, err := h.BuildRequest(method, urlVal, data, user, header)
+	req, err := BuildRequest(method, urlVal, data, header)
+	if err != nil {
+		clog.Warn("build request error: %v", err.Error())
+		return nil, err
+	}
+	return h.DoByUser(req, user)
+}
+
+// DoByUser 以 user 的身份发送请求，返回 401 时重新登录该用户并重试一次，
+// 带 body 的请求需设置 GetBody 才能重试
+func (h *HttpHelper) DoByUser(req *http.Request, user string) (*http.Response, error) {
+	role, known := h.roleByName(user)
+	generation := 0
+	if known {
+		generation = h.Session(role).Generation
+	}
+
+	resp, err := h.do(req, user)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || !known || h.loginFunc == nil {
+		return resp, err
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return resp, err
+	}
+	_ = resp.Body.Close()
+
+	clog.Warn("user %s got 401 on %s %s, login again", user, req.Method, req.URL)
+	err = h.relogin(role, generation)
 	if err != nil {
 		return nil, err
 	}
-	return h.Client.Do(req)
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		retry.Body, err = req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return h.do(retry, user)
+}
+
+func (h *HttpHelper) do(req *http.Request, user string) (*http.Response, error) {
+	req = h.Tracker.stampRequest(req)
+	resp, err := h.Client.Do(h.authorize(req, user))
+	if err == nil {
+		h.mu.Lock()
+		h.lastStatus[user] = resp.StatusCode
+		h.mu.Unlock()
+		h.Tracker.trackResponse(req, resp, user)
+	}
+	return resp, err
+}
+
+// LastStatus 返回用户最近一次请求的状态码，没有请求时返回 0
+func (h *HttpHelper) LastStatus(user string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.lastStatus[user]
+}
+
+func (h *HttpHelper) resetStatus(user string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.lastStatus, user)
 }
 
 // build request
@@ -123,22 +242,24 @@ func (h *HttpHelper) BuildRequest(method, urlVal, data, user string, header map[
 		clog.Warn("build request error: %v", err.Error())
 		return nil, err
 	}
-	switch user {
-	case Admin:
-		req.AddCookie(h.Admin.Cookie)
-		req.Header.Add(h.AuthHeader, h.Admin.Token)
-	case TenantAdmin:
-		req.AddCookie(h.TenantAdmin.Cookie)
-		req.Header.Add(h.AuthHeader, h.TenantAdmin.Token)
-	case ProjectAdmin:
-		req.AddCookie(h.ProjectAdmin.Cookie)
-		req.Header.Add(h.AuthHeader, h.ProjectAdmin.Token)
-	case User:
-		req.AddCookie(h.User.Cookie)
-		req.Header.Add(h.AuthHeader, h.User.Token)
-	}
+	return h.authorize(req, user), nil
+}
 
-	return req, nil
+// authorize 返回携带 user 登录凭证的请求副本
+func (h *HttpHelper) authorize(req *http.Request, user string) *http.Request {
+	req = req.Clone(withHARUser(req.Context(), user))
+	if role, ok := h.roleByName(user); ok {
+		h.mu.Lock()
+		u := h.Users[role]
+		if u.Cookie != nil {
+			req.AddCookie(u.Cookie)
+		}
+		if len(h.AuthHeader) > 0 {
+			req.Header.Set(h.AuthHeader, u.Token)
+		}
+		h.mu.Unlock()
+	}
+	return req
 }
 
 func IsSuccess(code int) bool {
@@ -153,18 +274,9 @@ type MultiRequestResponse struct {
 // multi user request test
 func (h *HttpHelper) MultiUserRequest(method, url, body string, header map[string]string) map[string]MultiRequestResponse {
 	ret := make(map[string]MultiRequestResponse)
-
-	resp1, err1 := h.RequestByUser(method, url, body, Admin, header)
-	ret["admin"] = MultiRequestResponse{resp1, err1}
-
-	resp2, err2 := h.RequestByUser(method, url, body, TenantAdmin, header)
-	ret["tenantAdmin"] = MultiRequestResponse{resp2, err2}
-
-	resp3, err3 := h.RequestByUser(method, url, body, ProjectAdmin, header)
-	ret["projectAdmin"] = MultiRequestResponse{resp3, err3}
-
-	resp4, err4 := h.RequestByUser(method, url, body, User, header)
-	ret["user"] = MultiRequestResponse{resp4, err4}
-
+	for _, role := range h.Roles {
+		resp, err := h.RequestByUser(method, url, body, h.Session(role).Username, header)
+		ret[role] = MultiRequestResponse{resp, err}
+	}
 	return ret
 }
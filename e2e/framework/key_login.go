@@ -0,0 +1,121 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	userv1 "github.com/kubecube-io/kubecube/pkg/apis/user/v1"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	kubecubeconstants "github.com/kubecube-io/kubecube/pkg/utils/constants"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubecube-io/kubecube-e2e/util/constants"
+	backoff "github.com/kubecube-io/kubecube-e2e/util/retry"
+)
+
+var keyLogin = &KeyLogin{}
+
+// KeyLogin 使用 user.kubecube.io/v1 Key 的 AccessKey/SecretKey 换取 token 登录
+type KeyLogin struct{}
+
+func init() {
+	Register(constants.KeyLoginType, keyLogin)
+}
+
+func (k *KeyLogin) AuthHeader() string {
+	return constants.AuthorizationHeader
+}
+
+// Provision 为用户创建 Key，AccessKey 为 Key 的名字
+func (k *KeyLogin) Provision(ctx context.Context, cli client.Client, user *AuthUser) error {
+	if len(user.AccessKey) > 0 && len(user.SecretKey) > 0 {
+		return nil
+	}
+	key := &userv1.Key{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: newKeyValue(),
+			Labels: map[string]string{
+				kubecubeconstants.LabelRelationship: user.Username,
+				E2EKeyLabel:                         "true",
+			},
+		},
+		Spec: userv1.KeySpec{
+			SecretKey: newKeyValue(),
+			User:      user.Username,
+		},
+	}
+	err := cli.Create(ctx, key)
+	if err != nil {
+		clog.Error("create key for user %s fail, %v", user.Username, err)
+		return err
+	}
+	user.AccessKey = key.Name
+	user.SecretKey = key.Spec.SecretKey
+	return nil
+}
+
+func (k *KeyLogin) LoginByUser(host string, user *AuthUser) error {
+	if len(user.AccessKey) == 0 || len(user.SecretKey) == 0 {
+		return backoff.Permanent(fmt.Errorf("user %s has no access key provisioned", user.Username))
+	}
+	url := fmt.Sprintf("%s/api/v1/cube/key/token?accessKey=%s&secretKey=%s", host, user.AccessKey, user.SecretKey)
+	req, err := BuildRequest(http.MethodGet, url, "", nil)
+	if err != nil {
+		clog.Error("login fail, error: %v", err)
+		return err
+	}
+	resp, err := httpclient.Do(req)
+	if err != nil {
+		clog.Error("login fail, error: %v", err)
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if !IsSuccess(resp.StatusCode) {
+		clog.Error("get token by key fail, code: %d, body: %s", resp.StatusCode, string(body))
+		return loginStatusError(resp.StatusCode, fmt.Errorf("get token by key fail, code %d", resp.StatusCode))
+	}
+
+	result := struct {
+		Token string `json:"token"`
+	}{}
+	err = json.Unmarshal(body, &result)
+	if err != nil {
+		clog.Error("get token by key fail, unmarshal body fail, %v", err)
+		return err
+	}
+	if len(result.Token) == 0 {
+		return fmt.Errorf("get token by key fail, empty token")
+	}
+	user.Token = "Bearer " + result.Token
+	return nil
+}
+
+func newKeyValue() string {
+	return strings.ReplaceAll(string(uuid.NewUUID()), "-", "")
+}
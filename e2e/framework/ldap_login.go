@@ -0,0 +1,85 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"github.com/kubecube-io/kubecube/pkg/clog"
+
+	"github.com/kubecube-io/kubecube-e2e/util/constants"
+	"github.com/kubecube-io/kubecube-e2e/util/fakeldap"
+)
+
+var ldapLogin = &LDAPLogin{GeneralLogin: GeneralLogin{loginType: "ldap"}}
+
+// LDAPConfig LDAP 登录配置
+type LDAPConfig struct {
+	// Fake 是否在本进程内启动 fake LDAP，KubeCube 需配置为访问该服务
+	Fake   bool   `mapstructure:"fake"`
+	Listen string `mapstructure:"listen"`
+	BaseDN string `mapstructure:"baseDN"`
+	// BindDN/BindPassword KubeCube 查询用户使用的账号
+	BindDN       string `mapstructure:"bindDN"`
+	BindPassword string `mapstructure:"bindPassword"`
+}
+
+// LDAPLogin 通过 KubeCube 登录接口以 ldap 方式登录，由 KubeCube 向 LDAP 校验用户名密码
+type LDAPLogin struct {
+	GeneralLogin
+	server *fakeldap.Server
+}
+
+func init() {
+	Register(constants.LDAPLoginType, ldapLogin)
+}
+
+// Configure 按需启动 fake LDAP，目录中的用户与角色注册表一致，服务在进程生命周期内保持运行，
+// 监听地址已被同机其它 e2e 进程占用时直接使用该进程的 fake LDAP
+func (l *LDAPLogin) Configure(cfg *Config) error {
+	if !cfg.LDAP.Fake || l.server != nil {
+		return nil
+	}
+	ldapCfg := fakeldap.Config{
+		BaseDN:       cfg.LDAP.BaseDN,
+		BindDN:       cfg.LDAP.BindDN,
+		BindPassword: cfg.LDAP.BindPassword,
+	}
+	if len(ldapCfg.BaseDN) == 0 {
+		ldapCfg.BaseDN = "dc=kubecube,dc=io"
+	}
+	for _, role := range cfg.Roles.Roles() {
+		ldapCfg.Users = append(ldapCfg.Users, fakeldap.User{
+			Username: role.Username,
+			Password: role.Password,
+			Email:    role.Username + "@e2e.kubecube.io",
+		})
+	}
+	server, err := fakeldap.New(ldapCfg)
+	if err != nil {
+		return err
+	}
+	listen := cfg.LDAP.Listen
+	if len(listen) == 0 {
+		listen = ":10389"
+	}
+	started, err := startFakeServer("fake ldap", listen, server.Start)
+	if err != nil || !started {
+		return err
+	}
+	clog.Info("fake ldap listening on %s, base dn %s", server.Addr(), ldapCfg.BaseDN)
+	l.server = server
+	return nil
+}
@@ -17,25 +17,66 @@ limitations under the License.
 package framework
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/url"
 	"strings"
+	"syscall"
+
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	backoff "github.com/kubecube-io/kubecube-e2e/util/retry"
 )
 
 type AuthUser struct {
-	Username string
-	Password string
-	Token    string
-	Cookie   *http.Cookie
+	Username  string
+	Password  string
+	AccessKey string
+	SecretKey string
+	Token     string
+	Cookie    *http.Cookie
 }
 
 type LoginByUser interface {
-	LoginByUser(user *AuthUser) error
+	LoginByUser(host string, user *AuthUser) error
 	AuthHeader() string
 }
 
+// LoginConfigurer 需要读取测试配置的登录方式实现此接口，在准备凭证与登录之前调用
+type LoginConfigurer interface {
+	Configure(cfg *Config) error
+}
+
+// CredentialProvisioner 登录前需要先在集群中为用户准备凭证的登录方式实现此接口
+type CredentialProvisioner interface {
+	Provision(ctx context.Context, cli client.Client, user *AuthUser) error
+}
+
 var loginMap = make(map[string]LoginByUser)
 
+// loginStatusError 登录请求返回失败状态码时的错误，4xx 说明凭证或请求本身有误，
+// 以 backoff.Permanent 包装使 login 不再重试，408 与 429 仍会重试
+func loginStatusError(code int, err error) error {
+	if code >= http.StatusBadRequest && code < http.StatusInternalServerError &&
+		code != http.StatusRequestTimeout && code != http.StatusTooManyRequests {
+		return backoff.Permanent(err)
+	}
+	return err
+}
+
+// startFakeServer 启动登录使用的替身服务，返回是否由本进程提供服务。
+// 同一台机器上的多个 e2e 进程使用相同的配置与监听地址，地址已被占用时认为由先启动的进程提供服务
+func startFakeServer(name, listen string, start func(addr string) error) (bool, error) {
+	err := start(listen)
+	if errors.Is(err, syscall.EADDRINUSE) {
+		clog.Warn("%s address %s already in use, assume it is served by another e2e process", name, listen)
+		return false, nil
+	}
+	return err == nil, err
+}
+
 func Register(key string, loginFunc LoginByUser) {
 	loginMap[key] = loginFunc
 }
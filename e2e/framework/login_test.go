@@ -0,0 +1,51 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"net"
+	"testing"
+)
+
+func TestStartFakeServer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	start := func(addr string) error {
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return err
+		}
+		return l.Close()
+	}
+
+	// 地址已被同机其它进程占用时不视为错误
+	started, err := startFakeServer("fake", l.Addr().String(), start)
+	if err != nil || started {
+		t.Fatalf("address in use: started %v, err %v", started, err)
+	}
+	started, err = startFakeServer("fake", "127.0.0.1:0", start)
+	if err != nil || !started {
+		t.Fatalf("free address: started %v, err %v", started, err)
+	}
+	_, err = startFakeServer("fake", "invalid-address", start)
+	if err == nil {
+		t.Fatal("expected error for invalid address")
+	}
+}
@@ -0,0 +1,331 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 步骤执行结果
+const (
+	// OutcomePass 操作成功
+	OutcomePass = "pass"
+	// OutcomeFail 操作返回错误，如无权限
+	OutcomeFail = "fail"
+	// OutcomeError 步骤内的断言失败，无法判断操作结果
+	OutcomeError = "error"
+	// OutcomeSkipped 依赖的步骤失败，步骤被跳过
+	OutcomeSkipped = "skipped"
+	// OutcomeNotRun 角色未参与该测试
+	OutcomeNotRun = "notRun"
+)
+
+// StepResult 某个角色在一个测试集群中执行一个步骤的结果
+type StepResult struct {
+	Cluster  string `json:"cluster"`
+	Role     string `json:"role"`
+	User     string `json:"user"`
+	Expected bool   `json:"expected"`
+	// Expect 预期结果的简写，如 pass、fail、403
+	Expect     string `json:"expect"`
+	Outcome    string `json:"outcome"`
+	StatusCode int    `json:"statusCode,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+	Error      string `json:"error,omitempty"`
+	// Policy 生效的超时与重试策略
+	Policy string `json:"policy,omitempty"`
+	// Attempts 执行次数，重试时大于 1
+	Attempts int `json:"attempts,omitempty"`
+	// Mismatch 实际结果与预期不一致
+	Mismatch bool `json:"mismatch"`
+}
+
+// MatrixStep 权限矩阵中的一行
+type MatrixStep struct {
+	Cluster     string `json:"cluster"`
+	Test        string `json:"test"`
+	Step        string `json:"step"`
+	Description string `json:"description,omitempty"`
+	// Policy 步骤生效的超时与重试策略
+	Policy  string        `json:"policy"`
+	Results []*StepResult `json:"results"`
+}
+
+// PermissionMatrix 集群 × 测试 × 步骤 × 角色的权限矩阵
+type PermissionMatrix struct {
+	GeneratedAt time.Time     `json:"generatedAt"`
+	RunID       string        `json:"runID"`
+	Clusters    []string      `json:"clusters"`
+	Roles       []string      `json:"roles"`
+	Steps       []*MatrixStep `json:"steps"`
+	Mismatches  int           `json:"mismatches"`
+}
+
+// MatrixRecorder 收集每个步骤的执行结果
+type MatrixRecorder struct {
+	mu      sync.Mutex
+	results map[string]*StepResult
+}
+
+// NewMatrixRecorder 创建结果收集器
+func NewMatrixRecorder() *MatrixRecorder {
+	return &MatrixRecorder{results: make(map[string]*StepResult)}
+}
+
+func matrixKey(cluster, test, step, role string) string {
+	return cluster + "\x00" + test + "\x00" + step + "\x00" + role
+}
+
+// Record 记录一个步骤的执行结果，结果按 result.Cluster 与 result.Role 区分
+func (m *MatrixRecorder) Record(test, step string, result *StepResult) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.results[matrixKey(result.Cluster, test, step, result.Role)] = result
+}
+
+// RecordedStep 已记录的步骤结果，用于在进程间传递
+type RecordedStep struct {
+	Test   string      `json:"test"`
+	Step   string      `json:"step"`
+	Result *StepResult `json:"result"`
+}
+
+// Records 返回已记录的所有结果
+func (m *MatrixRecorder) Records() []RecordedStep {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	records := make([]RecordedStep, 0, len(m.results))
+	for key, result := range m.results {
+		parts := strings.SplitN(key, "\x00", 4)
+		records = append(records, RecordedStep{Test: parts[1], Step: parts[2], Result: result})
+	}
+	return records
+}
+
+// Merge 合并其他进程记录的结果
+func (m *MatrixRecorder) Merge(records []RecordedStep) {
+	for _, r := range records {
+		if r.Result != nil {
+			m.Record(r.Test, r.Step, r.Result)
+		}
+	}
+}
+
+func (m *MatrixRecorder) result(cluster, test, step, role string) (*StepResult, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.results[matrixKey(cluster, test, step, role)]
+	return r, ok
+}
+
+// recordStep 按策略执行步骤并记录结果，返回步骤结果与状态码，步骤内断言失败或超时时记为 OutcomeError
+func recordStep(tc *TestContext, test string, step MultiUserTestStep, expect Expectation, policy StepPolicy) (TestResp, int) {
+	start := time.Now()
+	tc.HttpHelper.resetStatus(tc.User)
+	result := newStepResult(tc.TargetClusterName, tc.Role, tc.User, expect, OutcomeError)
+	result.Policy = policy.String()
+	// 步骤断言失败或超时时 runWithPolicy 直接 panic，期望通过的步骤记为与期望不符
+	result.Mismatch = expect.Pass
+	defer func() {
+		result.DurationMs = time.Since(start).Milliseconds()
+		if result.StatusCode == 0 {
+			result.StatusCode = tc.HttpHelper.LastStatus(tc.User)
+		}
+		tc.Matrix.Record(test, step.Name, result)
+	}()
+
+	resp, status, attempts := runWithPolicy(tc, step.StepFunc, expect, policy)
+	result.StatusCode, result.Attempts = status, attempts
+	result.Outcome = OutcomePass
+	if resp.Err != nil {
+		result.Outcome = OutcomeFail
+		result.Error = resp.Err.Error()
+	}
+	if expect.Detailed() {
+		result.Mismatch = expect.Check(resp, result.StatusCode) != nil
+	} else {
+		result.Mismatch = expect.Pass != (resp.Err == nil)
+	}
+	return resp, result.StatusCode
+}
+
+// recordSkipped 记录被跳过的步骤，reason 为跳过原因
+func recordSkipped(tc *TestContext, test string, step MultiUserTestStep, expect Expectation, policy StepPolicy, reason string) {
+	result := newStepResult(tc.TargetClusterName, tc.Role, tc.User, expect, OutcomeSkipped)
+	result.Policy = policy.String()
+	result.Error = reason
+	tc.Matrix.Record(test, step.Name, result)
+}
+
+func newStepResult(cluster, role, user string, expect Expectation, outcome string) *StepResult {
+	return &StepResult{Cluster: cluster, Role: role, User: user, Expected: expect.Pass, Expect: expect.String(), Outcome: outcome}
+}
+
+// BuildPermissionMatrix 根据已注册的测试与收集的结果生成每个测试集群的权限矩阵，未执行的组合记为 OutcomeNotRun
+func BuildPermissionMatrix(tc *TestContext) *PermissionMatrix {
+	roles := GetAllUsersAvailable(tc.Roles)
+	m := &PermissionMatrix{
+		GeneratedAt: time.Now(),
+		RunID:       tc.RunID,
+		Clusters:    tc.TargetClusters,
+		Roles:       roles,
+	}
+	for _, cluster := range tc.TargetClusters {
+		for _, test := range ConfigHelper {
+			configured := ToTestMap[test.TestName]
+			for _, step := range test.Steps {
+				expectStep := step
+				if s, ok := configured[step.Name]; ok {
+					expectStep = s
+				}
+				row := &MatrixStep{
+					Cluster:     cluster,
+					Test:        test.TestName,
+					Step:        step.Name,
+					Description: step.Description,
+					Policy:      tc.StepPolicy(step, expectStep).String(),
+				}
+				for _, role := range roles {
+					result, ok := tc.Matrix.result(cluster, test.TestName, step.Name, role)
+					if !ok {
+						result = newStepResult(cluster, role, tc.GetUser(role), tc.Roles.Expectation(expectStep, role), OutcomeNotRun)
+					}
+					if result.Mismatch {
+						m.Mismatches++
+					}
+					row.Results = append(row.Results, result)
+				}
+				m.Steps = append(m.Steps, row)
+			}
+		}
+	}
+	return m
+}
+
+// JSON 输出 JSON 格式的权限矩阵
+func (m *PermissionMatrix) JSON() ([]byte, error) {
+	return json.MarshalIndent(m, "", "  ")
+}
+
+// Markdown 输出 Markdown 表格，与预期不一致的结果加粗并标记
+func (m *PermissionMatrix) Markdown() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# 权限矩阵\n\n运行 %s，生成于 %s，不一致 %d 项\n\n", m.RunID, m.GeneratedAt.Format(time.RFC3339), m.Mismatches)
+	b.WriteString("| 集群 | 测试 | 步骤 | 策略 |")
+	for _, role := range m.Roles {
+		fmt.Fprintf(&b, " %s |", role)
+	}
+	b.WriteString("\n| --- | --- | --- | --- |")
+	for range m.Roles {
+		b.WriteString(" --- |")
+	}
+	b.WriteString("\n")
+	for _, row := range m.Steps {
+		fmt.Fprintf(&b, "| %s | %s | %s | %s |", markdownEscape(row.Cluster), markdownEscape(row.Test), markdownEscape(row.Step), row.Policy)
+		for _, r := range row.Results {
+			fmt.Fprintf(&b, " %s |", markdownCell(r))
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\n单元格格式：实际结果 / 预期结果 (状态码, 耗时) ×执行次数，❌ 表示与预期不一致\n")
+	return b.Bytes()
+}
+
+func markdownCell(r *StepResult) string {
+	cell := fmt.Sprintf("%s / %s", r.Outcome, r.Expect)
+	if r.Outcome != OutcomeNotRun && r.Outcome != OutcomeSkipped {
+		cell += fmt.Sprintf(" (%d, %dms)", r.StatusCode, r.DurationMs)
+	}
+	if r.Attempts > 1 {
+		cell += fmt.Sprintf(" ×%d", r.Attempts)
+	}
+	if r.Mismatch {
+		cell = "❌ **" + cell + "**"
+	}
+	return cell
+}
+
+func markdownEscape(s string) string {
+	return strings.ReplaceAll(s, "|", "\\|")
+}
+
+var matrixHTMLTemplate = template.Must(template.New("matrix").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>权限矩阵 {{.RunID}}</title>
+<style>
+body { font-family: sans-serif; font-size: 13px; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; vertical-align: top; }
+th { background: #f0f0f0; }
+td.pass { background: #e6f4ea; }
+td.fail { background: #fdf3e1; }
+td.error, td.mismatch { background: #fce8e6; font-weight: bold; }
+td.skipped, td.notRun { color: #999; }
+small { color: #666; }
+</style>
+</head>
+<body>
+<h1>权限矩阵</h1>
+<p>运行 {{.RunID}}，生成于 {{.GeneratedAt.Format "2006-01-02T15:04:05Z07:00"}}，不一致 {{.Mismatches}} 项</p>
+<table>
+<tr><th>集群</th><th>测试</th><th>步骤</th><th>策略</th>{{range .Roles}}<th>{{.}}</th>{{end}}</tr>
+{{range .Steps}}<tr><td>{{.Cluster}}</td><td>{{.Test}}</td><td title="{{.Description}}">{{.Step}}</td><td><small>{{.Policy}}</small></td>
+{{range .Results}}<td class="{{if .Mismatch}}mismatch{{else}}{{.Outcome}}{{end}}" title="{{.Error}}">{{.Outcome}} / {{.Expect}}{{if .StatusCode}}<br><small>{{.StatusCode}}, {{.DurationMs}}ms{{if gt .Attempts 1}}, ×{{.Attempts}}{{end}}</small>{{end}}</td>
+{{end}}</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// HTML 输出 HTML 表格，与预期不一致的结果标红
+func (m *PermissionMatrix) HTML() ([]byte, error) {
+	var b bytes.Buffer
+	err := matrixHTMLTemplate.Execute(&b, m)
+	return b.Bytes(), err
+}
+
+// WritePermissionMatrix 将权限矩阵以 JSON、Markdown 与 HTML 格式写入 dir
+func WritePermissionMatrix(m *PermissionMatrix, dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	data, err := m.JSON()
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(filepath.Join(dir, "permission-matrix.json"), data, 0o644); err != nil {
+		return err
+	}
+	if err = os.WriteFile(filepath.Join(dir, "permission-matrix.md"), m.Markdown(), 0o644); err != nil {
+		return err
+	}
+	data, err = m.HTML()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "permission-matrix.html"), data, 0o644)
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRecordStep(t *testing.T) {
+	cases := []struct {
+		name         string
+		expect       Expectation
+		run          TestFunc
+		wantOutcome  string
+		wantMismatch bool
+	}{
+		{name: "pass as expected", expect: Expectation{Pass: true}, run: func(*TestContext) TestResp { return SucceedResp },
+			wantOutcome: OutcomePass},
+		{name: "fail as expected", expect: Expectation{}, run: func(*TestContext) TestResp { return NewTestResp(errors.New("forbidden"), nil) },
+			wantOutcome: OutcomeFail},
+		{name: "unexpected pass", expect: Expectation{}, run: func(*TestContext) TestResp { return SucceedResp },
+			wantOutcome: OutcomePass, wantMismatch: true},
+		{name: "unexpected fail", expect: Expectation{Pass: true}, run: func(*TestContext) TestResp { return NewTestResp(errors.New("forbidden"), nil) },
+			wantOutcome: OutcomeFail, wantMismatch: true},
+		{name: "status mismatch", expect: Expectation{StatusCode: 403}, run: func(*TestContext) TestResp { return NewTestResp(errors.New("not found"), 404) },
+			wantOutcome: OutcomeFail, wantMismatch: true},
+		{name: "panic when pass is expected", expect: Expectation{Pass: true}, run: func(*TestContext) TestResp { panic(roleFailure{message: "assertion failed"}) },
+			wantOutcome: OutcomeError, wantMismatch: true},
+		{name: "panic when fail is expected", expect: Expectation{}, run: func(*TestContext) TestResp { panic(roleFailure{message: "assertion failed"}) },
+			wantOutcome: OutcomeError},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cfg := &Config{KubecubeHost: "http://127.0.0.1", Roles: testRoles(), TargetClusterName: "pivot"}
+			tc := &TestContext{Config: cfg, HttpHelper: NewHttpHelper(cfg), Matrix: NewMatrixRecorder(), Role: UserAdmin, User: "admin"}
+			func() {
+				defer func() { _ = recover() }()
+				recordStep(tc, "test", MultiUserTestStep{Name: "step", StepFunc: c.run}, c.expect, StepPolicy{})
+			}()
+
+			result, ok := tc.Matrix.result("pivot", "test", "step", UserAdmin)
+			if !ok {
+				t.Fatal("step result not recorded")
+			}
+			if result.Outcome != c.wantOutcome {
+				t.Errorf("outcome = %s, want %s", result.Outcome, c.wantOutcome)
+			}
+			if result.Mismatch != c.wantMismatch {
+				t.Errorf("mismatch = %v, want %v", result.Mismatch, c.wantMismatch)
+			}
+		})
+	}
+}
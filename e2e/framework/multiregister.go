@@ -18,6 +18,7 @@ package framework
 
 import (
 	"errors"
+	"fmt"
 	"os"
 
 	"github.com/kubecube-io/kubecube/pkg/clog"
@@ -48,6 +49,19 @@ func RegisterTestAndSteps(test MultiUserTest) error {
 			return errors.New("step duplicated")
 		}
 		funcMap[step.Name] = struct{}{}
+		if err := validateStepPolicy(test.TestName, step); err != nil {
+			clog.Warn(err.Error())
+			return err
+		}
+		if err := compileExpectations(test.TestName, step); err != nil {
+			clog.Warn(err.Error())
+			return err
+		}
+	}
+
+	if err := validateDependsOn(test); err != nil {
+		clog.Warn(err.Error())
+		return err
 	}
 
 	AllTestMap[test.TestName] = struct{}{}
@@ -55,7 +69,41 @@ func RegisterTestAndSteps(test MultiUserTest) error {
 	return nil
 }
 
-func CreateTestExample(test MultiUserTest) error {
+// ClusterDescribePrefix 多个测试集群时每个集群的用例包含在名为 "cluster <集群名>" 的 Describe 中
+const ClusterDescribePrefix = "cluster "
+
+// CreateTestExamples 为所有已注册的测试在每个测试集群中生成用例，需在 RunSpecs 之前调用
+func CreateTestExamples(tc *TestContext) {
+	if len(tc.TargetClusters) <= 1 {
+		createClusterTestExamples(tc)
+		return
+	}
+	for _, cluster := range tc.TargetClusters {
+		clusterCtx := tc.ForCluster(cluster)
+		_ = ginkgo.Describe(ClusterDescribePrefix+cluster, func() {
+			createClusterTestExamples(clusterCtx)
+		})
+	}
+}
+
+func createClusterTestExamples(tc *TestContext) {
+	for _, test := range ConfigHelper {
+		err := CreateTestExample(tc, test)
+		if err != nil {
+			clog.Error("fail to create tests with err %s \n", err.Error())
+		}
+	}
+}
+
+// clusterScope 多个测试集群时在 scope 前加上集群名，区分不同集群中执行的同一测试
+func clusterScope(tc *TestContext, scope string) string {
+	if len(tc.TargetClusters) <= 1 {
+		return scope
+	}
+	return tc.TargetClusterName + "/" + scope
+}
+
+func CreateTestExample(tc *TestContext, test MultiUserTest) error {
 	_, ok := AllTestMap[test.TestName]
 	if !ok {
 		return errors.New("test not exist")
@@ -69,8 +117,13 @@ func CreateTestExample(test MultiUserTest) error {
 		test.Skipfunc = DefaultSkipFunc
 	}
 
-	for _, user := range GetAllUsersAvailable() {
-		generateSingleUserTestExample(test, test.ErrorFunc, user, test.BeforeEach, test.AfterEach, test.Skipfunc)
+	if ConcurrentRoles {
+		generateConcurrentTestExample(tc, test, GetAllUsersAvailable(tc.Roles))
+		return nil
+	}
+
+	for _, user := range GetAllUsersAvailable(tc.Roles) {
+		generateSingleUserTestExample(tc, test, test.ErrorFunc, user, test.BeforeEach, test.AfterEach, test.Skipfunc)
 	}
 
 	return nil
@@ -80,7 +133,7 @@ func OutputMultiUserTestConfig(path string) error {
 	clog.Info("out put config helper")
 	m := MultiUserTestConfig{
 		TestMap:  ConfigHelper,
-		AllUsers: []string{UserAdmin, UserProjectAdmin, UserTenantAdmin, UserNormal},
+		AllUsers: outputRoleNames(),
 	}
 	out, err := yaml.Marshal(m)
 	if err != nil {
@@ -103,83 +156,107 @@ func OutputMultiUserTestConfig(path string) error {
 	return nil
 }
 
-func generateSingleUserTestExample(test MultiUserTest, errorFunc func(resp TestResp), user string, beforeEach, afterEach func(), skipFunc func() bool) {
+// outputRoleNames 导出配置时使用的角色列表，读取 config.yaml 失败时使用内置的四个角色
+func outputRoleNames() []string {
+	if err := readEnvConfig(); err != nil {
+		clog.Warn("read config failed, output builtin roles: %v", err)
+		return []string{UserAdmin, UserProjectAdmin, UserTenantAdmin, UserNormal}
+	}
+	roles, err := loadRoles()
+	if err != nil {
+		clog.Warn("load roles failed, output builtin roles: %v", err)
+		return []string{UserAdmin, UserProjectAdmin, UserTenantAdmin, UserNormal}
+	}
+	return roles.Names()
+}
+
+// testSkipReason 判断是否为角色生成测试用例，不生成时返回原因
+func testSkipReason(tc *TestContext, test MultiUserTest, user string, skipFunc func(tc *TestContext) bool) (string, bool) {
+	if _, ok := ToTestMap[test.TestName]; !ok {
+		return "test is not configured in " + MultiConfig, true
+	}
+	if !UserContains(user) {
+		return "user is not in -runAs", true
+	}
+	if skipFunc(tc) {
+		return "skipped by skip func", true
+	}
+	if contains(TestConfig[test.TestName].SkipUsers, user) {
+		return "user is in skipUsers", true
+	}
+	return "", false
+}
+
+func generateSingleUserTestExample(tc *TestContext, test MultiUserTest, errorFunc func(tc *TestContext, resp TestResp), user string, beforeEach, afterEach func(), skipFunc func(tc *TestContext) bool) {
 	_ = ginkgo.Describe(test.TestName, func() {
-		testExampleConfiguredByUser, ok := ToTestMap[test.TestName]
-		if !ok {
-			clog.Info("no test named detected %s", test.TestName)
+		if reason, skipped := testSkipReason(tc, test, user, skipFunc); skipped {
+			clog.Info("test %s is not generated for user %s: %s", test.TestName, user, reason)
 			return
 		}
 
-		if !UserContains(user) {
-			clog.Info("user %s is not in runAs list", user)
+		graph := newStepGraph(test)
+		selected := selectSteps(test, user, graph.deps)
+		if len(selected) == 0 {
+			clog.Info("test %s is not selected for user %s", test.TestName, user)
 			return
 		}
 
-		if skipFunc() {
-			clog.Info("test is skipped by skip func %s", test.TestName)
+		if reason, unmet := tc.requirementSkipReason(test); unmet {
+			clog.Info("test %s is skipped for user %s: %s", test.TestName, user, reason)
+			skipTestExample(test, []*roleRun{{ctx: tc.ForUser(user), selected: selected}}, reason)
 			return
 		}
 
-		userTestFromConfig := TestConfig[test.TestName]
-		if contains(userTestFromConfig.SkipUsers, user) {
-			clog.Info("user %s is skipped in test %s", user, test.TestName)
-			return
-		}
+		recordHAR(tc)
 
-		flag := false
+		total := len(selected)
+		if test.InitStep != nil {
+			total++
+		}
+		if test.FinalStep != nil {
+			total++
+		}
+		scope := clusterScope(tc, test.TestName+"/"+user)
+		beginTrack, cleanupTracked := trackTest(tc, scope, total)
+		takeSnapshot, diffSnapshot := snapshotTest(tc, test, scope, total)
+		ginkgo.BeforeEach(beginTrack)
+		ginkgo.BeforeEach(takeSnapshot)
 
 		ginkgo.BeforeEach(func() {
-			if !flag && beforeEach != nil {
+			if !graph.anyBlocked() && beforeEach != nil {
 				beforeEach()
 			}
 		})
 
 		ginkgo.AfterEach(func() {
-			if !flag && afterEach != nil {
+			if !graph.anyBlocked() && afterEach != nil {
 				afterEach()
 			}
 		})
 
+		// 在测试的 AfterEach 之后清理测试创建的对象
+		ginkgo.AfterEach(cleanupTracked)
+		// 测试创建的对象清理后再比较共享资源
+		ginkgo.AfterEach(diffSnapshot)
+
 		ginkgo.Context("测试用例", func() {
-			getUser := GetUser(user)
+			userCtx := tc.ForUser(user)
 
 			if test.InitStep != nil {
 				step := test.InitStep
 				ginkgo.It(user+" : "+step.Name, func() {
-					if len(step.Description) > 0 {
-						ginkgo.By(step.Description)
-					}
-					clog.Info("running init step as %s \n", getUser)
-					step.StepFunc(getUser)
+					runUserHook(userCtx, step, "init")
 				})
 			}
 
 			for _, s := range test.Steps {
 				step := s
+				if _, ok := selected[step.Name]; !ok {
+					continue
+				}
 				ginkgo.It(user+" : "+step.Name, func() {
-					if flag {
-						clog.Info("step before failed, skipping remaining steps")
-						ginkgo.Skip("ignore")
-					}
-
-					if len(step.Description) > 0 {
-						ginkgo.By(step.Description)
-					}
-
-					testFunc := step.StepFunc
-
-					clog.Info("running %s step %s as %s", test.TestName, step.Name, getUser)
-					resp := testFunc(getUser)
-					if !userTestFromConfig.ContinueIfError && resp.Err != nil {
-						flag = true
-					}
-
-					if !testExampleConfiguredByUser[step.Name].ExpectPass[user] {
-						flag = true
-						errorFunc(resp)
-					} else {
-						ExpectNoError(resp.Err)
+					if reason, skipped := runUserStep(userCtx, test, step, graph, errorFunc); skipped {
+						ginkgo.Skip(reason)
 					}
 				})
 
@@ -188,13 +265,62 @@ func generateSingleUserTestExample(test MultiUserTest, errorFunc func(resp TestR
 			if test.FinalStep != nil {
 				step := test.FinalStep
 				ginkgo.It(user+" : "+step.Name, func() {
-					if len(step.Description) > 0 {
-						ginkgo.By(step.Description)
-					}
-					clog.Info("running final step as %s \n", getUser)
-					step.StepFunc(getUser)
+					runUserHook(userCtx, step, "final")
 				})
 			}
 		})
 	})
 }
+
+// runUserHook 以用户身份执行 InitStep 或 FinalStep
+func runUserHook(userCtx *TestContext, step *MultiUserTestStep, kind string) {
+	if len(step.Description) > 0 {
+		ginkgo.By(step.Description)
+	}
+	clog.Info("running %s step as %s \n", kind, userCtx.User)
+	step.StepFunc(userCtx)
+}
+
+// runUserStep 以用户身份执行一个步骤并按预期断言，依赖的步骤未成功时返回跳过原因
+func runUserStep(userCtx *TestContext, test MultiUserTest, step MultiUserTestStep, graph *stepGraph, errorFunc func(tc *TestContext, resp TestResp)) (string, bool) {
+	configured := ToTestMap[test.TestName][step.Name]
+	expect := userCtx.Roles.Expectation(configured, userCtx.Role)
+	policy := userCtx.StepPolicy(step, configured)
+	if reason, blocked := graph.blockedBy(step.Name); blocked {
+		graph.finish(step.Name, true, fmt.Sprintf("was skipped (%s)", reason))
+		recordSkipped(userCtx, test.TestName, step, expect, policy, reason)
+		clog.Info("skip step %s as %s: %s", step.Name, userCtx.User, reason)
+		return reason, true
+	}
+
+	// 步骤内断言失败时依赖它的步骤同样跳过，ContinueIfError 只对未声明 DependsOn 时的隐式依赖生效
+	continueIfError := TestConfig[test.TestName].ContinueIfError && !graph.explicit
+	blocked, reason := !continueIfError, "failed"
+	defer func() {
+		graph.finish(step.Name, blocked, reason)
+	}()
+
+	if len(step.Description) > 0 {
+		ginkgo.By(step.Description)
+	}
+
+	clog.Info("running %s step %s as %s with %s", test.TestName, step.Name, userCtx.User, policy)
+	resp, status := recordStep(userCtx.forStep(policy), test.TestName, step, expect, policy)
+
+	switch {
+	case expect.Pass:
+		err := expect.Check(resp, status)
+		blocked = err != nil && !continueIfError
+		if blocked {
+			reason = fmt.Sprintf("failed: %v", err)
+		}
+		userCtx.ExpectNoError(err)
+	case expect.Detailed():
+		blocked, reason = true, "is expected to fail"
+		userCtx.ExpectNoError(expect.Check(resp, status))
+	default:
+		blocked, reason = true, "is expected to fail"
+		errorFunc(userCtx, resp)
+	}
+	return "", false
+}
@@ -18,6 +18,7 @@ package framework
 
 import (
 	"os"
+	"time"
 
 	"github.com/kubecube-io/kubecube/pkg/clog"
 	"gopkg.in/yaml.v3"
@@ -35,12 +36,20 @@ type MultiUserTest struct {
 	ContinueIfError bool                `yaml:"continueIfError"`
 	Steps           []MultiUserTestStep `yaml:"steps"`
 	SkipUsers       []string            `yaml:"skipUsers"`
-	BeforeEach      func()              `yaml:"-"`
-	AfterEach       func()              `yaml:"-"`
-	Skipfunc        func() bool         `yaml:"-"`
-	ErrorFunc       func(resp TestResp) `yaml:"-"`
-	InitStep        *MultiUserTestStep  `yaml:"-"`
-	FinalStep       *MultiUserTestStep  `yaml:"-"`
+	// Labels 测试的标签，如 smoke，可通过 -select 选择
+	Labels []string `yaml:"labels,omitempty"`
+	// SideEffects 测试有意修改且不恢复的共享资源类型，如 CubeResourceQuota，测试前后比较快照时忽略
+	SideEffects []string `yaml:"sideEffects,omitempty"`
+	// Components 测试依赖的 Hotplug 组件，如 audit，测试集群中未启用或部署失败时跳过测试
+	Components []string `yaml:"components,omitempty"`
+	// Capabilities 测试依赖的集群能力，如 CapabilityPV，预检查探测不到时跳过测试
+	Capabilities []string                             `yaml:"capabilities,omitempty"`
+	BeforeEach   func()                               `yaml:"-"`
+	AfterEach    func()                               `yaml:"-"`
+	Skipfunc     func(tc *TestContext) bool           `yaml:"-"`
+	ErrorFunc    func(tc *TestContext, resp TestResp) `yaml:"-"`
+	InitStep     *MultiUserTestStep                   `yaml:"-"`
+	FinalStep    *MultiUserTestStep                   `yaml:"-"`
 }
 
 type MultiUserTestStep struct {
@@ -48,6 +57,34 @@ type MultiUserTestStep struct {
 	Description string          `yaml:"description"`
 	StepFunc    TestFunc        `yaml:"-"`
 	ExpectPass  map[string]bool `yaml:"expectPass,omitempty"`
+	// Expect 各角色的详细预期，可指定状态码与错误信息，优先于 ExpectPass
+	Expect map[string]Expectation `yaml:"expect,omitempty"`
+	// DependsOn 依赖的步骤，依赖的步骤未成功时跳过当前步骤。
+	// 测试中所有步骤都未声明时，每个步骤依赖上一个步骤，multiConfig.yaml 中配置的值优先于代码中的值
+	DependsOn []string `yaml:"dependsOn,omitempty"`
+	// Timeout 步骤（含重试）的超时时间，同时限制步骤内的 tc.WaitTimeout，为 0 时使用 timeout.waitTimeout 且不限制步骤时长
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// Retries 结果不符合预期时的重试次数
+	Retries int `yaml:"retries,omitempty"`
+	// Backoff 重试的退避策略，默认以 timeout.waitInterval 为间隔
+	Backoff *BackoffPolicy `yaml:"backoff,omitempty"`
+
+	// timeoutSet、retriesSet multiConfig.yaml 中是否配置了 timeout 与 retries，配置为 0 时同样覆盖代码中的值
+	timeoutSet bool
+	retriesSet bool
+	// dependsOnSet multiConfig.yaml 中是否配置了 dependsOn，配置为空列表时步骤不依赖任何步骤
+	dependsOnSet bool
+}
+
+func (s *MultiUserTestStep) UnmarshalYAML(value *yaml.Node) error {
+	type plain MultiUserTestStep
+	if err := value.Decode((*plain)(s)); err != nil {
+		return err
+	}
+	s.timeoutSet = hasKey(value, "timeout")
+	s.retriesSet = hasKey(value, "retries")
+	s.dependsOnSet = hasKey(value, "dependsOn")
+	return nil
 }
 
 type MultiUserTestConfig struct {
@@ -67,12 +104,23 @@ func InitMultiConfig() error {
 		for _, test := range tests {
 			m := make(map[string]MultiUserTestStep)
 			for _, step := range test.Steps {
+				if err := validateStepPolicy(test.TestName, step); err != nil {
+					clog.Error(err.Error())
+					return err
+				}
 				m[step.Name] = step
 			}
 			ToTestMap[test.TestName] = m
 			TestConfig[test.TestName] = test
 		}
 	}
+	// 注册测试时已校验代码中的依赖，这里校验 multiConfig.yaml 覆盖后的依赖
+	for _, test := range ConfigHelper {
+		if err := validateDependsOn(withConfiguredDependsOn(test)); err != nil {
+			clog.Error(err.Error())
+			return err
+		}
+	}
 	return nil
 }
 
@@ -118,7 +166,8 @@ func UserContains(user string) bool {
 	return contains(TestUser, user)
 }
 
-func GetAllUsersAvailable() []string {
+// GetAllUsersAvailable 返回参与测试的角色，multiConfig.yaml 未指定 allUsers 时使用角色注册表中的全部角色
+func GetAllUsersAvailable(roles *RoleRegistry) []string {
 	if len(config.AllUsers) == 0 {
 		err := loadConfig()
 		if err != nil {
@@ -127,7 +176,7 @@ func GetAllUsersAvailable() []string {
 	}
 
 	if len(config.AllUsers) == 0 {
-		config.AllUsers = []string{UserAdmin, UserProjectAdmin, UserTenantAdmin, UserNormal}
+		return roles.Names()
 	}
 
 	return config.AllUsers
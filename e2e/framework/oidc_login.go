@@ -0,0 +1,346 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"golang.org/x/net/html"
+	"k8s.io/apimachinery/pkg/util/uuid"
+
+	"github.com/kubecube-io/kubecube-e2e/util/constants"
+	"github.com/kubecube-io/kubecube-e2e/util/fakeidp"
+	backoff "github.com/kubecube-io/kubecube-e2e/util/retry"
+)
+
+const defaultOIDCCallbackPath = "/api/v1/cube/oauth/redirect"
+
+var oidcLogin = &OIDCLogin{}
+
+// OIDCConfig OAuth2/OIDC 登录配置
+type OIDCConfig struct {
+	// Fake 是否在本进程内启动 fake IdP，KubeCube 需配置为信任该 IdP
+	Fake   bool   `mapstructure:"fake"`
+	Listen string `mapstructure:"listen"`
+	// Issuer IdP 的访问地址，需同时可被 e2e 与 KubeCube 访问
+	Issuer       string   `mapstructure:"issuer"`
+	ClientID     string   `mapstructure:"clientID"`
+	ClientSecret string   `mapstructure:"clientSecret"`
+	Scopes       []string `mapstructure:"scopes"`
+	// CallbackPath KubeCube 接收授权码的回调路径
+	CallbackPath string `mapstructure:"callbackPath"`
+	// UsernameField/PasswordField IdP 登录表单中的字段名，为空时按输入框类型识别
+	UsernameField string `mapstructure:"usernameField"`
+	PasswordField string `mapstructure:"passwordField"`
+}
+
+// OIDCLogin 走授权码流程登录：打开 IdP 授权地址，在返回的登录页表单中填入用户名密码并提交，
+// 跟随重定向到 KubeCube 回调地址，由 KubeCube 以授权码换取身份并写入 cookie
+type OIDCLogin struct {
+	cfg                   OIDCConfig
+	authorizationEndpoint string
+	server                *fakeidp.Server
+}
+
+func init() {
+	Register(constants.OIDCLoginType, oidcLogin)
+}
+
+func (o *OIDCLogin) AuthHeader() string {
+	return constants.AuthorizationHeader
+}
+
+// Configure 按需启动 fake IdP 并通过 discovery 获取授权端点，fake IdP 在进程生命周期内保持运行，
+// 监听地址已被同机其它 e2e 进程占用时直接使用该进程的 fake IdP
+func (o *OIDCLogin) Configure(cfg *Config) error {
+	o.cfg = cfg.OIDC
+	if len(o.cfg.CallbackPath) == 0 {
+		o.cfg.CallbackPath = defaultOIDCCallbackPath
+	}
+	if len(o.cfg.Scopes) == 0 {
+		o.cfg.Scopes = []string{"openid", "profile", "email"}
+	}
+
+	if o.cfg.Fake && o.server == nil {
+		idpCfg := fakeidp.Config{
+			Issuer:       o.cfg.Issuer,
+			ClientID:     o.cfg.ClientID,
+			ClientSecret: o.cfg.ClientSecret,
+		}
+		for _, role := range cfg.Roles.Roles() {
+			idpCfg.Users = append(idpCfg.Users, fakeidp.User{
+				Username: role.Username,
+				Password: role.Password,
+				Email:    role.Username + "@e2e.kubecube.io",
+			})
+		}
+		server, err := fakeidp.New(idpCfg)
+		if err != nil {
+			return err
+		}
+		listen := o.cfg.Listen
+		if len(listen) == 0 {
+			listen = ":10556"
+		}
+		started, err := startFakeServer("fake oidc idp", listen, server.Start)
+		if err != nil {
+			return err
+		}
+		if started {
+			clog.Info("fake oidc idp listening on %s, issuer %s", server.Addr(), o.cfg.Issuer)
+			o.server = server
+		}
+	}
+
+	endpoint, err := discoverAuthorizationEndpoint(o.cfg.Issuer)
+	if err != nil {
+		return err
+	}
+	o.authorizationEndpoint = endpoint
+	return nil
+}
+
+func (o *OIDCLogin) LoginByUser(host string, user *AuthUser) error {
+	if len(o.authorizationEndpoint) == 0 {
+		return errors.New("oidc login is not configured")
+	}
+	hostURL, err := url.Parse(host)
+	if err != nil {
+		return err
+	}
+	authURL, err := url.Parse(o.authorizationEndpoint)
+	if err != nil {
+		return err
+	}
+	callback := strings.TrimSuffix(host, "/") + o.cfg.CallbackPath
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return err
+	}
+	cli := &http.Client{
+		Jar:       jar,
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+		// IdP 内部的跳转与到 KubeCube 回调的跳转都需要跟随，KubeCube 处理回调后跳转到前端的重定向不再跟随
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			if isOIDCCallback(via[len(via)-1].URL, hostURL, o.cfg.CallbackPath) {
+				return http.ErrUseLastResponse
+			}
+			return nil
+		},
+	}
+
+	q := authURL.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", o.cfg.ClientID)
+	q.Set("redirect_uri", callback)
+	q.Set("scope", strings.Join(o.cfg.Scopes, " "))
+	q.Set("state", string(uuid.NewUUID()))
+	authURL.RawQuery = q.Encode()
+
+	// 打开授权地址，IdP 返回（或跳转到）登录页
+	resp, err := cli.Get(authURL.String())
+	if err != nil {
+		clog.Error("oidc login fail, error: %v", err)
+		return err
+	}
+	page, err := readOIDCResponse(resp)
+	if err != nil {
+		return err
+	}
+	form, err := o.loginForm(resp.Request.URL, page, user)
+	if err != nil {
+		return err
+	}
+
+	// 提交登录表单，IdP 校验通过后携带 code 跳转到 KubeCube 回调
+	resp, err = cli.PostForm(form.action, form.values)
+	if err != nil {
+		clog.Error("oidc login fail, error: %v", err)
+		return err
+	}
+	body, err := readOIDCResponse(resp)
+	if err != nil {
+		return err
+	}
+	if !isOIDCCallback(resp.Request.URL, hostURL, o.cfg.CallbackPath) {
+		clog.Error("oidc login fail, login form of %s was not accepted", resp.Request.URL.Host)
+		return backoff.Permanent(fmt.Errorf("oidc login fail, stopped at %s", resp.Request.URL.Redacted()))
+	}
+	if e := resp.Request.URL.Query().Get("error"); len(e) > 0 {
+		clog.Error("oidc login fail, idp returned error %s", e)
+		return backoff.Permanent(fmt.Errorf("oidc login fail, idp returned error %s", e))
+	}
+
+	cookies := jar.Cookies(hostURL)
+	if len(cookies) < 1 {
+		clog.Error("oidc login fail, no cookie set by %s", host)
+		return fmt.Errorf("get cookie error")
+	}
+	user.Cookie = cookies[0]
+
+	// KubeCube 回调直接返回 token 时同时使用 token 认证
+	result := struct {
+		Token string `json:"token"`
+	}{}
+	if json.Unmarshal(body, &result) == nil && len(result.Token) > 0 {
+		user.Token = "Bearer " + result.Token
+	}
+	return nil
+}
+
+// oidcForm 待提交的登录表单
+type oidcForm struct {
+	action string
+	values url.Values
+}
+
+// loginForm 在登录页中查找包含密码输入框的表单，保留其中的隐藏字段并填入用户名密码，
+// 未配置字段名时密码取 password 类型的输入框，用户名取第一个文本输入框
+func (o *OIDCLogin) loginForm(page *url.URL, body []byte, user *AuthUser) (*oidcForm, error) {
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	var found *oidcForm
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if found != nil {
+			return
+		}
+		if n.Type == html.ElementNode && n.Data == "form" {
+			found = o.fillForm(page, n, user)
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	if found == nil {
+		return nil, fmt.Errorf("oidc login fail, no login form found at %s", page.Redacted())
+	}
+	return found, nil
+}
+
+// fillForm 填写一个表单，表单中没有用户名或密码输入框时返回 nil
+func (o *OIDCLogin) fillForm(page *url.URL, form *html.Node, user *AuthUser) *oidcForm {
+	action, err := page.Parse(htmlAttr(form, "action"))
+	if err != nil {
+		return nil
+	}
+	values := url.Values{}
+	usernameField, passwordField := o.cfg.UsernameField, o.cfg.PasswordField
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "input" {
+			name := htmlAttr(n, "name")
+			if len(name) == 0 {
+				return
+			}
+			switch strings.ToLower(htmlAttr(n, "type")) {
+			case "password":
+				if len(passwordField) == 0 {
+					passwordField = name
+				}
+			case "", "text", "email":
+				if len(usernameField) == 0 {
+					usernameField = name
+				}
+			case "hidden":
+				values.Add(name, htmlAttr(n, "value"))
+			}
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(form)
+	if len(usernameField) == 0 || len(passwordField) == 0 {
+		return nil
+	}
+	values.Set(usernameField, user.Username)
+	values.Set(passwordField, user.Password)
+	return &oidcForm{action: action.String(), values: values}
+}
+
+func htmlAttr(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+func isOIDCCallback(u, host *url.URL, callbackPath string) bool {
+	return u.Host == host.Host && u.Path == callbackPath
+}
+
+func readOIDCResponse(resp *http.Response) ([]byte, error) {
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		clog.Error("oidc login fail, url: %s, code: %d, body: %s", resp.Request.URL.Redacted(), resp.StatusCode, string(body))
+		return nil, loginStatusError(resp.StatusCode, fmt.Errorf("oidc login fail, code %d", resp.StatusCode))
+	}
+	return body, nil
+}
+
+func discoverAuthorizationEndpoint(issuer string) (string, error) {
+	if len(issuer) == 0 {
+		return "", errors.New("oidc issuer can not be empty")
+	}
+	resp, err := httpclient.Get(strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("oidc discovery fail, code %d", resp.StatusCode)
+	}
+	discovery := struct {
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+	}{}
+	err = json.NewDecoder(resp.Body).Decode(&discovery)
+	if err != nil {
+		return "", err
+	}
+	if len(discovery.AuthorizationEndpoint) == 0 {
+		return "", errors.New("oidc discovery has no authorization_endpoint")
+	}
+	return discovery.AuthorizationEndpoint, nil
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/kubecube-io/kubecube-e2e/util/fakeidp"
+)
+
+// startOIDCTestEnv 启动 fake IdP 与模拟 KubeCube 回调的服务，回调以授权码向 IdP 换取 token 后写入 cookie
+func startOIDCTestEnv(t *testing.T) (idp, cube *httptest.Server) {
+	t.Helper()
+	idp = httptest.NewUnstartedServer(nil)
+	issuer := "http://" + idp.Listener.Addr().String()
+	server, err := fakeidp.New(fakeidp.Config{
+		Issuer:       issuer,
+		ClientID:     "kubecube",
+		ClientSecret: "secret",
+		Users:        []fakeidp.User{{Username: "alice", Password: "alice-pw"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp.Config.Handler = server.Handler()
+	idp.Start()
+	t.Cleanup(idp.Close)
+
+	cube = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != defaultOIDCCallbackPath {
+			http.NotFound(w, r)
+			return
+		}
+		if len(r.URL.Query().Get("code")) == 0 {
+			http.Error(w, "missing code", http.StatusBadRequest)
+			return
+		}
+		resp, err := http.PostForm(issuer+"/token", url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {r.URL.Query().Get("code")},
+			"client_id":     {"kubecube"},
+			"client_secret": {"secret"},
+			"redirect_uri":  {"http://" + r.Host + defaultOIDCCallbackPath},
+		})
+		if err != nil || resp.StatusCode != http.StatusOK {
+			http.Error(w, "exchange code failed", http.StatusUnauthorized)
+			return
+		}
+		resp.Body.Close()
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "alice", Path: "/"})
+		http.Redirect(w, r, "/dashboard", http.StatusFound)
+	}))
+	t.Cleanup(cube.Close)
+	return idp, cube
+}
+
+func TestOIDCLoginByUser(t *testing.T) {
+	idp, cube := startOIDCTestEnv(t)
+	login := &OIDCLogin{}
+	err := login.Configure(&Config{OIDC: OIDCConfig{Issuer: idp.URL, ClientID: "kubecube"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name     string
+		password string
+		wantErr  bool
+	}{
+		{name: "valid credentials", password: "alice-pw"},
+		{name: "wrong password", password: "wrong", wantErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			user := &AuthUser{Username: "alice", Password: c.password}
+			err := login.LoginByUser(cube.URL, user)
+			if c.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if user.Cookie == nil || user.Cookie.Value != "alice" {
+				t.Fatalf("unexpected cookie %v", user.Cookie)
+			}
+		})
+	}
+}
+
+func TestOIDCLoginForm(t *testing.T) {
+	page, _ := url.Parse("https://idp.example.com/realms/kubecube/protocol/openid-connect/auth?client_id=kubecube")
+	cases := []struct {
+		name   string
+		cfg    OIDCConfig
+		body   string
+		action string
+		values url.Values
+	}{
+		{
+			name: "detect fields by type",
+			body: `<html><body>
+<form id="search" action="/search"><input type="search" name="q"></form>
+<form method="post" action="login-actions/authenticate?session_code=abc">
+<input type="hidden" name="execution" value="e1">
+<input id="username" name="username" type="text">
+<input id="password" name="password" type="password">
+<input type="submit" name="login" value="Sign In">
+</form></body></html>`,
+			action: "https://idp.example.com/realms/kubecube/protocol/openid-connect/login-actions/authenticate?session_code=abc",
+			values: url.Values{"execution": {"e1"}, "username": {"alice"}, "password": {"alice-pw"}},
+		},
+		{
+			name: "configured field names",
+			cfg:  OIDCConfig{UsernameField: "login", PasswordField: "secret"},
+			body: `<form method="post" action="https://login.example.com/session">
+<input name="login" type="email"><input name="secret" type="password"></form>`,
+			action: "https://login.example.com/session",
+			values: url.Values{"login": {"alice"}, "secret": {"alice-pw"}},
+		},
+		{
+			name: "no login form",
+			body: `<html><body><p>already logged in</p></body></html>`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			login := &OIDCLogin{cfg: c.cfg}
+			form, err := login.loginForm(page, []byte(c.body), &AuthUser{Username: "alice", Password: "alice-pw"})
+			if len(c.action) == 0 {
+				if err == nil {
+					t.Fatalf("expected error, got form %+v", form)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if form.action != c.action {
+				t.Errorf("action = %s, want %s", form.action, c.action)
+			}
+			if form.values.Encode() != c.values.Encode() {
+				t.Errorf("values = %s, want %s", form.values.Encode(), c.values.Encode())
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// 执行计划中步骤的类型
+const (
+	PlanStepInit  = "init"
+	PlanStepMain  = "step"
+	PlanStepFinal = "final"
+)
+
+// PlanStep 执行计划中某个角色的一个步骤
+type PlanStep struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+	Run  bool   `json:"run"`
+	// Reason 不执行的原因
+	Reason string `json:"reason,omitempty"`
+	Expect string `json:"expect,omitempty"`
+	Policy string `json:"policy,omitempty"`
+	// DependsOn 依赖的步骤，依赖的步骤失败时运行时会跳过该步骤
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
+
+// PlanRole 执行计划中一个角色执行一个测试的情况
+type PlanRole struct {
+	Role   string      `json:"role"`
+	User   string      `json:"user"`
+	Run    bool        `json:"run"`
+	Reason string      `json:"reason,omitempty"`
+	Steps  []*PlanStep `json:"steps,omitempty"`
+}
+
+// PlanTest 执行计划中在一个测试集群中执行的测试
+type PlanTest struct {
+	Cluster string   `json:"cluster"`
+	Test    string   `json:"test"`
+	ID      string   `json:"id,omitempty"`
+	Module  string   `json:"module,omitempty"`
+	Labels  []string `json:"labels,omitempty"`
+	// Components 与 Capabilities 为测试依赖的 Hotplug 组件与集群能力，-dryRun 不执行预检查，只按 config.yaml 判断能力
+	Components   []string    `json:"components,omitempty"`
+	Capabilities []string    `json:"capabilities,omitempty"`
+	Roles        []*PlanRole `json:"roles"`
+}
+
+// ExecutionPlan 按注册、配置与过滤条件计算出的执行计划
+type ExecutionPlan struct {
+	GeneratedAt time.Time   `json:"generatedAt"`
+	RunAs       []string    `json:"runAs"`
+	Clusters    []string    `json:"clusters"`
+	Roles       []string    `json:"roles"`
+	Tests       []*PlanTest `json:"tests"`
+	// Steps 将执行的步骤数（集群 × 测试 × 角色 × 步骤）
+	Steps int `json:"steps"`
+	// Skipped 不执行的步骤数
+	Skipped int `json:"skipped"`
+}
+
+// BuildExecutionPlan 按与 CreateTestExamples 相同的规则计算每个测试集群中每个测试、角色与步骤是否执行，不访问集群
+func BuildExecutionPlan(tc *TestContext) *ExecutionPlan {
+	roles := GetAllUsersAvailable(tc.Roles)
+	plan := &ExecutionPlan{
+		GeneratedAt: time.Now(),
+		RunAs:       TestUser,
+		Clusters:    tc.TargetClusters,
+		Roles:       roles,
+	}
+	for _, cluster := range tc.TargetClusters {
+		clusterCtx := tc.ForCluster(cluster)
+		for _, test := range ConfigHelper {
+			id, module := ParseTestName(test.TestName)
+			pt := &PlanTest{
+				Cluster:      cluster,
+				Test:         test.TestName,
+				ID:           id,
+				Module:       module,
+				Labels:       testLabels(test),
+				Components:   test.Components,
+				Capabilities: test.Capabilities,
+			}
+			skipFunc := test.Skipfunc
+			if skipFunc == nil {
+				skipFunc = DefaultSkipFunc
+			}
+			for _, role := range roles {
+				pr := planRole(clusterCtx, test, role, skipFunc)
+				for _, step := range pr.Steps {
+					if step.Run {
+						plan.Steps++
+					} else {
+						plan.Skipped++
+					}
+				}
+				pt.Roles = append(pt.Roles, pr)
+			}
+			plan.Tests = append(plan.Tests, pt)
+		}
+	}
+	return plan
+}
+
+func planRole(tc *TestContext, test MultiUserTest, role string, skipFunc func(tc *TestContext) bool) *PlanRole {
+	pr := &PlanRole{Role: role, User: tc.GetUser(role), Run: true}
+	reason, skipped := testSkipReason(tc, test, role, skipFunc)
+	graph := newStepGraph(test)
+	selected := selectSteps(test, role, graph.deps)
+	if !skipped && len(selected) == 0 {
+		reason, skipped = "no step is selected by -select", true
+	}
+	if !skipped {
+		reason, skipped = tc.requirementSkipReason(test)
+	}
+	if skipped {
+		pr.Run, pr.Reason = false, reason
+	}
+
+	configured := ToTestMap[test.TestName]
+	if test.InitStep != nil {
+		pr.Steps = append(pr.Steps, &PlanStep{Name: test.InitStep.Name, Kind: PlanStepInit, Run: pr.Run, Reason: pr.Reason})
+	}
+	for _, step := range test.Steps {
+		ps := &PlanStep{
+			Name:      step.Name,
+			Kind:      PlanStepMain,
+			Run:       pr.Run,
+			Reason:    pr.Reason,
+			Expect:    tc.Roles.Expectation(configured[step.Name], role).String(),
+			Policy:    tc.StepPolicy(step, configured[step.Name]).String(),
+			DependsOn: graph.deps[step.Name],
+		}
+		if _, ok := selected[step.Name]; pr.Run && !ok {
+			ps.Run, ps.Reason = false, "not selected by -select"
+		}
+		pr.Steps = append(pr.Steps, ps)
+	}
+	if test.FinalStep != nil {
+		pr.Steps = append(pr.Steps, &PlanStep{Name: test.FinalStep.Name, Kind: PlanStepFinal, Run: pr.Run, Reason: pr.Reason})
+	}
+	return pr
+}
+
+// JSON 输出 JSON 格式的执行计划
+func (p *ExecutionPlan) JSON() ([]byte, error) {
+	return json.MarshalIndent(p, "", "  ")
+}
+
+// Text 输出便于阅读的执行计划，不执行的角色与步骤后附原因
+func (p *ExecutionPlan) Text() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "execution plan, runAs %v, clusters %v, %d steps to run, %d skipped\n", p.RunAs, p.Clusters, p.Steps, p.Skipped)
+	for _, test := range p.Tests {
+		fmt.Fprintf(&b, "\n[%s] %s\n", test.Cluster, test.Test)
+		if len(test.Components) > 0 || len(test.Capabilities) > 0 {
+			fmt.Fprintf(&b, "  requires components %v, capabilities %v\n", test.Components, test.Capabilities)
+		}
+		for _, role := range test.Roles {
+			if !role.Run {
+				fmt.Fprintf(&b, "  - %s (%s): skip, %s\n", role.Role, role.User, role.Reason)
+				continue
+			}
+			fmt.Fprintf(&b, "  + %s (%s)\n", role.Role, role.User)
+			for _, step := range role.Steps {
+				switch {
+				case !step.Run:
+					fmt.Fprintf(&b, "      - %s: skip, %s\n", step.Name, step.Reason)
+				case step.Kind != PlanStepMain:
+					fmt.Fprintf(&b, "      + %s [%s]\n", step.Name, step.Kind)
+				default:
+					fmt.Fprintf(&b, "      + %s: expect %s, %s", step.Name, step.Expect, step.Policy)
+					if len(step.DependsOn) > 0 {
+						fmt.Fprintf(&b, ", depends on %v", step.DependsOn)
+					}
+					b.WriteString("\n")
+				}
+			}
+		}
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,310 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	clusterv1 "github.com/kubecube-io/kubecube/pkg/apis/cluster/v1"
+	hotplugv1 "github.com/kubecube-io/kubecube/pkg/apis/hotplug/v1"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/onsi/ginkgo"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// 内置的集群能力，测试通过 MultiUserTest.Capabilities 声明依赖
+const (
+	// CapabilityPV 测试集群中存在 workload.storageClass 指定的 StorageClass
+	CapabilityPV = "pv"
+	// CapabilityCloudShell webconsole 可以访问
+	CapabilityCloudShell = "cloudshell"
+)
+
+// Hotplug 组件状态，与 KubeCube hotplug controller 写入的值一致
+const (
+	hotplugCommon  = "common"
+	hotplugEnabled = "enabled"
+	hotplugSuccess = "success"
+)
+
+// CapabilityProbe 探测当前测试集群是否具备某项能力，不具备时返回原因
+type CapabilityProbe func(tc *TestContext) error
+
+var capabilityProbes = map[string]CapabilityProbe{
+	CapabilityPV:         probePV,
+	CapabilityCloudShell: probeCloudShell,
+}
+
+// RegisterCapability 注册能力探测，需在 RunPreflight 之前调用，config.yaml 中显式开启或关闭的能力不会被探测
+func RegisterCapability(name string, probe CapabilityProbe) {
+	capabilityProbes[name] = probe
+}
+
+// Preflight 测试前的检查结果，key 为测试集群名
+type Preflight map[string]*ClusterPreflight
+
+// ClusterPreflight 一个测试集群的检查结果
+type ClusterPreflight struct {
+	Cluster string
+	// Hotplug 读取组件状态的 Hotplug，集群中没有 Hotplug 时为空
+	Hotplug string
+	// Components Hotplug status.results 中各组件的部署结果，key 为组件名
+	Components map[string]hotplugv1.DeployResult
+	// Capabilities 各能力的探测结果，可用时为空，不可用时为原因
+	Capabilities map[string]string
+}
+
+// RunPreflight 检查管控集群与所有测试集群的状态，存在状态不是 normal 的集群时返回错误；
+// 随后读取每个测试集群的 Hotplug 组件状态并探测已注册的能力，结果保存在 tc.Preflight 中
+func (tc *TestContext) RunPreflight() error {
+	var unhealthy []string
+	checked := make(map[string]struct{})
+	for _, cluster := range append([]string{tc.PivotClusterName}, tc.TargetClusters...) {
+		if _, ok := checked[cluster]; ok {
+			continue
+		}
+		checked[cluster] = struct{}{}
+		if err := tc.checkClusterState(cluster); err != nil {
+			unhealthy = append(unhealthy, err.Error())
+		}
+	}
+	if len(unhealthy) > 0 {
+		return fmt.Errorf("preflight failed: %s", strings.Join(unhealthy, "; "))
+	}
+
+	preflight := make(Preflight, len(tc.TargetClusters))
+	for _, cluster := range tc.TargetClusters {
+		p, err := tc.ForCluster(cluster).clusterPreflight()
+		if err != nil {
+			return fmt.Errorf("preflight of cluster %s failed: %v", cluster, err)
+		}
+		preflight[cluster] = p
+		clog.Info("preflight of cluster %s: %s", cluster, p)
+	}
+	tc.Preflight = preflight
+	return nil
+}
+
+// checkClusterState 读取管控集群中的 Cluster，状态不是 normal 时返回错误
+func (tc *TestContext) checkClusterState(cluster string) error {
+	c := &clusterv1.Cluster{}
+	err := tc.PivotClusterClient.Direct().Get(context.Background(), types.NamespacedName{Name: cluster}, c)
+	if err != nil {
+		return fmt.Errorf("get cluster %s failed: %v", cluster, err)
+	}
+	state := "unknown"
+	if c.Status.State != nil {
+		state = string(*c.Status.State)
+	}
+	if state != string(clusterv1.ClusterNormal) {
+		return fmt.Errorf("cluster %s is %s: %s", cluster, state, c.Status.Reason)
+	}
+	return nil
+}
+
+func (tc *TestContext) clusterPreflight() (*ClusterPreflight, error) {
+	p := &ClusterPreflight{
+		Cluster:      tc.TargetClusterName,
+		Components:   make(map[string]hotplugv1.DeployResult),
+		Capabilities: make(map[string]string, len(capabilityProbes)),
+	}
+	hotplug, err := tc.clusterHotplug()
+	if err != nil {
+		return nil, err
+	}
+	if hotplug != nil {
+		p.Hotplug = hotplug.Name
+		for _, r := range hotplug.Status.Results {
+			if r != nil {
+				p.Components[r.Name] = *r
+			}
+		}
+	}
+
+	for name, probe := range capabilityProbes {
+		p.Capabilities[name] = ""
+		if enabled, ok := tc.Capabilities[name]; ok {
+			if !enabled {
+				p.Capabilities[name] = "disabled in config"
+			}
+			continue
+		}
+		if err := probe(tc); err != nil {
+			p.Capabilities[name] = err.Error()
+		}
+	}
+	return p, nil
+}
+
+// clusterHotplug 读取测试集群中以集群名命名的 Hotplug，不存在时读取 common，都不存在时返回 nil
+func (tc *TestContext) clusterHotplug() (*hotplugv1.Hotplug, error) {
+	for _, name := range []string{tc.TargetClusterName, hotplugCommon} {
+		hotplug := &hotplugv1.Hotplug{}
+		err := tc.TargetClusterClient.Direct().Get(context.Background(), types.NamespacedName{Name: name}, hotplug)
+		switch {
+		case err == nil:
+			return hotplug, nil
+		case meta.IsNoMatchError(err):
+			return nil, nil
+		case !apierrors.IsNotFound(err):
+			return nil, fmt.Errorf("get hotplug %s failed: %v", name, err)
+		}
+	}
+	return nil, nil
+}
+
+// Unmet 返回 components 与 capabilities 中不满足的项及原因，全部满足时返回空
+func (p *ClusterPreflight) Unmet(components, capabilities []string) []string {
+	var unmet []string
+	for _, name := range components {
+		r, ok := p.Components[name]
+		switch {
+		case !ok && len(p.Hotplug) == 0:
+			unmet = append(unmet, fmt.Sprintf("component %s: no hotplug in cluster %s", name, p.Cluster))
+		case !ok:
+			unmet = append(unmet, fmt.Sprintf("component %s is not in hotplug %s", name, p.Hotplug))
+		case r.Status != hotplugEnabled:
+			unmet = append(unmet, fmt.Sprintf("component %s is %s", name, statusOrUnknown(r.Status)))
+		case r.Result != hotplugSuccess:
+			unmet = append(unmet, fmt.Sprintf("component %s is not deployed: %s", name, r.Message))
+		}
+	}
+	for _, name := range capabilities {
+		reason, ok := p.Capabilities[name]
+		switch {
+		case !ok:
+			unmet = append(unmet, fmt.Sprintf("capability %s is unknown", name))
+		case len(reason) > 0:
+			unmet = append(unmet, fmt.Sprintf("capability %s is unavailable: %s", name, reason))
+		}
+	}
+	return unmet
+}
+
+func (p *ClusterPreflight) String() string {
+	enabled := make([]string, 0, len(p.Components))
+	for name, r := range p.Components {
+		if r.Status == hotplugEnabled && r.Result == hotplugSuccess {
+			enabled = append(enabled, name)
+		}
+	}
+	var capabilities []string
+	for name, reason := range p.Capabilities {
+		if len(reason) == 0 {
+			capabilities = append(capabilities, name)
+		} else {
+			capabilities = append(capabilities, fmt.Sprintf("!%s(%s)", name, reason))
+		}
+	}
+	sort.Strings(enabled)
+	sort.Strings(capabilities)
+	return fmt.Sprintf("hotplug %q, enabled components %v, capabilities %v", p.Hotplug, enabled, capabilities)
+}
+
+func statusOrUnknown(s string) string {
+	if len(s) == 0 {
+		return "unknown"
+	}
+	return s
+}
+
+// requirementSkipReason 判断当前测试集群是否满足测试声明的组件与能力，不满足时返回原因。
+// 未执行预检查时（如 -dryRun）只检查 config.yaml 中关闭的能力
+func (tc *TestContext) requirementSkipReason(test MultiUserTest) (string, bool) {
+	var unmet []string
+	if p, ok := tc.Preflight[tc.TargetClusterName]; ok {
+		unmet = p.Unmet(test.Components, test.Capabilities)
+	} else {
+		for _, name := range test.Capabilities {
+			if enabled, ok := tc.Capabilities[name]; ok && !enabled {
+				unmet = append(unmet, fmt.Sprintf("capability %s is unavailable: disabled in config", name))
+			}
+		}
+	}
+	if len(unmet) == 0 {
+		return "", false
+	}
+	return "preflight: " + strings.Join(unmet, "; "), true
+}
+
+// HasCapability 当前测试集群是否具备能力，未执行预检查时只看 config.yaml 中的配置，未配置视为具备
+func (tc *TestContext) HasCapability(name string) bool {
+	if p, ok := tc.Preflight[tc.TargetClusterName]; ok {
+		reason, ok := p.Capabilities[name]
+		return ok && len(reason) == 0
+	}
+	enabled, ok := tc.Capabilities[name]
+	return !ok || enabled
+}
+
+// skipTestExample 为不满足预检查的测试生成跳过的用例，跳过原因写入测试报告与权限矩阵
+func skipTestExample(test MultiUserTest, runs []*roleRun, reason string) {
+	ginkgo.Context("测试用例", func() {
+		for _, s := range test.Steps {
+			step := s
+			var stepRuns []*roleRun
+			for _, r := range runs {
+				if _, ok := r.selected[step.Name]; ok {
+					stepRuns = append(stepRuns, r)
+				}
+			}
+			if len(stepRuns) == 0 {
+				continue
+			}
+			ginkgo.It(roleNames(stepRuns)+" : "+step.Name, func() {
+				configured := ToTestMap[test.TestName][step.Name]
+				for _, r := range stepRuns {
+					expect := r.ctx.Roles.Expectation(configured, r.ctx.Role)
+					recordSkipped(r.ctx, test.TestName, step, expect, r.ctx.StepPolicy(step, configured), reason)
+				}
+				ginkgo.Skip(reason)
+			})
+		}
+	})
+}
+
+func probePV(tc *TestContext) error {
+	sc := &storagev1.StorageClass{}
+	err := tc.TargetClusterClient.Direct().Get(context.Background(), types.NamespacedName{Name: tc.StorageClass}, sc)
+	if err != nil {
+		return fmt.Errorf("get storage class %s failed: %v", tc.StorageClass, err)
+	}
+	return nil
+}
+
+func probeCloudShell(tc *TestContext) error {
+	if len(tc.ConsoleHost) == 0 {
+		return fmt.Errorf("host.consoleHost is not configured")
+	}
+	cli := http.Client{
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+		Timeout:   tc.HttpRequestTimeout,
+	}
+	resp, err := cli.Get(tc.ConsoleHost)
+	if err != nil {
+		return fmt.Errorf("webconsole %s is unreachable: %v", tc.ConsoleHost, err)
+	}
+	return resp.Body.Close()
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/kubecube-io/kubecube/pkg/multicluster"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ProxyUrlPrefix KubeCube 转发 k8s 请求的路径前缀
+const ProxyUrlPrefix = "/api/v1/cube/proxy"
+
+// ProxyHost 返回经 KubeCube 访问 cluster 的 apiserver 地址
+func ProxyHost(host, cluster string) string {
+	return strings.TrimSuffix(host, "/") + ProxyUrlPrefix + "/clusters/" + cluster
+}
+
+// proxyTransport 以指定用户身份发送请求，复用 HttpHelper 的登录凭证、重新登录与请求录制
+type proxyTransport struct {
+	helper *HttpHelper
+	user   string
+}
+
+func (t *proxyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.helper.DoByUser(req, t.user)
+}
+
+// ProxyClient 返回以 user 身份经 KubeCube proxy 访问 cluster 的 client，
+// 返回的错误与 apiserver 一致，可使用 apierrors.IsForbidden 等判断
+func (h *HttpHelper) ProxyClient(cluster, user string, scheme *runtime.Scheme, mapper meta.RESTMapper) (client.Client, error) {
+	cfg := &rest.Config{
+		Host:      ProxyHost(h.Host, cluster),
+		Transport: &proxyTransport{helper: h, user: user},
+	}
+	httpClient, err := rest.HTTPClientFor(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return client.New(cfg, client.Options{
+		HTTPClient: httpClient,
+		Scheme:     scheme,
+		Mapper:     mapper,
+	})
+}
+
+// ProxyClient 返回以当前用户身份经 KubeCube proxy 访问 cluster 的 client，
+// scheme 与 RESTMapper 复用直连该集群的 client
+func (tc *TestContext) ProxyClient(cluster string) (client.Client, error) {
+	cli, err := multicluster.Interface().GetClient(cluster)
+	if err != nil {
+		return nil, fmt.Errorf("get client of cluster %s failed: %v", cluster, err)
+	}
+	return tc.HttpHelper.ProxyClient(cluster, tc.User, cli.Direct().Scheme(), cli.RESTMapper())
+}
+
+// TargetProxyClient 返回以当前用户身份经 KubeCube proxy 访问测试集群的 client
+func (tc *TestContext) TargetProxyClient() client.Client {
+	cli, err := tc.HttpHelper.ProxyClient(tc.TargetClusterName, tc.User,
+		tc.TargetClusterClient.Direct().Scheme(), tc.TargetClusterClient.RESTMapper())
+	tc.ExpectNoError(err, "proxy client of target cluster should be created")
+	return cli
+}
+
+// NewTestRespWithAPIError 将 client 返回的错误转换为 TestResp，Data 为 http 状态码，
+// 与 PermissionErrorFunc 配合判断无权限的操作
+func NewTestRespWithAPIError(err error) TestResp {
+	if err == nil {
+		return SucceedResp
+	}
+	var status apierrors.APIStatus
+	if errors.As(err, &status) {
+		return NewTestResp(err, int(status.Status().Code))
+	}
+	return NewTestRespWithErr(err)
+}
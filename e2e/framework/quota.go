@@ -0,0 +1,293 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	quotav1 "github.com/kubecube-io/kubecube/pkg/apis/quota/v1"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	QuotaKindCube      = "CubeResourceQuota"
+	QuotaKindNamespace = "ResourceQuota"
+)
+
+// QuotaNode 配额层级中的一个配额：租户、项目的 CubeResourceQuota 或空间的 ResourceQuota
+type QuotaNode struct {
+	Kind      string
+	Cluster   string
+	Namespace string
+	Name      string
+	Hard      corev1.ResourceList
+	// Used CubeResourceQuota 为 KubeCube 统计的子配额 hard 之和，ResourceQuota 为 k8s 统计的空间用量
+	Used corev1.ResourceList
+	// Observed 空间中 Pod 与 PVC 实际申请的资源，只有 ResourceQuota 有
+	Observed corev1.ResourceList
+	Children []*QuotaNode
+}
+
+func (n *QuotaNode) String() string {
+	name := n.Name
+	if len(n.Namespace) > 0 {
+		name = n.Namespace + "/" + name
+	}
+	return n.Kind + " " + name
+}
+
+// LoadQuotaTree 读取以 CubeResourceQuota name 为根的配额层级：spec.parentQuota 指向它的 CubeResourceQuota，
+// 以及 kubecube.io/quota 标签指向它的 ResourceQuota
+func (tc *TestContext) LoadQuotaTree(name string) (*QuotaNode, error) {
+	ctx := context.Background()
+	all := &quotav1.CubeResourceQuotaList{}
+	if err := tc.PivotClusterClient.Direct().List(ctx, all); err != nil {
+		return nil, fmt.Errorf("list cube resource quotas failed: %v", err)
+	}
+	quotas := make(map[string]*quotav1.CubeResourceQuota, len(all.Items))
+	for i := range all.Items {
+		quotas[all.Items[i].Name] = &all.Items[i]
+	}
+	if _, ok := quotas[name]; !ok {
+		return nil, fmt.Errorf("cube resource quota %s not found", name)
+	}
+	return tc.loadCubeQuota(ctx, quotas, name)
+}
+
+func (tc *TestContext) loadCubeQuota(ctx context.Context, quotas map[string]*quotav1.CubeResourceQuota, name string) (*QuotaNode, error) {
+	q := quotas[name]
+	node := &QuotaNode{
+		Kind:    QuotaKindCube,
+		Cluster: tc.PivotClusterName,
+		Name:    q.Name,
+		Hard:    q.Spec.Hard,
+		Used:    q.Status.Used,
+	}
+
+	var children []string
+	for _, sub := range quotas {
+		if sub.Spec.ParentQuota == name {
+			children = append(children, sub.Name)
+		}
+	}
+	sort.Strings(children)
+	for _, child := range children {
+		childNode, err := tc.loadCubeQuota(ctx, quotas, child)
+		if err != nil {
+			return nil, err
+		}
+		node.Children = append(node.Children, childNode)
+	}
+
+	// 空间配额在 CubeResourceQuota 所属的集群中
+	cluster := q.Labels[constants.ClusterLabel]
+	if len(cluster) == 0 {
+		cluster = tc.TargetClusterName
+	}
+	cli, err := tc.clusterClient(cluster)
+	if err != nil {
+		return nil, err
+	}
+	rqs := &corev1.ResourceQuotaList{}
+	if err = cli.Direct().List(ctx, rqs, ctrlclient.MatchingLabels{constants.CubeQuotaLabel: name}); err != nil {
+		return nil, fmt.Errorf("list resource quotas of %s in cluster %s failed: %v", name, cluster, err)
+	}
+	for i := range rqs.Items {
+		rq := &rqs.Items[i]
+		observed, err := namespaceUsage(ctx, cli.Direct(), rq.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		node.Children = append(node.Children, &QuotaNode{
+			Kind:      QuotaKindNamespace,
+			Cluster:   cluster,
+			Namespace: rq.Namespace,
+			Name:      rq.Name,
+			Hard:      rq.Spec.Hard,
+			Used:      rq.Status.Used,
+			Observed:  observed,
+		})
+	}
+	return node, nil
+}
+
+// namespaceUsage 统计空间中未结束的 Pod 申请的 cpu、memory 与 PVC 申请的存储，计算方式与 k8s 配额一致
+func namespaceUsage(ctx context.Context, cli ctrlclient.Client, namespace string) (corev1.ResourceList, error) {
+	usage := corev1.ResourceList{}
+	add := func(name corev1.ResourceName, q resource.Quantity) {
+		total := usage[name]
+		total.Add(q)
+		usage[name] = total
+	}
+
+	pods := &corev1.PodList{}
+	if err := cli.List(ctx, pods, ctrlclient.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("list pods in namespace %s failed: %v", namespace, err)
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		requests, limits := podResources(pod)
+		for _, rs := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+			add("requests."+rs, requests[rs])
+			add(rs, requests[rs])
+			add("limits."+rs, limits[rs])
+		}
+	}
+
+	pvcs := &corev1.PersistentVolumeClaimList{}
+	if err := cli.List(ctx, pvcs, ctrlclient.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("list pvcs in namespace %s failed: %v", namespace, err)
+	}
+	for i := range pvcs.Items {
+		add(corev1.ResourceRequestsStorage, pvcs.Items[i].Spec.Resources.Requests[corev1.ResourceStorage])
+	}
+	return usage, nil
+}
+
+// podResources 返回 Pod 的资源申请：容器之和与单个 init 容器中的较大值，再加上 overhead
+func podResources(pod *corev1.Pod) (requests, limits corev1.ResourceList) {
+	sum := func(containers []corev1.Container, get func(corev1.Container) corev1.ResourceList) corev1.ResourceList {
+		total := corev1.ResourceList{}
+		for _, c := range containers {
+			for name, q := range get(c) {
+				v := total[name]
+				v.Add(q)
+				total[name] = v
+			}
+		}
+		return total
+	}
+	maxInit := func(list corev1.ResourceList, get func(corev1.Container) corev1.ResourceList) {
+		for _, c := range pod.Spec.InitContainers {
+			for name, q := range get(c) {
+				if v, ok := list[name]; !ok || q.Cmp(v) > 0 {
+					list[name] = q
+				}
+			}
+		}
+		for name, q := range pod.Spec.Overhead {
+			v := list[name]
+			v.Add(q)
+			list[name] = v
+		}
+	}
+	getRequests := func(c corev1.Container) corev1.ResourceList { return c.Resources.Requests }
+	getLimits := func(c corev1.Container) corev1.ResourceList { return c.Resources.Limits }
+	requests = sum(pod.Spec.Containers, getRequests)
+	limits = sum(pod.Spec.Containers, getLimits)
+	maxInit(requests, getRequests)
+	maxInit(limits, getLimits)
+	return requests, limits
+}
+
+// Check 检查配额层级的一致性，返回所有不一致之处：
+// 子配额的 hard 之和不超过父配额的 hard，CubeResourceQuota 的 used 等于子配额的 hard 之和，
+// ResourceQuota 的 used 不超过 hard 且与空间中工作负载的实际申请一致
+func (n *QuotaNode) Check() []string {
+	var problems []string
+	report := func(format string, args ...interface{}) {
+		problems = append(problems, n.String()+": "+fmt.Sprintf(format, args...))
+	}
+
+	switch n.Kind {
+	case QuotaKindCube:
+		allocated := corev1.ResourceList{}
+		for _, child := range n.Children {
+			for name, q := range child.Hard {
+				v := allocated[name]
+				v.Add(q)
+				allocated[name] = v
+			}
+		}
+		for _, name := range sortedResourceNames(n.Hard) {
+			hard, sum := n.Hard[name], allocated[name]
+			if sum.Cmp(hard) > 0 {
+				report("sum of sub quotas %s %s exceeds hard %s", name, sum.String(), hard.String())
+			}
+			if used, ok := n.Used[name]; ok && used.Cmp(sum) != 0 {
+				report("used %s %s does not equal sum of sub quotas %s", name, used.String(), sum.String())
+			}
+		}
+	case QuotaKindNamespace:
+		for _, name := range sortedResourceNames(n.Hard) {
+			hard, used := n.Hard[name], n.Used[name]
+			if used.Cmp(hard) > 0 {
+				report("used %s %s exceeds hard %s", name, used.String(), hard.String())
+			}
+			if observed, ok := n.Observed[name]; ok && used.Cmp(observed) != 0 {
+				report("used %s %s does not reflect workloads in namespace, which request %s", name, used.String(), observed.String())
+			}
+		}
+	}
+
+	for _, child := range n.Children {
+		problems = append(problems, child.Check()...)
+	}
+	return problems
+}
+
+func sortedResourceNames(list corev1.ResourceList) []corev1.ResourceName {
+	names := make([]corev1.ResourceName, 0, len(list))
+	for name := range list {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+// CheckQuotaHierarchy 检查以 CubeResourceQuota name 为根的配额层级是否一致
+func (tc *TestContext) CheckQuotaHierarchy(name string) error {
+	tree, err := tc.LoadQuotaTree(name)
+	if err != nil {
+		return err
+	}
+	if problems := tree.Check(); len(problems) > 0 {
+		return fmt.Errorf("quota hierarchy of %s is inconsistent:\n%s", name, strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// WaitQuotaConsistent 等待配额层级一致，KubeCube 与 k8s 异步刷新 used，超时后返回最后一次检查的结果
+func (tc *TestContext) WaitQuotaConsistent(name string) error {
+	var lastErr error
+	err := wait.PollImmediate(tc.WaitInterval, tc.WaitTimeout, func() (bool, error) {
+		lastErr = tc.CheckQuotaHierarchy(name)
+		return lastErr == nil, nil
+	})
+	if err != nil && lastErr != nil {
+		return lastErr
+	}
+	return err
+}
+
+// GetCubeResourceQuota 从管控集群读取 CubeResourceQuota
+func (tc *TestContext) GetCubeResourceQuota(name string) (*quotav1.CubeResourceQuota, error) {
+	q := &quotav1.CubeResourceQuota{}
+	err := tc.PivotClusterClient.Direct().Get(context.Background(), types.NamespacedName{Name: name}, q)
+	return q, err
+}
@@ -0,0 +1,238 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func resources(kv ...string) corev1.ResourceList {
+	list := corev1.ResourceList{}
+	for i := 0; i+1 < len(kv); i += 2 {
+		list[corev1.ResourceName(kv[i])] = resource.MustParse(kv[i+1])
+	}
+	return list
+}
+
+func container(requests, limits corev1.ResourceList) corev1.Container {
+	return corev1.Container{Resources: corev1.ResourceRequirements{Requests: requests, Limits: limits}}
+}
+
+// equalResources 按数值比较，忽略 Quantity 的格式
+func equalResources(got, want corev1.ResourceList) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for name, q := range want {
+		v, ok := got[name]
+		if !ok || v.Cmp(q) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPodResources(t *testing.T) {
+	cases := []struct {
+		name         string
+		spec         corev1.PodSpec
+		wantRequests corev1.ResourceList
+		wantLimits   corev1.ResourceList
+	}{
+		{
+			name:         "no resources",
+			spec:         corev1.PodSpec{Containers: []corev1.Container{{}}},
+			wantRequests: corev1.ResourceList{},
+			wantLimits:   corev1.ResourceList{},
+		},
+		{
+			name: "containers are summed",
+			spec: corev1.PodSpec{Containers: []corev1.Container{
+				container(resources("cpu", "100m", "memory", "128Mi"), resources("cpu", "200m", "memory", "256Mi")),
+				container(resources("cpu", "250m"), resources("cpu", "500m")),
+			}},
+			wantRequests: resources("cpu", "350m", "memory", "128Mi"),
+			wantLimits:   resources("cpu", "700m", "memory", "256Mi"),
+		},
+		{
+			name: "larger init container wins",
+			spec: corev1.PodSpec{
+				InitContainers: []corev1.Container{
+					container(resources("cpu", "1", "memory", "64Mi"), resources("cpu", "2")),
+					container(resources("cpu", "500m"), nil),
+				},
+				Containers: []corev1.Container{
+					container(resources("cpu", "100m", "memory", "128Mi"), resources("cpu", "200m")),
+					container(resources("cpu", "100m"), resources("cpu", "200m")),
+				},
+			},
+			wantRequests: resources("cpu", "1", "memory", "128Mi"),
+			wantLimits:   resources("cpu", "2"),
+		},
+		{
+			name: "smaller init container is ignored",
+			spec: corev1.PodSpec{
+				InitContainers: []corev1.Container{container(resources("cpu", "50m"), resources("cpu", "50m"))},
+				Containers:     []corev1.Container{container(resources("cpu", "100m"), resources("cpu", "200m"))},
+			},
+			wantRequests: resources("cpu", "100m"),
+			wantLimits:   resources("cpu", "200m"),
+		},
+		{
+			name: "init container only resource",
+			spec: corev1.PodSpec{
+				InitContainers: []corev1.Container{container(resources("ephemeral-storage", "1Gi"), nil)},
+				Containers:     []corev1.Container{container(resources("cpu", "100m"), nil)},
+			},
+			wantRequests: resources("cpu", "100m", "ephemeral-storage", "1Gi"),
+			wantLimits:   corev1.ResourceList{},
+		},
+		{
+			name: "overhead is added after init containers",
+			spec: corev1.PodSpec{
+				InitContainers: []corev1.Container{container(resources("cpu", "1"), nil)},
+				Containers:     []corev1.Container{container(resources("cpu", "100m", "memory", "128Mi"), resources("cpu", "200m", "memory", "128Mi"))},
+				Overhead:       resources("cpu", "250m", "memory", "120Mi"),
+			},
+			wantRequests: resources("cpu", "1250m", "memory", "248Mi"),
+			wantLimits:   resources("cpu", "450m", "memory", "248Mi"),
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			requests, limits := podResources(&corev1.Pod{Spec: c.spec})
+			if !equalResources(requests, c.wantRequests) {
+				t.Errorf("requests = %v, want %v", requests, c.wantRequests)
+			}
+			if !equalResources(limits, c.wantLimits) {
+				t.Errorf("limits = %v, want %v", limits, c.wantLimits)
+			}
+		})
+	}
+}
+
+func TestNamespaceUsage(t *testing.T) {
+	pod := func(name string, phase corev1.PodPhase, cpu string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: name},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{container(resources("cpu", cpu, "memory", "64Mi"), resources("cpu", cpu))}},
+			Status:     corev1.PodStatus{Phase: phase},
+		}
+	}
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "data"},
+		Spec:       corev1.PersistentVolumeClaimSpec{Resources: corev1.ResourceRequirements{Requests: resources("storage", "10Gi")}},
+	}
+	cli := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+		pod("running", corev1.PodRunning, "100m"),
+		pod("pending", corev1.PodPending, "200m"),
+		pod("succeeded", corev1.PodSucceeded, "1"),
+		pod("failed", corev1.PodFailed, "1"),
+		pod("other", corev1.PodRunning, "1"),
+		pvc,
+	).Build()
+	other := pod("elsewhere", corev1.PodRunning, "4")
+	other.Namespace = "other"
+	if err := cli.Create(context.Background(), other); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := namespaceUsage(context.Background(), cli, "ns")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := resources(
+		"requests.cpu", "1300m", "cpu", "1300m", "limits.cpu", "1300m",
+		"requests.memory", "192Mi", "memory", "192Mi", "limits.memory", "0",
+		"requests.storage", "10Gi",
+	)
+	if !equalResources(got, want) {
+		t.Fatalf("usage = %v, want %v", got, want)
+	}
+}
+
+func TestQuotaNodeCheck(t *testing.T) {
+	namespace := func(ns string, hard, used, observed corev1.ResourceList) *QuotaNode {
+		return &QuotaNode{Kind: QuotaKindNamespace, Namespace: ns, Name: "quota", Hard: hard, Used: used, Observed: observed}
+	}
+	cases := []struct {
+		name string
+		tree *QuotaNode
+		want []string
+	}{
+		{
+			name: "consistent",
+			tree: &QuotaNode{Kind: QuotaKindCube, Name: "tenant", Hard: resources("cpu", "4"), Used: resources("cpu", "3"), Children: []*QuotaNode{
+				{Kind: QuotaKindCube, Name: "project", Hard: resources("cpu", "3"), Used: resources("cpu", "2"), Children: []*QuotaNode{
+					namespace("ns1", resources("cpu", "1"), resources("cpu", "500m"), resources("cpu", "0.5")),
+					namespace("ns2", resources("cpu", "1"), resources("cpu", "0"), nil),
+				}},
+			}},
+		},
+		{
+			name: "sub quotas exceed hard",
+			tree: &QuotaNode{Kind: QuotaKindCube, Name: "tenant", Hard: resources("cpu", "2", "memory", "4Gi"), Children: []*QuotaNode{
+				{Kind: QuotaKindCube, Name: "p1", Hard: resources("cpu", "1500m", "memory", "1Gi")},
+				{Kind: QuotaKindCube, Name: "p2", Hard: resources("cpu", "1")},
+			}},
+			want: []string{"CubeResourceQuota tenant: sum of sub quotas cpu 2500m exceeds hard 2"},
+		},
+		{
+			name: "used differs from sum of sub quotas",
+			tree: &QuotaNode{Kind: QuotaKindCube, Name: "tenant", Hard: resources("cpu", "4", "memory", "8Gi"), Used: resources("cpu", "1"), Children: []*QuotaNode{
+				{Kind: QuotaKindCube, Name: "project", Hard: resources("cpu", "2", "memory", "1Gi")},
+			}},
+			want: []string{"CubeResourceQuota tenant: used cpu 1 does not equal sum of sub quotas 2"},
+		},
+		{
+			name: "namespace quota exceeded and out of date",
+			tree: &QuotaNode{Kind: QuotaKindCube, Name: "project", Hard: resources("cpu", "2"), Used: resources("cpu", "1"), Children: []*QuotaNode{
+				namespace("ns1", resources("cpu", "1", "memory", "1Gi"), resources("cpu", "1500m", "memory", "512Mi"), resources("cpu", "1500m", "memory", "256Mi")),
+			}},
+			want: []string{
+				"ResourceQuota ns1/quota: used cpu 1500m exceeds hard 1",
+				"ResourceQuota ns1/quota: used memory 512Mi does not reflect workloads in namespace, which request 256Mi",
+			},
+		},
+		{
+			name: "problems of nested quotas are collected",
+			tree: &QuotaNode{Kind: QuotaKindCube, Name: "tenant", Hard: resources("cpu", "1"), Children: []*QuotaNode{
+				{Kind: QuotaKindCube, Name: "project", Hard: resources("cpu", "1"), Children: []*QuotaNode{
+					namespace("ns1", resources("cpu", "2"), nil, nil),
+				}},
+			}},
+			want: []string{"CubeResourceQuota project: sum of sub quotas cpu 2 exceeds hard 1"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := c.tree.Check()
+			if !reflect.DeepEqual(got, c.want) && !(len(got) == 0 && len(c.want) == 0) {
+				t.Fatalf("problems:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(c.want, "\n"))
+			}
+		})
+	}
+}
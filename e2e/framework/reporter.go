@@ -0,0 +1,503 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	ginkgoconfig "github.com/onsi/ginkgo/config"
+	"github.com/onsi/ginkgo/types"
+)
+
+// 用例状态
+const (
+	StatePassed  = "passed"
+	StateFailed  = "failed"
+	StateSkipped = "skipped"
+	StatePending = "pending"
+)
+
+// CaseResult 单个 Ginkgo 用例的结果
+type CaseResult struct {
+	// ID KubeCube 用例编号，如 [配置][9387667]ConfigMap检查 中的 9387667
+	ID string `json:"id,omitempty"`
+	// Category 用例分类，如 配置
+	Category string `json:"category,omitempty"`
+	// Cluster 执行用例的测试集群
+	Cluster string `json:"cluster,omitempty"`
+	Test    string `json:"test"`
+	Role    string `json:"role,omitempty"`
+	Step    string `json:"step,omitempty"`
+	// Name Ginkgo 中的完整用例名
+	Name       string    `json:"name"`
+	State      string    `json:"state"`
+	StartedAt  time.Time `json:"startedAt"`
+	DurationMs int64     `json:"durationMs"`
+	Failure    string    `json:"failure,omitempty"`
+	Location   string    `json:"location,omitempty"`
+	// Policy 步骤生效的超时与重试策略
+	Policy string `json:"policy,omitempty"`
+	// Attempts 步骤执行次数
+	Attempts int `json:"attempts,omitempty"`
+}
+
+// SuiteResult 一次运行的结果
+type SuiteResult struct {
+	Suite      string        `json:"suite"`
+	RunID      string        `json:"runID"`
+	Users      []string      `json:"users"`
+	StartedAt  time.Time     `json:"startedAt"`
+	DurationMs int64         `json:"durationMs"`
+	Passed     int           `json:"passed"`
+	Failed     int           `json:"failed"`
+	Skipped    int           `json:"skipped"`
+	Cases      []*CaseResult `json:"cases"`
+}
+
+// testNamePattern 匹配 [分类][编号]名称 格式的测试名
+var testNamePattern = regexp.MustCompile(`^((?:\[[^\]]*\])*)\s*(.*)$`)
+
+// ParseTestName 从测试名中解析用例编号与分类，编号为方括号中的纯数字
+func ParseTestName(name string) (id, category string) {
+	match := testNamePattern.FindStringSubmatch(name)
+	if match == nil {
+		return "", ""
+	}
+	for _, part := range strings.Split(strings.Trim(match[1], "[]"), "][") {
+		if len(part) == 0 {
+			continue
+		}
+		if strings.Trim(part, "0123456789") == "" {
+			if len(id) == 0 {
+				id = part
+			}
+		} else if len(category) == 0 {
+			category = part
+		}
+	}
+	return id, category
+}
+
+// ResultReporter Ginkgo reporter，收集用例结果，由 Write 输出 JUnit XML 与 JSON 格式的结果
+type ResultReporter struct {
+	junitPath string
+	jsonPath  string
+	runID     string
+	matrix    *MatrixRecorder
+	// cluster 用例名中没有集群时使用的测试集群
+	cluster string
+
+	mu     sync.Mutex
+	result SuiteResult
+}
+
+// NewResultReporter 创建结果 reporter，路径为空时不输出对应格式
+func NewResultReporter(tc *TestContext, junitPath, jsonPath string) *ResultReporter {
+	return &ResultReporter{junitPath: junitPath, jsonPath: jsonPath, runID: tc.RunID, matrix: tc.Matrix, cluster: tc.TargetClusterName}
+}
+
+func (r *ResultReporter) SpecSuiteWillBegin(_ ginkgoconfig.GinkgoConfigType, summary *types.SuiteSummary) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.result = SuiteResult{
+		Suite:     summary.SuiteDescription,
+		RunID:     r.runID,
+		Users:     append([]string{}, TestUser...),
+		StartedAt: time.Now(),
+		Cases:     []*CaseResult{},
+	}
+}
+
+func (r *ResultReporter) BeforeSuiteDidRun(setup *types.SetupSummary) {
+	r.setupDidRun("BeforeSuite", setup)
+}
+
+func (r *ResultReporter) AfterSuiteDidRun(setup *types.SetupSummary) {
+	r.setupDidRun("AfterSuite", setup)
+}
+
+// setupDidRun 只记录失败的 BeforeSuite 与 AfterSuite
+func (r *ResultReporter) setupDidRun(name string, setup *types.SetupSummary) {
+	if setup.State != types.SpecStateFailed && setup.State != types.SpecStatePanicked && setup.State != types.SpecStateTimedOut {
+		return
+	}
+	r.add(&CaseResult{
+		Test:       name,
+		Name:       name,
+		State:      StateFailed,
+		StartedAt:  time.Now().Add(-setup.RunTime),
+		DurationMs: setup.RunTime.Milliseconds(),
+		Failure:    failureMessage(setup.Failure),
+		Location:   location(setup.Failure.Location),
+	})
+}
+
+func (r *ResultReporter) SpecWillRun(*types.SpecSummary) {}
+
+func (r *ResultReporter) SpecDidComplete(spec *types.SpecSummary) {
+	c := &CaseResult{
+		Cluster:    r.cluster,
+		Name:       strings.Join(spec.ComponentTexts[1:], " "),
+		StartedAt:  time.Now().Add(-spec.RunTime),
+		DurationMs: spec.RunTime.Milliseconds(),
+	}
+	for _, text := range spec.ComponentTexts {
+		if cluster, ok := strings.CutPrefix(text, ClusterDescribePrefix); ok && len(c.Test) == 0 {
+			c.Cluster = cluster
+			continue
+		}
+		if _, ok := AllTestMap[text]; ok {
+			c.Test = text
+			break
+		}
+	}
+	if len(c.Test) == 0 && len(spec.ComponentTexts) > 1 {
+		c.Test = spec.ComponentTexts[1]
+	}
+	c.ID, c.Category = ParseTestName(c.Test)
+	// 用例名格式为 "角色 : 步骤"，-concurrentRoles 时为 "角色1,角色2 : 步骤"
+	leaf := spec.ComponentTexts[len(spec.ComponentTexts)-1]
+	roles, step, ok := strings.Cut(leaf, " : ")
+	if !ok {
+		c.Step = leaf
+		setCaseState(c, spec)
+		r.add(c)
+		return
+	}
+	c.Step = step
+	if !strings.Contains(roles, ",") {
+		c.Role = roles
+		r.setPolicy(c)
+		setCaseState(c, spec)
+		r.add(c)
+		return
+	}
+
+	// 并发执行多个角色的用例，每个角色记录一个结果
+	names := strings.Split(roles, ",")
+	sep := "\n\n"
+	if spec.Skipped() {
+		sep = "\n"
+	}
+	sections := roleSections(spec.Failure.Message, sep, names)
+	prefix := strings.TrimSuffix(c.Name, leaf)
+	for _, role := range names {
+		rc := *c
+		rc.Role = role
+		rc.Name = prefix + role + " : " + step
+		r.setPolicy(&rc)
+		r.setRoleState(&rc, spec, sections)
+		r.add(&rc)
+	}
+}
+
+func (r *ResultReporter) setPolicy(c *CaseResult) {
+	if result, ok := r.matrix.result(c.Cluster, c.Test, c.Step, c.Role); ok {
+		c.Policy, c.Attempts = result.Policy, result.Attempts
+	}
+}
+
+func setCaseState(c *CaseResult, spec *types.SpecSummary) {
+	switch {
+	case spec.Passed():
+		c.State = StatePassed
+	case spec.Skipped():
+		c.State = StateSkipped
+		c.Failure = spec.Failure.Message
+	case spec.Pending():
+		c.State = StatePending
+	default:
+		c.State = StateFailed
+		c.Failure = failureMessage(spec.Failure)
+		c.Location = location(spec.Failure.Location)
+	}
+}
+
+// setRoleState 设置并发执行的用例中一个角色的状态：失败或跳过信息中有该角色时以其为准，
+// 用例失败但信息不属于任何角色时（如 BeforeEach 失败）所有角色都失败，其余角色按权限矩阵中的结果判断是否被跳过
+func (r *ResultReporter) setRoleState(c *CaseResult, spec *types.SpecSummary, sections map[string]string) {
+	section, found := sections[c.Role]
+	switch {
+	case spec.Pending():
+		c.State = StatePending
+	case spec.Skipped():
+		c.State = StateSkipped
+		c.Failure = spec.Failure.Message
+		if found {
+			c.Failure = section
+		}
+	case !spec.Passed() && (found || len(sections) == 0):
+		setCaseState(c, spec)
+		if found {
+			c.Failure = section
+		}
+	default:
+		c.State = StatePassed
+		if result, ok := r.matrix.result(c.Cluster, c.Test, c.Step, c.Role); ok && result.Outcome == OutcomeSkipped {
+			c.State = StateSkipped
+			c.Failure = result.Error
+		}
+	}
+}
+
+// roleSections 拆分并发执行多个角色的用例的失败或跳过信息，每个角色的信息以 "[角色] " 开头，以 sep 分隔
+func roleSections(message, sep string, roles []string) map[string]string {
+	type mark struct {
+		role  string
+		start int
+	}
+	var marks []mark
+	for _, role := range roles {
+		prefix := "[" + role + "] "
+		for i := 0; ; {
+			j := strings.Index(message[i:], prefix)
+			if j < 0 {
+				break
+			}
+			pos := i + j
+			if pos == 0 || strings.HasSuffix(message[:pos], sep) {
+				marks = append(marks, mark{role: role, start: pos})
+				break
+			}
+			i = pos + len(prefix)
+		}
+	}
+	sort.Slice(marks, func(i, j int) bool { return marks[i].start < marks[j].start })
+
+	sections := make(map[string]string, len(marks))
+	for i, m := range marks {
+		end := len(message)
+		if i+1 < len(marks) {
+			end = marks[i+1].start - len(sep)
+		}
+		sections[m.role] = message[m.start+len(m.role)+3 : end]
+	}
+	return sections
+}
+
+func (r *ResultReporter) SpecSuiteDidEnd(*types.SuiteSummary) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.result.DurationMs = time.Since(r.result.StartedAt).Milliseconds()
+}
+
+// Result 返回当前收集的结果
+func (r *ResultReporter) Result() SuiteResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result := r.result
+	result.Users = append([]string{}, r.result.Users...)
+	result.Cases = append([]*CaseResult{}, r.result.Cases...)
+	return result
+}
+
+// Merge 合并其他进程的结果，如 master 合并各 worker 的结果
+func (r *ResultReporter) Merge(result SuiteResult) {
+	r.mu.Lock()
+	for _, user := range result.Users {
+		if !contains(r.result.Users, user) {
+			r.result.Users = append(r.result.Users, user)
+		}
+	}
+	r.mu.Unlock()
+	for _, c := range result.Cases {
+		r.add(c)
+	}
+}
+
+// AddFailure 记录不属于任何用例的失败，如 worker 崩溃
+func (r *ResultReporter) AddFailure(test, name, failure string) {
+	r.add(&CaseResult{Test: test, Name: name, State: StateFailed, StartedAt: time.Now(), Failure: failure})
+}
+
+// Write 输出 JUnit XML 与 JSON 格式的结果，路径为空时不输出对应格式
+func (r *ResultReporter) Write() error {
+	result := r.Result()
+	if len(r.jsonPath) > 0 {
+		if err := writeJSONResult(r.jsonPath, &result); err != nil {
+			return fmt.Errorf("write json report failed: %v", err)
+		}
+	}
+	if len(r.junitPath) > 0 {
+		if err := writeJUnitResult(r.junitPath, &result); err != nil {
+			return fmt.Errorf("write junit report failed: %v", err)
+		}
+	}
+	return nil
+}
+
+func (r *ResultReporter) add(c *CaseResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch c.State {
+	case StatePassed:
+		r.result.Passed++
+	case StateFailed:
+		r.result.Failed++
+	default:
+		r.result.Skipped++
+	}
+	r.result.Cases = append(r.result.Cases, c)
+}
+
+func failureMessage(f types.SpecFailure) string {
+	if len(f.ForwardedPanic) > 0 {
+		return f.Message + "\n" + f.ForwardedPanic
+	}
+	return f.Message
+}
+
+func location(l types.CodeLocation) string {
+	if len(l.FileName) == 0 {
+		return ""
+	}
+	return l.String()
+}
+
+func writeJSONResult(path string, result *SuiteResult) error {
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeReportFile(path, data)
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Errors     int             `xml:"errors,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       float64         `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	TestCases  []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name       string          `xml:"name,attr"`
+	ClassName  string          `xml:"classname,attr"`
+	Time       float64         `xml:"time,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Failure    *junitFailure   `xml:"failure,omitempty"`
+	Skipped    *junitSkipped   `xml:"skipped,omitempty"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr,omitempty"`
+}
+
+// writeJUnitResult 输出 JUnit XML，classname 为测试名，name 为 "角色 : 步骤"，编号、集群、角色与步骤写入 properties
+func writeJUnitResult(path string, result *SuiteResult) error {
+	suite := junitTestSuite{
+		Name:      result.Suite,
+		Tests:     len(result.Cases),
+		Failures:  result.Failed,
+		Skipped:   result.Skipped,
+		Time:      float64(result.DurationMs) / 1000,
+		Timestamp: result.StartedAt.Format("2006-01-02T15:04:05"),
+		Properties: []junitProperty{
+			{Name: "runID", Value: result.RunID},
+			{Name: "users", Value: strings.Join(result.Users, ",")},
+		},
+	}
+	for _, c := range result.Cases {
+		tc := junitTestCase{
+			Name:      c.Name,
+			ClassName: c.Test,
+			Time:      float64(c.DurationMs) / 1000,
+		}
+		if len(c.Role) > 0 {
+			tc.Name = fmt.Sprintf("%s : %s", c.Role, c.Step)
+		}
+		for _, p := range []junitProperty{
+			{Name: "id", Value: c.ID},
+			{Name: "category", Value: c.Category},
+			{Name: "cluster", Value: c.Cluster},
+			{Name: "role", Value: c.Role},
+			{Name: "step", Value: c.Step},
+			{Name: "policy", Value: c.Policy},
+			{Name: "attempts", Value: attempts(c.Attempts)},
+		} {
+			if len(p.Value) > 0 {
+				tc.Properties = append(tc.Properties, p)
+			}
+		}
+		switch c.State {
+		case StateFailed:
+			tc.Failure = &junitFailure{
+				Message: firstLine(c.Failure),
+				Type:    "Failure",
+				Text:    strings.TrimSpace(c.Failure + "\n" + c.Location),
+			}
+		case StateSkipped, StatePending:
+			tc.Skipped = &junitSkipped{Message: c.Failure}
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+
+	data, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeReportFile(path, append([]byte(xml.Header), data...))
+}
+
+func attempts(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+func writeReportFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
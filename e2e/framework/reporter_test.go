@@ -0,0 +1,180 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/onsi/ginkgo/types"
+)
+
+func TestParseTestName(t *testing.T) {
+	cases := []struct {
+		name         string
+		wantID       string
+		wantCategory string
+	}{
+		{name: "[配置][9387667]ConfigMap检查", wantID: "9387667", wantCategory: "配置"},
+		{name: "[9387667][配置]ConfigMap检查", wantID: "9387667", wantCategory: "配置"},
+		{name: "[配置][9387667] ConfigMap检查", wantID: "9387667", wantCategory: "配置"},
+		{name: "ConfigMap检查"},
+		{name: ""},
+		{name: "[配置]ConfigMap检查", wantCategory: "配置"},
+		{name: "[9387667]ConfigMap检查", wantID: "9387667"},
+		// 只取第一个编号与第一个分类
+		{name: "[1][配置][2][存储]检查", wantID: "1", wantCategory: "配置"},
+		// 空方括号被忽略
+		{name: "[][配置][][9387667]检查", wantID: "9387667", wantCategory: "配置"},
+		// 含非数字字符的视为分类
+		{name: "[v1][9387667]检查", wantID: "9387667", wantCategory: "v1"},
+		{name: "[-1]检查", wantCategory: "-1"},
+		// 只解析名称开头连续的方括号
+		{name: "ConfigMap检查[配置][9387667]"},
+		{name: "[配置] [9387667]ConfigMap检查", wantCategory: "配置"},
+		// 方括号不嵌套，未闭合的方括号属于名称
+		{name: "[配置[9387667]检查", wantCategory: "配置[9387667"},
+		{name: "[配置"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			id, category := ParseTestName(c.name)
+			if id != c.wantID || category != c.wantCategory {
+				t.Fatalf("ParseTestName(%q) = (%q, %q), want (%q, %q)", c.name, id, category, c.wantID, c.wantCategory)
+			}
+		})
+	}
+}
+
+func TestRoleSections(t *testing.T) {
+	roles := []string{"admin", "tenant-admin", "viewer"}
+	cases := []struct {
+		name    string
+		message string
+		sep     string
+		want    map[string]string
+	}{
+		{
+			name:    "failures of several roles",
+			message: "[admin] expected 200\nstep.go:10\n\n[viewer] got 403\n\nstep.go:20",
+			sep:     "\n\n",
+			want:    map[string]string{"admin": "expected 200\nstep.go:10", "viewer": "got 403\n\nstep.go:20"},
+		},
+		{
+			name:    "skip reasons",
+			message: "[tenant-admin] step a failed\n[viewer] step a failed",
+			sep:     "\n",
+			want:    map[string]string{"tenant-admin": "step a failed", "viewer": "step a failed"},
+		},
+		{
+			name:    "role name inside another message",
+			message: "[tenant-admin] user [admin] not found",
+			sep:     "\n\n",
+			want:    map[string]string{"tenant-admin": "user [admin] not found"},
+		},
+		{
+			name:    "not a role failure",
+			message: "BeforeEach failed",
+			sep:     "\n\n",
+			want:    map[string]string{},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := roleSections(c.message, c.sep, roles); !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("roleSections() = %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestSpecDidCompleteConcurrentRoles(t *testing.T) {
+	matrix := NewMatrixRecorder()
+	matrix.Record("test", "step", &StepResult{Cluster: "c1", Role: "admin", Policy: "timeout=1m0s retries=2", Attempts: 3})
+	matrix.Record("test", "step", &StepResult{Cluster: "c1", Role: "tenant-admin", Policy: "timeout=none retries=0", Attempts: 1})
+	matrix.Record("test", "step", &StepResult{Cluster: "c1", Role: "viewer", Outcome: OutcomeSkipped, Error: "step a failed"})
+
+	spec := func(state types.SpecState, message string) *types.SpecSummary {
+		return &types.SpecSummary{
+			ComponentTexts: []string{"[Top Level]", "test", "测试用例", "admin,tenant-admin,viewer : step"},
+			State:          state,
+			Failure:        types.SpecFailure{Message: message},
+		}
+	}
+	type result struct {
+		Role, Name, State, Failure, Policy string
+		Attempts                           int
+	}
+	cases := []struct {
+		name string
+		spec *types.SpecSummary
+		want []result
+	}{
+		{
+			name: "passed",
+			spec: spec(types.SpecStatePassed, ""),
+			want: []result{
+				{"admin", "test 测试用例 admin : step", StatePassed, "", "timeout=1m0s retries=2", 3},
+				{"tenant-admin", "test 测试用例 tenant-admin : step", StatePassed, "", "timeout=none retries=0", 1},
+				{"viewer", "test 测试用例 viewer : step", StateSkipped, "step a failed", "", 0},
+			},
+		},
+		{
+			name: "one role failed",
+			spec: spec(types.SpecStateFailed, "[tenant-admin] expected 200"),
+			want: []result{
+				{"admin", "test 测试用例 admin : step", StatePassed, "", "timeout=1m0s retries=2", 3},
+				{"tenant-admin", "test 测试用例 tenant-admin : step", StateFailed, "expected 200", "timeout=none retries=0", 1},
+				{"viewer", "test 测试用例 viewer : step", StateSkipped, "step a failed", "", 0},
+			},
+		},
+		{
+			name: "failure of no role",
+			spec: spec(types.SpecStateFailed, "AfterEach failed"),
+			want: []result{
+				{"admin", "test 测试用例 admin : step", StateFailed, "AfterEach failed", "timeout=1m0s retries=2", 3},
+				{"tenant-admin", "test 测试用例 tenant-admin : step", StateFailed, "AfterEach failed", "timeout=none retries=0", 1},
+				{"viewer", "test 测试用例 viewer : step", StateFailed, "AfterEach failed", "", 0},
+			},
+		},
+		{
+			name: "all skipped",
+			spec: spec(types.SpecStateSkipped, "[admin] a\n[tenant-admin] b\n[viewer] c"),
+			want: []result{
+				{"admin", "test 测试用例 admin : step", StateSkipped, "a", "timeout=1m0s retries=2", 3},
+				{"tenant-admin", "test 测试用例 tenant-admin : step", StateSkipped, "b", "timeout=none retries=0", 1},
+				{"viewer", "test 测试用例 viewer : step", StateSkipped, "c", "", 0},
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := &ResultReporter{matrix: matrix, cluster: "c1"}
+			r.SpecDidComplete(c.spec)
+			var got []result
+			for _, cr := range r.Result().Cases {
+				if cr.Test != "test" || cr.Step != "step" || cr.Cluster != "c1" {
+					t.Fatalf("unexpected case %+v", cr)
+				}
+				got = append(got, result{cr.Role, cr.Name, cr.State, cr.Failure, cr.Policy, cr.Attempts})
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("cases:\n%+v\nwant:\n%+v", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"fmt"
+
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+	"github.com/spf13/viper"
+)
+
+const (
+	// BindingScopeCluster 以 ClusterRoleBinding 绑定
+	BindingScopeCluster = "cluster"
+	// BindingScopeTenant 在租户空间 kubecube-tenant-<tenant> 下绑定
+	BindingScopeTenant = "tenant"
+	// BindingScopeProject 在项目空间 kubecube-project-<project> 下绑定
+	BindingScopeProject = "project"
+)
+
+// RoleBinding 角色在初始化时需要绑定的 ClusterRole
+type RoleBinding struct {
+	Scope       string `mapstructure:"scope"`
+	ClusterRole string `mapstructure:"clusterRole"`
+}
+
+// Role 测试角色，包含登录凭证以及需要绑定的权限
+type Role struct {
+	// Name 角色名，与测试用例中 ExpectPass 的 key 对应
+	Name     string `mapstructure:"name"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	// Builtin 平台内置用户，如 admin，不创建也不清理
+	Builtin bool `mapstructure:"builtin"`
+	// ExpectAs 测试用例未声明该角色的期望结果时，沿用此角色的期望结果
+	ExpectAs string        `mapstructure:"expectAs"`
+	Bindings []RoleBinding `mapstructure:"bindings"`
+}
+
+// RoleRegistry 本次测试所有角色，保持配置中的顺序
+type RoleRegistry struct {
+	roles []*Role
+}
+
+// NewRoleRegistry 校验角色配置并创建角色注册表
+func NewRoleRegistry(roles []*Role) (*RoleRegistry, error) {
+	if len(roles) == 0 {
+		return nil, fmt.Errorf("no role configured")
+	}
+	names := make(map[string]struct{})
+	users := make(map[string]struct{})
+	for _, role := range roles {
+		if len(role.Name) == 0 || len(role.Username) == 0 {
+			return nil, fmt.Errorf("role name and username can not be empty: %+v", role)
+		}
+		if _, ok := names[role.Name]; ok {
+			return nil, fmt.Errorf("role %s duplicated", role.Name)
+		}
+		if _, ok := users[role.Username]; ok {
+			return nil, fmt.Errorf("username %s used by more than one role", role.Username)
+		}
+		names[role.Name] = struct{}{}
+		users[role.Username] = struct{}{}
+		for _, b := range role.Bindings {
+			switch b.Scope {
+			case BindingScopeCluster, BindingScopeTenant, BindingScopeProject:
+			default:
+				return nil, fmt.Errorf("role %s has unknown binding scope %q", role.Name, b.Scope)
+			}
+			if len(b.ClusterRole) == 0 {
+				return nil, fmt.Errorf("role %s has binding without clusterRole", role.Name)
+			}
+		}
+	}
+	for _, role := range roles {
+		if len(role.ExpectAs) == 0 {
+			continue
+		}
+		if _, ok := names[role.ExpectAs]; !ok {
+			return nil, fmt.Errorf("role %s expectAs unknown role %s", role.Name, role.ExpectAs)
+		}
+	}
+	return &RoleRegistry{roles: roles}, nil
+}
+
+// Roles 返回所有角色
+func (r *RoleRegistry) Roles() []*Role {
+	return r.roles
+}
+
+// Names 返回所有角色名
+func (r *RoleRegistry) Names() []string {
+	names := make([]string, 0, len(r.roles))
+	for _, role := range r.roles {
+		names = append(names, role.Name)
+	}
+	return names
+}
+
+// Get 根据角色名获取角色
+func (r *RoleRegistry) Get(name string) (*Role, bool) {
+	for _, role := range r.roles {
+		if role.Name == name {
+			return role, true
+		}
+	}
+	return nil, false
+}
+
+// Default 默认角色，未指定用户的请求以此角色发出，优先使用 admin
+func (r *RoleRegistry) Default() *Role {
+	if role, ok := r.Get(UserAdmin); ok {
+		return role
+	}
+	return r.roles[0]
+}
+
+// ExpectPass 判断角色在某一步骤中是否期望成功，未声明时按 ExpectAs 查找
+func (r *RoleRegistry) ExpectPass(expect map[string]bool, name string) bool {
+	return r.Expectation(MultiUserTestStep{ExpectPass: expect}, name).Pass
+}
+
+// loadRoles 从 e2eInit.roles 读取角色，未配置时兼容旧的 e2eInit.multiuser 配置
+func loadRoles() (*RoleRegistry, error) {
+	if viper.IsSet("e2eInit.roles") {
+		var roles []*Role
+		if err := viper.UnmarshalKey("e2eInit.roles", &roles); err != nil {
+			return nil, err
+		}
+		return NewRoleRegistry(roles)
+	}
+
+	return NewRoleRegistry([]*Role{
+		{
+			Name:     UserAdmin,
+			Username: viper.GetString("e2eInit.multiuser.admin"),
+			Password: viper.GetString("e2eInit.multiuser.adminPassword"),
+			Builtin:  true,
+		},
+		{
+			Name:     UserTenantAdmin,
+			Username: viper.GetString("e2eInit.multiuser.tenantAdmin"),
+			Password: viper.GetString("e2eInit.multiuser.tenantAdminPassword"),
+			Bindings: []RoleBinding{{Scope: BindingScopeTenant, ClusterRole: constants.TenantAdmin}},
+		},
+		{
+			Name:     UserProjectAdmin,
+			Username: viper.GetString("e2eInit.multiuser.projectAdmin"),
+			Password: viper.GetString("e2eInit.multiuser.projectAdminPassword"),
+			Bindings: []RoleBinding{{Scope: BindingScopeProject, ClusterRole: constants.ProjectAdmin}},
+		},
+		{
+			Name:     UserNormal,
+			Username: viper.GetString("e2eInit.multiuser.user"),
+			Password: viper.GetString("e2eInit.multiuser.userPassword"),
+			Bindings: []RoleBinding{{Scope: BindingScopeProject, ClusterRole: constants.Reviewer}},
+		},
+	})
+}
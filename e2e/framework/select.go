@@ -0,0 +1,342 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"unicode"
+)
+
+// 选择表达式中可用的字段
+const (
+	SelectTest   = "test"
+	SelectModule = "module"
+	SelectID     = "id"
+	SelectStep   = "step"
+	SelectRole   = "role"
+	SelectLabel  = "label"
+)
+
+// TestSelector -select 参数解析得到的选择器，为 nil 时执行所有测试
+var TestSelector Selector
+
+// SelectTarget 选择器匹配的对象，即某个角色执行某个测试的某一步骤
+type SelectTarget struct {
+	Test   string
+	Module string
+	ID     string
+	Step   string
+	Role   string
+	Labels []string
+}
+
+// Selector 判断测试步骤是否需要执行
+type Selector interface {
+	Match(t SelectTarget) bool
+}
+
+type andSelector struct{ left, right Selector }
+
+func (s andSelector) Match(t SelectTarget) bool { return s.left.Match(t) && s.right.Match(t) }
+
+type orSelector struct{ left, right Selector }
+
+func (s orSelector) Match(t SelectTarget) bool { return s.left.Match(t) || s.right.Match(t) }
+
+type notSelector struct{ s Selector }
+
+func (s notSelector) Match(t SelectTarget) bool { return !s.s.Match(t) }
+
+// fieldSelector 匹配单个字段，value 支持 * 与 ? 通配符
+type fieldSelector struct {
+	field string
+	value string
+}
+
+func (s fieldSelector) Match(t SelectTarget) bool {
+	switch s.field {
+	case SelectTest:
+		return globMatch(s.value, t.Test)
+	case SelectModule:
+		return globMatch(strings.Trim(s.value, "[]"), t.Module)
+	case SelectID:
+		return globMatch(s.value, t.ID)
+	case SelectStep:
+		return globMatch(s.value, t.Step)
+	case SelectRole:
+		return globMatch(s.value, t.Role)
+	}
+	for _, label := range t.Labels {
+		if globMatch(s.value, label) {
+			return true
+		}
+	}
+	return false
+}
+
+// globMatch 只把 * 与 ? 作为通配符，测试名中的 [] 按字面匹配
+func globMatch(pattern, s string) bool {
+	ok, err := path.Match(globEscaper.Replace(pattern), s)
+	return err == nil && ok
+}
+
+var globEscaper = strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`)
+
+// ParseSelector 解析选择表达式，表达式由 字段=值、字段!=值 或单独的标签组成，
+// 可用 &&、||、! 与括号组合，如 module=存储 && role=projectAdmin、smoke || id=9386601。
+// 字段为 test、module、id、step、role 与 label，值中可以使用通配符，包含空格时用双引号括起。
+// 表达式为空时返回 nil
+func ParseSelector(expr string) (Selector, error) {
+	tokens, err := lexSelector(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+	p := &selectorParser{tokens: tokens}
+	s, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in select expression", p.tokens[p.pos].text)
+	}
+	return s, nil
+}
+
+type selectorTokenKind int
+
+const (
+	tokenWord selectorTokenKind = iota
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenEq
+	tokenNotEq
+	tokenLParen
+	tokenRParen
+)
+
+type selectorToken struct {
+	kind selectorTokenKind
+	text string
+}
+
+func lexSelector(expr string) ([]selectorToken, error) {
+	var tokens []selectorToken
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		next := rune(0)
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '&' && next == '&':
+			tokens = append(tokens, selectorToken{tokenAnd, "&&"})
+			i += 2
+		case r == '|' && next == '|':
+			tokens = append(tokens, selectorToken{tokenOr, "||"})
+			i += 2
+		case r == '!' && next == '=':
+			tokens = append(tokens, selectorToken{tokenNotEq, "!="})
+			i += 2
+		case r == '!':
+			tokens = append(tokens, selectorToken{tokenNot, "!"})
+			i++
+		case r == '=':
+			tokens = append(tokens, selectorToken{tokenEq, "="})
+			i++
+		case r == '(':
+			tokens = append(tokens, selectorToken{tokenLParen, "("})
+			i++
+		case r == ')':
+			tokens = append(tokens, selectorToken{tokenRParen, ")"})
+			i++
+		case r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("unterminated quote in select expression %q", expr)
+			}
+			tokens = append(tokens, selectorToken{tokenWord, string(runes[i+1 : end])})
+			i = end + 1
+		case r == '&' || r == '|':
+			return nil, fmt.Errorf("unexpected %q in select expression %q, use && or ||", r, expr)
+		default:
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && !strings.ContainsRune("&|!=()\"", runes[end]) {
+				end++
+			}
+			tokens = append(tokens, selectorToken{tokenWord, string(runes[i:end])})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+type selectorParser struct {
+	tokens []selectorToken
+	pos    int
+}
+
+func (p *selectorParser) peek() (selectorToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return selectorToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *selectorParser) accept(kind selectorTokenKind) bool {
+	if t, ok := p.peek(); ok && t.kind == kind {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *selectorParser) parseOr() (Selector, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(tokenOr) {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orSelector{left, right}
+	}
+	return left, nil
+}
+
+func (p *selectorParser) parseAnd() (Selector, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(tokenAnd) {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andSelector{left, right}
+	}
+	return left, nil
+}
+
+func (p *selectorParser) parseUnary() (Selector, error) {
+	if p.accept(tokenNot) {
+		s, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notSelector{s}, nil
+	}
+	if p.accept(tokenLParen) {
+		s, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(tokenRParen) {
+			return nil, fmt.Errorf("missing ) in select expression")
+		}
+		return s, nil
+	}
+	return p.parseField()
+}
+
+func (p *selectorParser) parseField() (Selector, error) {
+	t, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("unexpected end of select expression")
+	}
+	if t.kind != tokenWord {
+		return nil, fmt.Errorf("unexpected %q in select expression", t.text)
+	}
+	p.pos++
+
+	negate := false
+	switch {
+	case p.accept(tokenEq):
+	case p.accept(tokenNotEq):
+		negate = true
+	default:
+		// 单独的词为标签
+		return fieldSelector{field: SelectLabel, value: t.text}, nil
+	}
+
+	switch t.text {
+	case SelectTest, SelectModule, SelectID, SelectStep, SelectRole, SelectLabel:
+	default:
+		return nil, fmt.Errorf("unknown select field %q, should be one of test, module, id, step, role and label", t.text)
+	}
+	v, ok := p.peek()
+	if !ok || v.kind != tokenWord {
+		return nil, fmt.Errorf("missing value of %s in select expression", t.text)
+	}
+	p.pos++
+
+	var s Selector = fieldSelector{field: t.text, value: v.text}
+	if negate {
+		s = notSelector{s}
+	}
+	return s, nil
+}
+
+// testLabels 测试在代码与 multiConfig.yaml 中声明的标签
+func testLabels(test MultiUserTest) []string {
+	labels := append([]string{}, test.Labels...)
+	for _, label := range TestConfig[test.TestName].Labels {
+		if !contains(labels, label) {
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+// selectSteps 返回角色在测试中需要执行的步骤，包括被选中步骤（间接）依赖的步骤
+func selectSteps(test MultiUserTest, role string, deps map[string][]string) map[string]struct{} {
+	selected := make(map[string]struct{})
+	id, module := ParseTestName(test.TestName)
+	target := SelectTarget{Test: test.TestName, Module: module, ID: id, Role: role, Labels: testLabels(test)}
+
+	var include func(step string)
+	include = func(step string) {
+		if _, ok := selected[step]; ok {
+			return
+		}
+		selected[step] = struct{}{}
+		for _, dep := range deps[step] {
+			include(dep)
+		}
+	}
+	for _, step := range test.Steps {
+		target.Step = step.Name
+		if TestSelector == nil || TestSelector.Match(target) {
+			include(step.Name)
+		}
+	}
+	return selected
+}
@@ -0,0 +1,210 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestLexSelector(t *testing.T) {
+	cases := []struct {
+		expr    string
+		want    []string
+		wantErr string
+	}{
+		{expr: "", want: nil},
+		{expr: "smoke", want: []string{"smoke"}},
+		{expr: "module=存储 && role!=admin", want: []string{"module", "=", "存储", "&&", "role", "!=", "admin"}},
+		{expr: "!(a||b)", want: []string{"!", "(", "a", "||", "b", ")"}},
+		{expr: `test="[配置][9387667]ConfigMap 检查"`, want: []string{"test", "=", "[配置][9387667]ConfigMap 检查"}},
+		{expr: `step="a && b"`, want: []string{"step", "=", "a && b"}},
+		{expr: "id=93*", want: []string{"id", "=", "93*"}},
+		{expr: `step="unterminated`, wantErr: "unterminated quote"},
+		{expr: "a & b", wantErr: "use && or ||"},
+		{expr: "a | b", wantErr: "use && or ||"},
+	}
+	for _, c := range cases {
+		t.Run(c.expr, func(t *testing.T) {
+			tokens, err := lexSelector(c.expr)
+			if len(c.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("err = %v, want %q", err, c.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, tok := range tokens {
+				got = append(got, tok.text)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("got %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseSelector(t *testing.T) {
+	storage := SelectTarget{Test: "[存储][9386601]PVC创建", Module: "存储", ID: "9386601", Step: "创建PVC", Role: "projectAdmin", Labels: []string{"smoke"}}
+	config := SelectTarget{Test: "[配置][9387667]ConfigMap检查", Module: "配置", ID: "9387667", Step: "删除CM", Role: UserAdmin, Labels: []string{"nightly", "slow"}}
+	quota := SelectTarget{Test: "[配额和空间管理]配额层级与超限校验", Module: "配额和空间管理", Step: "超限创建", Role: "viewer"}
+	targets := map[string]SelectTarget{"storage": storage, "config": config, "quota": quota}
+
+	cases := []struct {
+		expr string
+		want []string
+	}{
+		{expr: "smoke", want: []string{"storage"}},
+		{expr: "label=slow", want: []string{"config"}},
+		{expr: "label=s*", want: []string{"config", "storage"}},
+		{expr: "module=存储", want: []string{"storage"}},
+		{expr: "module=[存储]", want: []string{"storage"}},
+		{expr: "id=9386601", want: []string{"storage"}},
+		{expr: "id=938*", want: []string{"config", "storage"}},
+		{expr: "role!=admin", want: []string{"quota", "storage"}},
+		{expr: "!role=admin", want: []string{"quota", "storage"}},
+		{expr: `test="[配置][9387667]ConfigMap检查"`, want: []string{"config"}},
+		{expr: "step=??CM", want: []string{"config"}},
+		{expr: "test=*PVC*", want: []string{"storage"}},
+		{expr: "test=[配置]*", want: []string{"config"}},
+		{expr: "test=[存配]*", want: nil},
+		// && 优先于 ||
+		{expr: "smoke || nightly && role=viewer", want: []string{"storage"}},
+		{expr: "(smoke || nightly) && role=viewer", want: nil},
+		{expr: "nightly && role=admin || module=配额和空间管理", want: []string{"config", "quota"}},
+		// ! 只作用于紧随其后的表达式
+		{expr: "!smoke && !nightly", want: []string{"quota"}},
+		{expr: "!(smoke || nightly)", want: []string{"quota"}},
+		{expr: "!!smoke", want: []string{"storage"}},
+		{expr: "id!=9386601 && id!=9387667", want: []string{"quota"}},
+	}
+	for _, c := range cases {
+		t.Run(c.expr, func(t *testing.T) {
+			s, err := ParseSelector(c.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for name, target := range targets {
+				if s.Match(target) {
+					got = append(got, name)
+				}
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("matched %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseSelectorErrors(t *testing.T) {
+	cases := []struct {
+		expr    string
+		wantErr string
+	}{
+		{expr: "smoke &&", wantErr: "unexpected end"},
+		{expr: "(smoke", wantErr: "missing )"},
+		{expr: "smoke)", wantErr: `unexpected ")"`},
+		{expr: "smoke nightly", wantErr: `unexpected "nightly"`},
+		{expr: "owner=admin", wantErr: `unknown select field "owner"`},
+		{expr: "role=", wantErr: "missing value of role"},
+		{expr: "role=(admin)", wantErr: "missing value of role"},
+		{expr: "&& smoke", wantErr: `unexpected "&&"`},
+		{expr: "=admin", wantErr: `unexpected "="`},
+	}
+	for _, c := range cases {
+		t.Run(c.expr, func(t *testing.T) {
+			_, err := ParseSelector(c.expr)
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Fatalf("err = %v, want %q", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseSelectorEmpty(t *testing.T) {
+	for _, expr := range []string{"", "   "} {
+		s, err := ParseSelector(expr)
+		if err != nil || s != nil {
+			t.Fatalf("ParseSelector(%q) = %v, %v", expr, s, err)
+		}
+	}
+}
+
+func TestTestLabels(t *testing.T) {
+	const name = "[配置][9387667]ConfigMap检查"
+	TestConfig[name] = MultiUserTest{TestName: name, Labels: []string{"nightly", "smoke"}}
+	defer delete(TestConfig, name)
+
+	got := testLabels(MultiUserTest{TestName: name, Labels: []string{"smoke", "configmap"}})
+	want := []string{"smoke", "configmap", "nightly"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestSelectSteps(t *testing.T) {
+	test := MultiUserTest{
+		TestName: "[配置][9387667]ConfigMap检查",
+		Labels:   []string{"smoke"},
+		Steps: []MultiUserTestStep{
+			{Name: "create"}, {Name: "get"}, {Name: "update"}, {Name: "delete"}, {Name: "audit"},
+		},
+	}
+	// update 依赖 get，get 依赖 create，audit 无依赖
+	deps := map[string][]string{"get": {"create"}, "update": {"get"}, "delete": {"create"}}
+
+	cases := []struct {
+		expr string
+		role string
+		want []string
+	}{
+		{expr: "", role: UserAdmin, want: []string{"audit", "create", "delete", "get", "update"}},
+		{expr: "step=update", role: UserAdmin, want: []string{"create", "get", "update"}},
+		{expr: "step=delete || step=audit", role: UserAdmin, want: []string{"audit", "create", "delete"}},
+		{expr: "step=create", role: UserAdmin, want: []string{"create"}},
+		{expr: "step=update && role=viewer", role: UserAdmin, want: []string{}},
+		{expr: "step=update && role=viewer", role: "viewer", want: []string{"create", "get", "update"}},
+		{expr: "smoke && step=get", role: UserAdmin, want: []string{"create", "get"}},
+		{expr: "module=配置 && id=9387667 && step=audit", role: UserAdmin, want: []string{"audit"}},
+		{expr: "nightly", role: UserAdmin, want: []string{}},
+	}
+	defer func() { TestSelector = nil }()
+	for _, c := range cases {
+		t.Run(c.expr+"/"+c.role, func(t *testing.T) {
+			s, err := ParseSelector(c.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			TestSelector = s
+			got := []string{}
+			for step := range selectSteps(test, c.role, deps) {
+				got = append(got, step)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("selected %v, want %v", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"context"
+	"time"
+
+	"github.com/kubecube-io/kubecube/pkg/clog"
+
+	backoff "github.com/kubecube-io/kubecube-e2e/util/retry"
+)
+
+// SessionState 用户登录会话状态，用于诊断
+type SessionState struct {
+	Role     string
+	Username string
+	LoggedIn bool
+	// LastLogin 最近一次登录成功的时间
+	LastLogin time.Time
+	// LastError 最近一次登录失败的原因，登录成功后清空
+	LastError string
+	// Relogins 因会话过期重新登录的次数
+	Relogins int
+	// Generation 每次登录成功加一，用于判断并发请求是否已经刷新过会话
+	Generation int
+}
+
+// Session 返回角色当前的会话状态
+func (h *HttpHelper) Session(role string) SessionState {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.sessions[role]; ok {
+		return *s
+	}
+	return SessionState{Role: role}
+}
+
+// SessionStates 按角色顺序返回所有会话状态
+func (h *HttpHelper) SessionStates() []SessionState {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	states := make([]SessionState, 0, len(h.Roles))
+	for _, role := range h.Roles {
+		states = append(states, *h.sessions[role])
+	}
+	return states
+}
+
+// relogin 会话过期后重新登录，若在等待期间其他请求已刷新会话则直接返回
+func (h *HttpHelper) relogin(role string, generation int) error {
+	h.loginMu[role].Lock()
+	defer h.loginMu[role].Unlock()
+	if h.Session(role).Generation != generation {
+		return nil
+	}
+	err := h.login(role)
+	if err == nil {
+		h.mu.Lock()
+		h.sessions[role].Relogins++
+		h.mu.Unlock()
+	}
+	return err
+}
+
+// loginRole 登录角色对应的用户
+func (h *HttpHelper) loginRole(role string) error {
+	h.loginMu[role].Lock()
+	defer h.loginMu[role].Unlock()
+	return h.login(role)
+}
+
+// login 以指数退避重试登录，调用方需持有该角色的 loginMu
+func (h *HttpHelper) login(role string) error {
+	h.mu.Lock()
+	user := *h.Users[role]
+	h.mu.Unlock()
+	user.Cookie = nil
+	user.Token = ""
+
+	b := backoff.NewExponentialBackOff()
+	b.MaxElapsedTime = h.LoginTimeout
+	err := backoff.RetryNotify(func() error {
+		return h.loginFunc.LoginByUser(h.Host, &user)
+	}, b, context.Background(), func(err error, d time.Duration) {
+		clog.Warn("login %s as %s failed, retry after %v: %v", role, user.Username, d, err)
+	})
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	session := h.sessions[role]
+	if err != nil {
+		session.LoggedIn = false
+		session.LastError = err.Error()
+		return err
+	}
+	*h.Users[role] = user
+	session.LoggedIn = true
+	session.LastLogin = time.Now()
+	session.LastError = ""
+	session.Generation++
+	return nil
+}
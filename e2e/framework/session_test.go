@@ -0,0 +1,123 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	backoff "github.com/kubecube-io/kubecube-e2e/util/retry"
+)
+
+// countingLogin 记录登录次数，前 failures 次返回 err
+type countingLogin struct {
+	calls    atomic.Int32
+	failures int32
+	err      error
+}
+
+func (c *countingLogin) LoginByUser(_ string, user *AuthUser) error {
+	if c.calls.Add(1) <= c.failures {
+		return c.err
+	}
+	user.Token = "Bearer " + user.Username
+	return nil
+}
+
+func (c *countingLogin) AuthHeader() string {
+	return "Authorization"
+}
+
+func TestLoginRetry(t *testing.T) {
+	cases := []struct {
+		name      string
+		failures  int32
+		err       error
+		wantCalls int32
+		wantErr   bool
+	}{
+		{name: "success", wantCalls: 1},
+		{name: "transient error is retried", failures: 2, err: errors.New("connection refused"), wantCalls: 3},
+		{name: "server error is retried", failures: 1, err: loginStatusError(http.StatusBadGateway, errors.New("bad gateway")), wantCalls: 2},
+		{name: "too many requests is retried", failures: 1, err: loginStatusError(http.StatusTooManyRequests, errors.New("slow down")), wantCalls: 2},
+		{name: "unauthorized is permanent", failures: 10, err: loginStatusError(http.StatusUnauthorized, errors.New("bad password")), wantCalls: 1, wantErr: true},
+		{name: "permanent error", failures: 10, err: backoff.Permanent(errors.New("no access key")), wantCalls: 1, wantErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cfg := &Config{KubecubeHost: "http://127.0.0.1", WaitTimeout: 30 * time.Second}
+			cfg.Roles = testRoles()
+			h := NewHttpHelper(cfg)
+			login := &countingLogin{failures: c.failures, err: c.err}
+			h.loginFunc = login
+
+			role := h.Roles[0]
+			err := h.loginRole(role)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("err = %v, want error %v", err, c.wantErr)
+			}
+			if got := login.calls.Load(); got != c.wantCalls {
+				t.Fatalf("login called %d times, want %d", got, c.wantCalls)
+			}
+			if s := h.Session(role); s.LoggedIn == c.wantErr {
+				t.Fatalf("session logged in = %v", s.LoggedIn)
+			}
+		})
+	}
+}
+
+func testRoles() *RoleRegistry {
+	roles, err := NewRoleRegistry([]*Role{
+		{Name: UserAdmin, Username: "admin", Password: "admin-pw"},
+		{Name: "tenant-admin", Username: "tenant", Password: "tenant-pw"},
+		{Name: "viewer", Username: "viewer", Password: "viewer-pw"},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return roles
+}
+
+// TestLoginConcurrentWithLookup 登录写入用户凭证的同时按用户名查找角色，配合 -race 检查数据竞争
+func TestLoginConcurrentWithLookup(t *testing.T) {
+	Register("counting", &countingLogin{})
+	cfg := &Config{KubecubeHost: "http://127.0.0.1", WaitTimeout: time.Second, Roles: testRoles()}
+	h := NewHttpHelper(cfg)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			if _, ok := h.roleByName("viewer"); !ok {
+				t.Error("role of viewer not found")
+				return
+			}
+		}
+	}()
+	if err := h.Login("counting"); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	for _, s := range h.SessionStates() {
+		if !s.LoggedIn {
+			t.Errorf("role %s not logged in", s.Role)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	mcclient "github.com/kubecube-io/kubecube/pkg/multicluster/client"
+	"github.com/onsi/ginkgo"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// SnapshotOff 不检查测试对共享资源的修改
+	SnapshotOff = "off"
+	// SnapshotWarn 测试结束后共享资源与测试开始前不一致时输出差异
+	SnapshotWarn = "warn"
+	// SnapshotFail 测试结束后共享资源与测试开始前不一致时测试失败
+	SnapshotFail = "fail"
+)
+
+// SnapshotKind 测试前后需要比较的资源类型
+type SnapshotKind struct {
+	APIVersion string `mapstructure:"apiVersion"`
+	Kind       string `mapstructure:"kind"`
+}
+
+func (k SnapshotKind) gvk() schema.GroupVersionKind {
+	return schema.FromAPIVersionAndKind(k.APIVersion, k.Kind)
+}
+
+// DefaultSnapshotKinds 未配置 snapshot.kinds 时比较的资源类型
+var DefaultSnapshotKinds = []SnapshotKind{
+	{APIVersion: "quota.kubecube.io/v1", Kind: "CubeResourceQuota"},
+	{APIVersion: "v1", Kind: "ResourceQuota"},
+	{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "RoleBinding"},
+	{APIVersion: "v1", Kind: "Namespace"},
+	{APIVersion: "apiextensions.k8s.io/v1", Kind: "CustomResourceDefinition"},
+}
+
+// snapshotKey 快照中对象的标识
+type snapshotKey struct {
+	cluster   string
+	gvk       schema.GroupVersionKind
+	namespace string
+	name      string
+}
+
+func (k snapshotKey) String() string {
+	name := k.name
+	if len(k.namespace) > 0 {
+		name = k.namespace + "/" + name
+	}
+	return fmt.Sprintf("%s %s in cluster %s", k.gvk.Kind, name, k.cluster)
+}
+
+// Snapshot 管控集群与目标集群中共享资源的状态，只保留对象的标签、注解与 status 以外的字段
+type Snapshot map[snapshotKey]map[string]interface{}
+
+// TakeSnapshot 读取两个集群中 kinds 类型的对象，命名空间级别的资源与 Namespace 只读取测试使用的租户、项目与测试空间，
+// 测试创建的对象（带有 kubecube-e2e-run-id 标签，包括其他进程与其他运行创建的）由 ResourceTracker 清理，不计入快照
+func (tc *TestContext) TakeSnapshot(kinds []SnapshotKind) (Snapshot, error) {
+	clusters := map[string]mcclient.Client{
+		tc.PivotClusterName:  tc.PivotClusterClient,
+		tc.TargetClusterName: tc.TargetClusterClient,
+	}
+	namespaces := []string{
+		"kubecube-tenant-" + tc.TenantName,
+		"kubecube-project-" + tc.ProjectName,
+		tc.NamespaceName,
+	}
+	sharedNamespaces := make(map[string]bool, len(namespaces))
+	for _, ns := range namespaces {
+		sharedNamespaces[ns] = true
+	}
+
+	s := make(Snapshot)
+	for cluster, cli := range clusters {
+		for _, kind := range kinds {
+			gvk := kind.gvk()
+			namespaced, err := isNamespacedKind(cli, gvk)
+			if meta.IsNoMatchError(err) {
+				// 集群中没有该资源，如成员集群未安装的 CRD
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("get mapping of %s in cluster %s failed: %v", gvk.Kind, cluster, err)
+			}
+			scopes := []string{""}
+			if namespaced {
+				scopes = namespaces
+			}
+			for _, ns := range scopes {
+				list := &unstructured.UnstructuredList{}
+				list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+				if err := cli.Direct().List(context.Background(), list, ctrlclient.InNamespace(ns)); err != nil {
+					return nil, fmt.Errorf("list %s in cluster %s failed: %v", gvk.Kind, cluster, err)
+				}
+				for i := range list.Items {
+					obj := &list.Items[i]
+					if _, ok := obj.GetLabels()[RunIDLabel]; ok {
+						continue
+					}
+					if isNamespaceKind(gvk) && !sharedNamespaces[obj.GetName()] {
+						continue
+					}
+					key := snapshotKey{cluster: cluster, gvk: gvk, namespace: obj.GetNamespace(), name: obj.GetName()}
+					s[key] = snapshotContent(obj)
+				}
+			}
+		}
+	}
+	return s, nil
+}
+
+func isNamespaceKind(gvk schema.GroupVersionKind) bool {
+	return gvk.Group == "" && gvk.Kind == "Namespace"
+}
+
+func isNamespacedKind(cli mcclient.Client, gvk schema.GroupVersionKind) (bool, error) {
+	mapping, err := cli.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return false, err
+	}
+	return mapping.Scope.Name() == meta.RESTScopeNameNamespace, nil
+}
+
+// snapshotContent 去掉 status 与由服务端维护的元数据，只比较声明的内容
+func snapshotContent(obj *unstructured.Unstructured) map[string]interface{} {
+	content := make(map[string]interface{}, len(obj.Object))
+	for k, v := range obj.Object {
+		if k == "metadata" || k == "status" || k == "apiVersion" || k == "kind" {
+			continue
+		}
+		content[k] = v
+	}
+	metadata := make(map[string]interface{})
+	if labels := obj.GetLabels(); len(labels) > 0 {
+		metadata["labels"] = labels
+	}
+	if annotations := obj.GetAnnotations(); len(annotations) > 0 {
+		metadata["annotations"] = annotations
+	}
+	if obj.GetDeletionTimestamp() != nil {
+		metadata["deleting"] = true
+	}
+	if len(metadata) > 0 {
+		content["metadata"] = metadata
+	}
+	return content
+}
+
+// Diff 返回从 before 到 s 的差异，ignoreKinds 中的资源类型不比较，没有差异时返回空字符串
+func (s Snapshot) Diff(before Snapshot, ignoreKinds ...string) string {
+	ignored := make(map[string]bool, len(ignoreKinds))
+	for _, kind := range ignoreKinds {
+		ignored[kind] = true
+	}
+
+	keys := make([]snapshotKey, 0, len(s)+len(before))
+	for k := range before {
+		keys = append(keys, k)
+	}
+	for k := range s {
+		if _, ok := before[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+
+	var b strings.Builder
+	for _, k := range keys {
+		if ignored[k.gvk.Kind] {
+			continue
+		}
+		old, hadOld := before[k]
+		cur, hasCur := s[k]
+		switch {
+		case !hasCur:
+			fmt.Fprintf(&b, "- %s (deleted)\n", k)
+		case !hadOld:
+			fmt.Fprintf(&b, "+ %s (created)\n", k)
+			writeFieldDiff(&b, nil, flattenFields(cur))
+		default:
+			oldFields, curFields := flattenFields(old), flattenFields(cur)
+			if !fieldsEqual(oldFields, curFields) {
+				fmt.Fprintf(&b, "~ %s\n", k)
+				writeFieldDiff(&b, oldFields, curFields)
+			}
+		}
+	}
+	return b.String()
+}
+
+// flattenFields 将对象展开为 字段路径 -> json 值
+func flattenFields(obj map[string]interface{}) map[string]string {
+	fields := make(map[string]string)
+	var walk func(path string, v interface{})
+	walk = func(path string, v interface{}) {
+		switch val := v.(type) {
+		case map[string]interface{}:
+			if len(val) == 0 {
+				fields[path] = "{}"
+			}
+			for k, child := range val {
+				walk(joinFieldPath(path, k), child)
+			}
+		case map[string]string:
+			for k, child := range val {
+				walk(joinFieldPath(path, k), child)
+			}
+		default:
+			data, _ := json.Marshal(val)
+			fields[path] = string(data)
+		}
+	}
+	walk("", obj)
+	return fields
+}
+
+func joinFieldPath(path, key string) string {
+	if len(path) == 0 {
+		return key
+	}
+	return path + "." + key
+}
+
+func fieldsEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || v != w {
+			return false
+		}
+	}
+	return true
+}
+
+func writeFieldDiff(b *strings.Builder, old, cur map[string]string) {
+	paths := make([]string, 0, len(old)+len(cur))
+	for p := range old {
+		paths = append(paths, p)
+	}
+	for p := range cur {
+		if _, ok := old[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		o, hadOld := old[p]
+		c, hasCur := cur[p]
+		if hadOld && hasCur && o == c {
+			continue
+		}
+		if hadOld {
+			fmt.Fprintf(b, "    - %s: %s\n", p, o)
+		}
+		if hasCur {
+			fmt.Fprintf(b, "    + %s: %s\n", p, c)
+		}
+	}
+}
+
+// snapshotTest 返回在测试第一个用例开始前记录快照、所有 total 个用例结束后比较快照的 BeforeEach 与 AfterEach，
+// after 需注册在清理测试创建的对象之后
+func snapshotTest(tc *TestContext, test MultiUserTest, scope string, total int) (before, after func()) {
+	if tc.SnapshotMode == SnapshotOff || tc.PivotClusterClient == nil {
+		return func() {}, func() {}
+	}
+	var (
+		snapshot Snapshot
+		started  bool
+		finished int
+	)
+	before = func() {
+		if started {
+			return
+		}
+		started = true
+		s, err := tc.TakeSnapshot(tc.SnapshotKinds)
+		if err != nil {
+			clog.Warn("fail to take snapshot before test %s: %v", scope, err)
+			return
+		}
+		snapshot = s
+	}
+	after = func() {
+		finished++
+		if finished != total || snapshot == nil {
+			return
+		}
+		s, err := tc.TakeSnapshot(tc.SnapshotKinds)
+		if err != nil {
+			clog.Warn("fail to take snapshot after test %s: %v", scope, err)
+			return
+		}
+		diff := s.Diff(snapshot, test.SideEffects...)
+		if len(diff) == 0 {
+			return
+		}
+		message := fmt.Sprintf("test %s left changes on shared resources:\n%s", scope, diff)
+		if tc.SnapshotMode == SnapshotFail {
+			ginkgo.Fail(message)
+			return
+		}
+		clog.Warn(message)
+	}
+	return before, after
+}
@@ -0,0 +1,218 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestFlattenFields(t *testing.T) {
+	cases := []struct {
+		name string
+		obj  map[string]interface{}
+		want map[string]string
+	}{
+		{
+			name: "empty object",
+			obj:  map[string]interface{}{},
+			want: map[string]string{"": "{}"},
+		},
+		{
+			name: "nested maps and scalars",
+			obj: map[string]interface{}{
+				"spec": map[string]interface{}{
+					"hard":     map[string]interface{}{"cpu": "2", "pods": int64(10)},
+					"selector": map[string]interface{}{},
+					"enabled":  true,
+				},
+				"metadata": map[string]interface{}{
+					"labels": map[string]string{"app": "demo"},
+				},
+			},
+			want: map[string]string{
+				"spec.hard.cpu":       `"2"`,
+				"spec.hard.pods":      `10`,
+				"spec.selector":       `{}`,
+				"spec.enabled":        `true`,
+				"metadata.labels.app": `"demo"`,
+			},
+		},
+		{
+			name: "lists are compared as a whole",
+			obj: map[string]interface{}{
+				"subjects": []interface{}{map[string]interface{}{"kind": "User", "name": "admin"}},
+				"data":     nil,
+			},
+			want: map[string]string{
+				"subjects": `[{"kind":"User","name":"admin"}]`,
+				"data":     `null`,
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := flattenFields(c.obj); !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("flattenFields() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestWriteFieldDiff(t *testing.T) {
+	cases := []struct {
+		name     string
+		old, cur map[string]string
+		want     string
+	}{
+		{
+			name: "created",
+			cur:  map[string]string{"b": "2", "a": "1"},
+			want: "    + a: 1\n    + b: 2\n",
+		},
+		{
+			name: "deleted",
+			old:  map[string]string{"a": "1"},
+			want: "    - a: 1\n",
+		},
+		{
+			name: "changed, added and removed fields",
+			old:  map[string]string{"a": "1", "b": "2", "c": "3"},
+			cur:  map[string]string{"a": "1", "b": "20", "d": "4"},
+			want: "    - b: 2\n    + b: 20\n    - c: 3\n    + d: 4\n",
+		},
+		{
+			name: "unchanged",
+			old:  map[string]string{"a": "1"},
+			cur:  map[string]string{"a": "1"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var b strings.Builder
+			writeFieldDiff(&b, c.old, c.cur)
+			if got := b.String(); got != c.want {
+				t.Fatalf("writeFieldDiff():\n%s\nwant:\n%s", got, c.want)
+			}
+		})
+	}
+}
+
+func TestSnapshotDiff(t *testing.T) {
+	quota := func(ns string) snapshotKey {
+		return snapshotKey{cluster: "pivot", gvk: schema.GroupVersionKind{Version: "v1", Kind: "ResourceQuota"}, namespace: ns, name: "quota"}
+	}
+	binding := snapshotKey{cluster: "pivot", gvk: schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "RoleBinding"}, namespace: "ns1", name: "admin"}
+	hard := func(cpu string) map[string]interface{} {
+		return map[string]interface{}{"spec": map[string]interface{}{"hard": map[string]interface{}{"cpu": cpu}}}
+	}
+
+	cases := []struct {
+		name          string
+		before, after Snapshot
+		ignoreKinds   []string
+		want          string
+	}{
+		{
+			name:   "unchanged",
+			before: Snapshot{quota("ns1"): hard("1")},
+			after:  Snapshot{quota("ns1"): hard("1")},
+		},
+		{
+			name:   "created",
+			before: Snapshot{},
+			after:  Snapshot{quota("ns1"): hard("1")},
+			want: "+ ResourceQuota ns1/quota in cluster pivot (created)\n" +
+				"    + spec.hard.cpu: \"1\"\n",
+		},
+		{
+			name:   "deleted",
+			before: Snapshot{quota("ns1"): hard("1")},
+			after:  Snapshot{},
+			want:   "- ResourceQuota ns1/quota in cluster pivot (deleted)\n",
+		},
+		{
+			name:   "changed",
+			before: Snapshot{quota("ns1"): hard("1"), quota("ns2"): hard("2")},
+			after:  Snapshot{quota("ns1"): hard("2"), quota("ns2"): hard("2")},
+			want: "~ ResourceQuota ns1/quota in cluster pivot\n" +
+				"    - spec.hard.cpu: \"1\"\n" +
+				"    + spec.hard.cpu: \"2\"\n",
+		},
+		{
+			name:   "sorted by object",
+			before: Snapshot{quota("ns2"): hard("1"), binding: {"roleRef": "admin"}},
+			after:  Snapshot{quota("ns1"): hard("1"), binding: {"roleRef": "viewer"}},
+			want: "+ ResourceQuota ns1/quota in cluster pivot (created)\n" +
+				"    + spec.hard.cpu: \"1\"\n" +
+				"- ResourceQuota ns2/quota in cluster pivot (deleted)\n" +
+				"~ RoleBinding ns1/admin in cluster pivot\n" +
+				"    - roleRef: \"admin\"\n" +
+				"    + roleRef: \"viewer\"\n",
+		},
+		{
+			name:        "ignored kinds",
+			before:      Snapshot{quota("ns2"): hard("1"), binding: {"roleRef": "admin"}},
+			after:       Snapshot{quota("ns1"): hard("1"), binding: {"roleRef": "viewer"}},
+			ignoreKinds: []string{"ResourceQuota"},
+			want: "~ RoleBinding ns1/admin in cluster pivot\n" +
+				"    - roleRef: \"admin\"\n" +
+				"    + roleRef: \"viewer\"\n",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.after.Diff(c.before, c.ignoreKinds...); got != c.want {
+				t.Fatalf("Diff():\n%s\nwant:\n%s", got, c.want)
+			}
+		})
+	}
+}
+
+func TestSnapshotContent(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ResourceQuota",
+		"metadata": map[string]interface{}{
+			"name":            "quota",
+			"namespace":       "ns1",
+			"resourceVersion": "42",
+			"labels":          map[string]interface{}{"app": "demo"},
+		},
+		"spec":   map[string]interface{}{"hard": map[string]interface{}{"cpu": "1"}},
+		"status": map[string]interface{}{"used": map[string]interface{}{"cpu": "500m"}},
+	}}
+	want := map[string]string{
+		"spec.hard.cpu":       `"1"`,
+		"metadata.labels.app": `"demo"`,
+	}
+	if got := flattenFields(snapshotContent(obj)); !reflect.DeepEqual(got, want) {
+		t.Fatalf("snapshotContent() = %v, want %v", got, want)
+	}
+
+	now := metav1.Now()
+	obj.SetDeletionTimestamp(&now)
+	want["metadata.deleting"] = "true"
+	if got := flattenFields(snapshotContent(obj)); !reflect.DeepEqual(got, want) {
+		t.Fatalf("snapshotContent() of deleting object = %v, want %v", got, want)
+	}
+}
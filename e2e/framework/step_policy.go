@@ -0,0 +1,256 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kubecube-io/kubecube/pkg/clog"
+
+	backoff "github.com/kubecube-io/kubecube-e2e/util/retry"
+)
+
+// 退避策略
+const (
+	BackoffConstant    = "constant"
+	BackoffExponential = "exponential"
+)
+
+// BackoffPolicy 步骤重试的退避策略
+type BackoffPolicy struct {
+	// Policy constant 或 exponential，默认 constant
+	Policy string `yaml:"policy,omitempty" json:"policy"`
+	// Interval 重试间隔，exponential 时为初始间隔，默认为 timeout.waitInterval
+	Interval time.Duration `yaml:"interval,omitempty" json:"-"`
+	// MaxInterval exponential 时的最大间隔，默认不限制
+	MaxInterval time.Duration `yaml:"maxInterval,omitempty" json:"-"`
+}
+
+func (b BackoffPolicy) String() string {
+	s := fmt.Sprintf("%s/%v", b.Policy, b.Interval)
+	if b.Policy == BackoffExponential && b.MaxInterval > 0 {
+		s += fmt.Sprintf("-%v", b.MaxInterval)
+	}
+	return s
+}
+
+func (b BackoffPolicy) newBackOff() backoff.BackOff {
+	if b.Policy != BackoffExponential {
+		return backoff.NewConstantBackOff(b.Interval)
+	}
+	e := backoff.NewExponentialBackOff()
+	e.InitialInterval = b.Interval
+	// 总时长由步骤的 Timeout 控制
+	e.MaxElapsedTime = 0
+	e.MaxInterval = b.MaxInterval
+	if e.MaxInterval <= 0 {
+		e.MaxInterval = backoff.DefaultMaxInterval
+	}
+	if e.MaxInterval < e.InitialInterval {
+		e.MaxInterval = e.InitialInterval
+	}
+	e.Reset()
+	return e
+}
+
+// StepPolicy 步骤生效的超时与重试策略
+type StepPolicy struct {
+	// Timeout 步骤（含重试）的超时时间，为 0 时不限制
+	Timeout time.Duration
+	Retries int
+	Backoff BackoffPolicy
+}
+
+func (p StepPolicy) String() string {
+	timeout := "none"
+	if p.Timeout > 0 {
+		timeout = p.Timeout.String()
+	}
+	return fmt.Sprintf("timeout=%s retries=%d backoff=%s", timeout, p.Retries, p.Backoff)
+}
+
+// validateStepPolicy 检查步骤的超时与重试配置
+func validateStepPolicy(test string, step MultiUserTestStep) error {
+	if step.Timeout < 0 || step.Retries < 0 {
+		return fmt.Errorf("step %s of test %s has negative timeout or retries", step.Name, test)
+	}
+	if step.Backoff == nil {
+		return nil
+	}
+	switch step.Backoff.Policy {
+	case "", BackoffConstant, BackoffExponential:
+	default:
+		return fmt.Errorf("step %s of test %s has unknown backoff policy %q", step.Name, test, step.Backoff.Policy)
+	}
+	if step.Backoff.Interval < 0 || step.Backoff.MaxInterval < 0 {
+		return fmt.Errorf("step %s of test %s has negative backoff interval", step.Name, test)
+	}
+	return nil
+}
+
+// StepPolicy 返回步骤生效的超时与重试策略，multiConfig.yaml 中配置的值优先于代码中的值，配置为 0 时关闭代码中的超时或重试，
+// 都未配置时不限制超时、不重试，重试间隔为 timeout.waitInterval
+func (tc *TestContext) StepPolicy(step, configured MultiUserTestStep) StepPolicy {
+	p := StepPolicy{
+		Timeout: step.Timeout,
+		Retries: step.Retries,
+		Backoff: BackoffPolicy{Policy: BackoffConstant, Interval: tc.WaitInterval},
+	}
+	if configured.timeoutSet {
+		p.Timeout = configured.Timeout
+	}
+	if configured.retriesSet {
+		p.Retries = configured.Retries
+	}
+	for _, b := range []*BackoffPolicy{step.Backoff, configured.Backoff} {
+		if b == nil {
+			continue
+		}
+		if len(b.Policy) > 0 {
+			p.Backoff.Policy = b.Policy
+		}
+		if b.Interval > 0 {
+			p.Backoff.Interval = b.Interval
+		}
+		if b.MaxInterval > 0 {
+			p.Backoff.MaxInterval = b.MaxInterval
+		}
+	}
+	return p
+}
+
+// runWithPolicy 按策略执行步骤，结果不符合预期或步骤内 gomega 断言失败时按退避策略重试，超过 Timeout 时步骤失败，
+// 返回最后一次执行的结果、状态码与执行次数。重试用尽后最后一次的断言失败作为步骤的失败
+func runWithPolicy(tc *TestContext, run TestFunc, expect Expectation, policy StepPolicy) (resp TestResp, status int, attempts int) {
+	ctx := context.Background()
+	if policy.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, policy.Timeout)
+		defer cancel()
+	}
+	// 可重试的步骤内断言失败时转为 panic，由 runAttempt 收集后作为一次不符合预期的执行重试
+	attemptTc := tc
+	if policy.Retries > 0 {
+		attemptTc = tc.withFailHandler(panicFail)
+	}
+
+	var failure *roleFailure
+	// WithMaxRetries 的次数为 0 时不限制重试，未配置重试时只执行一次
+	var b backoff.BackOff = &backoff.StopBackOff{}
+	if policy.Retries > 0 {
+		b = backoff.WithMaxRetries(policy.Backoff.newBackOff(), uint64(policy.Retries))
+	}
+	_ = backoff.RetryNotify(func() error {
+		attempts++
+		tc.HttpHelper.resetStatus(tc.User)
+		resp, failure = runAttempt(ctx, attemptTc, run, policy)
+		if failure != nil {
+			return errors.New(failure.message)
+		}
+		status = respStatus(tc, resp)
+		return expect.Check(resp, status)
+	}, b, ctx, func(err error, d time.Duration) {
+		clog.Warn("attempt %d of step as %s does not meet expectation: %v, retry after %v", attempts, tc.User, err, d)
+	})
+	if failure != nil {
+		raise(tc, *failure)
+	}
+	return resp, status, attempts
+}
+
+// runAttempt 执行一次步骤，可重试时返回断言失败而不是使步骤失败
+func runAttempt(ctx context.Context, tc *TestContext, run TestFunc, policy StepPolicy) (resp TestResp, failure *roleFailure) {
+	if policy.Retries > 0 {
+		defer func() {
+			if r := recover(); r != nil {
+				f, ok := r.(roleFailure)
+				if !ok {
+					panic(r)
+				}
+				failure = &f
+			}
+		}()
+	}
+	return runStepFunc(ctx, tc, run, policy.Timeout), nil
+}
+
+// raise 以 tc 的失败处理函数重新抛出断言失败：并发执行角色时交给所在角色的 goroutine，否则使当前用例失败
+func raise(tc *TestContext, f roleFailure) {
+	message := f.message
+	if len(f.location) > 0 {
+		message += "\n" + f.location
+	}
+	tc.Fail(message)
+}
+
+// stepTimeoutGrace 步骤超时后等待步骤函数返回的最长时间
+var stepTimeoutGrace = 30 * time.Second
+
+// runStepFunc 执行步骤函数，步骤内的 tc.WaitTimeout 不超过剩余的超时时间。ctx 超时后取消步骤的 tc.Context()，
+// 在 stepTimeoutGrace 内等待步骤函数返回后使步骤失败，避免超时的步骤在后续步骤执行时继续修改集群或使其他用例失败
+func runStepFunc(ctx context.Context, tc *TestContext, run TestFunc, timeout time.Duration) TestResp {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return run(tc)
+	}
+
+	stepCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stepTc := *tc
+	stepTc.ctx = stepCtx
+	// 重试时每次执行只使用剩余的时间，避免最后一次执行的等待超出步骤的超时时间
+	if remaining := time.Until(deadline); remaining < tc.WaitTimeout {
+		cfg := *tc.Config
+		cfg.WaitTimeout = remaining
+		stepTc.Config = &cfg
+	}
+
+	done := make(chan TestResp, 1)
+	panicked := make(chan interface{}, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				panicked <- r
+			}
+		}()
+		done <- run(&stepTc)
+	}()
+
+	select {
+	case resp := <-done:
+		return resp
+	case r := <-panicked:
+		// 在执行步骤的 goroutine 中重新 panic，由 ginkgo、重试或并发执行的角色处理断言失败
+		panic(r)
+	case <-ctx.Done():
+	}
+
+	cancel()
+	clog.Warn("step as %s timed out after %v, wait for it to return", tc.User, timeout)
+	select {
+	case <-done:
+	case r := <-panicked:
+		clog.Warn("step as %s failed after timeout: %v", tc.User, failureOf(r))
+	case <-time.After(stepTimeoutGrace):
+		clog.Warn("step as %s did not return within %v after timeout, leave it running", tc.User, stepTimeoutGrace)
+	}
+	tc.Fail(fmt.Sprintf("step timed out after %v", timeout))
+	return TestResp{}
+}
@@ -0,0 +1,326 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	backoff "github.com/kubecube-io/kubecube-e2e/util/retry"
+	"gopkg.in/yaml.v3"
+)
+
+func TestStepPolicy(t *testing.T) {
+	tc := &TestContext{Config: &Config{WaitInterval: 2 * time.Second}}
+	cases := []struct {
+		name       string
+		step       MultiUserTestStep
+		configured string
+		want       StepPolicy
+	}{
+		{
+			name: "defaults",
+			want: StepPolicy{Backoff: BackoffPolicy{Policy: BackoffConstant, Interval: 2 * time.Second}},
+		},
+		{
+			name: "code only",
+			step: MultiUserTestStep{Timeout: time.Minute, Retries: 3, Backoff: &BackoffPolicy{Policy: BackoffExponential, Interval: time.Second}},
+			want: StepPolicy{Timeout: time.Minute, Retries: 3, Backoff: BackoffPolicy{Policy: BackoffExponential, Interval: time.Second}},
+		},
+		{
+			name:       "config overrides code",
+			step:       MultiUserTestStep{Timeout: time.Minute, Retries: 3, Backoff: &BackoffPolicy{Policy: BackoffExponential, Interval: time.Second, MaxInterval: 10 * time.Second}},
+			configured: "{timeout: 5m, retries: 1, backoff: {interval: 3s}}",
+			want:       StepPolicy{Timeout: 5 * time.Minute, Retries: 1, Backoff: BackoffPolicy{Policy: BackoffExponential, Interval: 3 * time.Second, MaxInterval: 10 * time.Second}},
+		},
+		{
+			name:       "config zero disables code",
+			step:       MultiUserTestStep{Timeout: time.Minute, Retries: 3},
+			configured: "{timeout: 0s, retries: 0}",
+			want:       StepPolicy{Backoff: BackoffPolicy{Policy: BackoffConstant, Interval: 2 * time.Second}},
+		},
+		{
+			name:       "config without timeout keeps code",
+			step:       MultiUserTestStep{Timeout: time.Minute, Retries: 3},
+			configured: "{name: s}",
+			want:       StepPolicy{Timeout: time.Minute, Retries: 3, Backoff: BackoffPolicy{Policy: BackoffConstant, Interval: 2 * time.Second}},
+		},
+		{
+			name:       "config only backoff policy",
+			configured: "{backoff: {policy: exponential, maxInterval: 1m}}",
+			want:       StepPolicy{Backoff: BackoffPolicy{Policy: BackoffExponential, Interval: 2 * time.Second, MaxInterval: time.Minute}},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var configured MultiUserTestStep
+			if len(c.configured) > 0 {
+				if err := yaml.Unmarshal([]byte(c.configured), &configured); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := tc.StepPolicy(c.step, configured); got != c.want {
+				t.Fatalf("got %s, want %s", got, c.want)
+			}
+		})
+	}
+}
+
+func TestStepPolicyString(t *testing.T) {
+	cases := []struct {
+		policy StepPolicy
+		want   string
+	}{
+		{policy: StepPolicy{Backoff: BackoffPolicy{Policy: BackoffConstant, Interval: time.Second}}, want: "timeout=none retries=0 backoff=constant/1s"},
+		{policy: StepPolicy{Timeout: time.Minute, Retries: 2, Backoff: BackoffPolicy{Policy: BackoffExponential, Interval: time.Second, MaxInterval: 8 * time.Second}},
+			want: "timeout=1m0s retries=2 backoff=exponential/1s-8s"},
+	}
+	for _, c := range cases {
+		if got := c.policy.String(); got != c.want {
+			t.Errorf("got %s, want %s", got, c.want)
+		}
+	}
+}
+
+func TestValidateStepPolicy(t *testing.T) {
+	cases := []struct {
+		name    string
+		step    MultiUserTestStep
+		wantErr string
+	}{
+		{name: "empty", step: MultiUserTestStep{Name: "s"}},
+		{name: "valid", step: MultiUserTestStep{Name: "s", Timeout: time.Minute, Retries: 2, Backoff: &BackoffPolicy{Policy: BackoffExponential, Interval: time.Second}}},
+		{name: "default policy", step: MultiUserTestStep{Name: "s", Backoff: &BackoffPolicy{Interval: time.Second}}},
+		{name: "negative timeout", step: MultiUserTestStep{Name: "s", Timeout: -time.Second}, wantErr: "negative timeout or retries"},
+		{name: "negative retries", step: MultiUserTestStep{Name: "s", Retries: -1}, wantErr: "negative timeout or retries"},
+		{name: "unknown policy", step: MultiUserTestStep{Name: "s", Backoff: &BackoffPolicy{Policy: "linear"}}, wantErr: `unknown backoff policy "linear"`},
+		{name: "negative interval", step: MultiUserTestStep{Name: "s", Backoff: &BackoffPolicy{Interval: -time.Second}}, wantErr: "negative backoff interval"},
+		{name: "negative max interval", step: MultiUserTestStep{Name: "s", Backoff: &BackoffPolicy{MaxInterval: -time.Second}}, wantErr: "negative backoff interval"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateStepPolicy("t", c.step)
+			if len(c.wantErr) == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Fatalf("err = %v, want %q", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestBackoffPolicyNewBackOff(t *testing.T) {
+	t.Run("constant", func(t *testing.T) {
+		b := BackoffPolicy{Policy: BackoffConstant, Interval: 3 * time.Second}.newBackOff()
+		for i := 0; i < 5; i++ {
+			if d := b.NextBackOff(); d != 3*time.Second {
+				t.Fatalf("interval %v, want 3s", d)
+			}
+		}
+	})
+	t.Run("default is constant", func(t *testing.T) {
+		if d := (BackoffPolicy{Interval: time.Second}).newBackOff().NextBackOff(); d != time.Second {
+			t.Fatalf("interval %v, want 1s", d)
+		}
+	})
+	t.Run("exponential", func(t *testing.T) {
+		b := BackoffPolicy{Policy: BackoffExponential, Interval: time.Second, MaxInterval: 4 * time.Second}.newBackOff()
+		e, ok := b.(*backoff.ExponentialBackOff)
+		if !ok {
+			t.Fatalf("got %T", b)
+		}
+		if e.InitialInterval != time.Second || e.MaxInterval != 4*time.Second || e.MaxElapsedTime != 0 {
+			t.Fatalf("unexpected backoff %+v", e)
+		}
+		// 总时长由步骤超时控制，退避本身不会停止，间隔不超过 MaxInterval 加随机抖动
+		max := time.Duration(float64(4*time.Second) * (1 + e.RandomizationFactor))
+		for i := 0; i < 20; i++ {
+			d := b.NextBackOff()
+			if d == backoff.Stop || d > max {
+				t.Fatalf("attempt %d: interval %v exceeds %v", i, d, max)
+			}
+		}
+	})
+	t.Run("exponential default max interval", func(t *testing.T) {
+		e := BackoffPolicy{Policy: BackoffExponential, Interval: time.Second}.newBackOff().(*backoff.ExponentialBackOff)
+		if e.MaxInterval != backoff.DefaultMaxInterval {
+			t.Fatalf("max interval %v, want %v", e.MaxInterval, backoff.DefaultMaxInterval)
+		}
+	})
+	t.Run("exponential max interval below initial", func(t *testing.T) {
+		e := BackoffPolicy{Policy: BackoffExponential, Interval: 10 * time.Second, MaxInterval: time.Second}.newBackOff().(*backoff.ExponentialBackOff)
+		if e.MaxInterval != 10*time.Second {
+			t.Fatalf("max interval %v, want 10s", e.MaxInterval)
+		}
+	})
+}
+
+// policyTestContext 不依赖集群的 TestContext，并模拟并发执行角色使断言失败以 roleFailure panic
+func policyTestContext(t *testing.T) *TestContext {
+	t.Helper()
+	cfg := &Config{KubecubeHost: "http://127.0.0.1", Roles: testRoles()}
+	tc := &TestContext{Config: cfg, HttpHelper: NewHttpHelper(cfg), Role: UserAdmin, User: "admin"}
+	return tc.withFailHandler(panicFail)
+}
+
+// sequence 依次返回 results 中的结果，nil 表示断言失败，超出后重复最后一个
+func sequence(calls *atomic.Int32, results ...error) TestFunc {
+	return func(tc *TestContext) TestResp {
+		i := int(calls.Add(1)) - 1
+		if i >= len(results) {
+			i = len(results) - 1
+		}
+		if results[i] == nil {
+			tc.ExpectEqual(results[i], errOK, "assertion failed")
+		}
+		if results[i] == errOK {
+			return SucceedResp
+		}
+		return NewTestResp(results[i], nil)
+	}
+}
+
+var errOK = errors.New("ok")
+
+func TestRunWithPolicy(t *testing.T) {
+	forbidden := errors.New("forbidden")
+	cases := []struct {
+		name         string
+		retries      int
+		results      []error
+		wantAttempts int
+		wantFailure  bool
+		wantErr      bool
+	}{
+		{name: "pass", results: []error{errOK}, wantAttempts: 1},
+		{name: "mismatch without retries runs once", results: []error{forbidden}, wantAttempts: 1, wantErr: true},
+		{name: "mismatch retried until pass", retries: 3, results: []error{forbidden, forbidden, errOK}, wantAttempts: 3},
+		{name: "retries exhausted", retries: 2, results: []error{forbidden}, wantAttempts: 3, wantErr: true},
+		{name: "assertion failure retried", retries: 2, results: []error{nil, errOK}, wantAttempts: 2},
+		{name: "assertion failure after retries", retries: 2, results: []error{nil}, wantAttempts: 3, wantFailure: true},
+		{name: "assertion failure without retries", results: []error{nil, errOK}, wantAttempts: 1, wantFailure: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tc := policyTestContext(t)
+			var calls atomic.Int32
+			var (
+				resp    TestResp
+				failure interface{}
+			)
+			func() {
+				defer func() { failure = recover() }()
+				resp, _, _ = runWithPolicy(tc, sequence(&calls, c.results...), Expectation{Pass: true}, StepPolicy{Retries: c.retries})
+			}()
+			if got := int(calls.Load()); got != c.wantAttempts {
+				t.Errorf("attempts = %d, want %d", got, c.wantAttempts)
+			}
+			if _, ok := failure.(roleFailure); ok != c.wantFailure {
+				t.Fatalf("failure = %v, want failure %v", failure, c.wantFailure)
+			}
+			if !c.wantFailure && (resp.Err != nil) != c.wantErr {
+				t.Fatalf("resp.Err = %v, want error %v", resp.Err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunWithPolicyTimeout(t *testing.T) {
+	tc := policyTestContext(t)
+	var returned atomic.Bool
+	run := func(tc *TestContext) TestResp {
+		<-tc.Context().Done()
+		// 超时后步骤仍需时间退出，runWithPolicy 需等待其返回
+		time.Sleep(50 * time.Millisecond)
+		returned.Store(true)
+		return NewTestResp(tc.Context().Err(), nil)
+	}
+
+	var failure interface{}
+	func() {
+		defer func() { failure = recover() }()
+		runWithPolicy(tc, run, Expectation{Pass: true}, StepPolicy{Timeout: 100 * time.Millisecond})
+	}()
+	f, ok := failure.(roleFailure)
+	if !ok || !strings.Contains(f.message, "step timed out after 100ms") {
+		t.Fatalf("failure = %v", failure)
+	}
+	if !returned.Load() {
+		t.Fatal("runWithPolicy returned before the timed out step")
+	}
+	if tc.Context().Err() != nil {
+		t.Fatal("context of the test is cancelled")
+	}
+}
+
+func TestRunWithPolicyTimeoutStepNeverReturns(t *testing.T) {
+	grace := stepTimeoutGrace
+	stepTimeoutGrace = 50 * time.Millisecond
+	t.Cleanup(func() { stepTimeoutGrace = grace })
+
+	tc := policyTestContext(t)
+	block := make(chan struct{})
+	t.Cleanup(func() { close(block) })
+	run := func(tc *TestContext) TestResp {
+		<-block
+		return NewTestResp(nil, nil)
+	}
+
+	var failure interface{}
+	start := time.Now()
+	func() {
+		defer func() { failure = recover() }()
+		runWithPolicy(tc, run, Expectation{Pass: true}, StepPolicy{Timeout: 100 * time.Millisecond})
+	}()
+	if f, ok := failure.(roleFailure); !ok || !strings.Contains(f.message, "step timed out after 100ms") {
+		t.Fatalf("failure = %v", failure)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("runWithPolicy returned after %v", d)
+	}
+}
+
+func TestRunWithPolicyRemainingWaitTimeout(t *testing.T) {
+	tc := policyTestContext(t)
+	tc.Config.WaitTimeout = time.Minute
+	var waits []time.Duration
+	run := func(tc *TestContext) TestResp {
+		waits = append(waits, tc.WaitTimeout)
+		time.Sleep(100 * time.Millisecond)
+		// 结果不符合预期，用尽重试
+		return NewTestResp(errors.New("not ready"), nil)
+	}
+
+	policy := StepPolicy{Timeout: 2 * time.Second, Retries: 1, Backoff: BackoffPolicy{Policy: BackoffConstant}}
+	_, _, attempts := runWithPolicy(tc, run, Expectation{Pass: true}, policy)
+	if attempts != 2 || len(waits) != 2 {
+		t.Fatalf("attempts = %d, waits = %v", attempts, waits)
+	}
+	if waits[0] > 2*time.Second || waits[1] > waits[0]-100*time.Millisecond {
+		t.Fatalf("wait timeouts %v do not shrink with the remaining time", waits)
+	}
+	if tc.WaitTimeout != time.Minute {
+		t.Fatalf("WaitTimeout of the test changed to %v", tc.WaitTimeout)
+	}
+}
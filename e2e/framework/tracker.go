@@ -0,0 +1,445 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/multicluster"
+	mcclient "github.com/kubecube-io/kubecube/pkg/multicluster/client"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// RunIDLabel 标记 e2e 通过框架创建的对象，值为创建对象的运行标识
+const RunIDLabel = "kubecube-e2e-run-id"
+
+// LeakFile 测试结束后仍未删除的对象，写入 artifacts.dir
+const LeakFile = "leaks.json"
+
+// TrackedObject 测试中创建的对象
+type TrackedObject struct {
+	Cluster   string                  `json:"cluster"`
+	GVK       schema.GroupVersionKind `json:"gvk"`
+	Namespace string                  `json:"namespace,omitempty"`
+	Name      string                  `json:"name"`
+	// Test 创建对象时所在的测试，角色依次执行时带有 "/角色" 后缀
+	Test string `json:"test"`
+	// User 经 KubeCube proxy 创建时的用户，直连集群创建时为空
+	User string `json:"user,omitempty"`
+}
+
+func (o TrackedObject) String() string {
+	name := o.Name
+	if len(o.Namespace) > 0 {
+		name = o.Namespace + "/" + name
+	}
+	return fmt.Sprintf("%s %s in cluster %s", o.GVK.Kind, name, o.Cluster)
+}
+
+func (o TrackedObject) sameAs(other TrackedObject) bool {
+	return o.Cluster == other.Cluster && o.GVK == other.GVK && o.Namespace == other.Namespace && o.Name == other.Name
+}
+
+// ResourceTracker 记录测试中经框架创建的对象，测试结束后按创建的逆序删除，删除后仍存在的对象记为泄漏。
+// 用例串行执行，同一时间只有一个测试处于记录中
+type ResourceTracker struct {
+	runID    string
+	interval time.Duration
+	timeout  time.Duration
+
+	mu      sync.Mutex
+	test    string
+	objects []TrackedObject
+	leaks   []TrackedObject
+	// clients 删除对象使用的直连客户端，key 为集群名
+	clients map[string]ctrlclient.Client
+}
+
+// NewResourceTracker 创建资源记录器，删除后在 WaitTimeout 内等待对象消失
+func NewResourceTracker(runID string, cfg *Config) *ResourceTracker {
+	return &ResourceTracker{
+		runID:    runID,
+		interval: cfg.WaitInterval,
+		timeout:  cfg.WaitTimeout,
+		clients:  make(map[string]ctrlclient.Client),
+	}
+}
+
+// Begin 开始记录测试创建的对象
+func (t *ResourceTracker) Begin(test string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.test = test
+}
+
+// recording 是否处于测试中，测试外（如 Start 中应用 fixture）创建的对象不打标签也不记录，
+// 以便共享资源快照比较这些对象
+func (t *ResourceTracker) recording() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.test) > 0
+}
+
+// stamp 为对象添加运行标识标签
+func (t *ResourceTracker) stamp(obj ctrlclient.Object) {
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	labels[RunIDLabel] = t.runID
+	obj.SetLabels(labels)
+}
+
+// track 记录对象，不在测试中时不记录
+func (t *ResourceTracker) track(obj TrackedObject) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.test) == 0 {
+		return
+	}
+	obj.Test = t.test
+	for i := range t.objects {
+		if t.objects[i].sameAs(obj) {
+			t.objects = append(t.objects[:i], t.objects[i+1:]...)
+			break
+		}
+	}
+	t.objects = append(t.objects, obj)
+}
+
+// Cleanup 结束记录测试，按创建的逆序删除其创建的对象，返回删除后仍存在的对象
+func (t *ResourceTracker) Cleanup(test string) []TrackedObject {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	var objects, rest []TrackedObject
+	for _, o := range t.objects {
+		if o.Test == test {
+			objects = append(objects, o)
+		} else {
+			rest = append(rest, o)
+		}
+	}
+	t.objects = rest
+	if t.test == test {
+		t.test = ""
+	}
+	t.mu.Unlock()
+
+	return t.cleanup(objects)
+}
+
+// CleanupAll 删除所有测试未清理的对象，用于测试中断或用例被过滤未执行到测试结束的情况
+func (t *ResourceTracker) CleanupAll() []TrackedObject {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	objects := t.objects
+	t.objects = nil
+	t.test = ""
+	t.mu.Unlock()
+
+	return t.cleanup(objects)
+}
+
+func (t *ResourceTracker) cleanup(objects []TrackedObject) []TrackedObject {
+	if len(objects) == 0 {
+		return nil
+	}
+
+	var pending []TrackedObject
+	for i := len(objects) - 1; i >= 0; i-- {
+		o := objects[i]
+		cli, err := t.clientFor(o.Cluster)
+		if err != nil {
+			clog.Warn("fail to delete %s: %v", o, err)
+			pending = append(pending, o)
+			continue
+		}
+		err = cli.Delete(context.Background(), o.object(), ctrlclient.PropagationPolicy("Background"))
+		if err == nil {
+			clog.Info("deleted leftover %s created by test %s", o, o.Test)
+		} else if !isGone(err) {
+			clog.Warn("fail to delete %s: %v", o, err)
+		}
+		pending = append(pending, o)
+	}
+
+	// 等待对象删除完成，finalizer 未移除的对象在超时后记为泄漏
+	_ = wait.PollImmediate(t.interval, t.timeout, func() (bool, error) {
+		var remained []TrackedObject
+		for _, o := range pending {
+			if t.exists(o) {
+				remained = append(remained, o)
+			}
+		}
+		pending = remained
+		return len(pending) == 0, nil
+	})
+
+	if len(pending) > 0 {
+		t.mu.Lock()
+		t.leaks = append(t.leaks, pending...)
+		t.mu.Unlock()
+		for _, o := range pending {
+			clog.Warn("leaked %s created by test %s", o, o.Test)
+		}
+	}
+	return pending
+}
+
+func (t *ResourceTracker) exists(o TrackedObject) bool {
+	cli, err := t.clientFor(o.Cluster)
+	if err != nil {
+		return true
+	}
+	err = cli.Get(context.Background(), ctrlclient.ObjectKey{Namespace: o.Namespace, Name: o.Name}, o.object())
+	return !isGone(err)
+}
+
+// isGone 对象不存在，或对象的 CRD 已经删除
+func isGone(err error) bool {
+	return apierrors.IsNotFound(err) || meta.IsNoMatchError(err)
+}
+
+func (t *ResourceTracker) clientFor(cluster string) (ctrlclient.Client, error) {
+	t.mu.Lock()
+	cli, ok := t.clients[cluster]
+	t.mu.Unlock()
+	if ok {
+		return cli, nil
+	}
+	c, err := multicluster.Interface().GetClient(cluster)
+	if err != nil {
+		return nil, fmt.Errorf("get client of cluster %s failed: %v", cluster, err)
+	}
+	return c.Direct(), nil
+}
+
+func (o TrackedObject) object() *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(o.GVK)
+	u.SetNamespace(o.Namespace)
+	u.SetName(o.Name)
+	return u
+}
+
+// Leaks 返回所有测试泄漏的对象
+func (t *ResourceTracker) Leaks() []TrackedObject {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]TrackedObject(nil), t.leaks...)
+}
+
+// AddLeaks 合并其他进程泄漏的对象
+func (t *ResourceTracker) AddLeaks(leaks []TrackedObject) {
+	if t == nil || len(leaks) == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.leaks = append(t.leaks, leaks...)
+}
+
+// WriteLeaks 存在泄漏的对象时写入 dir 下的 leaks.json
+func (t *ResourceTracker) WriteLeaks(dir string) error {
+	leaks := t.Leaks()
+	if len(leaks) == 0 || len(dir) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(leaks, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, LeakFile), data, 0o644)
+}
+
+// Wrap 返回创建对象时打标签并记录的集群客户端，删除对象时使用未包装的直连客户端
+func (t *ResourceTracker) Wrap(cluster string, cli mcclient.Client) mcclient.Client {
+	t.mu.Lock()
+	t.clients[cluster] = cli.Direct()
+	t.mu.Unlock()
+	return &trackedClusterClient{
+		Client: cli,
+		direct: &trackingClient{Client: cli.Direct(), tracker: t, cluster: cluster},
+	}
+}
+
+// trackedClusterClient Direct 返回记录创建对象的客户端，其余方法与原客户端一致
+type trackedClusterClient struct {
+	mcclient.Client
+	direct ctrlclient.Client
+}
+
+func (c *trackedClusterClient) Direct() ctrlclient.Client {
+	return c.direct
+}
+
+// trackingClient 创建对象时打上运行标识标签并记录到 tracker
+type trackingClient struct {
+	ctrlclient.Client
+	tracker *ResourceTracker
+	cluster string
+}
+
+func (c *trackingClient) Create(ctx context.Context, obj ctrlclient.Object, opts ...ctrlclient.CreateOption) error {
+	if !c.tracker.recording() {
+		return c.Client.Create(ctx, obj, opts...)
+	}
+	c.tracker.stamp(obj)
+	if err := c.Client.Create(ctx, obj, opts...); err != nil {
+		return err
+	}
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err != nil {
+		clog.Warn("fail to track %s/%s: %v", obj.GetNamespace(), obj.GetName(), err)
+		return nil
+	}
+	c.tracker.track(TrackedObject{Cluster: c.cluster, GVK: gvk, Namespace: obj.GetNamespace(), Name: obj.GetName()})
+	return nil
+}
+
+// trackTest 返回为测试开始记录与清理对象的 BeforeEach 与 AfterEach，
+// 测试生成的 total 个用例都执行结束后清理对象
+func trackTest(tc *TestContext, test string, total int) (before, after func()) {
+	finished := 0
+	before = func() {
+		tc.Tracker.Begin(test)
+	}
+	after = func() {
+		finished++
+		if finished == total {
+			tc.Tracker.Cleanup(test)
+		}
+	}
+	return before, after
+}
+
+// proxyCluster 从 KubeCube proxy 的请求路径中解析集群名
+func proxyCluster(path string) (string, bool) {
+	prefix := ProxyUrlPrefix + "/clusters/"
+	i := strings.Index(path, prefix)
+	if i < 0 {
+		return "", false
+	}
+	cluster, _, _ := strings.Cut(path[i+len(prefix):], "/")
+	return cluster, len(cluster) > 0
+}
+
+func isProxyCreate(req *http.Request) bool {
+	if req.Method != http.MethodPost || req.URL.Query().Has("dryRun") {
+		return false
+	}
+	_, ok := proxyCluster(req.URL.Path)
+	return ok
+}
+
+// stampRequest 为经 KubeCube proxy 创建对象的 json 请求体添加运行标识标签，其他请求原样返回
+func (t *ResourceTracker) stampRequest(req *http.Request) *http.Request {
+	if t == nil || req.Body == nil || req.Body == http.NoBody || !isProxyCreate(req) || !t.recording() {
+		return req
+	}
+	body, err := readBody(req)
+	if err != nil {
+		return req
+	}
+	stamped := req.Clone(req.Context())
+	setBody(stamped, body)
+
+	u := &unstructured.Unstructured{}
+	if err = u.UnmarshalJSON(body); err != nil {
+		return stamped
+	}
+	t.stamp(u)
+	data, err := u.MarshalJSON()
+	if err != nil {
+		return stamped
+	}
+	setBody(stamped, data)
+	return stamped
+}
+
+// trackResponse 记录经 KubeCube proxy 创建成功的对象
+func (t *ResourceTracker) trackResponse(req *http.Request, resp *http.Response, user string) {
+	if t == nil || !IsSuccess(resp.StatusCode) || !isProxyCreate(req) {
+		return
+	}
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	u := &unstructured.Unstructured{}
+	if err = u.UnmarshalJSON(body); err != nil || len(u.GetName()) == 0 {
+		return
+	}
+	cluster, _ := proxyCluster(req.URL.Path)
+	t.track(TrackedObject{Cluster: cluster, GVK: u.GroupVersionKind(), Namespace: u.GetNamespace(), Name: u.GetName(), User: user})
+}
+
+func readBody(req *http.Request) ([]byte, error) {
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		return io.ReadAll(body)
+	}
+	data, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	// 请求体已读取，无论是否出错都需要重新设置
+	setBody(req, data)
+	return data, err
+}
+
+func setBody(req *http.Request, data []byte) {
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	req.ContentLength = int64(len(data))
+}
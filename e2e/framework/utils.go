@@ -87,7 +87,7 @@ func PostFile(filename string, fieldName string, target_url string, header map[s
 }
 
 // CreateNamespace Create namespace
-func CreateNamespace(baseName string, cli client.Client) (*v1.Namespace, error) {
+func (tc *TestContext) CreateNamespace(baseName string, cli client.Client) (*v1.Namespace, error) {
 	labels := map[string]string{
 		"e2e-run":       string(uuid.NewUUID()),
 		"e2e-framework": baseName,
@@ -99,7 +99,7 @@ func CreateNamespace(baseName string, cli client.Client) (*v1.Namespace, error)
 			Labels: labels,
 		},
 	}
-	if err := wait.PollImmediate(WaitInterval, WaitTimeout, func() (bool, error) {
+	if err := wait.PollImmediate(tc.WaitInterval, tc.WaitTimeout, func() (bool, error) {
 		err := cli.Direct().Create(context.TODO(), namespaceObj)
 		if err != nil {
 			if apierrors.IsAlreadyExists(err) {
@@ -120,13 +120,13 @@ func CreateNamespace(baseName string, cli client.Client) (*v1.Namespace, error)
 }
 
 // DeleteNamespace Delete Namespace
-func DeleteNamespace(ns *v1.Namespace, cli client.Client) error {
+func (tc *TestContext) DeleteNamespace(ns *v1.Namespace, cli client.Client) error {
 	err := cli.Direct().Delete(context.TODO(), ns)
 	if err != nil && !apierrors.IsNotFound(err) {
 		clog.Error("error deleting namespace %s: %v", ns.Name, err)
 		return err
 	}
-	if err = wait.Poll(WaitInterval, WaitTimeout,
+	if err = wait.Poll(tc.WaitInterval, tc.WaitTimeout,
 		func() (bool, error) {
 			var nsTemp v1.Namespace
 			err := cli.Direct().Get(context.TODO(), types.NamespacedName{Name: ns.Name}, &nsTemp)
@@ -143,21 +143,6 @@ func DeleteNamespace(ns *v1.Namespace, cli client.Client) error {
 	return nil
 }
 
-func GetUser(user string) string {
-	switch user {
-	case UserAdmin:
-		return Admin
-	case UserProjectAdmin:
-		return ProjectAdmin
-	case UserTenantAdmin:
-		return TenantAdmin
-	case UserNormal:
-		return User
-	default:
-		return Admin
-	}
-}
-
 func contains(list []string, target string) bool {
 	for _, s := range list {
 		if s == target {
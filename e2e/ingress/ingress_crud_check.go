@@ -33,19 +33,18 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 
+	"github.com/kubecube-io/kubecube-e2e/e2e/fixtures"
 	"github.com/kubecube-io/kubecube-e2e/e2e/framework"
 )
 
-func createDeployAndSvc1(user string) framework.TestResp {
-	initParam()
-	deploy1NameWithUser = framework.NameWithUser(deploy1Name, user)
-	svc1NameWithUser = framework.NameWithUser(svc1Name, user)
-	ingress1NameWithUser = framework.NameWithUser(ingress1Name, user)
+func createDeployAndSvc1(tc *framework.TestContext) framework.TestResp {
+	deploy1NameWithUser := tc.NameWithUser(deploy1Name)
+	svc1NameWithUser := tc.NameWithUser(svc1Name)
 	replicas := int32(1)
-	deploy1 = &appsv1.Deployment{
+	deploy1 := &appsv1.Deployment{
 		ObjectMeta: v1.ObjectMeta{
 			Name:      deploy1NameWithUser,
-			Namespace: framework.NamespaceName,
+			Namespace: tc.NamespaceName,
 		},
 		Spec: appsv1.DeploymentSpec{
 			Replicas: &replicas,
@@ -60,21 +59,21 @@ func createDeployAndSvc1(user string) framework.TestResp {
 					Containers: []corev1.Container{
 						{
 							Name:  "nginx",
-							Image: framework.TestImage,
+							Image: tc.TestImage,
 						},
 					},
-					ImagePullSecrets: []corev1.LocalObjectReference{{Name: framework.ImagePullSecret}},
+					ImagePullSecrets: []corev1.LocalObjectReference{{Name: tc.ImagePullSecret}},
 				},
 			},
 		},
 	}
-	err := framework.TargetClusterClient.Direct().Create(ctx, deploy1)
-	framework.ExpectNoError(err)
+	err := tc.TargetClusterClient.Direct().Create(ctx, deploy1)
+	tc.ExpectNoError(err)
 
-	svc1 = &corev1.Service{
+	svc1 := &corev1.Service{
 		ObjectMeta: v1.ObjectMeta{
 			Name:      svc1NameWithUser,
-			Namespace: framework.NamespaceName,
+			Namespace: tc.NamespaceName,
 		},
 		Spec: corev1.ServiceSpec{
 			Selector: map[string]string{"kubecube.io/app": deploy1NameWithUser},
@@ -87,56 +86,29 @@ func createDeployAndSvc1(user string) framework.TestResp {
 			},
 		},
 	}
-	err = framework.TargetClusterClient.Direct().Create(ctx, svc1)
-	framework.ExpectNoError(err)
+	err = tc.TargetClusterClient.Direct().Create(ctx, svc1)
+	tc.ExpectNoError(err)
 	return framework.SucceedResp
 }
 
-func deleteDeployAndSvc1(user string) framework.TestResp {
-	err := framework.TargetClusterClient.Direct().Delete(ctx, deploy1)
-	framework.ExpectNoError(err)
+func deleteDeployAndSvc1(tc *framework.TestContext) framework.TestResp {
+	deploy1 := &appsv1.Deployment{ObjectMeta: v1.ObjectMeta{Name: tc.NameWithUser(deploy1Name), Namespace: tc.NamespaceName}}
+	svc1 := &corev1.Service{ObjectMeta: v1.ObjectMeta{Name: tc.NameWithUser(svc1Name), Namespace: tc.NamespaceName}}
+	err := tc.TargetClusterClient.Direct().Delete(ctx, deploy1)
+	tc.ExpectNoError(err)
 
-	err = framework.TargetClusterClient.Direct().Delete(ctx, svc1)
-	framework.ExpectNoError(err)
+	err = tc.TargetClusterClient.Direct().Delete(ctx, svc1)
+	tc.ExpectNoError(err)
 	return framework.SucceedResp
 }
 
-func createIngress(user string) framework.TestResp {
-	url := "/api/v1/cube/proxy/clusters/" + framework.TargetClusterName + "/apis/networking.k8s.io/v1/namespaces/" + framework.NamespaceName + "/ingresses"
-	postJson := fmt.Sprintf(`{
-	"apiVersion": "networking.k8s.io/v1",
-	"kind": "Ingress",
-	"metadata": {
-		"name": "%s",
-		"annotations": {
-			"nginx.ingress.kubernetes.io/load-balance": "round_robin",
-			"kubernetes.io/ingress.class": "istio"
-		},
-		"labels": {}
-	},
-	"spec": {
-		"rules": [{
-			"host": "poctest",
-			"http": {
-				"paths": [{
-					"path": "/test",
-					"pathType": "ImplementationSpecific",
-					"backend": {
-						"service": {
-							"name": "%s",
-							"port": {
-								"number": 8080
-							}
-						}
-					}
-				}]
-			}
-		}],
-		"tls": []
-	}
-}`, ingress1NameWithUser, svc1NameWithUser)
-	resp, err := httpHelper.RequestByUser(http.MethodPost, framework.KubecubeHost+url, postJson, user, nil)
-	framework.ExpectNoError(err)
+func createIngress(tc *framework.TestContext) framework.TestResp {
+	svc1NameWithUser := tc.NameWithUser(svc1Name)
+	ingress1NameWithUser := tc.NameWithUser(ingress1Name)
+	url := "/api/v1/cube/proxy/clusters/" + tc.TargetClusterName + "/apis/networking.k8s.io/v1/namespaces/" + tc.NamespaceName + "/ingresses"
+	postJson := fixtures.MustRender(tc, "ingress.yaml", fixtures.For(tc, ingress1NameWithUser).With("service", svc1NameWithUser))
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodPost, tc.KubecubeHost+url, postJson, tc.User, nil)
+	tc.ExpectNoError(err)
 	defer resp.Body.Close()
 
 	if !framework.IsSuccess(resp.StatusCode) {
@@ -145,14 +117,14 @@ func createIngress(user string) framework.TestResp {
 	}
 
 	// check return success
-	framework.ExpectEqual(resp.StatusCode, http.StatusCreated)
+	tc.ExpectEqual(resp.StatusCode, http.StatusCreated)
 	return framework.SucceedResp
 }
 
-func listIngress(user string) framework.TestResp {
-	url := "/api/v1/cube/proxy/clusters/" + framework.TargetClusterName + "/apis/networking.k8s.io/v1/namespaces/" + framework.NamespaceName + "/ingresses?pageNum=1&pageSize=10"
-	resp, err := httpHelper.RequestByUser(http.MethodGet, framework.KubecubeHost+url, "", user, nil)
-	framework.ExpectNoError(err)
+func listIngress(tc *framework.TestContext) framework.TestResp {
+	url := "/api/v1/cube/proxy/clusters/" + tc.TargetClusterName + "/apis/networking.k8s.io/v1/namespaces/" + tc.NamespaceName + "/ingresses?pageNum=1&pageSize=10"
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodGet, tc.KubecubeHost+url, "", tc.User, nil)
+	tc.ExpectNoError(err)
 	defer resp.Body.Close()
 
 	if !framework.IsSuccess(resp.StatusCode) {
@@ -161,60 +133,28 @@ func listIngress(user string) framework.TestResp {
 	}
 
 	// check return success
-	framework.ExpectEqual(resp.StatusCode, http.StatusOK)
+	tc.ExpectEqual(resp.StatusCode, http.StatusOK)
 	body, err := io.ReadAll(resp.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	var result map[string]interface{}
 	err = json.Unmarshal(body, &result)
-	framework.ExpectNoError(err)
-	framework.ExpectNotEqual(int(result["total"].(float64)), 0)
+	tc.ExpectNoError(err)
+	tc.ExpectNotEqual(int(result["total"].(float64)), 0)
 	return framework.SucceedResp
 }
 
-func updateIngress(user string) framework.TestResp {
+func updateIngress(tc *framework.TestContext) framework.TestResp {
+	svc1NameWithUser := tc.NameWithUser(svc1Name)
+	ingress1NameWithUser := tc.NameWithUser(ingress1Name)
 	ingress := &networkingv1.Ingress{}
-	err := framework.TargetConvertClient.Get(ctx, types.NamespacedName{Name: ingress1NameWithUser, Namespace: framework.NamespaceName}, ingress)
-	framework.ExpectNoError(err)
+	err := tc.TargetConvertClient.Get(ctx, types.NamespacedName{Name: ingress1NameWithUser, Namespace: tc.NamespaceName}, ingress)
+	tc.ExpectNoError(err)
 
-	url := "/api/v1/cube/proxy/clusters/" + framework.TargetClusterName + "/apis/networking.k8s.io/v1/namespaces/" + framework.NamespaceName + "/ingresses/" + ingress1NameWithUser
-	postJson := fmt.Sprintf(`{
-	"apiVersion": "networking.k8s.io/v1",
-	"kind": "Ingress",
-	"metadata": {
-		"namespace": "%s",
-		"pureLabels": {},
-		"resourceVersion": "%s",
-		"uid": "%s",
-		"name": "%s",
-		"annotations": {
-			"kubernetes.io/ingress.class": "istio",
-			"nginx.ingress.kubernetes.io/load-balance": "round_robin"
-		},
-		"labels": {}
-	},
-	"spec": {
-		"rules": [{
-			"host": "poctest",
-			"http": {
-				"paths": [{
-					"path": "/test2",
-					"pathType": "ImplementationSpecific",
-					"backend": {
-						"service": {
-							"name": "%s",
-							"port": {
-								"number": 8080
-							}
-						}
-					}
-				}]
-			}
-		}],
-		"tls": []
-	}
-}`, framework.NamespaceName, ingress.ResourceVersion, ingress.UID, ingress1NameWithUser, svc1NameWithUser)
-	resp, err := httpHelper.RequestByUser(http.MethodPut, framework.KubecubeHost+url, postJson, user, nil)
-	framework.ExpectNoError(err)
+	url := "/api/v1/cube/proxy/clusters/" + tc.TargetClusterName + "/apis/networking.k8s.io/v1/namespaces/" + tc.NamespaceName + "/ingresses/" + ingress1NameWithUser
+	postJson := fixtures.MustRender(tc, "ingress-update.yaml", fixtures.For(tc, ingress1NameWithUser).
+		With("resourceVersion", ingress.ResourceVersion).With("uid", string(ingress.UID)).With("service", svc1NameWithUser))
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodPut, tc.KubecubeHost+url, postJson, tc.User, nil)
+	tc.ExpectNoError(err)
 	defer resp.Body.Close()
 
 	if !framework.IsSuccess(resp.StatusCode) {
@@ -223,14 +163,15 @@ func updateIngress(user string) framework.TestResp {
 	}
 
 	// check return success
-	framework.ExpectEqual(resp.StatusCode, http.StatusOK)
+	tc.ExpectEqual(resp.StatusCode, http.StatusOK)
 	return framework.SucceedResp
 }
 
-func checkIngress(user string) framework.TestResp {
-	url := "/api/v1/cube/proxy/clusters/" + framework.TargetClusterName + "/apis/networking.k8s.io/v1/namespaces/" + framework.NamespaceName + "/ingresses/" + ingress1NameWithUser
-	resp, err := httpHelper.RequestByUser(http.MethodGet, framework.KubecubeHost+url, "", user, nil)
-	framework.ExpectNoError(err)
+func checkIngress(tc *framework.TestContext) framework.TestResp {
+	ingress1NameWithUser := tc.NameWithUser(ingress1Name)
+	url := "/api/v1/cube/proxy/clusters/" + tc.TargetClusterName + "/apis/networking.k8s.io/v1/namespaces/" + tc.NamespaceName + "/ingresses/" + ingress1NameWithUser
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodGet, tc.KubecubeHost+url, "", tc.User, nil)
+	tc.ExpectNoError(err)
 	defer resp.Body.Close()
 
 	if !framework.IsSuccess(resp.StatusCode) {
@@ -239,21 +180,22 @@ func checkIngress(user string) framework.TestResp {
 	}
 
 	// check return success
-	framework.ExpectEqual(resp.StatusCode, http.StatusOK)
+	tc.ExpectEqual(resp.StatusCode, http.StatusOK)
 	body, err := io.ReadAll(resp.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	ingress := networkingv1.Ingress{}
 	err = json.Unmarshal(body, &ingress)
-	framework.ExpectNoError(err)
-	framework.ExpectEqual(ingress.Name, ingress1NameWithUser)
-	framework.ExpectEqual(ingress.Spec.Rules[0].HTTP.Paths[0].Path, "/test2")
+	tc.ExpectNoError(err)
+	tc.ExpectEqual(ingress.Name, ingress1NameWithUser)
+	tc.ExpectEqual(ingress.Spec.Rules[0].HTTP.Paths[0].Path, "/test2")
 	return framework.SucceedResp
 }
 
-func deleteIngress(user string) framework.TestResp {
-	url := "/api/v1/cube/proxy/clusters/" + framework.TargetClusterName + "/apis/networking.k8s.io/v1/namespaces/" + framework.NamespaceName + "/ingresses/" + ingress1NameWithUser
-	resp, err := httpHelper.RequestByUser(http.MethodDelete, framework.KubecubeHost+url, "", user, nil)
-	framework.ExpectNoError(err)
+func deleteIngress(tc *framework.TestContext) framework.TestResp {
+	ingress1NameWithUser := tc.NameWithUser(ingress1Name)
+	url := "/api/v1/cube/proxy/clusters/" + tc.TargetClusterName + "/apis/networking.k8s.io/v1/namespaces/" + tc.NamespaceName + "/ingresses/" + ingress1NameWithUser
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodDelete, tc.KubecubeHost+url, "", tc.User, nil)
+	tc.ExpectNoError(err)
 	defer resp.Body.Close()
 
 	if !framework.IsSuccess(resp.StatusCode) {
@@ -262,10 +204,10 @@ func deleteIngress(user string) framework.TestResp {
 	}
 
 	// check return success
-	framework.ExpectEqual(resp.StatusCode, http.StatusOK)
+	tc.ExpectEqual(resp.StatusCode, http.StatusOK)
 	ingress := networkingv1.Ingress{}
-	err = framework.TargetConvertClient.Get(ctx, types.NamespacedName{Name: ingress1NameWithUser, Namespace: framework.NamespaceName}, &ingress)
-	framework.ExpectEqual(true, kerrors.IsNotFound(err))
+	err = tc.TargetConvertClient.Get(ctx, types.NamespacedName{Name: ingress1NameWithUser, Namespace: tc.NamespaceName}, &ingress)
+	tc.ExpectEqual(true, kerrors.IsNotFound(err))
 	return framework.SucceedResp
 }
 
@@ -32,16 +32,15 @@ import (
 	"github.com/kubecube-io/kubecube-e2e/e2e/framework"
 )
 
-func createDeployAndSvcAndIngress(user string) framework.TestResp {
-	initParam()
-	deploy1NameWithUser = framework.NameWithUser(deploy1Name, user)
-	svc1NameWithUser = framework.NameWithUser(svc1Name, user)
-	ingress1NameWithUser = framework.NameWithUser(ingress1Name, user)
+func createDeployAndSvcAndIngress(tc *framework.TestContext) framework.TestResp {
+	deploy1NameWithUser := tc.NameWithUser(deploy1Name)
+	svc1NameWithUser := tc.NameWithUser(svc1Name)
+	ingress1NameWithUser := tc.NameWithUser(ingress1Name)
 	replicas := int32(1)
-	deploy1 = &appsv1.Deployment{
+	deploy1 := &appsv1.Deployment{
 		ObjectMeta: v1.ObjectMeta{
 			Name:      deploy1NameWithUser,
-			Namespace: framework.NamespaceName,
+			Namespace: tc.NamespaceName,
 		},
 		Spec: appsv1.DeploymentSpec{
 			Selector: &v1.LabelSelector{
@@ -56,21 +55,21 @@ func createDeployAndSvcAndIngress(user string) framework.TestResp {
 					Containers: []corev1.Container{
 						{
 							Name:  "nginx",
-							Image: framework.TestImage,
+							Image: tc.TestImage,
 						},
 					},
-					ImagePullSecrets: []corev1.LocalObjectReference{{Name: framework.ImagePullSecret}},
+					ImagePullSecrets: []corev1.LocalObjectReference{{Name: tc.ImagePullSecret}},
 				},
 			},
 		},
 	}
-	err := framework.TargetClusterClient.Direct().Create(ctx, deploy1)
-	framework.ExpectNoError(err)
+	err := tc.TargetClusterClient.Direct().Create(ctx, deploy1)
+	tc.ExpectNoError(err)
 
-	svc1 = &corev1.Service{
+	svc1 := &corev1.Service{
 		ObjectMeta: v1.ObjectMeta{
 			Name:      svc1NameWithUser,
-			Namespace: framework.NamespaceName,
+			Namespace: tc.NamespaceName,
 		},
 		Spec: corev1.ServiceSpec{
 			Selector: map[string]string{"kubecube.io/app": deploy1NameWithUser},
@@ -79,15 +78,15 @@ func createDeployAndSvcAndIngress(user string) framework.TestResp {
 			},
 		},
 	}
-	err = framework.TargetClusterClient.Direct().Create(ctx, svc1)
-	framework.ExpectNoError(err)
+	err = tc.TargetClusterClient.Direct().Create(ctx, svc1)
+	tc.ExpectNoError(err)
 
 	pathType := networkingv1.PathTypeImplementationSpecific
 
-	ingress1 = &networkingv1.Ingress{
+	ingress1 := &networkingv1.Ingress{
 		ObjectMeta: v1.ObjectMeta{
 			Name:      ingress1NameWithUser,
-			Namespace: framework.NamespaceName,
+			Namespace: tc.NamespaceName,
 		},
 		Spec: networkingv1.IngressSpec{
 			Rules: []networkingv1.IngressRule{
@@ -111,38 +110,42 @@ func createDeployAndSvcAndIngress(user string) framework.TestResp {
 			},
 		},
 	}
-	err = framework.TargetConvertClient.Create(ctx, ingress1)
-	framework.ExpectNoError(err)
+	err = tc.TargetConvertClient.Create(ctx, ingress1)
+	tc.ExpectNoError(err)
 	return framework.SucceedResp
 }
 
-func deleteDeployAndSvcAndIngress(user string) framework.TestResp {
-	err := framework.TargetClusterClient.Direct().Delete(ctx, deploy1)
-	framework.ExpectNoError(err)
-	err = framework.TargetClusterClient.Direct().Delete(ctx, svc1)
-	framework.ExpectNoError(err)
-	err = framework.TargetConvertClient.Delete(ctx, ingress1)
-	framework.ExpectNoError(err)
+func deleteDeployAndSvcAndIngress(tc *framework.TestContext) framework.TestResp {
+	deploy1 := &appsv1.Deployment{ObjectMeta: v1.ObjectMeta{Name: tc.NameWithUser(deploy1Name), Namespace: tc.NamespaceName}}
+	svc1 := &corev1.Service{ObjectMeta: v1.ObjectMeta{Name: tc.NameWithUser(svc1Name), Namespace: tc.NamespaceName}}
+	ingress1 := &networkingv1.Ingress{ObjectMeta: v1.ObjectMeta{Name: tc.NameWithUser(ingress1Name), Namespace: tc.NamespaceName}}
+	err := tc.TargetClusterClient.Direct().Delete(ctx, deploy1)
+	tc.ExpectNoError(err)
+	err = tc.TargetClusterClient.Direct().Delete(ctx, svc1)
+	tc.ExpectNoError(err)
+	err = tc.TargetConvertClient.Delete(ctx, ingress1)
+	tc.ExpectNoError(err)
 	return framework.SucceedResp
 }
 
-func checkEvents(user string) framework.TestResp {
-	url := "/api/v1/cube/proxy/clusters/" + framework.TargetClusterName + "/api/v1/namespaces/" + framework.NamespaceName + "/events?fieldSelector=involvedObject.kind=Ingress,involvedObject.name=" + ingress1NameWithUser
-	resp, err := httpHelper.RequestByUser(http.MethodGet, framework.KubecubeHost+url, "", user, nil)
-	framework.ExpectNoError(err)
+func checkEvents(tc *framework.TestContext) framework.TestResp {
+	ingress1NameWithUser := tc.NameWithUser(ingress1Name)
+	url := "/api/v1/cube/proxy/clusters/" + tc.TargetClusterName + "/api/v1/namespaces/" + tc.NamespaceName + "/events?fieldSelector=involvedObject.kind=Ingress,involvedObject.name=" + ingress1NameWithUser
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodGet, tc.KubecubeHost+url, "", tc.User, nil)
+	tc.ExpectNoError(err)
 	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	if !framework.IsSuccess(resp.StatusCode) {
 		clog.Warn("res code %d", resp.StatusCode)
 		return framework.NewTestResp(errors.New("fail to list ingress events"), resp.StatusCode)
 	}
 
-	framework.ExpectEqual(resp.StatusCode, http.StatusOK)
+	tc.ExpectEqual(resp.StatusCode, http.StatusOK)
 
 	var eventList corev1.EventList
 	err = json.Unmarshal(body, &eventList)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	return framework.SucceedResp
 }
 
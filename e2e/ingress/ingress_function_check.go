@@ -32,22 +32,21 @@ import (
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/wait"
 
+	"github.com/kubecube-io/kubecube-e2e/e2e/fixtures"
 	"github.com/kubecube-io/kubecube-e2e/e2e/framework"
 )
 
-func createDeployAndSvc2(user string) framework.TestResp {
-	initParam()
+func createDeployAndSvc2(tc *framework.TestContext) framework.TestResp {
+	deploy1NameWithUser := tc.NameWithUser(deploy1Name)
+	svc1NameWithUser := tc.NameWithUser(svc1Name)
 	replicas := int32(2)
-	deploy1NameWithUser = framework.NameWithUser(deploy1Name, user)
-	svc1NameWithUser = framework.NameWithUser(svc1Name, user)
-	ingress2NameWithUser = framework.NameWithUser(ingress2Name, user)
 
 	cpu := resource.MustParse("100m")
 	memory := resource.MustParse("100Mi")
-	deploy1 = &appsv1.Deployment{
+	deploy1 := &appsv1.Deployment{
 		ObjectMeta: v1.ObjectMeta{
 			Name:      deploy1NameWithUser,
-			Namespace: framework.NamespaceName,
+			Namespace: tc.NamespaceName,
 		},
 		Spec: appsv1.DeploymentSpec{
 			Selector: &v1.LabelSelector{
@@ -62,7 +61,7 @@ func createDeployAndSvc2(user string) framework.TestResp {
 					Containers: []corev1.Container{
 						{
 							Name:  "nginx",
-							Image: framework.TestImage,
+							Image: tc.TestImage,
 							Resources: corev1.ResourceRequirements{
 								Requests: corev1.ResourceList{
 									corev1.ResourceCPU:    cpu,
@@ -75,18 +74,18 @@ func createDeployAndSvc2(user string) framework.TestResp {
 							},
 						},
 					},
-					ImagePullSecrets: []corev1.LocalObjectReference{{Name: framework.ImagePullSecret}},
+					ImagePullSecrets: []corev1.LocalObjectReference{{Name: tc.ImagePullSecret}},
 				},
 			},
 		},
 	}
-	err := framework.TargetClusterClient.Direct().Create(ctx, deploy1)
-	framework.ExpectNoError(err)
+	err := tc.TargetClusterClient.Direct().Create(ctx, deploy1)
+	tc.ExpectNoError(err)
 
-	svc1 = &corev1.Service{
+	svc1 := &corev1.Service{
 		ObjectMeta: v1.ObjectMeta{
 			Name:      svc1NameWithUser,
-			Namespace: framework.NamespaceName,
+			Namespace: tc.NamespaceName,
 		},
 		Spec: corev1.ServiceSpec{
 			Selector: map[string]string{"kubecube.io/app": deploy1NameWithUser},
@@ -99,24 +98,27 @@ func createDeployAndSvc2(user string) framework.TestResp {
 			},
 		},
 	}
-	err = framework.TargetClusterClient.Direct().Create(ctx, svc1)
-	framework.ExpectNoError(err)
+	err = tc.TargetClusterClient.Direct().Create(ctx, svc1)
+	tc.ExpectNoError(err)
 	return framework.SucceedResp
 }
 
-func deleteDeployAndSvc2(user string) framework.TestResp {
-	framework.ExpectNoError(framework.TargetClusterClient.Direct().Delete(ctx, deploy1))
-	framework.ExpectNoError(framework.TargetClusterClient.Direct().Delete(ctx, svc1))
+func deleteDeployAndSvc2(tc *framework.TestContext) framework.TestResp {
+	deploy1 := &appsv1.Deployment{ObjectMeta: v1.ObjectMeta{Name: tc.NameWithUser(deploy1Name), Namespace: tc.NamespaceName}}
+	svc1 := &corev1.Service{ObjectMeta: v1.ObjectMeta{Name: tc.NameWithUser(svc1Name), Namespace: tc.NamespaceName}}
+	tc.ExpectNoError(tc.TargetClusterClient.Direct().Delete(ctx, deploy1))
+	tc.ExpectNoError(tc.TargetClusterClient.Direct().Delete(ctx, svc1))
 
 	return framework.SucceedResp
 }
 
-func createIngress2(user string) framework.TestResp {
-	url := "/api/v1/cube/proxy/clusters/" + framework.TargetClusterName + "/apis/networking.k8s.io/v1/namespaces/" + framework.NamespaceName + "/ingresses"
-	postJson := fmt.Sprintf("{\"apiVersion\":\"networking.k8s.io/v1\",\"kind\":\"Ingress\",\"metadata\":{\"name\":\"%s\",\"annotations\":{\"nginx.ingress.kubernetes.io/load-balance\":\"round_robin\"},\"labels\":{}},\"spec\":{\"rules\":[{\"host\":\"%s\",\"http\":{\"paths\":[{\"pathType\":\"ImplementationSpecific\",\"path\":\"/%s\",\"backend\":{\"service\":{\"name\":\"%s\",\"port\":{\"number\":80}}}}]}}],\"tls\":[]}}",
-		ingress2NameWithUser, ingressAddr, user, svc1NameWithUser)
-	resp, err := httpHelper.RequestByUser(http.MethodPost, framework.KubecubeHost+url, postJson, user, nil)
-	framework.ExpectNoError(err)
+func createIngress2(tc *framework.TestContext) framework.TestResp {
+	svc1NameWithUser := tc.NameWithUser(svc1Name)
+	ingress2NameWithUser := tc.NameWithUser(ingress2Name)
+	url := "/api/v1/cube/proxy/clusters/" + tc.TargetClusterName + "/apis/networking.k8s.io/v1/namespaces/" + tc.NamespaceName + "/ingresses"
+	postJson := fixtures.MustRender(tc, "ingress-balance.yaml", fixtures.For(tc, ingress2NameWithUser).With("host", ingressAddr).With("service", svc1NameWithUser))
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodPost, tc.KubecubeHost+url, postJson, tc.User, nil)
+	tc.ExpectNoError(err)
 	defer resp.Body.Close()
 
 	if !framework.IsSuccess(resp.StatusCode) {
@@ -124,31 +126,31 @@ func createIngress2(user string) framework.TestResp {
 		return framework.NewTestResp(fmt.Errorf("fail to create ingress %s", ingress2NameWithUser), resp.StatusCode)
 	}
 
-	framework.ExpectEqual(resp.StatusCode, http.StatusCreated)
-	ingress2 = &networkingv1.Ingress{}
-	err = wait.Poll(waitInterval, waitTimeout,
+	tc.ExpectEqual(resp.StatusCode, http.StatusCreated)
+	ingress2 := &networkingv1.Ingress{}
+	err = wait.Poll(tc.WaitInterval, tc.WaitTimeout,
 		func() (bool, error) {
-			err = framework.TargetConvertClient.Get(ctx, types.NamespacedName{Name: ingress2NameWithUser, Namespace: framework.NamespaceName}, ingress2)
-			framework.ExpectNoError(err)
+			err = tc.TargetConvertClient.Get(ctx, types.NamespacedName{Name: ingress2NameWithUser, Namespace: tc.NamespaceName}, ingress2)
+			tc.ExpectNoError(err)
 			if ingress2.Name == ingress2NameWithUser {
 				return true, nil
 			} else {
 				return false, nil
 			}
 		})
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	return framework.SucceedResp
 }
 
-func accessIngress(user string) framework.TestResp {
-	url := fmt.Sprintf("http://%s/%s", ingressAddr, user)
-	err := wait.Poll(waitInterval, waitTimeout,
+func accessIngress(tc *framework.TestContext) framework.TestResp {
+	url := fmt.Sprintf("http://%s/%s", ingressAddr, tc.User)
+	err := wait.Poll(tc.WaitInterval, tc.WaitTimeout,
 		func() (bool, error) {
-			resp, err := httpHelper.Get(url, nil)
+			resp, err := tc.HttpHelper.Get(url, nil)
 			if err == nil && resp.StatusCode == http.StatusOK {
 				defer resp.Body.Close()
 				body, err := io.ReadAll(resp.Body)
-				framework.ExpectNoError(err)
+				tc.ExpectNoError(err)
 				bodyList := strings.Split(string(body), "\n")
 				if strings.HasPrefix(bodyList[0], "Server address: ") {
 					return true, nil
@@ -158,91 +160,58 @@ func accessIngress(user string) framework.TestResp {
 			}
 			return true, nil
 		})
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	return framework.SucceedResp
 }
 
-func updateIngress2(user string) framework.TestResp {
-	url := "/api/v1/cube/proxy/clusters/" + framework.TargetClusterName + "/apis/networking.k8s.io/v1/namespaces/" + framework.NamespaceName + "/ingresses/" + ingress2NameWithUser
-	err := wait.Poll(waitInterval, waitTimeout,
+func updateIngress2(tc *framework.TestContext) framework.TestResp {
+	svc1NameWithUser := tc.NameWithUser(svc1Name)
+	ingress2NameWithUser := tc.NameWithUser(ingress2Name)
+	url := "/api/v1/cube/proxy/clusters/" + tc.TargetClusterName + "/apis/networking.k8s.io/v1/namespaces/" + tc.NamespaceName + "/ingresses/" + ingress2NameWithUser
+	ingress2 := &networkingv1.Ingress{}
+	err := wait.Poll(tc.WaitInterval, tc.WaitTimeout,
 		func() (bool, error) {
-			err := framework.TargetConvertClient.Get(ctx, types.NamespacedName{Name: ingress2NameWithUser, Namespace: framework.NamespaceName}, ingress2)
-			framework.ExpectNoError(err)
+			err := tc.TargetConvertClient.Get(ctx, types.NamespacedName{Name: ingress2NameWithUser, Namespace: tc.NamespaceName}, ingress2)
+			tc.ExpectNoError(err)
 			if ingress2.Name == ingress2NameWithUser {
 				return true, nil
 			} else {
 				return false, nil
 			}
 		})
-	framework.ExpectNoError(err)
-	postJson := fmt.Sprintf(`{
-    "apiVersion": "networking.k8s.io/v1",
-	"kind": "Ingress",
-	"metadata": {
-		"namespace": "%s",
-		"pureLabels": {},
-		"resourceVersion": "%s",
-		"uid": "%s",
-		"name": "%s",
-		"annotations": {
-			"nginx.ingress.kubernetes.io/load-balance": "round_robin",
-			"nginx.ingress.kubernetes.io/affinity": "cookie",
-			"nginx.ingress.kubernetes.io/session-cookie-hash": "md5",
-			"nginx.ingress.kubernetes.io/session-cookie-name": "qz_t"
-		},
-		"labels": {}
-	},
-	"spec": {
-		"rules": [{
-			"host": "%s",
-			"http": {
-				"paths": [{
-					"pathType": "ImplementationSpecific",
-					"path": "/%s",
-					"backend": {
-						"service": {
-							"name": "%s",
-							"port": {
-								"number": 80
-							}
-						}
-					}
-				}]
-			}
-		}],
-		"tls": []
-	}
-}`,
-		framework.NamespaceName, ingress2.ResourceVersion, ingress2.UID, ingress2NameWithUser, ingressAddr, user, svc1NameWithUser)
-	resp, err := httpHelper.RequestByUser(http.MethodPut, framework.KubecubeHost+url, postJson, user, nil)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
+	postJson := fixtures.MustRender(tc, "ingress-balance-update.yaml", fixtures.For(tc, ingress2NameWithUser).
+		With("resourceVersion", ingress2.ResourceVersion).With("uid", string(ingress2.UID)).With("host", ingressAddr).With("service", svc1NameWithUser))
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodPut, tc.KubecubeHost+url, postJson, tc.User, nil)
+	tc.ExpectNoError(err)
 	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	clog.Info("get ingress cookie response: %s", string(body))
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 
 	if !framework.IsSuccess(resp.StatusCode) {
 		clog.Warn("res code %d", resp.StatusCode)
 		return framework.NewTestResp(fmt.Errorf("fail to update ingress %s", ingress2NameWithUser), resp.StatusCode)
 	}
 
-	framework.ExpectEqual(resp.StatusCode, http.StatusOK)
-	err = wait.Poll(waitInterval, waitTimeout,
+	tc.ExpectEqual(resp.StatusCode, http.StatusOK)
+	err = wait.Poll(tc.WaitInterval, tc.WaitTimeout,
 		func() (bool, error) {
-			err = framework.TargetConvertClient.Get(ctx, types.NamespacedName{Name: ingress2NameWithUser, Namespace: framework.NamespaceName}, ingress2)
-			framework.ExpectNoError(err)
+			err = tc.TargetConvertClient.Get(ctx, types.NamespacedName{Name: ingress2NameWithUser, Namespace: tc.NamespaceName}, ingress2)
+			tc.ExpectNoError(err)
 			if ingress2.Annotations["nginx.ingress.kubernetes.io/affinity"] == "cookie" {
 				return true, nil
 			} else {
 				return false, nil
 			}
 		})
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	return framework.SucceedResp
 }
 
-func deleteIngress2(user string) framework.TestResp {
-	framework.ExpectNoError(framework.TargetConvertClient.Delete(ctx, ingress2))
+func deleteIngress2(tc *framework.TestContext) framework.TestResp {
+	ingress2 := &networkingv1.Ingress{ObjectMeta: v1.ObjectMeta{Name: tc.NameWithUser(ingress2Name), Namespace: tc.NamespaceName}}
+	tc.ExpectNoError(tc.TargetConvertClient.Delete(ctx, ingress2))
 	return framework.SucceedResp
 }
 
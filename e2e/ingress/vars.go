@@ -18,43 +18,19 @@ package ingress
 
 import (
 	"context"
-	"time"
 
 	"github.com/kubecube-io/kubecube-e2e/e2e/framework"
-	appsv1 "k8s.io/api/apps/v1"
-	corev1 "k8s.io/api/core/v1"
-	networkingv1 "k8s.io/api/networking/v1"
 )
 
-var (
-	httpHelper *framework.HttpHelper
-
-	deploy1  *appsv1.Deployment
-	svc1     *corev1.Service
-	ingress1 *networkingv1.Ingress
-	ingress2 *networkingv1.Ingress
-
-	ctx = context.Background()
-
-	deploy1Name          = "deploy1"
-	svc1Name             = "svc1"
-	ingress1Name         = "ingress1"
-	ingress2Name         = "ingress"
-	ingressAddr          = "e2e.test"
-	deploy1NameWithUser  string
-	svc1NameWithUser     string
-	ingress1NameWithUser string
-	ingress2NameWithUser string
-
-	waitInterval time.Duration
-	waitTimeout  time.Duration
+const (
+	deploy1Name  = "deploy1"
+	svc1Name     = "svc1"
+	ingress1Name = "ingress1"
+	ingress2Name = "ingress"
+	ingressAddr  = "e2e.test"
 )
 
-func initParam() {
-	httpHelper = framework.NewSingleHttpHelper()
-	waitInterval = framework.WaitInterval
-	waitTimeout = framework.WaitTimeout
-}
+var ctx = context.Background()
 
 func init() {
 	framework.RegisterByDefault(multiUserIngressCRUDTest)
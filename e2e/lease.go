@@ -0,0 +1,448 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubecube-io/kubecube-e2e/e2e/framework"
+)
+
+// master 与 worker 通过 Lease 协调：master 的 Lease 记录资源初始化阶段，每个 worker 持有一个 Lease，
+// 运行期间定期续约，超过 LeaseDuration 未续约的 worker 视为已崩溃
+const (
+	// leaseLabel 标记 e2e 创建的 Lease，值为 master 或 worker
+	leaseLabel  = "kubecube-e2e-lease"
+	leaseMaster = "master"
+	leaseWorker = "worker"
+
+	// phaseAnnotation master Lease 记录的资源初始化阶段
+	phaseAnnotation = "kubecube-e2e-phase"
+	// usersAnnotation worker Lease 记录该 worker 执行的用户
+	usersAnnotation = "kubecube-e2e-users"
+	// runIDAnnotation 持有 Lease 的进程的运行标识
+	runIDAnnotation = "kubecube-e2e-run-id"
+	// masterRunIDAnnotation worker Lease 所属 master 的运行标识，master 只等待本次运行的 worker
+	masterRunIDAnnotation = "kubecube-e2e-master-run-id"
+	// workerFailedAnnotation worker 未能发布结果时保留 Lease 并记录原因
+	workerFailedAnnotation = "kubecube-e2e-worker-failed"
+	// deadWorkersAnnotation master Lease 记录已崩溃的 worker
+	deadWorkersAnnotation = "kubecube-e2e-dead-workers"
+
+	phaseInitializing = "initializing"
+	phaseReady        = "ready"
+	phaseFailed       = "failed"
+	phaseCleared      = "cleared"
+)
+
+// heartbeat 当前进程持有的 Lease 的续约
+var heartbeat *leaseHeartbeat
+
+// DeadWorker 未正常结束的 worker
+type DeadWorker struct {
+	Lease     string    `json:"lease"`
+	Holder    string    `json:"holder"`
+	Users     []string  `json:"users"`
+	RunID     string    `json:"runID,omitempty"`
+	RenewTime time.Time `json:"renewTime"`
+	// Reason 崩溃（Lease 过期）或等待超时
+	Reason string `json:"reason"`
+}
+
+func masterLeaseName(tc *framework.TestContext) string {
+	return strings.ToLower(tc.KubeCubeE2ECM + "-master")
+}
+
+func workerLeaseName(tc *framework.TestContext) string {
+	return strings.ToLower(tc.KubeCubeE2ECM + "-worker-" + strings.Join(framework.TestUser, "-"))
+}
+
+// holderIdentity 当前进程的标识
+func holderIdentity() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
+// updateLease 创建或更新 Lease 并续约，mutate 修改 Lease 的其他内容
+func updateLease(tc *framework.TestContext, name, kind string, mutate func(lease *coordinationv1.Lease)) error {
+	ctx := context.Background()
+	cli := tc.PivotClusterClient.Direct()
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		lease := &coordinationv1.Lease{}
+		err := cli.Get(ctx, types.NamespacedName{Namespace: tc.KubeCubeSystem, Name: name}, lease)
+		notFound := kerrors.IsNotFound(err)
+		if err != nil && !notFound {
+			return err
+		}
+		if notFound {
+			lease = &coordinationv1.Lease{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: tc.KubeCubeSystem}}
+		}
+
+		now := metav1.NewMicroTime(time.Now())
+		holder := holderIdentity()
+		duration := int32(tc.LeaseDuration.Seconds())
+		if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != holder {
+			lease.Spec.AcquireTime = &now
+		}
+		lease.Spec.HolderIdentity = &holder
+		lease.Spec.LeaseDurationSeconds = &duration
+		lease.Spec.RenewTime = &now
+		if lease.Labels == nil {
+			lease.Labels = make(map[string]string)
+		}
+		lease.Labels[leaseLabel] = kind
+		if lease.Annotations == nil {
+			lease.Annotations = make(map[string]string)
+		}
+		lease.Annotations[runIDAnnotation] = tc.RunID
+		if mutate != nil {
+			mutate(lease)
+		}
+
+		if notFound {
+			return cli.Create(ctx, lease)
+		}
+		return cli.Update(ctx, lease)
+	})
+}
+
+// leaseExpired Lease 是否已超过有效期未续约
+func leaseExpired(lease *coordinationv1.Lease, now time.Time) bool {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	return now.After(lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second))
+}
+
+// leaseHeartbeat 在后台定期续约 Lease
+type leaseHeartbeat struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// startHeartbeat 每 LeaseDuration/3 续约一次 Lease，续约失败时记录日志并在下个周期重试
+func startHeartbeat(tc *framework.TestContext, name, kind string) *leaseHeartbeat {
+	ctx, cancel := context.WithCancel(context.Background())
+	h := &leaseHeartbeat{cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(h.done)
+		ticker := time.NewTicker(tc.LeaseDuration / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := updateLease(tc, name, kind, nil); err != nil {
+					clog.Warn("renew lease %s failed: %v", name, err)
+				}
+			}
+		}
+	}()
+	return h
+}
+
+func (h *leaseHeartbeat) stop() {
+	if h == nil {
+		return
+	}
+	h.cancel()
+	<-h.done
+}
+
+// setMasterPhase 更新 master Lease 的阶段，首次调用时开始续约
+func setMasterPhase(tc *framework.TestContext, phase string) error {
+	name := masterLeaseName(tc)
+	err := updateLease(tc, name, leaseMaster, func(lease *coordinationv1.Lease) {
+		lease.Annotations[phaseAnnotation] = phase
+		if phase == phaseInitializing {
+			delete(lease.Annotations, deadWorkersAnnotation)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("update master lease %s to %s failed: %v", name, phase, err)
+	}
+	if heartbeat == nil {
+		heartbeat = startHeartbeat(tc, name, leaseMaster)
+	}
+	return nil
+}
+
+// markResourceIniting 进入初始化阶段，并删除之前的运行遗留的 worker Lease
+func markResourceIniting(tc *framework.TestContext) error {
+	if err := setMasterPhase(tc, phaseInitializing); err != nil {
+		return err
+	}
+	err := tc.PivotClusterClient.Direct().DeleteAllOf(context.Background(), &coordinationv1.Lease{},
+		client.InNamespace(tc.KubeCubeSystem),
+		client.MatchingLabels{leaseLabel: leaseWorker})
+	if err != nil && !kerrors.IsNotFound(err) {
+		clog.Warn("fail to delete worker leases left by previous runs: %v", err)
+	}
+	return nil
+}
+
+func markAllResourceInited(tc *framework.TestContext) {
+	if err := setMasterPhase(tc, phaseReady); err != nil {
+		clog.Error(err.Error())
+		return
+	}
+	clog.Info("master initialized all resources, master is going to test")
+}
+
+func markAllResourceInitFailed(tc *framework.TestContext) {
+	if err := setMasterPhase(tc, phaseFailed); err != nil {
+		clog.Error(err.Error())
+	}
+}
+
+// markAllResourceCleared 资源清理完成后停止续约，初始化失败时保留 failed 阶段
+func markAllResourceCleared(tc *framework.TestContext) {
+	defer func() {
+		heartbeat.stop()
+		heartbeat = nil
+	}()
+	err := updateLease(tc, masterLeaseName(tc), leaseMaster, func(lease *coordinationv1.Lease) {
+		if lease.Annotations[phaseAnnotation] != phaseFailed {
+			lease.Annotations[phaseAnnotation] = phaseCleared
+		}
+	})
+	if err != nil {
+		clog.Error("fail to update master lease when markAllResourceCleared due to %s", err.Error())
+		return
+	}
+	clog.Info("master cleared all resources")
+}
+
+// waitUntilResourceInited worker 等待 master 初始化资源，master 在初始化过程中退出或初始化失败时返回错误，
+// 资源就绪后创建 worker Lease 并开始续约。
+// 之前的运行留下的 Lease（cleared 阶段、已过期或在 worker 启动前就已失败）不代表本次运行的 master，继续等待新的 master
+func waitUntilResourceInited(tc *framework.TestContext) error {
+	name := masterLeaseName(tc)
+	start := time.Now()
+	deadline := start.Add(tc.InitTimeout)
+	// initRunID 观察到的正在初始化的 master 的运行标识
+	initRunID := ""
+	for {
+		lease := &coordinationv1.Lease{}
+		err := tc.PivotClusterClient.Direct().Get(context.Background(), types.NamespacedName{Namespace: tc.KubeCubeSystem, Name: name}, lease)
+		switch {
+		case kerrors.IsNotFound(err):
+			clog.Info("master lease %s not found, waiting", name)
+		case err != nil:
+			clog.Warn("fail to get master lease %s: %v", name, err)
+		default:
+			phase := lease.Annotations[phaseAnnotation]
+			runID := lease.Annotations[runIDAnnotation]
+			// current 为 true 表示 Lease 属于 worker 等待的这次运行：观察到它初始化，或在 worker 启动后才被获取
+			current := (len(initRunID) > 0 && runID == initRunID) ||
+				(lease.Spec.AcquireTime != nil && !lease.Spec.AcquireTime.Time.Before(start))
+			switch {
+			case leaseExpired(lease, time.Now()):
+				if len(initRunID) > 0 && runID == initRunID {
+					return fmt.Errorf("master %s stopped renewing lease %s during %s", holderOf(lease), name, phase)
+				}
+				clog.Info("master lease %s expired, waiting for master", name)
+			case len(initRunID) > 0 && runID != initRunID && phase != phaseInitializing:
+				// 不是观察到初始化的 master 的结果，等待新的 master 初始化
+				clog.Info("master lease %s is held by another run (%s), waiting", name, phase)
+			case phase == phaseInitializing:
+				initRunID = runID
+				clog.Info("resource not ready (%s), waiting", phase)
+			case phase == phaseReady:
+				clog.Info("resource ready")
+				masterRunID = runID
+				return registerWorker(tc)
+			case phase == phaseFailed && current:
+				clog.Warn("resource failed")
+				return fmt.Errorf("master %s failed to initialize resources", holderOf(lease))
+			default:
+				// cleared 或之前的运行留下的 failed
+				clog.Info("master lease %s is left by previous run (%s), waiting for master", name, phase)
+			}
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("resources are not initialized in %v", tc.InitTimeout)
+		}
+		time.Sleep(tc.WaitInterval)
+	}
+}
+
+// registerWorker 创建记录执行用户的 worker Lease 并开始续约
+func registerWorker(tc *framework.TestContext) error {
+	name := workerLeaseName(tc)
+	err := updateLease(tc, name, leaseWorker, func(lease *coordinationv1.Lease) {
+		lease.Annotations[usersAnnotation] = strings.Join(framework.TestUser, ",")
+		lease.Annotations[masterRunIDAnnotation] = masterRunID
+	})
+	if err != nil {
+		clog.Error("fail to create worker lease %s due to %s", name, err.Error())
+		return err
+	}
+	heartbeat = startHeartbeat(tc, name, leaseWorker)
+	clog.Info("resource initialized, worker is going to test")
+	return nil
+}
+
+// markAllTestInThisWorkerFinished 停止续约并删除 worker Lease
+func markAllTestInThisWorkerFinished(tc *framework.TestContext) {
+	heartbeat.stop()
+	heartbeat = nil
+
+	lease := &coordinationv1.Lease{ObjectMeta: metav1.ObjectMeta{Name: workerLeaseName(tc), Namespace: tc.KubeCubeSystem}}
+	err := tc.PivotClusterClient.Direct().Delete(context.Background(), lease)
+	if err != nil && !kerrors.IsNotFound(err) {
+		clog.Error("fail to delete worker lease when markAllTestInThisWorkerFinished due to %s", err.Error())
+		return
+	}
+
+	clog.Info("worker finished test and marked")
+}
+
+// markWorkerFailed worker 未能发布结果时停止续约，保留 Lease 并记录原因，master 据此将该 worker 记为失败。
+// 未创建 Lease（如资源未就绪）时不做处理
+func markWorkerFailed(tc *framework.TestContext, reason error) {
+	if heartbeat == nil {
+		return
+	}
+	heartbeat.stop()
+	heartbeat = nil
+
+	err := updateLease(tc, workerLeaseName(tc), leaseWorker, func(lease *coordinationv1.Lease) {
+		lease.Annotations[workerFailedAnnotation] = reason.Error()
+	})
+	if err != nil {
+		clog.Error("fail to mark worker lease as failed due to %s", err.Error())
+		return
+	}
+	clog.Warn("worker marked as failed: %v", reason)
+}
+
+// waitUntilTestsInAllWorkersFinished master 等待本次运行的所有 worker 删除各自的 Lease，
+// Lease 过期或标记为失败的 worker 视为已崩溃，记录其执行的用户后不再等待，其他运行的 Lease 不等待也不计入。
+// 返回本次运行中出现过的所有 worker（key 为 Lease 名）与崩溃的 worker
+func waitUntilTestsInAllWorkersFinished(tc *framework.TestContext) (map[string]DeadWorker, []DeadWorker) {
+	var dead []DeadWorker
+	workers := make(map[string]DeadWorker)
+	ignored := make(map[string]bool)
+	deadline := time.Now().Add(tc.WorkersTimeout)
+	for {
+		leases := &coordinationv1.LeaseList{}
+		err := tc.PivotClusterClient.Direct().List(context.Background(), leases,
+			client.InNamespace(tc.KubeCubeSystem),
+			client.MatchingLabels{leaseLabel: leaseWorker})
+		if err != nil {
+			clog.Warn("fail to list worker leases due to %s", err.Error())
+		} else {
+			now := time.Now()
+			timeout := now.After(deadline)
+			running := 0
+			for i := range leases.Items {
+				lease := &leases.Items[i]
+				if lease.Annotations[masterRunIDAnnotation] != tc.RunID {
+					if !ignored[lease.Name] {
+						ignored[lease.Name] = true
+						clog.Info("ignore lease %s of worker %s from another run", lease.Name, holderOf(lease))
+					}
+					continue
+				}
+				if _, ok := workers[lease.Name]; !ok {
+					workers[lease.Name] = deadWorkerOf(lease, "")
+				}
+				switch {
+				case len(lease.Annotations[workerFailedAnnotation]) > 0:
+					dead = append(dead, deadWorkerOf(lease, lease.Annotations[workerFailedAnnotation]))
+				case leaseExpired(lease, now):
+					dead = append(dead, deadWorkerOf(lease, "lease expired"))
+				case timeout:
+					dead = append(dead, deadWorkerOf(lease, fmt.Sprintf("not finished in %v", tc.WorkersTimeout)))
+				default:
+					running++
+					continue
+				}
+				clog.Warn("worker %s running %s is considered dead: %s", holderOf(lease), lease.Annotations[usersAnnotation], dead[len(dead)-1].Reason)
+				err = tc.PivotClusterClient.Direct().Delete(context.Background(), lease)
+				if err != nil && !kerrors.IsNotFound(err) {
+					clog.Warn("fail to delete lease %s of dead worker: %v", lease.Name, err)
+				}
+			}
+			if running == 0 {
+				break
+			}
+			clog.Info("%d workers running, waiting", running)
+		}
+		time.Sleep(tc.WaitInterval)
+	}
+
+	if len(dead) > 0 {
+		recordDeadWorkers(tc, dead)
+	}
+	clog.Info("all tests of workers finished, master is going to clean resources")
+	return workers, dead
+}
+
+func deadWorkerOf(lease *coordinationv1.Lease, reason string) DeadWorker {
+	w := DeadWorker{
+		Lease:  lease.Name,
+		Holder: holderOf(lease),
+		Users:  strings.Split(lease.Annotations[usersAnnotation], ","),
+		RunID:  lease.Annotations[runIDAnnotation],
+		Reason: reason,
+	}
+	if lease.Spec.RenewTime != nil {
+		w.RenewTime = lease.Spec.RenewTime.Time
+	}
+	return w
+}
+
+func holderOf(lease *coordinationv1.Lease) string {
+	if lease.Spec.HolderIdentity == nil {
+		return lease.Name
+	}
+	return *lease.Spec.HolderIdentity
+}
+
+// recordDeadWorkers 将崩溃的 worker 记录到 master Lease 的注解中
+func recordDeadWorkers(tc *framework.TestContext, dead []DeadWorker) {
+	data, err := json.Marshal(dead)
+	if err != nil {
+		clog.Warn("marshal dead workers failed: %v", err)
+		return
+	}
+	err = updateLease(tc, masterLeaseName(tc), leaseMaster, func(lease *coordinationv1.Lease) {
+		lease.Annotations[deadWorkersAnnotation] = string(data)
+	})
+	if err != nil {
+		clog.Warn("record dead workers in master lease failed: %v", err)
+	}
+}
@@ -24,27 +24,14 @@ import (
 	"net/http"
 
 	"github.com/kubecube-io/kubecube-e2e/e2e/framework"
-	"github.com/kubecube-io/kubecube/pkg/multicluster/client"
 
 	v1 "k8s.io/api/core/v1"
 )
 
-var (
-	pivotClient  client.Client
-	pivotCluster string
-	httpHelper   *framework.HttpHelper
-)
-
 func init() {
 	framework.RegisterByDefault(multiUserTest)
 }
 
-func initParam() {
-	pivotClient = framework.PivotClusterClient
-	pivotCluster = framework.PivotClusterName
-	httpHelper = framework.NewSingleHttpHelper()
-}
-
 var multiUserTest = framework.MultiUserTest{
 	TestName:        "[节点信息]集群节点列表检查检查",
 	ContinueIfError: false,
@@ -70,33 +57,32 @@ var multiUserTest = framework.MultiUserTest{
 	},
 }
 
-func listNode(user string) framework.TestResp {
-	initParam()
+func listNode(tc *framework.TestContext) framework.TestResp {
 	urlOfListNode := "%s/api/v1/cube/extend/clusters/%s/resources/nodes"
-	urlOfListNode = fmt.Sprintf(urlOfListNode, framework.KubecubeHost, pivotCluster)
-	resp, err := httpHelper.RequestByUser(http.MethodGet, urlOfListNode, "", user, nil)
-	framework.ExpectNoError(err)
+	urlOfListNode = fmt.Sprintf(urlOfListNode, tc.KubecubeHost, tc.PivotClusterName)
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodGet, urlOfListNode, "", tc.User, nil)
+	tc.ExpectNoError(err)
 	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	var nodeResMap map[string]interface{}
 	err = json.Unmarshal(body, &nodeResMap)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	nodeList := v1.NodeList{}
-	err = pivotClient.Direct().List(context.Background(), &nodeList)
-	framework.ExpectNoError(err)
+	err = tc.PivotClusterClient.Direct().List(context.Background(), &nodeList)
+	tc.ExpectNoError(err)
 	total, ok := nodeResMap["total"]
-	framework.ExpectEqual(ok, true)
-	framework.ExpectEqual(float64(len(nodeList.Items)), total)
+	tc.ExpectEqual(ok, true)
+	tc.ExpectEqual(float64(len(nodeList.Items)), total)
 	items, ok := nodeResMap["items"].([]interface{})
-	framework.ExpectEqual(ok, true)
+	tc.ExpectEqual(ok, true)
 	for _, item := range items {
 		item, ok := item.(map[string]interface{})
-		framework.ExpectEqual(ok, true)
+		tc.ExpectEqual(ok, true)
 		metadata, ok := item["metadata"].(map[string]interface{})
-		framework.ExpectEqual(ok, true)
+		tc.ExpectEqual(ok, true)
 		name, ok := metadata["name"].(string)
-		framework.ExpectEqual(ok, true)
+		tc.ExpectEqual(ok, true)
 		nameCheck := false
 		for _, node := range nodeList.Items {
 			if name == node.Name {
@@ -104,7 +90,7 @@ func listNode(user string) framework.TestResp {
 				break
 			}
 		}
-		framework.ExpectEqual(nameCheck, true)
+		tc.ExpectEqual(nameCheck, true)
 	}
 	return framework.SucceedResp
 }
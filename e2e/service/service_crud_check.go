@@ -32,20 +32,18 @@ import (
 	"k8s.io/apimachinery/pkg/util/wait"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/kubecube-io/kubecube-e2e/e2e/fixtures"
 	"github.com/kubecube-io/kubecube-e2e/e2e/framework"
 )
 
-func createDeploy1AndDeploy2(user string) framework.TestResp {
-	initParam()
-	deploy1NameWithUser = framework.NameWithUser(deploy1Name, user)
-	deploy2NameWithUser = framework.NameWithUser(deploy2Name, user)
-	service1NameWithUser = framework.NameWithUser(service1Name, user)
-	service2NameWithUser = framework.NameWithUser(service2Name, user)
+func createDeploy1AndDeploy2(tc *framework.TestContext) framework.TestResp {
+	deploy1NameWithUser := tc.NameWithUser(deploy1Name)
+	deploy2NameWithUser := tc.NameWithUser(deploy2Name)
 	replica := int32(1)
-	deploy1 = &v12.Deployment{
+	deploy1 := &v12.Deployment{
 		ObjectMeta: v1.ObjectMeta{
 			Name:      deploy1NameWithUser,
-			Namespace: framework.NamespaceName,
+			Namespace: tc.NamespaceName,
 		},
 		Spec: v12.DeploymentSpec{
 			Selector: &v1.LabelSelector{
@@ -60,21 +58,21 @@ func createDeploy1AndDeploy2(user string) framework.TestResp {
 					Containers: []v13.Container{
 						{
 							Name:  "nginx",
-							Image: framework.TestImage,
+							Image: tc.TestImage,
 						},
 					},
-					ImagePullSecrets: []v13.LocalObjectReference{{Name: framework.ImagePullSecret}},
+					ImagePullSecrets: []v13.LocalObjectReference{{Name: tc.ImagePullSecret}},
 				},
 			},
 		},
 	}
-	err := framework.TargetClusterClient.Direct().Create(ctx, deploy1)
-	framework.ExpectNoError(err)
+	err := tc.TargetClusterClient.Direct().Create(ctx, deploy1)
+	tc.ExpectNoError(err)
 
-	deploy2 = &v12.Deployment{
+	deploy2 := &v12.Deployment{
 		ObjectMeta: v1.ObjectMeta{
 			Name:      deploy2NameWithUser,
-			Namespace: framework.NamespaceName,
+			Namespace: tc.NamespaceName,
 		},
 		Spec: v12.DeploymentSpec{
 			Selector: &v1.LabelSelector{
@@ -89,32 +87,35 @@ func createDeploy1AndDeploy2(user string) framework.TestResp {
 					Containers: []v13.Container{
 						{
 							Name:  "nginx",
-							Image: framework.TestImage,
+							Image: tc.TestImage,
 						},
 					},
-					ImagePullSecrets: []v13.LocalObjectReference{{Name: framework.ImagePullSecret}},
+					ImagePullSecrets: []v13.LocalObjectReference{{Name: tc.ImagePullSecret}},
 				},
 			},
 		},
 	}
-	err = framework.TargetClusterClient.Direct().Create(ctx, deploy2)
-	framework.ExpectNoError(err)
+	err = tc.TargetClusterClient.Direct().Create(ctx, deploy2)
+	tc.ExpectNoError(err)
 	return framework.SucceedResp
 }
 
-func deleteDeploy1AndDeploy2(user string) framework.TestResp {
-	err := framework.TargetClusterClient.Direct().Delete(ctx, deploy1)
-	framework.ExpectNoError(err)
-	err = framework.TargetClusterClient.Direct().Delete(ctx, deploy2)
-	framework.ExpectNoError(err)
+func deleteDeploy1AndDeploy2(tc *framework.TestContext) framework.TestResp {
+	deploy1 := &v12.Deployment{ObjectMeta: v1.ObjectMeta{Name: tc.NameWithUser(deploy1Name), Namespace: tc.NamespaceName}}
+	deploy2 := &v12.Deployment{ObjectMeta: v1.ObjectMeta{Name: tc.NameWithUser(deploy2Name), Namespace: tc.NamespaceName}}
+	err := tc.TargetClusterClient.Direct().Delete(ctx, deploy1)
+	tc.ExpectNoError(err)
+	err = tc.TargetClusterClient.Direct().Delete(ctx, deploy2)
+	tc.ExpectNoError(err)
 	return framework.SucceedResp
 }
 
-func createService1(user string) framework.TestResp {
-	url := "/api/v1/cube/proxy/clusters/" + framework.TargetClusterName + "/api/v1/namespaces/" + framework.NamespaceName + "/services"
-	postJson := fmt.Sprintf("{\"apiVersion\":\"v1\",\"kind\":\"Service\",\"metadata\":{\"name\":\"%s\",\"annotations\":{},\"labels\":{}},\"spec\":{\"ports\":[{\"name\":\"port1\",\"protocol\":\"TCP\",\"port\":8080,\"targetPort\":8080}],\"type\":\"ClusterIP\",\"selector\":{\"kubecube.io/app\":\"nginx\"},\"sessionAffinity\":\"None\"}}", service1NameWithUser)
-	resp, err := httpHelper.RequestByUser(http.MethodPost, framework.KubecubeHost+url, postJson, user, nil)
-	framework.ExpectNoError(err)
+func createService1(tc *framework.TestContext) framework.TestResp {
+	service1NameWithUser := tc.NameWithUser(service1Name)
+	url := "/api/v1/cube/proxy/clusters/" + tc.TargetClusterName + "/api/v1/namespaces/" + tc.NamespaceName + "/services"
+	postJson := fixtures.MustRender(tc, "service.yaml", fixtures.For(tc, service1NameWithUser))
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodPost, tc.KubecubeHost+url, postJson, tc.User, nil)
+	tc.ExpectNoError(err)
 	defer resp.Body.Close()
 
 	if !framework.IsSuccess(resp.StatusCode) {
@@ -122,18 +123,19 @@ func createService1(user string) framework.TestResp {
 		return framework.NewTestResp(fmt.Errorf("fail to create svc %s", service1NameWithUser), resp.StatusCode)
 	}
 	// check return success
-	framework.ExpectEqual(resp.StatusCode, http.StatusCreated)
+	tc.ExpectEqual(resp.StatusCode, http.StatusCreated)
 	service := v13.Service{}
-	err = framework.TargetClusterClient.Direct().Get(ctx, types.NamespacedName{Name: service1NameWithUser, Namespace: framework.NamespaceName}, &service)
-	framework.ExpectNoError(err)
-	framework.ExpectEqual(service.Name, service1NameWithUser)
+	err = tc.TargetClusterClient.Direct().Get(ctx, types.NamespacedName{Name: service1NameWithUser, Namespace: tc.NamespaceName}, &service)
+	tc.ExpectNoError(err)
+	tc.ExpectEqual(service.Name, service1NameWithUser)
 	return framework.SucceedResp
 }
 
-func checkService1(user string) framework.TestResp {
-	url := "/api/v1/cube/proxy/clusters/" + framework.TargetClusterName + "/api/v1/namespaces/" + framework.NamespaceName + "/services/" + service1NameWithUser
-	resp, err := httpHelper.RequestByUser(http.MethodGet, framework.KubecubeHost+url, "", user, nil)
-	framework.ExpectNoError(err)
+func checkService1(tc *framework.TestContext) framework.TestResp {
+	service1NameWithUser := tc.NameWithUser(service1Name)
+	url := "/api/v1/cube/proxy/clusters/" + tc.TargetClusterName + "/api/v1/namespaces/" + tc.NamespaceName + "/services/" + service1NameWithUser
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodGet, tc.KubecubeHost+url, "", tc.User, nil)
+	tc.ExpectNoError(err)
 	defer resp.Body.Close()
 
 	if !framework.IsSuccess(resp.StatusCode) {
@@ -142,22 +144,23 @@ func checkService1(user string) framework.TestResp {
 	}
 
 	// check return success
-	framework.ExpectEqual(resp.StatusCode, http.StatusOK)
+	tc.ExpectEqual(resp.StatusCode, http.StatusOK)
 	body, err := io.ReadAll(resp.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	svc := v13.Service{}
 	err = json.Unmarshal(body, &svc)
-	framework.ExpectNoError(err)
-	framework.ExpectEqual(svc.Name, service1NameWithUser)
-	framework.ExpectEqual(svc.Spec.Selector["kubecube.io/app"], "nginx")
+	tc.ExpectNoError(err)
+	tc.ExpectEqual(svc.Name, service1NameWithUser)
+	tc.ExpectEqual(svc.Spec.Selector["kubecube.io/app"], "nginx")
 	return framework.SucceedResp
 }
 
-func createService2(user string) framework.TestResp {
-	url := "/api/v1/cube/proxy/clusters/" + framework.TargetClusterName + "/api/v1/namespaces/" + framework.NamespaceName + "/services"
-	postJson := fmt.Sprintf("{\"apiVersion\":\"v1\",\"kind\":\"Service\",\"metadata\":{\"name\":\"%s\",\"annotations\":{},\"labels\":{}},\"spec\":{\"ports\":[{\"name\":\"demo-port\",\"protocol\":\"TCP\",\"port\":8080,\"targetPort\":8080}],\"type\":\"ClusterIP\",\"clusterIP\":\"None\",\"selector\":{},\"sessionAffinity\":\"None\"}}", service2NameWithUser)
-	resp, err := httpHelper.RequestByUser(http.MethodPost, framework.KubecubeHost+url, postJson, user, nil)
-	framework.ExpectNoError(err)
+func createService2(tc *framework.TestContext) framework.TestResp {
+	service2NameWithUser := tc.NameWithUser(service2Name)
+	url := "/api/v1/cube/proxy/clusters/" + tc.TargetClusterName + "/api/v1/namespaces/" + tc.NamespaceName + "/services"
+	postJson := fixtures.MustRender(tc, "service-headless.yaml", fixtures.For(tc, service2NameWithUser))
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodPost, tc.KubecubeHost+url, postJson, tc.User, nil)
+	tc.ExpectNoError(err)
 	defer resp.Body.Close()
 
 	if !framework.IsSuccess(resp.StatusCode) {
@@ -166,18 +169,20 @@ func createService2(user string) framework.TestResp {
 	}
 
 	// check return success
-	framework.ExpectEqual(resp.StatusCode, http.StatusCreated)
+	tc.ExpectEqual(resp.StatusCode, http.StatusCreated)
 	service := v13.Service{}
-	err = framework.TargetClusterClient.Direct().Get(ctx, types.NamespacedName{Name: service2NameWithUser, Namespace: framework.NamespaceName}, &service)
-	framework.ExpectNoError(err)
-	framework.ExpectEqual(service.Spec.ClusterIP, "None")
+	err = tc.TargetClusterClient.Direct().Get(ctx, types.NamespacedName{Name: service2NameWithUser, Namespace: tc.NamespaceName}, &service)
+	tc.ExpectNoError(err)
+	tc.ExpectEqual(service.Spec.ClusterIP, "None")
 	return framework.SucceedResp
 }
 
-func listService(user string) framework.TestResp {
-	url := "/api/v1/cube/proxy/clusters/" + framework.TargetClusterName + "/api/v1/namespaces/" + framework.NamespaceName + "/services?pageNum=1&pageSize=10"
-	resp, err := httpHelper.RequestByUser(http.MethodGet, framework.KubecubeHost+url, "", user, nil)
-	framework.ExpectNoError(err)
+func listService(tc *framework.TestContext) framework.TestResp {
+	service1NameWithUser := tc.NameWithUser(service1Name)
+	service2NameWithUser := tc.NameWithUser(service2Name)
+	url := "/api/v1/cube/proxy/clusters/" + tc.TargetClusterName + "/api/v1/namespaces/" + tc.NamespaceName + "/services?pageNum=1&pageSize=10"
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodGet, tc.KubecubeHost+url, "", tc.User, nil)
+	tc.ExpectNoError(err)
 	defer resp.Body.Close()
 
 	if !framework.IsSuccess(resp.StatusCode) {
@@ -186,17 +191,17 @@ func listService(user string) framework.TestResp {
 	}
 
 	// check return success
-	framework.ExpectEqual(resp.StatusCode, http.StatusOK)
+	tc.ExpectEqual(resp.StatusCode, http.StatusOK)
 	body, err := io.ReadAll(resp.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	clog.Info("svc list %s", string(body))
 	var result map[string]interface{}
 	err = json.Unmarshal(body, &result)
-	framework.ExpectNoError(err)
-	framework.ExpectNotEqual(int(result["total"].(float64)), 0)
+	tc.ExpectNoError(err)
+	tc.ExpectNotEqual(int(result["total"].(float64)), 0)
 
 	list, ok := result["items"].([]interface{})
-	framework.ExpectEqual(ok, true)
+	tc.ExpectEqual(ok, true)
 
 	count := 0
 	for _, item := range list {
@@ -206,21 +211,22 @@ func listService(user string) framework.TestResp {
 		}
 	}
 
-	framework.ExpectEqual(count, 2)
+	tc.ExpectEqual(count, 2)
 
 	return framework.SucceedResp
 }
 
-func updateService1(user string) framework.TestResp {
+func updateService1(tc *framework.TestContext) framework.TestResp {
+	service1NameWithUser := tc.NameWithUser(service1Name)
 	service := &v13.Service{}
-	err := framework.TargetClusterClient.Direct().Get(ctx, types.NamespacedName{Name: service1NameWithUser, Namespace: framework.NamespaceName}, service)
-	framework.ExpectNoError(err)
-
-	url := "/api/v1/cube/proxy/clusters/" + framework.TargetClusterName + "/api/v1/namespaces/" + framework.NamespaceName + "/services/" + service1NameWithUser
-	postJson := fmt.Sprintf("{\"metadata\":{\"namespace\":\"%s\",\"pureLabels\":{},\"resourceVersion\":\"%s\",\"uid\":\"%s\",\"name\":\"%s\",\"annotations\":{},\"labels\":{}},\"spec\":{\"ports\":[{\"name\":\"port1\",\"protocol\":\"TCP\",\"port\":8080,\"targetPort\":8080},{\"name\":\"port2\",\"protocol\":\"TCP\",\"port\":50000,\"targetPort\":50000}],\"type\":\"ClusterIP\",\"clusterIP\":\"%s\",\"selector\":{\"kubecube.io/app\":\"nginx\"},\"sessionAffinity\":\"None\"}}",
-		framework.NamespaceName, service.ResourceVersion, service.UID, service1NameWithUser, service.Spec.ClusterIP)
-	resp, err := httpHelper.RequestByUser(http.MethodPut, framework.KubecubeHost+url, postJson, user, nil)
-	framework.ExpectNoError(err)
+	err := tc.TargetClusterClient.Direct().Get(ctx, types.NamespacedName{Name: service1NameWithUser, Namespace: tc.NamespaceName}, service)
+	tc.ExpectNoError(err)
+
+	url := "/api/v1/cube/proxy/clusters/" + tc.TargetClusterName + "/api/v1/namespaces/" + tc.NamespaceName + "/services/" + service1NameWithUser
+	postJson := fixtures.MustRender(tc, "service-update.yaml", fixtures.For(tc, service1NameWithUser).
+		With("resourceVersion", service.ResourceVersion).With("uid", string(service.UID)).With("clusterIP", service.Spec.ClusterIP))
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodPut, tc.KubecubeHost+url, postJson, tc.User, nil)
+	tc.ExpectNoError(err)
 	defer resp.Body.Close()
 
 	if !framework.IsSuccess(resp.StatusCode) {
@@ -229,18 +235,20 @@ func updateService1(user string) framework.TestResp {
 	}
 
 	// check return success
-	framework.ExpectEqual(resp.StatusCode, http.StatusOK)
+	tc.ExpectEqual(resp.StatusCode, http.StatusOK)
 	service = &v13.Service{}
-	err = framework.TargetClusterClient.Direct().Get(ctx, types.NamespacedName{Name: service1NameWithUser, Namespace: framework.NamespaceName}, service)
-	framework.ExpectNoError(err)
-	framework.ExpectEqual(len(service.Spec.Ports), 2)
+	err = tc.TargetClusterClient.Direct().Get(ctx, types.NamespacedName{Name: service1NameWithUser, Namespace: tc.NamespaceName}, service)
+	tc.ExpectNoError(err)
+	tc.ExpectEqual(len(service.Spec.Ports), 2)
 	return framework.SucceedResp
 }
 
-func deleteService(user string) framework.TestResp {
-	url := "/api/v1/cube/proxy/clusters/" + framework.TargetClusterName + "/api/v1/namespaces/" + framework.NamespaceName + "/services/" + service2NameWithUser
-	resp, err := httpHelper.RequestByUser(http.MethodDelete, framework.KubecubeHost+url, "", user, nil)
-	framework.ExpectNoError(err)
+func deleteService(tc *framework.TestContext) framework.TestResp {
+	service1NameWithUser := tc.NameWithUser(service1Name)
+	service2NameWithUser := tc.NameWithUser(service2Name)
+	url := "/api/v1/cube/proxy/clusters/" + tc.TargetClusterName + "/api/v1/namespaces/" + tc.NamespaceName + "/services/" + service2NameWithUser
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodDelete, tc.KubecubeHost+url, "", tc.User, nil)
+	tc.ExpectNoError(err)
 	defer resp.Body.Close()
 
 	if !framework.IsSuccess(resp.StatusCode) {
@@ -249,20 +257,20 @@ func deleteService(user string) framework.TestResp {
 	}
 
 	// check return success
-	framework.ExpectEqual(resp.StatusCode, http.StatusOK)
+	tc.ExpectEqual(resp.StatusCode, http.StatusOK)
 	service := v13.Service{}
-	err = wait.Poll(framework.WaitInterval, framework.WaitTimeout, func() (done bool, err error) {
-		err = framework.TargetClusterClient.Direct().Get(ctx, types.NamespacedName{Name: service2NameWithUser, Namespace: framework.NamespaceName}, &service)
+	err = wait.Poll(tc.WaitInterval, tc.WaitTimeout, func() (done bool, err error) {
+		err = tc.TargetClusterClient.Direct().Get(ctx, types.NamespacedName{Name: service2NameWithUser, Namespace: tc.NamespaceName}, &service)
 		if !kerrors.IsNotFound(err) {
 			return false, err
 		}
 		return true, nil
 	})
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 
-	url = "/api/v1/cube/proxy/clusters/" + framework.TargetClusterName + "/api/v1/namespaces/" + framework.NamespaceName + "/services/" + service1NameWithUser
-	resp, err = httpHelper.RequestByUser(http.MethodDelete, framework.KubecubeHost+url, "", user, nil)
-	framework.ExpectNoError(err)
+	url = "/api/v1/cube/proxy/clusters/" + tc.TargetClusterName + "/api/v1/namespaces/" + tc.NamespaceName + "/services/" + service1NameWithUser
+	resp, err = tc.HttpHelper.RequestByUser(http.MethodDelete, tc.KubecubeHost+url, "", tc.User, nil)
+	tc.ExpectNoError(err)
 	defer resp.Body.Close()
 
 	if !framework.IsSuccess(resp.StatusCode) {
@@ -272,8 +280,8 @@ func deleteService(user string) framework.TestResp {
 
 	// check return success
 	serviceList := v13.ServiceList{}
-	err = wait.Poll(framework.WaitInterval, framework.WaitTimeout, func() (done bool, err error) {
-		err = framework.TargetClusterClient.Direct().List(ctx, &serviceList, &client.ListOptions{Namespace: framework.NamespaceName})
+	err = wait.Poll(tc.WaitInterval, tc.WaitTimeout, func() (done bool, err error) {
+		err = tc.TargetClusterClient.Direct().List(ctx, &serviceList, &client.ListOptions{Namespace: tc.NamespaceName})
 		if err != nil {
 			return false, err
 		}
@@ -288,7 +296,7 @@ func deleteService(user string) framework.TestResp {
 		}
 		return true, nil
 	})
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 
 	return framework.SucceedResp
 }
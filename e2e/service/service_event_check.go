@@ -33,17 +33,16 @@ import (
 	"github.com/kubecube-io/kubecube-e2e/e2e/framework"
 )
 
-func createDeployAndService(user string) framework.TestResp {
-	initParam()
-	deploy1NameWithUser = framework.NameWithUser(deploy1Name, user)
-	service1NameWithUser = framework.NameWithUser(service1Name, user)
-	hostWithName = fmt.Sprintf(host, user)
-	ingress1NameWithUser = framework.NameWithUser(ingress1Name, user)
+func createDeployAndService(tc *framework.TestContext) framework.TestResp {
+	deploy1NameWithUser := tc.NameWithUser(deploy1Name)
+	service1NameWithUser := tc.NameWithUser(service1Name)
+	ingress1NameWithUser := tc.NameWithUser(ingress1Name)
+	hostWithName := fmt.Sprintf(host, tc.User)
 	replicas := int32(1)
-	deploy1 = &v12.Deployment{
+	deploy1 := &v12.Deployment{
 		ObjectMeta: v1.ObjectMeta{
 			Name:      deploy1NameWithUser,
-			Namespace: framework.NamespaceName,
+			Namespace: tc.NamespaceName,
 		},
 		Spec: v12.DeploymentSpec{
 			Selector: &v1.LabelSelector{
@@ -58,21 +57,21 @@ func createDeployAndService(user string) framework.TestResp {
 					Containers: []v13.Container{
 						{
 							Name:  "nginx",
-							Image: framework.TestImage,
+							Image: tc.TestImage,
 						},
 					},
-					ImagePullSecrets: []v13.LocalObjectReference{{Name: framework.ImagePullSecret}},
+					ImagePullSecrets: []v13.LocalObjectReference{{Name: tc.ImagePullSecret}},
 				},
 			},
 		},
 	}
-	err := framework.TargetClusterClient.Direct().Create(ctx, deploy1)
-	framework.ExpectNoError(err)
+	err := tc.TargetClusterClient.Direct().Create(ctx, deploy1)
+	tc.ExpectNoError(err)
 
-	svc1 = &v13.Service{
+	svc1 := &v13.Service{
 		ObjectMeta: v1.ObjectMeta{
 			Name:      service1NameWithUser,
-			Namespace: framework.NamespaceName,
+			Namespace: tc.NamespaceName,
 		},
 		Spec: v13.ServiceSpec{
 			Selector: map[string]string{"kubecube.io/app": deploy1NameWithUser},
@@ -81,15 +80,15 @@ func createDeployAndService(user string) framework.TestResp {
 			},
 		},
 	}
-	err = framework.TargetClusterClient.Direct().Create(ctx, svc1)
-	framework.ExpectNoError(err)
+	err = tc.TargetClusterClient.Direct().Create(ctx, svc1)
+	tc.ExpectNoError(err)
 
 	pathType := networkingv1.PathTypeImplementationSpecific
 
-	ingress1 = &networkingv1.Ingress{
+	ingress1 := &networkingv1.Ingress{
 		ObjectMeta: v1.ObjectMeta{
 			Name:      ingress1NameWithUser,
-			Namespace: framework.NamespaceName,
+			Namespace: tc.NamespaceName,
 		},
 		Spec: networkingv1.IngressSpec{
 			Rules: []networkingv1.IngressRule{
@@ -113,38 +112,42 @@ func createDeployAndService(user string) framework.TestResp {
 			},
 		},
 	}
-	err = framework.TargetConvertClient.Create(ctx, ingress1)
-	framework.ExpectNoError(err)
+	err = tc.TargetConvertClient.Create(ctx, ingress1)
+	tc.ExpectNoError(err)
 	return framework.SucceedResp
 }
 
-func deleteDeployAndService(user string) framework.TestResp {
-	err := framework.TargetClusterClient.Direct().Delete(ctx, deploy1)
-	framework.ExpectNoError(err)
-	err = framework.TargetClusterClient.Direct().Delete(ctx, svc1)
-	framework.ExpectNoError(err)
-	err = framework.TargetConvertClient.Delete(ctx, ingress1)
-	framework.ExpectNoError(err)
+func deleteDeployAndService(tc *framework.TestContext) framework.TestResp {
+	deploy1 := &v12.Deployment{ObjectMeta: v1.ObjectMeta{Name: tc.NameWithUser(deploy1Name), Namespace: tc.NamespaceName}}
+	svc1 := &v13.Service{ObjectMeta: v1.ObjectMeta{Name: tc.NameWithUser(service1Name), Namespace: tc.NamespaceName}}
+	ingress1 := &networkingv1.Ingress{ObjectMeta: v1.ObjectMeta{Name: tc.NameWithUser(ingress1Name), Namespace: tc.NamespaceName}}
+	err := tc.TargetClusterClient.Direct().Delete(ctx, deploy1)
+	tc.ExpectNoError(err)
+	err = tc.TargetClusterClient.Direct().Delete(ctx, svc1)
+	tc.ExpectNoError(err)
+	err = tc.TargetConvertClient.Delete(ctx, ingress1)
+	tc.ExpectNoError(err)
 	return framework.SucceedResp
 }
 
-func checkServiceEvent(user string) framework.TestResp {
-	url := "/api/v1/cube/proxy/clusters/" + framework.TargetClusterName + "/api/v1/namespaces/" + framework.NamespaceName + "/events?fieldSelector=involvedObject.kind=Service,involvedObject.name=" + service1NameWithUser
-	resp, err := httpHelper.RequestByUser(http.MethodGet, framework.KubecubeHost+url, "", user, nil)
-	framework.ExpectNoError(err)
+func checkServiceEvent(tc *framework.TestContext) framework.TestResp {
+	service1NameWithUser := tc.NameWithUser(service1Name)
+	url := "/api/v1/cube/proxy/clusters/" + tc.TargetClusterName + "/api/v1/namespaces/" + tc.NamespaceName + "/events?fieldSelector=involvedObject.kind=Service,involvedObject.name=" + service1NameWithUser
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodGet, tc.KubecubeHost+url, "", tc.User, nil)
+	tc.ExpectNoError(err)
 	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	if !framework.IsSuccess(resp.StatusCode) {
 		clog.Warn("res code %d", resp.StatusCode)
 		return framework.NewTestResp(errors.New("fail to get svc events"), resp.StatusCode)
 	}
 
-	framework.ExpectEqual(resp.StatusCode, http.StatusOK)
+	tc.ExpectEqual(resp.StatusCode, http.StatusOK)
 
 	var eventList v13.EventList
 	err = json.Unmarshal(body, &eventList)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	return framework.SucceedResp
 }
 
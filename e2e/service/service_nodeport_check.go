@@ -36,29 +36,28 @@ import (
 	"github.com/kubecube-io/kubecube-e2e/e2e/framework"
 )
 
-func createDeployAndServiceForNodeport(user string) framework.TestResp {
-	initParam()
-	deploy1NameWithUser = framework.NameWithUser(deploy1Name, user)
-	service1NameWithUser = framework.NameWithUser(service1Name, user)
+func createDeployAndServiceForNodeport(tc *framework.TestContext) framework.TestResp {
+	deploy1NameWithUser := tc.NameWithUser(deploy1Name)
+	service1NameWithUser := tc.NameWithUser(service1Name)
 	replica := int32(1)
 
-	ns1 = &v13.Namespace{}
-	err := cli.Get(ctx, client.ObjectKey{Name: framework.NamespaceName}, ns1)
+	ns1 := &v13.Namespace{}
+	err := tc.PivotClusterClient.Direct().Get(ctx, client.ObjectKey{Name: tc.NamespaceName}, ns1)
 	if errors.IsNotFound(err) {
-		nsExist = false
+		tc.SetValue(nsCreatedKey, true)
 		ns1 = &v13.Namespace{
 			ObjectMeta: v1.ObjectMeta{
-				Name: framework.NamespaceName,
+				Name: tc.NamespaceName,
 			},
 		}
-		errInfo := cli.Create(ctx, ns1)
-		framework.ExpectNoError(errInfo)
+		errInfo := tc.PivotClusterClient.Direct().Create(ctx, ns1)
+		tc.ExpectNoError(errInfo)
 	}
 
-	deploy1 = &v12.Deployment{
+	deploy1 := &v12.Deployment{
 		ObjectMeta: v1.ObjectMeta{
 			Name:      deploy1NameWithUser,
-			Namespace: framework.NamespaceName,
+			Namespace: tc.NamespaceName,
 		},
 		Spec: v12.DeploymentSpec{
 			Selector: &v1.LabelSelector{
@@ -73,21 +72,21 @@ func createDeployAndServiceForNodeport(user string) framework.TestResp {
 					Containers: []v13.Container{
 						{
 							Name:  "nginx",
-							Image: framework.TestImage,
+							Image: tc.TestImage,
 						},
 					},
-					ImagePullSecrets: []v13.LocalObjectReference{{Name: framework.ImagePullSecret}},
+					ImagePullSecrets: []v13.LocalObjectReference{{Name: tc.ImagePullSecret}},
 				},
 			},
 		},
 	}
-	err = cli.Create(ctx, deploy1)
-	framework.ExpectNoError(err)
+	err = tc.PivotClusterClient.Direct().Create(ctx, deploy1)
+	tc.ExpectNoError(err)
 
-	svc1 = &v13.Service{
+	svc1 := &v13.Service{
 		ObjectMeta: v1.ObjectMeta{
 			Name:      service1NameWithUser,
-			Namespace: framework.NamespaceName,
+			Namespace: tc.NamespaceName,
 		},
 		Spec: v13.ServiceSpec{
 			Selector: map[string]string{"kubecube.io/app": deploy1NameWithUser},
@@ -96,37 +95,42 @@ func createDeployAndServiceForNodeport(user string) framework.TestResp {
 			},
 		},
 	}
-	err = cli.Create(ctx, svc1)
-	framework.ExpectNoError(err)
+	err = tc.PivotClusterClient.Direct().Create(ctx, svc1)
+	tc.ExpectNoError(err)
 	return framework.SucceedResp
 }
 
-func deleteDeployAndServiceForNodeport(user string) framework.TestResp {
-	framework.ExpectNoError(cli.Delete(ctx, deploy1))
-	framework.ExpectNoError(cli.Delete(ctx, svc1))
-	if !nsExist {
-		framework.ExpectNoError(cli.Delete(ctx, ns1))
-		err := wait.Poll(waitInterval, waitTimeout,
+func deleteDeployAndServiceForNodeport(tc *framework.TestContext) framework.TestResp {
+	deploy1 := &v12.Deployment{ObjectMeta: v1.ObjectMeta{Name: tc.NameWithUser(deploy1Name), Namespace: tc.NamespaceName}}
+	svc1 := &v13.Service{ObjectMeta: v1.ObjectMeta{Name: tc.NameWithUser(service1Name), Namespace: tc.NamespaceName}}
+	tc.ExpectNoError(tc.PivotClusterClient.Direct().Delete(ctx, deploy1))
+	tc.ExpectNoError(tc.PivotClusterClient.Direct().Delete(ctx, svc1))
+	if created, _ := tc.Value(nsCreatedKey).(bool); created {
+		ns1 := &v13.Namespace{ObjectMeta: v1.ObjectMeta{Name: tc.NamespaceName}}
+		tc.ExpectNoError(tc.PivotClusterClient.Direct().Delete(ctx, ns1))
+		err := wait.Poll(tc.WaitInterval, tc.WaitTimeout,
 			func() (bool, error) {
 				var namespace v13.Namespace
-				errInfo := cli.Get(ctx, types.NamespacedName{Name: framework.NamespaceName}, &namespace)
+				errInfo := tc.PivotClusterClient.Direct().Get(ctx, types.NamespacedName{Name: tc.NamespaceName}, &namespace)
 				if errors.IsNotFound(errInfo) {
 					return true, nil
 				} else {
 					return false, nil
 				}
 			})
-		framework.ExpectNoError(err)
+		tc.ExpectNoError(err)
 	}
 	return framework.SucceedResp
 }
 
-func checkExternalAccess(user string) framework.TestResp {
-	ginkgo.By("1. 设置对外服务端口80：1111")
-	url := fmt.Sprintf("/api/v1/cube/extend/clusters/%s/namespaces/%s/externalAccess/%s", framework.PivotClusterName, framework.NamespaceName, service1NameWithUser)
-	postJson := fmt.Sprintf("[{\"protocol\":\"TCP\",\"servicePort\":80,\"externalPorts\":[%d]}]", portMap[user])
-	resp, err := httpHelper.RequestByUser(http.MethodPost, framework.KubecubeHost+url, postJson, user, nil)
-	framework.ExpectNoError(err)
+func checkExternalAccess(tc *framework.TestContext) framework.TestResp {
+	service1NameWithUser := tc.NameWithUser(service1Name)
+	port, newPort := nodePorts(tc)
+	ginkgo.By(fmt.Sprintf("1. 设置对外服务端口80：%d", port))
+	url := fmt.Sprintf("/api/v1/cube/extend/clusters/%s/namespaces/%s/externalAccess/%s", tc.PivotClusterName, tc.NamespaceName, service1NameWithUser)
+	postJson := fmt.Sprintf("[{\"protocol\":\"TCP\",\"servicePort\":80,\"externalPorts\":[%d]}]", port)
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodPost, tc.KubecubeHost+url, postJson, tc.User, nil)
+	tc.ExpectNoError(err)
 	defer resp.Body.Close()
 
 	if !framework.IsSuccess(resp.StatusCode) {
@@ -134,27 +138,27 @@ func checkExternalAccess(user string) framework.TestResp {
 		return framework.NewTestResp(fmt.Errorf("fail to update svc %s", service1NameWithUser), resp.StatusCode)
 	}
 
-	framework.ExpectEqual(resp.StatusCode, http.StatusOK)
+	tc.ExpectEqual(resp.StatusCode, http.StatusOK)
 
-	ginkgo.By("2. 在页面提示的访问地址（node ip）中选取一个IP1；登陆到可访问node节点机的部署机器执行：curl http://IP1:1111")
-	url = fmt.Sprintf("/api/v1/cube/extend/clusters/%s/namespaces/%s/externalAccessAddress", framework.PivotClusterName, framework.NamespaceName)
-	resp, err = httpHelper.Get(framework.KubecubeHost+url, nil)
-	framework.ExpectNoError(err)
+	ginkgo.By(fmt.Sprintf("2. 在页面提示的访问地址（node ip）中选取一个IP1；登陆到可访问node节点机的部署机器执行：curl http://IP1:%d", port))
+	url = fmt.Sprintf("/api/v1/cube/extend/clusters/%s/namespaces/%s/externalAccessAddress", tc.PivotClusterName, tc.NamespaceName)
+	resp, err = tc.HttpHelper.Get(tc.KubecubeHost+url, nil)
+	tc.ExpectNoError(err)
 	defer resp.Body.Close()
-	framework.ExpectEqual(resp.StatusCode, http.StatusOK)
+	tc.ExpectEqual(resp.StatusCode, http.StatusOK)
 
 	var result []string
 	body, err := io.ReadAll(resp.Body)
 	clog.Info("externalAccessAddress %s", string(body))
 	err = json.Unmarshal(body, &result)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	var ip string
 	if len(result) > 0 {
 		ip = result[0]
-		url = fmt.Sprintf("http://%s:%d", ip, portMap[user])
-		err = wait.Poll(waitInterval, waitTimeout,
+		url = fmt.Sprintf("http://%s:%d", ip, port)
+		err = wait.Poll(tc.WaitInterval, tc.WaitTimeout,
 			func() (bool, error) {
-				resp, err = httpHelper.Get(url, nil)
+				resp, err = tc.HttpHelper.Get(url, nil)
 				if err == nil && resp.StatusCode == http.StatusOK {
 					defer resp.Body.Close()
 					return true, nil
@@ -164,11 +168,11 @@ func checkExternalAccess(user string) framework.TestResp {
 			})
 	}
 
-	ginkgo.By("3. 更改设置对外服务端口从80：1111到80：1114")
-	url = fmt.Sprintf("/api/v1/cube/extend/clusters/%s/namespaces/%s/externalAccessAddress", framework.PivotClusterName, framework.NamespaceName)
-	postJson = fmt.Sprintf("[{\"protocol\":\"TCP\",\"servicePort\":80,\"externalPorts\":[%d]}]", newportMap[user])
-	resp, err = httpHelper.RequestByUser(http.MethodPost, framework.KubecubeHost+url, postJson, user, nil)
-	framework.ExpectNoError(err)
+	ginkgo.By(fmt.Sprintf("3. 更改设置对外服务端口从80：%d到80：%d", port, newPort))
+	url = fmt.Sprintf("/api/v1/cube/extend/clusters/%s/namespaces/%s/externalAccessAddress", tc.PivotClusterName, tc.NamespaceName)
+	postJson = fmt.Sprintf("[{\"protocol\":\"TCP\",\"servicePort\":80,\"externalPorts\":[%d]}]", newPort)
+	resp, err = tc.HttpHelper.RequestByUser(http.MethodPost, tc.KubecubeHost+url, postJson, tc.User, nil)
+	tc.ExpectNoError(err)
 	defer resp.Body.Close()
 
 	if !framework.IsSuccess(resp.StatusCode) {
@@ -176,12 +180,12 @@ func checkExternalAccess(user string) framework.TestResp {
 		return framework.NewTestResp(fmt.Errorf("fail to update externalAccessAddress"), resp.StatusCode)
 	}
 
-	framework.ExpectEqual(resp.StatusCode, http.StatusOK)
+	tc.ExpectEqual(resp.StatusCode, http.StatusOK)
 
-	url = fmt.Sprintf("http://%s:%d", ip, portMap[user])
-	err = wait.Poll(waitInterval, waitTimeout,
+	url = fmt.Sprintf("http://%s:%d", ip, port)
+	err = wait.Poll(tc.WaitInterval, tc.WaitTimeout,
 		func() (bool, error) {
-			resp, err = httpHelper.Get(url, nil)
+			resp, err = tc.HttpHelper.Get(url, nil)
 			if err != nil {
 				return true, nil
 			} else {
@@ -190,10 +194,10 @@ func checkExternalAccess(user string) framework.TestResp {
 			}
 		})
 
-	url = fmt.Sprintf("http://%s:%d", ip, newportMap[user])
-	err = wait.Poll(waitInterval, waitTimeout,
+	url = fmt.Sprintf("http://%s:%d", ip, newPort)
+	err = wait.Poll(tc.WaitInterval, tc.WaitTimeout,
 		func() (bool, error) {
-			resp, err = httpHelper.Get(url, nil)
+			resp, err = tc.HttpHelper.Get(url, nil)
 			if err == nil && resp.StatusCode == http.StatusOK {
 				defer resp.Body.Close()
 				return true, nil
@@ -203,10 +207,10 @@ func checkExternalAccess(user string) framework.TestResp {
 		})
 
 	ginkgo.By("4. 更改设置对外服务端口从on到off")
-	url = fmt.Sprintf("/api/v1/cube/extend/clusters/%s/namespaces/%s/externalAccessAddress", framework.PivotClusterName, framework.NamespaceName)
+	url = fmt.Sprintf("/api/v1/cube/extend/clusters/%s/namespaces/%s/externalAccessAddress", tc.PivotClusterName, tc.NamespaceName)
 	postJson = "[{protocol: \"TCP\", servicePort: 80}]"
-	resp, err = httpHelper.RequestByUser(http.MethodPost, framework.KubecubeHost+url, postJson, user, nil)
-	framework.ExpectNoError(err)
+	resp, err = tc.HttpHelper.RequestByUser(http.MethodPost, tc.KubecubeHost+url, postJson, tc.User, nil)
+	tc.ExpectNoError(err)
 	defer resp.Body.Close()
 
 	if !framework.IsSuccess(resp.StatusCode) {
@@ -214,12 +218,12 @@ func checkExternalAccess(user string) framework.TestResp {
 		return framework.NewTestResp(fmt.Errorf("fail to update externalAccessAddress"), resp.StatusCode)
 	}
 
-	framework.ExpectEqual(resp.StatusCode, http.StatusOK)
+	tc.ExpectEqual(resp.StatusCode, http.StatusOK)
 
-	url = fmt.Sprintf("http://%s:%d", ip, newportMap[user])
-	err = wait.Poll(waitInterval, waitTimeout,
+	url = fmt.Sprintf("http://%s:%d", ip, newPort)
+	err = wait.Poll(tc.WaitInterval, tc.WaitTimeout,
 		func() (bool, error) {
-			resp, err = httpHelper.Get(url, nil)
+			resp, err = tc.HttpHelper.Get(url, nil)
 			if err != nil {
 				return true, nil
 			} else {
@@ -18,65 +18,43 @@ package service
 
 import (
 	"context"
-	"time"
-
-	v12 "k8s.io/api/apps/v1"
-	v13 "k8s.io/api/core/v1"
-	networkingv1 "k8s.io/api/networking/v1"
-	"sigs.k8s.io/controller-runtime/pkg/client"
+	"fmt"
 
 	"github.com/kubecube-io/kubecube-e2e/e2e/framework"
 )
 
-var (
-	ctx          = context.Background()
-	deploy1      *v12.Deployment
-	deploy2      *v12.Deployment
-	svc1         *v13.Service
-	ingress1     *networkingv1.Ingress
-	ns1          *v13.Namespace
-	httpHelper   *framework.HttpHelper
-	waitInterval time.Duration
-	waitTimeout  time.Duration
-	cli          client.Client
-
-	nsExist = true
+const (
+	deploy1Name  = "deploy1"
+	deploy2Name  = "deploy2"
+	service1Name = "service1"
+	service2Name = "service2"
+	ingress1Name = "ingress"
 
-	deploy1Name         = "deploy1"
-	deploy2Name         = "deploy2"
-	deploy1NameWithUser string
-	deploy2NameWithUser string
+	host = "test.e2e.%s.svc"
 
-	service1Name         = "service1"
-	service1NameWithUser string
-	service2Name         = "service2"
-	service2NameWithUser string
-
-	host         = "test.e2e.%s.svc"
-	hostWithName string
+	// nsCreatedKey 标记 nodeport 测试是否自行创建了 namespace
+	nsCreatedKey = "nsCreated"
+)
 
-	ingress1Name         = "ingress"
-	ingress1NameWithUser string
+var ctx = context.Background()
 
-	portMap = map[string]int{
-		framework.UserAdmin:        1111,
-		framework.UserProjectAdmin: 1121,
-		framework.UserTenantAdmin:  1131,
-		framework.UserNormal:       1141,
-	}
-	newportMap = map[string]int{
-		framework.UserAdmin:        1114,
-		framework.UserProjectAdmin: 1124,
-		framework.UserTenantAdmin:  1134,
-		framework.UserNormal:       1144,
-	}
+// nodeport 测试的对外端口按角色在配置中的顺序分配，每个角色占用 nodePortStep 个端口，避免并发的角色互相占用
+const (
+	nodePortBase   = 1111
+	nodePortStep   = 10
+	nodePortUpdate = 3
 )
 
-func initParam() {
-	httpHelper = framework.NewSingleHttpHelper()
-	waitInterval = framework.WaitInterval
-	waitTimeout = framework.WaitTimeout
-	cli = framework.PivotClusterClient.Direct()
+// nodePorts 返回当前角色设置的对外端口以及更改后的端口
+func nodePorts(tc *framework.TestContext) (port, newPort int) {
+	for i, name := range tc.Roles.Names() {
+		if name == tc.Role {
+			port = nodePortBase + nodePortStep*i
+			return port, port + nodePortUpdate
+		}
+	}
+	tc.ExpectNoError(fmt.Errorf("role %s is not configured", tc.Role))
+	return 0, 0
 }
 
 func init() {
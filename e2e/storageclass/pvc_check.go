@@ -22,9 +22,9 @@ import (
 	"io"
 	"net/http"
 
+	"github.com/kubecube-io/kubecube-e2e/e2e/fixtures"
 	"github.com/kubecube-io/kubecube-e2e/e2e/framework"
 	"github.com/kubecube-io/kubecube/pkg/clog"
-	"github.com/kubecube-io/kubecube/pkg/multicluster/client"
 
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -32,44 +32,24 @@ import (
 	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-var (
-	clusterName string
-	httpHelper  *framework.HttpHelper
-	namespace   string
-	cli         client.Client
+const (
+	pvc1Name = "demo1"
+	pvc2Name = "demo2"
+	podName  = "task-pv-storage"
 
-	pvc1Name         = "demo1"
-	pvc1NameWithUser string
-
-	pvc2Name         = "demo2"
-	pvc2NameWithUser string
-
-	podName         = "task-pv-storage"
-	podNameWithUser string
-
-	PV string
+	// pvKey 保存 pvc1 绑定的 pv 名，供删除步骤使用
+	pvKey = "pv"
 )
 
-func initParam() {
-	clusterName = framework.TargetClusterName
-	httpHelper = framework.NewSingleHttpHelper()
-	namespace = framework.NamespaceName
-	cli = framework.TargetClusterClient
-}
-
-func createPVC1(user string) framework.TestResp {
-	initParam()
-	pvc1NameWithUser = framework.NameWithUser(pvc1Name, user)
-	pvc2NameWithUser = framework.NameWithUser(pvc2Name, user)
-	podNameWithUser = framework.NameWithUser(podName, user)
-	postJsonOfCreatePVC := `{"apiVersion":"v1","kind":"PersistentVolumeClaim","metadata":{"finalizers":["kubernetes.io/pvc-protection"],"name":"%s","namespace":"%s"},"spec":{"accessModes":["ReadWriteOnce"],"resources":{"requests":{"storage":"10Gi"}},"storageClassName":"%s","volumeMode":"Filesystem"}}`
-	postJsonOfCreatePVC = fmt.Sprintf(postJsonOfCreatePVC, pvc1NameWithUser, namespace, framework.StorageClass)
+func createPVC1(tc *framework.TestContext) framework.TestResp {
+	pvc1NameWithUser := tc.NameWithUser(pvc1Name)
+	postJsonOfCreatePVC := fixtures.MustRender(tc, "pvc.yaml", fixtures.For(tc, pvc1NameWithUser).With("accessMode", "ReadWriteOnce").With("storage", "10Gi"))
 	urlOfCreatePVC := "%s/api/v1/cube/proxy/clusters/%s/api/v1/namespaces/%s/persistentvolumeclaims"
-	urlOfCreatePVC = fmt.Sprintf(urlOfCreatePVC, framework.KubecubeHost, clusterName, namespace)
-	respOfCreatePVC, err := httpHelper.RequestByUser(http.MethodPost, urlOfCreatePVC, postJsonOfCreatePVC, user, nil)
+	urlOfCreatePVC = fmt.Sprintf(urlOfCreatePVC, tc.KubecubeHost, tc.TargetClusterName, tc.NamespaceName)
+	respOfCreatePVC, err := tc.HttpHelper.RequestByUser(http.MethodPost, urlOfCreatePVC, postJsonOfCreatePVC, tc.User, nil)
 	defer respOfCreatePVC.Body.Close()
 	body, err := io.ReadAll(respOfCreatePVC.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	clog.Info("get pvc1: %+v", string(body))
 
 	if !framework.IsSuccess(respOfCreatePVC.StatusCode) {
@@ -78,24 +58,24 @@ func createPVC1(user string) framework.TestResp {
 	}
 
 	checkOfCreatePVC := &v1.PersistentVolumeClaim{}
-	err = cli.Direct().Get(context.Background(), ctrlclient.ObjectKey{
-		Namespace: namespace,
+	err = tc.TargetClusterClient.Direct().Get(context.Background(), ctrlclient.ObjectKey{
+		Namespace: tc.NamespaceName,
 		Name:      pvc1NameWithUser,
 	}, checkOfCreatePVC)
-	framework.ExpectNoError(err, "new pvc should be created")
+	tc.ExpectNoError(err, "new pvc should be created")
 
 	return framework.SucceedResp
 }
 
-func createPVC2(user string) framework.TestResp {
-	postJsonOfCreatePVC := `{"apiVersion":"v1","kind":"PersistentVolumeClaim","metadata":{"finalizers":["kubernetes.io/pvc-protection"],"name":"%s","namespace":"%s"},"spec":{"accessModes":["ReadOnlyMany"],"resources":{"requests":{"storage":"20Gi"}},"storageClassName":"%s","volumeMode":"Filesystem"}}`
-	postJsonOfCreatePVC = fmt.Sprintf(postJsonOfCreatePVC, pvc2NameWithUser, namespace, framework.StorageClass)
+func createPVC2(tc *framework.TestContext) framework.TestResp {
+	pvc2NameWithUser := tc.NameWithUser(pvc2Name)
+	postJsonOfCreatePVC := fixtures.MustRender(tc, "pvc.yaml", fixtures.For(tc, pvc2NameWithUser).With("accessMode", "ReadOnlyMany").With("storage", "20Gi"))
 	urlOfCreatePVC := "%s/api/v1/cube/proxy/clusters/%s/api/v1/namespaces/%s/persistentvolumeclaims"
-	urlOfCreatePVC = fmt.Sprintf(urlOfCreatePVC, framework.KubecubeHost, clusterName, namespace)
-	respOfCreatePVC, err := httpHelper.RequestByUser(http.MethodPost, urlOfCreatePVC, postJsonOfCreatePVC, user, nil)
+	urlOfCreatePVC = fmt.Sprintf(urlOfCreatePVC, tc.KubecubeHost, tc.TargetClusterName, tc.NamespaceName)
+	respOfCreatePVC, err := tc.HttpHelper.RequestByUser(http.MethodPost, urlOfCreatePVC, postJsonOfCreatePVC, tc.User, nil)
 	defer respOfCreatePVC.Body.Close()
 	body, err := io.ReadAll(respOfCreatePVC.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	clog.Info("get pvc2: %+v", string(body))
 
 	if !framework.IsSuccess(respOfCreatePVC.StatusCode) {
@@ -104,24 +84,25 @@ func createPVC2(user string) framework.TestResp {
 	}
 
 	checkOfCreatePVC := &v1.PersistentVolumeClaim{}
-	err = cli.Direct().Get(context.Background(), ctrlclient.ObjectKey{
-		Namespace: namespace,
+	err = tc.TargetClusterClient.Direct().Get(context.Background(), ctrlclient.ObjectKey{
+		Namespace: tc.NamespaceName,
 		Name:      pvc2NameWithUser,
 	}, checkOfCreatePVC)
-	framework.ExpectNoError(err, "new pvc should be created")
+	tc.ExpectNoError(err, "new pvc should be created")
 
 	return framework.SucceedResp
 }
 
-func createPod(user string) framework.TestResp {
-	postJsonOfCreatePod := `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"%s","namespace":"%s"},"spec":{"volumes":[{"name":"task-pv-storage","persistentVolumeClaim":{"claimName":"%s"}}],"imagePullSecrets": [{"name": "%s"}],"affinity":{"nodeAffinity":{"requiredDuringSchedulingIgnoredDuringExecution":{"nodeSelectorTerms":[{"matchExpressions":[{"key":"node.kubecube.io/tenant","operator":"In","values":["share"]}]}]}}},"containers":[{"name":"task-pv-container","image":"%s","command":[],"resources":{"limits":{"cpu":"5000m","memory":"5120Mi"},"requests":{"cpu":"500m","memory":"512Mi"}},"volumeMounts":[{"mountPath":"/root/test","name":"task-pv-storage"}]}]}}`
-	postJsonOfCreatePod = fmt.Sprintf(postJsonOfCreatePod, podNameWithUser, namespace, pvc1NameWithUser, framework.ImagePullSecret, framework.TestImage)
+func createPod(tc *framework.TestContext) framework.TestResp {
+	pvc1NameWithUser := tc.NameWithUser(pvc1Name)
+	podNameWithUser := tc.NameWithUser(podName)
+	postJsonOfCreatePod := fixtures.MustRender(tc, "pod-with-pvc.yaml", fixtures.For(tc, podNameWithUser).With("claim", pvc1NameWithUser))
 	urlOfCreatePod := "%s/api/v1/cube/proxy/clusters/%s/api/v1/namespaces/%s/pods"
-	urlOfCreatePod = fmt.Sprintf(urlOfCreatePod, framework.KubecubeHost, clusterName, namespace)
-	respOfCreatePod, err := httpHelper.RequestByUser(http.MethodPost, urlOfCreatePod, postJsonOfCreatePod, user, nil)
+	urlOfCreatePod = fmt.Sprintf(urlOfCreatePod, tc.KubecubeHost, tc.TargetClusterName, tc.NamespaceName)
+	respOfCreatePod, err := tc.HttpHelper.RequestByUser(http.MethodPost, urlOfCreatePod, postJsonOfCreatePod, tc.User, nil)
 	defer respOfCreatePod.Body.Close()
 	body, err := io.ReadAll(respOfCreatePod.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	clog.Info("get pod task-pv-pod: %+v", string(body))
 
 	if !framework.IsSuccess(respOfCreatePod.StatusCode) {
@@ -129,10 +110,10 @@ func createPod(user string) framework.TestResp {
 		return framework.NewTestResp(fmt.Errorf("fail to create pod %s", podNameWithUser), respOfCreatePod.StatusCode)
 	}
 
-	err = wait.Poll(framework.WaitInterval, framework.WaitTimeout, func() (done bool, err error) {
+	err = wait.Poll(tc.WaitInterval, tc.WaitTimeout, func() (done bool, err error) {
 		checkOfCreatePVC := &v1.PersistentVolumeClaim{}
-		err = cli.Direct().Get(context.Background(), ctrlclient.ObjectKey{
-			Namespace: namespace,
+		err = tc.TargetClusterClient.Direct().Get(context.Background(), ctrlclient.ObjectKey{
+			Namespace: tc.NamespaceName,
 			Name:      pvc1NameWithUser,
 		}, checkOfCreatePVC)
 		if err != nil {
@@ -143,12 +124,12 @@ func createPod(user string) framework.TestResp {
 		}
 		return true, nil
 	})
-	framework.ExpectNoError(err, "pvc should be created")
+	tc.ExpectNoError(err, "pvc should be created")
 
-	err = wait.Poll(framework.WaitInterval, framework.WaitTimeout, func() (done bool, err error) {
+	err = wait.Poll(tc.WaitInterval, tc.WaitTimeout, func() (done bool, err error) {
 		checkOfCreatePod := &v1.Pod{}
-		err = cli.Direct().Get(context.Background(), ctrlclient.ObjectKey{
-			Namespace: namespace,
+		err = tc.TargetClusterClient.Direct().Get(context.Background(), ctrlclient.ObjectKey{
+			Namespace: tc.NamespaceName,
 			Name:      podNameWithUser,
 		}, checkOfCreatePod)
 		if err != nil {
@@ -157,27 +138,30 @@ func createPod(user string) framework.TestResp {
 		return true, nil
 
 	})
-	framework.ExpectNoError(err, "pod should be created")
+	tc.ExpectNoError(err, "pod should be created")
 
 	checkOfPVList := &v1.PersistentVolumeList{}
-	err = cli.Direct().List(context.Background(), checkOfPVList, &ctrlclient.ListOptions{Namespace: namespace})
+	err = tc.TargetClusterClient.Direct().List(context.Background(), checkOfPVList, &ctrlclient.ListOptions{Namespace: tc.NamespaceName})
+	var pv string
 	for _, item := range checkOfPVList.Items {
 		if item.Spec.ClaimRef.Name == pvc1NameWithUser {
-			PV = checkOfPVList.Items[0].Name
+			pv = checkOfPVList.Items[0].Name
 		}
 	}
-	clog.Info("Pv Got %s", PV)
+	clog.Info("Pv Got %s", pv)
+	tc.SetValue(pvKey, pv)
 
 	return framework.SucceedResp
 }
 
-func deletePod(user string) framework.TestResp {
+func deletePod(tc *framework.TestContext) framework.TestResp {
+	podNameWithUser := tc.NameWithUser(podName)
 	urlOfDeletePod := "%s/api/v1/cube/proxy/clusters/%s/api/v1/namespaces/%s/pods/%s"
-	urlOfDeletePod = fmt.Sprintf(urlOfDeletePod, framework.KubecubeHost, clusterName, namespace, podNameWithUser)
-	respOfDeletePod, err := httpHelper.RequestByUser(http.MethodDelete, urlOfDeletePod, "", user, nil)
+	urlOfDeletePod = fmt.Sprintf(urlOfDeletePod, tc.KubecubeHost, tc.TargetClusterName, tc.NamespaceName, podNameWithUser)
+	respOfDeletePod, err := tc.HttpHelper.RequestByUser(http.MethodDelete, urlOfDeletePod, "", tc.User, nil)
 	defer respOfDeletePod.Body.Close()
 	body, err := io.ReadAll(respOfDeletePod.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	clog.Info("delete pod: %+v", string(body))
 
 	if !framework.IsSuccess(respOfDeletePod.StatusCode) {
@@ -185,10 +169,10 @@ func deletePod(user string) framework.TestResp {
 		return framework.NewTestResp(fmt.Errorf("fail to delete pod %s", podNameWithUser), respOfDeletePod.StatusCode)
 	}
 
-	err = wait.Poll(framework.WaitInterval, framework.WaitTimeout, func() (done bool, err error) {
+	err = wait.Poll(tc.WaitInterval, tc.WaitTimeout, func() (done bool, err error) {
 		checkOfDeletePod := &v1.Pod{}
-		err = cli.Direct().Get(context.Background(), ctrlclient.ObjectKey{
-			Namespace: namespace,
+		err = tc.TargetClusterClient.Direct().Get(context.Background(), ctrlclient.ObjectKey{
+			Namespace: tc.NamespaceName,
 			Name:      podNameWithUser,
 		}, checkOfDeletePod)
 		if !errors.IsNotFound(err) {
@@ -196,15 +180,17 @@ func deletePod(user string) framework.TestResp {
 		}
 		return true, nil
 	})
-	framework.ExpectNoError(err, "pod should be delete")
+	tc.ExpectNoError(err, "pod should be delete")
 
 	return framework.SucceedResp
 }
 
-func deletePvc(user string) framework.TestResp {
+func deletePvc(tc *framework.TestContext) framework.TestResp {
+	pvc1NameWithUser := tc.NameWithUser(pvc1Name)
+	pvc2NameWithUser := tc.NameWithUser(pvc2Name)
 	urlOfDeletePVC := "%s/api/v1/cube/proxy/clusters/%s/api/v1/namespaces/%s/persistentvolumeclaims/%s"
-	urlOfDeletePVC = fmt.Sprintf(urlOfDeletePVC, framework.KubecubeHost, clusterName, namespace, pvc1NameWithUser)
-	respOfDeletePVC, err := httpHelper.RequestByUser(http.MethodDelete, urlOfDeletePVC, "", user, nil)
+	urlOfDeletePVC = fmt.Sprintf(urlOfDeletePVC, tc.KubecubeHost, tc.TargetClusterName, tc.NamespaceName, pvc1NameWithUser)
+	respOfDeletePVC, err := tc.HttpHelper.RequestByUser(http.MethodDelete, urlOfDeletePVC, "", tc.User, nil)
 	defer respOfDeletePVC.Body.Close()
 	body, err := io.ReadAll(respOfDeletePVC.Body)
 	clog.Info("delete pvc1: %+v", string(body))
@@ -215,11 +201,11 @@ func deletePvc(user string) framework.TestResp {
 	}
 
 	urlOfDeletePVC = "%s/api/v1/cube/proxy/clusters/%s/api/v1/namespaces/%s/persistentvolumeclaims/%s"
-	urlOfDeletePVC = fmt.Sprintf(urlOfDeletePVC, framework.KubecubeHost, clusterName, namespace, pvc2NameWithUser)
-	respOfDeletePVC, err = httpHelper.RequestByUser(http.MethodDelete, urlOfDeletePVC, "", user, nil)
+	urlOfDeletePVC = fmt.Sprintf(urlOfDeletePVC, tc.KubecubeHost, tc.TargetClusterName, tc.NamespaceName, pvc2NameWithUser)
+	respOfDeletePVC, err = tc.HttpHelper.RequestByUser(http.MethodDelete, urlOfDeletePVC, "", tc.User, nil)
 	defer respOfDeletePVC.Body.Close()
 	body, err = io.ReadAll(respOfDeletePVC.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	clog.Info("delete pvc2: %+v", string(body))
 
 	if !framework.IsSuccess(respOfDeletePVC.StatusCode) {
@@ -227,10 +213,10 @@ func deletePvc(user string) framework.TestResp {
 		return framework.NewTestResp(fmt.Errorf("fail to delete pvc %s", pvc2NameWithUser), respOfDeletePVC.StatusCode)
 	}
 
-	err = wait.Poll(framework.WaitInterval, framework.WaitTimeout, func() (done bool, err error) {
+	err = wait.Poll(tc.WaitInterval, tc.WaitTimeout, func() (done bool, err error) {
 		checkOfDeletePVC := &v1.PersistentVolumeClaim{}
-		err = cli.Direct().Get(context.Background(), ctrlclient.ObjectKey{
-			Namespace: namespace,
+		err = tc.TargetClusterClient.Direct().Get(context.Background(), ctrlclient.ObjectKey{
+			Namespace: tc.NamespaceName,
 			Name:      pvc1NameWithUser,
 		}, checkOfDeletePVC)
 		if !errors.IsNotFound(err) {
@@ -238,12 +224,12 @@ func deletePvc(user string) framework.TestResp {
 		}
 		return true, nil
 	})
-	framework.ExpectNoError(err, "pvc1 should be deleted")
+	tc.ExpectNoError(err, "pvc1 should be deleted")
 
-	err = wait.Poll(framework.WaitInterval, framework.WaitTimeout, func() (done bool, err error) {
+	err = wait.Poll(tc.WaitInterval, tc.WaitTimeout, func() (done bool, err error) {
 		checkOfDeletePVC := &v1.PersistentVolumeClaim{}
-		err = cli.Direct().Get(context.Background(), ctrlclient.ObjectKey{
-			Namespace: namespace,
+		err = tc.TargetClusterClient.Direct().Get(context.Background(), ctrlclient.ObjectKey{
+			Namespace: tc.NamespaceName,
 			Name:      pvc2NameWithUser,
 		}, checkOfDeletePVC)
 		if !errors.IsNotFound(err) {
@@ -251,20 +237,21 @@ func deletePvc(user string) framework.TestResp {
 		}
 		return true, nil
 	})
-	framework.ExpectNoError(err, "pvc2 should be deleted")
+	tc.ExpectNoError(err, "pvc2 should be deleted")
 
 	return framework.SucceedResp
 }
 
-func deletePv(user string) framework.TestResp {
+func deletePv(tc *framework.TestContext) framework.TestResp {
 	urlOfDeletePV := "%s/api/v1/cube/proxy/clusters/%s/api/v1/namespaces/%s/persistentvolume/%s"
-	urlOfDeletePV = fmt.Sprintf(urlOfDeletePV, framework.KubecubeHost, clusterName, namespace, PV)
-	clog.Info("delete pv %s", PV)
-	respOfDeletePV, err := httpHelper.RequestByUser(http.MethodDelete, urlOfDeletePV, "", user, nil)
+	pv, _ := tc.Value(pvKey).(string)
+	urlOfDeletePV = fmt.Sprintf(urlOfDeletePV, tc.KubecubeHost, tc.TargetClusterName, tc.NamespaceName, pv)
+	clog.Info("delete pv %s", pv)
+	respOfDeletePV, err := tc.HttpHelper.RequestByUser(http.MethodDelete, urlOfDeletePV, "", tc.User, nil)
 	defer respOfDeletePV.Body.Close()
 	body, err := io.ReadAll(respOfDeletePV.Body)
 	clog.Info("delete pv %s", string(body))
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 
 	return framework.SucceedResp
 }
@@ -272,14 +259,12 @@ func deletePv(user string) framework.TestResp {
 var multiUserTest = framework.MultiUserTest{
 	TestName:        "[存储][9387658]存储声明创建检查",
 	ContinueIfError: false,
-	Skipfunc: func() bool {
-		return !framework.PVEnabled
-	},
-	ErrorFunc:  framework.PermissionErrorFunc,
-	AfterEach:  nil,
-	BeforeEach: nil,
-	InitStep:   nil,
-	FinalStep:  nil,
+	Capabilities:    []string{framework.CapabilityPV},
+	ErrorFunc:       framework.PermissionErrorFunc,
+	AfterEach:       nil,
+	BeforeEach:      nil,
+	InitStep:        nil,
+	FinalStep:       nil,
 	Steps: []framework.MultiUserTestStep{
 		{
 			Name:        "create PVC1",
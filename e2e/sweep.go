@@ -0,0 +1,330 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/multicluster"
+	mcclient "github.com/kubecube-io/kubecube/pkg/multicluster/client"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubecube-io/kubecube-e2e/e2e/framework"
+)
+
+// stuckAfter 命名空间处于 Terminating 超过该时间时强制移除 finalizer
+const stuckAfter = 5 * time.Minute
+
+// sweepLabels e2e 为创建的对象打上的标签，存在任一标签的对象都视为 e2e 的产物
+var sweepLabels = append(append([]string{}, framework.ArtifactLabels...), "kubecube-e2e-config", leaseLabel, resultLabel)
+
+// sweepSkipResources 不会被 e2e 打标签、数量较多的资源，查找时跳过
+var sweepSkipResources = sets.New("events", "events.events.k8s.io")
+
+// SweepOptions 孤儿资源清理的参数
+type SweepOptions struct {
+	// DryRun 只输出清理计划，不删除
+	DryRun bool
+	// OlderThan 只清理创建时间早于该时长的对象，为 0 时不限制
+	OlderThan time.Duration
+	// Clusters 只清理这些集群，为空时清理管控集群与所有成员集群
+	Clusters []string
+	// Out 清理计划的输出
+	Out io.Writer
+}
+
+// orphan e2e 遗留的对象
+type orphan struct {
+	cluster   string
+	gvk       schema.GroupVersionKind
+	namespace string
+	name      string
+	label     string
+	created   time.Time
+	// deleting 开始删除的时间，未处于删除中时为零值
+	deleting time.Time
+}
+
+func (o orphan) String() string {
+	name := o.name
+	if len(o.namespace) > 0 {
+		name = o.namespace + "/" + name
+	}
+	return o.gvk.Kind + " " + name
+}
+
+func (o orphan) object() *metav1.PartialObjectMetadata {
+	obj := &metav1.PartialObjectMetadata{}
+	obj.SetGroupVersionKind(o.gvk)
+	obj.SetNamespace(o.namespace)
+	obj.SetName(o.name)
+	return obj
+}
+
+// stuck 处于 Terminating 超过 stuckAfter 的命名空间
+func (o orphan) stuck(now time.Time) bool {
+	return o.gvk.Kind == "Namespace" && o.gvk.Group == "" && !o.deleting.IsZero() && now.Sub(o.deleting) > stuckAfter
+}
+
+// deleteOrder 先删除命名空间中的对象，再删除集群级别的对象，最后删除命名空间
+func (o orphan) deleteOrder() int {
+	switch {
+	case len(o.namespace) > 0:
+		return 0
+	case o.gvk.Kind != "Namespace" || o.gvk.Group != "":
+		return 1
+	}
+	return 2
+}
+
+// Sweep 按 e2e 标签查找管控集群与成员集群中遗留的对象，输出清理计划后删除，
+// 并强制移除长时间处于 Terminating 的命名空间的 finalizer
+func Sweep(opts SweepOptions) error {
+	if err := framework.StartMultiCluster(); err != nil {
+		return err
+	}
+	clusters, err := sweepClusters(opts.Clusters)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var (
+		orphans []orphan
+		errs    []error
+	)
+	for _, name := range sortedKeys(clusters) {
+		found, err := findOrphans(name, clusters[name], opts.OlderThan, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cluster %s: %v", name, err))
+		}
+		orphans = append(orphans, found...)
+	}
+	sort.SliceStable(orphans, func(i, j int) bool {
+		if orphans[i].cluster != orphans[j].cluster {
+			return orphans[i].cluster < orphans[j].cluster
+		}
+		return orphans[i].deleteOrder() < orphans[j].deleteOrder()
+	})
+	printSweepPlan(opts, orphans, len(clusters), now)
+	if opts.DryRun {
+		return utilerrors.NewAggregate(errs)
+	}
+
+	for _, o := range orphans {
+		cli := clusters[o.cluster]
+		if o.deleting.IsZero() {
+			err := cli.Direct().Delete(context.Background(), o.object(), client.PropagationPolicy(metav1.DeletePropagationBackground))
+			if err != nil && !kerrors.IsNotFound(err) {
+				errs = append(errs, fmt.Errorf("delete %s in cluster %s failed: %v", o, o.cluster, err))
+				continue
+			}
+			clog.Info("deleted %s in cluster %s", o, o.cluster)
+		}
+		if o.stuck(now) {
+			if err := finalizeNamespace(cli, o.name); err != nil {
+				errs = append(errs, fmt.Errorf("finalize namespace %s in cluster %s failed: %v", o.name, o.cluster, err))
+				continue
+			}
+			clog.Info("removed finalizers of namespace %s in cluster %s", o.name, o.cluster)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// sweepClusters 返回需要清理的集群，names 中包含未纳管的集群时返回错误
+func sweepClusters(names []string) (map[string]mcclient.Client, error) {
+	all := make(map[string]mcclient.Client)
+	for name := range multicluster.Interface().FuzzyCopy() {
+		cli, err := multicluster.Interface().GetClient(name)
+		if err != nil {
+			clog.Warn("skip cluster %s: %v", name, err)
+			continue
+		}
+		all[name] = cli
+	}
+	if len(names) == 0 {
+		return all, nil
+	}
+	clusters := make(map[string]mcclient.Client)
+	for _, name := range names {
+		cli, ok := all[name]
+		if !ok {
+			return nil, fmt.Errorf("cluster %s is not managed by kubecube", name)
+		}
+		clusters[name] = cli
+	}
+	return clusters, nil
+}
+
+// findOrphans 查找集群中带有 e2e 标签且创建时间早于 olderThan 的对象
+func findOrphans(cluster string, cli mcclient.Client, olderThan time.Duration, now time.Time) ([]orphan, error) {
+	lists, err := cli.Discovery().ServerPreferredResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, err
+	}
+	if err != nil {
+		clog.Warn("discover some groups of cluster %s failed: %v", cluster, err)
+	}
+
+	var orphans []orphan
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, r := range list.APIResources {
+			resource := r.Name
+			if len(gv.Group) > 0 {
+				resource += "." + gv.Group
+			}
+			verbs := sets.New(r.Verbs...)
+			if strings.Contains(r.Name, "/") || sweepSkipResources.Has(resource) || !verbs.HasAll("list", "delete") {
+				continue
+			}
+
+			found, err := listLabelled(cli.Direct(), gv.WithKind(r.Kind))
+			if err != nil {
+				clog.Warn("list %s in cluster %s failed: %v", resource, cluster, err)
+				continue
+			}
+			for _, o := range found {
+				if now.Sub(o.created) < olderThan {
+					continue
+				}
+				o.cluster = cluster
+				orphans = append(orphans, o)
+			}
+		}
+	}
+	return orphans, nil
+}
+
+// listLabelled 对每个 e2e 标签以 Exists 选择器各查询一次，只读取元数据，
+// 同时带有多个标签的对象只返回一次，label 取 sweepLabels 中靠前的标签
+func listLabelled(cli client.Client, gvk schema.GroupVersionKind) ([]orphan, error) {
+	seen := sets.New[types.NamespacedName]()
+	var orphans []orphan
+	for _, label := range sweepLabels {
+		objs := &metav1.PartialObjectMetadataList{}
+		objs.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		if err := cli.List(context.Background(), objs, client.HasLabels{label}); err != nil {
+			return nil, err
+		}
+		for _, obj := range objs.Items {
+			key := types.NamespacedName{Namespace: obj.Namespace, Name: obj.Name}
+			if seen.Has(key) {
+				continue
+			}
+			seen.Insert(key)
+			o := orphan{
+				gvk:       gvk,
+				namespace: obj.Namespace,
+				name:      obj.Name,
+				label:     label,
+				created:   obj.CreationTimestamp.Time,
+			}
+			if obj.DeletionTimestamp != nil {
+				o.deleting = obj.DeletionTimestamp.Time
+			}
+			orphans = append(orphans, o)
+		}
+	}
+	return orphans, nil
+}
+
+// finalizeNamespace 移除命名空间的 finalizer，使 Terminating 的命名空间完成删除
+func finalizeNamespace(cli mcclient.Client, name string) error {
+	ctx := context.Background()
+	ns := &v1.Namespace{}
+	err := cli.Direct().Get(ctx, types.NamespacedName{Name: name}, ns)
+	if kerrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(ns.Finalizers) > 0 {
+		patch := client.RawPatch(types.MergePatchType, []byte(`{"metadata":{"finalizers":null}}`))
+		if err = cli.Direct().Patch(ctx, ns, patch); err != nil {
+			return err
+		}
+	}
+	if len(ns.Spec.Finalizers) > 0 {
+		ns.Spec.Finalizers = nil
+		_, err = cli.ClientSet().CoreV1().Namespaces().Finalize(ctx, ns, metav1.UpdateOptions{})
+		if kerrors.IsNotFound(err) {
+			return nil
+		}
+	}
+	return err
+}
+
+// printSweepPlan 输出清理计划，按集群列出待删除的对象
+func printSweepPlan(opts SweepOptions, orphans []orphan, clusters int, now time.Time) {
+	if opts.Out == nil {
+		return
+	}
+	age := "any age"
+	if opts.OlderThan > 0 {
+		age = "older than " + opts.OlderThan.String()
+	}
+	mode := "delete"
+	if opts.DryRun {
+		mode = "dry run"
+	}
+	fmt.Fprintf(opts.Out, "sweep plan (%s), %d e2e objects %s in %d clusters\n", mode, len(orphans), age, clusters)
+
+	cluster := ""
+	for _, o := range orphans {
+		if o.cluster != cluster {
+			cluster = o.cluster
+			fmt.Fprintf(opts.Out, "\n%s\n", cluster)
+		}
+		fmt.Fprintf(opts.Out, "  - %s, label %s, age %v", o, o.label, now.Sub(o.created).Truncate(time.Second))
+		if !o.deleting.IsZero() {
+			fmt.Fprintf(opts.Out, ", terminating for %v", now.Sub(o.deleting).Truncate(time.Second))
+		}
+		if o.stuck(now) {
+			fmt.Fprint(opts.Out, ", force remove finalizers")
+		}
+		fmt.Fprintln(opts.Out)
+	}
+}
+
+func sortedKeys(m map[string]mcclient.Client) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,267 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tenantquota
+
+import (
+	"context"
+	"fmt"
+
+	quotav1 "github.com/kubecube-io/kubecube/pkg/apis/quota/v1"
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	"github.com/kubecube-io/kubecube/pkg/utils/constants"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubecube-io/kubecube-e2e/e2e/framework"
+)
+
+const (
+	stepCheckHierarchy = "配额层级应该一致"
+
+	overloadMessage  = "overload"
+	exceededMessage  = "exceeded quota"
+	lessUsedMessage  = "should not less than used"
+	projectQuotaName = "e2e-project-quota"
+	quotaPodName     = "e2e-quota-pod"
+)
+
+// namespaceQuotaName 测试空间的 ResourceQuota 名，与 KubeCube 创建空间配额时的命名一致
+func namespaceQuotaName(tc *framework.TestContext) string {
+	return tc.TargetClusterName + "." + tc.TenantName + "." + tc.ProjectName + "." + tc.NamespaceName
+}
+
+// exceed 返回比 q 多一个单位的值
+func exceed(q resource.Quantity) resource.Quantity {
+	more := q.DeepCopy()
+	more.Add(resource.MustParse("1"))
+	return more
+}
+
+func checkQuotaHierarchy(tc *framework.TestContext) framework.TestResp {
+	return framework.NewTestRespWithErr(tc.WaitQuotaConsistent(tc.CubeResourceQuota))
+}
+
+func exceedTenantQuotaByProject(tc *framework.TestContext) framework.TestResp {
+	tenantQuota, err := tc.GetCubeResourceQuota(tc.CubeResourceQuota)
+	tc.ExpectNoError(err, "get tenant quota should success")
+	cli, err := tc.ProxyClient(tc.PivotClusterName)
+	tc.ExpectNoError(err, "proxy client of pivot cluster should be created")
+
+	hard := tenantQuota.Spec.Hard.DeepCopy()
+	hard[corev1.ResourceRequestsCPU] = exceed(hard[corev1.ResourceRequestsCPU])
+	projectQuota := &quotav1.CubeResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: tc.NameWithUser(projectQuotaName),
+			Labels: map[string]string{
+				constants.ClusterLabel: tc.TargetClusterName,
+				constants.TenantLabel:  tc.TenantName,
+				constants.ProjectLabel: tc.ProjectName,
+			},
+		},
+		Spec: quotav1.CubeResourceQuotaSpec{
+			Hard:        hard,
+			ParentQuota: tenantQuota.Name,
+			Target:      quotav1.TargetObj{Name: tc.ProjectName, Kind: quotav1.ProjectObj},
+		},
+	}
+	err = cli.Create(context.Background(), projectQuota)
+	if err != nil {
+		clog.Info("create project quota exceeding tenant quota: %v", err)
+		return framework.NewTestRespWithAPIError(err)
+	}
+
+	// 超出租户配额的项目配额被接受时立即删除，避免占用租户配额
+	err = tc.PivotClusterClient.Direct().Delete(context.Background(), projectQuota)
+	tc.ExpectNoError(err, "accepted project quota should be deleted")
+	return framework.SucceedResp
+}
+
+func exceedTenantQuotaByNamespace(tc *framework.TestContext) framework.TestResp {
+	tenantQuota, err := tc.GetCubeResourceQuota(tc.CubeResourceQuota)
+	tc.ExpectNoError(err, "get tenant quota should success")
+
+	cli := tc.TargetProxyClient()
+	nsQuota := &corev1.ResourceQuota{}
+	err = cli.Get(context.Background(), types.NamespacedName{Namespace: tc.NamespaceName, Name: namespaceQuotaName(tc)}, nsQuota)
+	if err != nil {
+		return framework.NewTestRespWithAPIError(err)
+	}
+	origin := nsQuota.Spec.Hard.DeepCopy()
+	nsQuota.Spec.Hard[corev1.ResourceRequestsCPU] = exceed(tenantQuota.Spec.Hard[corev1.ResourceRequestsCPU])
+	nsQuota.Spec.Hard[corev1.ResourceLimitsCPU] = exceed(tenantQuota.Spec.Hard[corev1.ResourceLimitsCPU])
+	err = cli.Update(context.Background(), nsQuota)
+	if err != nil {
+		clog.Info("update namespace quota exceeding tenant quota: %v", err)
+		return framework.NewTestRespWithAPIError(err)
+	}
+
+	restore(tc, tc.TargetClusterClient.Direct(), nsQuota, func() { nsQuota.Spec.Hard = origin })
+	return framework.SucceedResp
+}
+
+func exceedNamespaceQuotaByPod(tc *framework.TestContext) framework.TestResp {
+	nsQuota := &corev1.ResourceQuota{}
+	err := tc.TargetClusterClient.Direct().Get(context.Background(), types.NamespacedName{Namespace: tc.NamespaceName, Name: namespaceQuotaName(tc)}, nsQuota)
+	tc.ExpectNoError(err, "get namespace quota should success")
+
+	cpu := exceed(nsQuota.Spec.Hard[corev1.ResourceRequestsCPU])
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: tc.NameWithUser(quotaPodName), Namespace: tc.NamespaceName},
+		Spec: corev1.PodSpec{
+			ImagePullSecrets: []corev1.LocalObjectReference{{Name: tc.ImagePullSecret}},
+			Containers: []corev1.Container{{
+				Name:            "quota",
+				Image:           tc.TestImage,
+				ImagePullPolicy: corev1.PullIfNotPresent,
+				Command:         []string{"sleep", "2m"},
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceCPU: cpu, corev1.ResourceMemory: resource.MustParse("64Mi")},
+					Limits:   corev1.ResourceList{corev1.ResourceCPU: cpu, corev1.ResourceMemory: resource.MustParse("64Mi")},
+				},
+			}},
+		},
+	}
+	err = tc.TargetProxyClient().Create(context.Background(), pod)
+	if err != nil {
+		clog.Info("create pod exceeding namespace quota: %v", err)
+		return framework.NewTestRespWithAPIError(err)
+	}
+
+	err = tc.TargetClusterClient.Direct().Delete(context.Background(), pod)
+	tc.ExpectNoError(err, "accepted pod should be deleted")
+	return framework.SucceedResp
+}
+
+func lowerTenantQuotaBelowUsed(tc *framework.TestContext) framework.TestResp {
+	cli, err := tc.ProxyClient(tc.PivotClusterName)
+	tc.ExpectNoError(err, "proxy client of pivot cluster should be created")
+
+	tenantQuota := &quotav1.CubeResourceQuota{}
+	err = cli.Get(context.Background(), types.NamespacedName{Name: tc.CubeResourceQuota}, tenantQuota)
+	if err != nil {
+		return framework.NewTestRespWithAPIError(err)
+	}
+	used := tenantQuota.Status.Used[corev1.ResourceRequestsCPU]
+	tc.ExpectEqual(used.IsZero(), false, fmt.Sprintf("tenant quota %s should have allocated cpu", tenantQuota.Name))
+
+	origin := tenantQuota.Spec.Hard.DeepCopy()
+	lower := used.DeepCopy()
+	lower.Sub(resource.MustParse("1m"))
+	tenantQuota.Spec.Hard[corev1.ResourceRequestsCPU] = lower
+	err = cli.Update(context.Background(), tenantQuota)
+	if err != nil {
+		clog.Info("lower tenant quota below used: %v", err)
+		return framework.NewTestRespWithAPIError(err)
+	}
+
+	restore(tc, tc.PivotClusterClient.Direct(), tenantQuota, func() { tenantQuota.Spec.Hard = origin })
+	return framework.SucceedResp
+}
+
+// restore 超限的修改被接受时恢复配额，避免影响后续测试
+func restore(tc *framework.TestContext, cli ctrlclient.Client, obj ctrlclient.Object, reset func()) {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := cli.Get(context.Background(), ctrlclient.ObjectKeyFromObject(obj), obj); err != nil {
+			return err
+		}
+		reset()
+		return cli.Update(context.Background(), obj)
+	})
+	tc.ExpectNoError(err, "accepted quota should be restored")
+}
+
+// rejected 管理员的超限操作应被配额校验拒绝，其余角色无权限或被配额拒绝
+func rejected(message string) map[string]framework.Expectation {
+	return map[string]framework.Expectation{
+		framework.UserAdmin: {Message: message},
+	}
+}
+
+var hierarchyTest = framework.MultiUserTest{
+	TestName:        "[配额和空间管理]配额层级与超限校验",
+	ContinueIfError: true,
+	ErrorFunc:       framework.DefaultErrorFunc,
+	Steps: []framework.MultiUserTestStep{
+		{
+			Name:        stepCheckHierarchy,
+			Description: "租户配额 ≥ 项目配额之和 ≥ 空间配额之和，配额的 used 与子配额、工作负载一致",
+			StepFunc:    checkQuotaHierarchy,
+			ExpectPass: map[string]bool{
+				framework.UserAdmin:        true,
+				framework.UserTenantAdmin:  true,
+				framework.UserProjectAdmin: true,
+				framework.UserNormal:       true,
+			},
+		},
+		{
+			Name:        "项目配额超出租户配额应该被拒绝",
+			Description: "创建 cpu 超出租户配额的项目配额",
+			StepFunc:    exceedTenantQuotaByProject,
+			DependsOn:   []string{stepCheckHierarchy},
+			ExpectPass: map[string]bool{
+				framework.UserTenantAdmin:  false,
+				framework.UserProjectAdmin: false,
+				framework.UserNormal:       false,
+			},
+			Expect: rejected(overloadMessage),
+		},
+		{
+			Name:        "空间配额超出租户配额应该被拒绝",
+			Description: "将测试空间配额的 cpu 调整为超出租户配额",
+			StepFunc:    exceedTenantQuotaByNamespace,
+			DependsOn:   []string{stepCheckHierarchy},
+			ExpectPass: map[string]bool{
+				framework.UserTenantAdmin:  false,
+				framework.UserProjectAdmin: false,
+				framework.UserNormal:       false,
+			},
+			Expect: rejected(overloadMessage),
+		},
+		{
+			Name:        "工作负载超出空间配额应该被拒绝",
+			Description: "在测试空间中创建 cpu 超出空间配额的 Pod",
+			StepFunc:    exceedNamespaceQuotaByPod,
+			DependsOn:   []string{stepCheckHierarchy},
+			ExpectPass: map[string]bool{
+				framework.UserTenantAdmin:  false,
+				framework.UserProjectAdmin: false,
+				framework.UserNormal:       false,
+			},
+			Expect: rejected(exceededMessage),
+		},
+		{
+			Name:        "租户配额调低至已分配量以下应该被拒绝",
+			Description: "将租户配额的 cpu 调整为小于已分配给空间的 cpu",
+			StepFunc:    lowerTenantQuotaBelowUsed,
+			DependsOn:   []string{stepCheckHierarchy},
+			ExpectPass: map[string]bool{
+				framework.UserTenantAdmin:  false,
+				framework.UserProjectAdmin: false,
+				framework.UserNormal:       false,
+			},
+			Expect: rejected(lessUsedMessage),
+		},
+	},
+}
+
+func init() {
+	framework.RegisterByDefault(hierarchyTest)
+}
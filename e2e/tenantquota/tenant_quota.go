@@ -27,12 +27,12 @@ import (
 	"github.com/kubecube-io/kubecube-e2e/e2e/framework"
 )
 
-func updateTenantQuota(user string) framework.TestResp {
+func updateTenantQuota(tc *framework.TestContext) framework.TestResp {
 	ctx := context.TODO()
-	memberClient := framework.TargetClusterClient
+	memberClient := tc.TargetClusterClient
 	cubeQuota := &quotav1.CubeResourceQuota{}
-	err := memberClient.Direct().Get(ctx, types.NamespacedName{Name: framework.CubeResourceQuota}, cubeQuota)
-	framework.ExpectNoError(err, "get cube resource quota should success")
+	err := memberClient.Direct().Get(ctx, types.NamespacedName{Name: tc.CubeResourceQuota}, cubeQuota)
+	tc.ExpectNoError(err, "get cube resource quota should success")
 
 	cubeQuota.Spec.Hard[corev1.ResourceRequestsCPU] = resource.MustParse("11")
 	cubeQuota.Spec.Hard[corev1.ResourceRequestsMemory] = resource.MustParse("11Gi")
@@ -41,7 +41,7 @@ func updateTenantQuota(user string) framework.TestResp {
 	cubeQuota.Spec.Hard[corev1.ResourceRequestsStorage] = resource.MustParse("31Gi")
 
 	err = memberClient.Direct().Update(ctx, cubeQuota)
-	framework.ExpectNoError(err, "update tenant quota should success")
+	tc.ExpectNoError(err, "update tenant quota should success")
 	return framework.SucceedResp
 }
 
@@ -0,0 +1,260 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kubecube-io/kubecube/pkg/clog"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubecube-io/kubecube-e2e/e2e/framework"
+)
+
+const (
+	// resultLabel 标记 worker 发布结果的 ConfigMap，值为 master 的运行标识
+	resultLabel = "kubecube-e2e-result"
+	resultKey   = "result.json"
+
+	// maxResultBytes 结果的最大长度，ConfigMap 总大小不能超过 1MiB，为元数据保留空间
+	maxResultBytes = 1000 * 1024
+	// maxMessageBytes 结果过大时每条失败信息保留的长度
+	maxMessageBytes = 2048
+)
+
+var (
+	// resultReporter 收集本进程的用例结果，master 在其中合并 worker 的结果
+	resultReporter *framework.ResultReporter
+	// masterRunID worker 等待资源初始化时读取的 master 运行标识
+	masterRunID string
+)
+
+// WorkerResult worker 发布到管控集群的结果
+type WorkerResult struct {
+	// Lease worker 的 Lease 名，master 据此找出未发布结果的 worker
+	Lease  string                   `json:"lease"`
+	Holder string                   `json:"holder"`
+	Users  []string                 `json:"users"`
+	RunID  string                   `json:"runID"`
+	Suite  framework.SuiteResult    `json:"suite"`
+	Steps  []framework.RecordedStep `json:"steps"`
+	// Leaks worker 中测试结束后未能删除的对象
+	Leaks []framework.TrackedObject `json:"leaks,omitempty"`
+	// ExitCode worker 中 Ginkgo 的退出码
+	ExitCode int `json:"exitCode"`
+	// OmittedSteps、OmittedCases 结果过大时省略的步骤结果与用例数，失败的用例与不符合预期的步骤不会省略
+	OmittedSteps int `json:"omittedSteps,omitempty"`
+	OmittedCases int `json:"omittedCases,omitempty"`
+}
+
+func workerResultName(tc *framework.TestContext) string {
+	return strings.ToLower(tc.KubeCubeE2ECM + "-result-" + strings.Join(framework.TestUser, "-"))
+}
+
+// publishWorkerResult worker 将本进程的结果写入管控集群的 ConfigMap，供 master 汇总
+func publishWorkerResult(tc *framework.TestContext, exitCode int) error {
+	if len(masterRunID) == 0 {
+		return fmt.Errorf("master run id is unknown, resources may not be initialized")
+	}
+	result := WorkerResult{
+		Lease:    workerLeaseName(tc),
+		Holder:   holderIdentity(),
+		Users:    framework.TestUser,
+		RunID:    tc.RunID,
+		Steps:    tc.Matrix.Records(),
+		Leaks:    tc.Tracker.Leaks(),
+		ExitCode: exitCode,
+	}
+	if resultReporter != nil {
+		result.Suite = resultReporter.Result()
+	}
+	data, err := marshalWorkerResult(&result)
+	if err != nil {
+		return err
+	}
+
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      workerResultName(tc),
+			Namespace: tc.KubeCubeSystem,
+			Labels:    map[string]string{resultLabel: masterRunID},
+		},
+		Data: map[string]string{resultKey: string(data)},
+	}
+	cli := tc.PivotClusterClient.Direct()
+	err = cli.Delete(context.Background(), cm)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return err
+	}
+	if err = cli.Create(context.Background(), cm); err != nil {
+		return err
+	}
+	clog.Info("worker published result of %d cases to %s", len(result.Suite.Cases), cm.Name)
+	return nil
+}
+
+// marshalWorkerResult 序列化结果，超过 maxResultBytes 时依次截断失败信息、省略符合预期的步骤结果与成功或跳过的用例，
+// 仍然过大时返回错误
+func marshalWorkerResult(result *WorkerResult) ([]byte, error) {
+	data, err := json.Marshal(result)
+	if err != nil || len(data) <= maxResultBytes {
+		return data, err
+	}
+
+	for _, c := range result.Suite.Cases {
+		c.Failure = truncateMessage(c.Failure)
+	}
+	for _, s := range result.Steps {
+		if s.Result != nil {
+			s.Result.Error = truncateMessage(s.Result.Error)
+		}
+	}
+	reductions := []func(){
+		func() {
+			var steps []framework.RecordedStep
+			for _, s := range result.Steps {
+				if s.Result != nil && s.Result.Mismatch {
+					steps = append(steps, s)
+				}
+			}
+			result.OmittedSteps = len(result.Steps) - len(steps)
+			result.Steps = steps
+		},
+		func() {
+			var cases []*framework.CaseResult
+			for _, c := range result.Suite.Cases {
+				if c.State == framework.StateFailed {
+					cases = append(cases, c)
+				}
+			}
+			result.OmittedCases = len(result.Suite.Cases) - len(cases)
+			result.Suite.Cases = cases
+		},
+	}
+	for i := 0; ; i++ {
+		data, err = json.Marshal(result)
+		if err != nil || len(data) <= maxResultBytes {
+			return data, err
+		}
+		if i == len(reductions) {
+			return nil, fmt.Errorf("result of %d failed cases is %d bytes, exceeds the limit of %d bytes", len(result.Suite.Cases), len(data), maxResultBytes)
+		}
+		reductions[i]()
+	}
+}
+
+func truncateMessage(message string) string {
+	if len(message) <= maxMessageBytes {
+		return message
+	}
+	return strings.ToValidUTF8(message[:maxMessageBytes], "") + "...(truncated)"
+}
+
+// collectWorkerResults master 合并本次运行中各 worker 发布的结果并删除对应的 ConfigMap，
+// 崩溃的 worker 以及结束后未发布结果的 worker 记为失败的用例，任一 worker 失败时返回错误
+func collectWorkerResults(tc *framework.TestContext, workers map[string]DeadWorker, dead []DeadWorker) error {
+	cmList := &v1.ConfigMapList{}
+	cli := tc.PivotClusterClient.Direct()
+	err := cli.List(context.Background(), cmList, client.InNamespace(tc.KubeCubeSystem), client.MatchingLabels{resultLabel: tc.RunID})
+	if err != nil {
+		return fmt.Errorf("list worker results failed: %v", err)
+	}
+
+	var failed []string
+	published := make(map[string]bool, len(cmList.Items))
+	for i := range cmList.Items {
+		cm := &cmList.Items[i]
+		result := WorkerResult{}
+		if err := json.Unmarshal([]byte(cm.Data[resultKey]), &result); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: invalid result: %v", cm.Name, err))
+			continue
+		}
+		published[result.Lease] = true
+		clog.Info("worker %s running %v: %d passed, %d failed, %d skipped", result.Holder, result.Users,
+			result.Suite.Passed, result.Suite.Failed, result.Suite.Skipped)
+		if result.OmittedSteps > 0 || result.OmittedCases > 0 {
+			clog.Warn("result of worker %s is truncated, %d step results and %d passed or skipped cases are omitted",
+				result.Holder, result.OmittedSteps, result.OmittedCases)
+		}
+		tc.Matrix.Merge(result.Steps)
+		tc.Tracker.AddLeaks(result.Leaks)
+		if resultReporter != nil {
+			resultReporter.Merge(result.Suite)
+		}
+		if result.Suite.Failed > 0 || result.ExitCode != 0 {
+			failed = append(failed, fmt.Sprintf("worker %s running %v failed", result.Holder, result.Users))
+		}
+		if err := cli.Delete(context.Background(), cm); err != nil && !kerrors.IsNotFound(err) {
+			clog.Warn("fail to delete worker result %s: %v", cm.Name, err)
+		}
+	}
+
+	isDead := make(map[string]bool, len(dead))
+	for _, w := range dead {
+		isDead[w.Lease] = true
+	}
+	names := make([]string, 0, len(workers))
+	for name := range workers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !published[name] && !isDead[name] {
+			w := workers[name]
+			w.Reason = "finished without publishing result"
+			dead = append(dead, w)
+		}
+	}
+
+	for _, w := range dead {
+		message := fmt.Sprintf("worker %s running %v did not finish: %s, last renewed at %s", w.Holder, w.Users, w.Reason, w.RenewTime.Format("2006-01-02T15:04:05Z07:00"))
+		if resultReporter != nil {
+			resultReporter.AddFailure("worker", fmt.Sprintf("worker %s", strings.Join(w.Users, ",")), message)
+		}
+		failed = append(failed, message)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d workers failed:\n%s", len(failed), strings.Join(failed, "\n"))
+	}
+	return nil
+}
+
+// writeReports 输出测试报告、权限矩阵与泄漏的对象，master 中包含所有 worker 的结果
+func writeReports(tc *framework.TestContext) {
+	if resultReporter != nil {
+		if err := resultReporter.Write(); err != nil {
+			clog.Warn(err.Error())
+		}
+	}
+	if len(tc.ArtifactsDir) > 0 {
+		err := framework.WritePermissionMatrix(framework.BuildPermissionMatrix(tc), tc.ArtifactsDir)
+		if err != nil {
+			clog.Warn("write permission matrix failed: %v", err)
+		}
+	}
+	if err := tc.Tracker.WriteLeaks(tc.ArtifactsDir); err != nil {
+		clog.Warn("write leaked objects failed: %v", err)
+	}
+}
@@ -22,7 +22,9 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 
+	"github.com/kubecube-io/kubecube-e2e/e2e/fixtures"
 	"github.com/kubecube-io/kubecube-e2e/e2e/framework"
 	"github.com/kubecube-io/kubecube/pkg/clog"
 	v1 "k8s.io/api/batch/v1"
@@ -34,17 +36,15 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func createCronjob(user string) framework.TestResp {
-	initParam()
-	cronJobNameWithUser = framework.NameWithUser(cronJobName, user)
-	cronJobJson := `{"apiVersion":"batch/v1beta1","kind":"CronJob","metadata":{"name":"%s","annotations":{},"labels":{"kubecube.io/app":"%s"}},"spec":{"selector":{"matchLabels":{"kubecube.io/app":"%s"}},"concurrencyPolicy":null,"schedule":"*/1 * * * *","successfulJobsHistoryLimit":null,"failedJobsHistoryLimit":null,"jobTemplate":{"spec":{"completions":null,"parallelism":null,"backoffLimit":null,"template":{"metadata":{"annotations":{},"labels":{"kubecube.io/app":"%s"}},"spec":{"containers":[{"name":"%s","args":["Hello from the Kubernetes cluste"],"command":["echo"],"env":[],"image":"%s","imagePullPolicy":"IfNotPresent","lifecycle":{"postStart":null,"preStop":null},"livenessProbe":null,"readinessProbe":null,"ports":null,"resources":{"limits":{"cpu":"100m","memory":"128Mi"},"requests":{"cpu":"100m","memory":"128Mi"}},"volumeMounts":[]}],"initContainers":[],"volumes":[],"affinity":{},"restartPolicy":"OnFailure","imagePullSecrets":[{"name":"%s"}]}}}}}}`
-	cronJobJson = fmt.Sprintf(cronJobJson, cronJobNameWithUser, cronJobNameWithUser, cronJobNameWithUser, cronJobNameWithUser, cronJobNameWithUser, framework.TestImage, framework.ImagePullSecret)
-	url := BuildK8sProxyUrl(framework.KubecubeHost, framework.TargetClusterName, "apis/batch/v1beta1", framework.NamespaceName, "cronjobs", "")
-	cronJobResp, err := httpHelper.RequestByUser(http.MethodPost, url, cronJobJson, user, nil)
-	framework.ExpectNoError(err)
+func createCronjob(tc *framework.TestContext) framework.TestResp {
+	cronJobNameWithUser := tc.NameWithUser(cronJobName)
+	cronJobJson := fixtures.MustRender(tc, "cronjob.yaml", fixtures.For(tc, cronJobNameWithUser))
+	url := BuildK8sProxyUrl(tc.KubecubeHost, tc.TargetClusterName, "apis/batch/v1beta1", tc.NamespaceName, "cronjobs", "")
+	cronJobResp, err := tc.HttpHelper.RequestByUser(http.MethodPost, url, cronJobJson, tc.User, nil)
+	tc.ExpectNoError(err)
 	defer cronJobResp.Body.Close()
 	body, err := io.ReadAll(cronJobResp.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	clog.Info("create cronJob %v, %v", cronJobNameWithUser, string(body))
 
 	if !framework.IsSuccess(cronJobResp.StatusCode) {
@@ -53,11 +53,11 @@ func createCronjob(user string) framework.TestResp {
 	}
 
 	cronJob := v1beta1.CronJob{}
-	err = wait.Poll(framework.WaitInterval, framework.WaitTimeout,
+	err = wait.Poll(tc.WaitInterval, tc.WaitTimeout,
 		func() (bool, error) {
-			err := framework.TargetConvertClient.Get(context.TODO(), types.NamespacedName{
+			err := tc.TargetConvertClient.Get(context.TODO(), types.NamespacedName{
 				Name:      cronJobNameWithUser,
-				Namespace: framework.NamespaceName,
+				Namespace: tc.NamespaceName,
 			}, &cronJob)
 			if err != nil {
 				return false, nil
@@ -65,17 +65,18 @@ func createCronjob(user string) framework.TestResp {
 				return true, nil
 			}
 		})
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	return framework.SucceedResp
 }
 
-func checkCronjobCreate(user string) framework.TestResp {
+func checkCronjobCreate(tc *framework.TestContext) framework.TestResp {
+	cronJobNameWithUser := tc.NameWithUser(cronJobName)
 	cronJob := v1beta1.CronJob{}
-	err := wait.Poll(framework.WaitInterval, framework.WaitTimeout,
+	err := wait.Poll(tc.WaitInterval, tc.WaitTimeout,
 		func() (bool, error) {
-			err := framework.TargetConvertClient.Get(context.TODO(), types.NamespacedName{
+			err := tc.TargetConvertClient.Get(context.TODO(), types.NamespacedName{
 				Name:      cronJobNameWithUser,
-				Namespace: framework.NamespaceName,
+				Namespace: tc.NamespaceName,
 			}, &cronJob)
 			if err != nil {
 				return false, nil
@@ -83,18 +84,19 @@ func checkCronjobCreate(user string) framework.TestResp {
 				return true, nil
 			}
 		})
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	clog.Info("cronjob status: %v", cronJob.Status)
 	return framework.SucceedResp
 }
 
-func checkCronjobInfo(user string) framework.TestResp {
+func checkCronjobInfo(tc *framework.TestContext) framework.TestResp {
+	cronJobNameWithUser := tc.NameWithUser(cronJobName)
 	cronJob := v1beta1.CronJob{}
-	err := wait.Poll(framework.WaitInterval, framework.WaitTimeout,
+	err := wait.Poll(tc.WaitInterval, tc.WaitTimeout,
 		func() (bool, error) {
-			err := framework.TargetConvertClient.Get(context.TODO(), types.NamespacedName{
+			err := tc.TargetConvertClient.Get(context.TODO(), types.NamespacedName{
 				Name:      cronJobNameWithUser,
-				Namespace: framework.NamespaceName,
+				Namespace: tc.NamespaceName,
 			}, &cronJob)
 			if err != nil {
 				return false, nil
@@ -102,20 +104,21 @@ func checkCronjobInfo(user string) framework.TestResp {
 				return true, nil
 			}
 		})
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	clog.Info("查看CronJob列表信息")
-	framework.ExpectEqual(cronJob.Name, cronJobNameWithUser)
-	framework.ExpectEqual(cronJob.Namespace, framework.NamespaceName)
-	framework.ExpectEqual(cronJob.Spec.Schedule, "*/1 * * * *")
+	tc.ExpectEqual(cronJob.Name, cronJobNameWithUser)
+	tc.ExpectEqual(cronJob.Namespace, tc.NamespaceName)
+	tc.ExpectEqual(cronJob.Spec.Schedule, "*/1 * * * *")
 	return framework.SucceedResp
 }
 
-func checkCronjobStatus(user string) framework.TestResp {
+func checkCronjobStatus(tc *framework.TestContext) framework.TestResp {
+	cronJobNameWithUser := tc.NameWithUser(cronJobName)
 	jobList := v1.JobList{}
-	err := wait.Poll(framework.WaitInterval, framework.WaitTimeout,
+	err := wait.Poll(tc.WaitInterval, tc.WaitTimeout,
 		func() (bool, error) {
-			err := targetClient.Cache().List(context.TODO(), &jobList, &client.ListOptions{
-				Namespace:     framework.NamespaceName,
+			err := tc.TargetClusterClient.Cache().List(context.TODO(), &jobList, &client.ListOptions{
+				Namespace:     tc.NamespaceName,
 				LabelSelector: labels.Set{"kubecube.io/app": cronJobNameWithUser}.AsSelector(),
 			})
 			if err != nil || len(jobList.Items) == 0 || jobList.Items[0].Status.Succeeded != 1 {
@@ -124,18 +127,18 @@ func checkCronjobStatus(user string) framework.TestResp {
 				return true, nil
 			}
 		})
-	framework.ExpectNoError(err)
-	framework.ExpectNotEqual(len(jobList.Items), 0)
-	framework.ExpectEqual(jobList.Items[0].Status.Conditions[0].Type, v1.JobComplete)
+	tc.ExpectNoError(err)
+	tc.ExpectNotEqual(len(jobList.Items), 0)
+	tc.ExpectEqual(jobList.Items[0].Status.Conditions[0].Type, v1.JobComplete)
 	return framework.SucceedResp
 }
 
-func updateCronjob(user string) framework.TestResp {
-	updateJson := `{"apiVersion":"batch/v1beta1","kind":"CronJob","metadata":{"labels":{"kubecube.io/app":"%s"},"name":"%s","namespace":"%s"},"spec":{"failedJobsHistoryLimit":1,"jobTemplate":{"metadata":{"creationTimestamp":null},"spec":{"template":{"metadata":{"creationTimestamp":null,"labels":{"kubecube.io/app":"%s"},"annotations":{}},"spec":{"affinity":{},"containers":[{"name":"%s","args":["-c","date;echo  Hello from the Kubernetes cluste"],"command":["/bin/bash"],"env":[],"image":"%s","imagePullPolicy":"IfNotPresent","lifecycle":{"postStart":null,"preStop":null},"livenessProbe":null,"readinessProbe":null,"ports":null,"resources":{"limits":{"cpu":"100m","memory":"128Mi"},"requests":{"cpu":"100m","memory":"128Mi"}},"volumeMounts":[]}],"dnsPolicy":"ClusterFirst","restartPolicy":"OnFailure","schedulerName":"default-scheduler","securityContext":{},"terminationGracePeriodSeconds":30,"initContainers":[],"imagePullSecrets":[{"name":"%s"}],"volumes":[]}}}},"schedule":"0 0 */1 * *","successfulJobsHistoryLimit":3,"suspend":false}}`
-	updateJson = fmt.Sprintf(updateJson, cronJobNameWithUser, cronJobNameWithUser, framework.NamespaceName, cronJobNameWithUser, cronJobNameWithUser, framework.TestImage, framework.ImagePullSecret)
-	url := BuildK8sProxyUrl(framework.KubecubeHost, framework.TargetClusterName, "apis/batch/v1beta1", framework.NamespaceName, "cronjobs", cronJobNameWithUser)
-	body, err := httpHelper.RequestByUser(http.MethodPut, url, updateJson, user, nil)
-	framework.ExpectNoError(err)
+func updateCronjob(tc *framework.TestContext) framework.TestResp {
+	cronJobNameWithUser := tc.NameWithUser(cronJobName)
+	updateJson := fixtures.MustRender(tc, "cronjob-update.yaml", fixtures.For(tc, cronJobNameWithUser))
+	url := BuildK8sProxyUrl(tc.KubecubeHost, tc.TargetClusterName, "apis/batch/v1beta1", tc.NamespaceName, "cronjobs", cronJobNameWithUser)
+	body, err := tc.HttpHelper.RequestByUser(http.MethodPut, url, updateJson, tc.User, nil)
+	tc.ExpectNoError(err)
 	defer body.Body.Close()
 
 	if !framework.IsSuccess(body.StatusCode) {
@@ -144,10 +147,10 @@ func updateCronjob(user string) framework.TestResp {
 	}
 
 	cronJob := v1beta1.CronJob{}
-	err = wait.Poll(framework.WaitInterval, framework.WaitTimeout, func() (done bool, err error) {
-		err = framework.TargetConvertClient.Get(context.TODO(), types.NamespacedName{
+	err = wait.Poll(tc.WaitInterval, tc.WaitTimeout, func() (done bool, err error) {
+		err = tc.TargetConvertClient.Get(context.TODO(), types.NamespacedName{
 			Name:      cronJobNameWithUser,
-			Namespace: framework.NamespaceName,
+			Namespace: tc.NamespaceName,
 		}, &cronJob)
 		if err != nil {
 			return false, err
@@ -157,17 +160,18 @@ func updateCronjob(user string) framework.TestResp {
 		}
 		return true, nil
 	})
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	return framework.SucceedResp
 }
 
-func checkUpdatedCronjob(user string) framework.TestResp {
+func checkUpdatedCronjob(tc *framework.TestContext) framework.TestResp {
+	cronJobNameWithUser := tc.NameWithUser(cronJobName)
 	cronJob := v1beta1.CronJob{}
-	err := wait.Poll(framework.WaitInterval, framework.WaitTimeout,
+	err := wait.Poll(tc.WaitInterval, tc.WaitTimeout,
 		func() (bool, error) {
-			err := framework.TargetConvertClient.Get(context.TODO(), types.NamespacedName{
+			err := tc.TargetConvertClient.Get(context.TODO(), types.NamespacedName{
 				Name:      cronJobNameWithUser,
-				Namespace: framework.NamespaceName,
+				Namespace: tc.NamespaceName,
 			}, &cronJob)
 			if err != nil {
 				return false, nil
@@ -175,23 +179,24 @@ func checkUpdatedCronjob(user string) framework.TestResp {
 				return true, nil
 			}
 		})
-	framework.ExpectNoError(err)
-	framework.ExpectEqual(len(cronJob.Spec.JobTemplate.Spec.Template.Spec.Containers), 1)
+	tc.ExpectNoError(err)
+	tc.ExpectEqual(len(cronJob.Spec.JobTemplate.Spec.Template.Spec.Containers), 1)
 	container := cronJob.Spec.JobTemplate.Spec.Template.Spec.Containers[0]
-	framework.ExpectEqual(container.Image, framework.TestImage)
-	framework.ExpectEqual(container.Command[0], "/bin/bash")
-	framework.ExpectEqual(container.Args[0], "-c")
-	framework.ExpectEqual(container.Args[1], "date;echo  Hello from the Kubernetes cluste")
+	tc.ExpectEqual(container.Image, tc.TestImage)
+	tc.ExpectEqual(container.Command[0], "/bin/bash")
+	tc.ExpectEqual(container.Args[0], "-c")
+	tc.ExpectEqual(container.Args[1], "date;echo  Hello from the Kubernetes cluste")
 	return framework.SucceedResp
 }
 
-func checkUpdatedCronjobStatus(user string) framework.TestResp {
+func checkUpdatedCronjobStatus(tc *framework.TestContext) framework.TestResp {
+	cronJobNameWithUser := tc.NameWithUser(cronJobName)
 	cronJob := v1beta1.CronJob{}
-	err := wait.Poll(framework.WaitInterval, framework.WaitTimeout,
+	err := wait.Poll(tc.WaitInterval, tc.WaitTimeout,
 		func() (bool, error) {
-			err := framework.TargetConvertClient.Get(context.TODO(), types.NamespacedName{
+			err := tc.TargetConvertClient.Get(context.TODO(), types.NamespacedName{
 				Name:      cronJobNameWithUser,
-				Namespace: framework.NamespaceName,
+				Namespace: tc.NamespaceName,
 			}, &cronJob)
 			if err != nil {
 				return false, nil
@@ -199,12 +204,12 @@ func checkUpdatedCronjobStatus(user string) framework.TestResp {
 				return true, nil
 			}
 		})
-	framework.ExpectNoError(err)
-	url := BuildEventUrl(framework.KubecubeHost, framework.TargetClusterName, framework.NamespaceName, string(cronJob.UID))
-	resp, err := httpHelper.RequestByUser(http.MethodGet, url, "", user, nil)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
+	url := BuildEventUrl(tc.KubecubeHost, tc.TargetClusterName, tc.NamespaceName, string(cronJob.UID))
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodGet, url, "", tc.User, nil)
+	tc.ExpectNoError(err)
 	body, err := io.ReadAll(resp.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 
 	if !framework.IsSuccess(resp.StatusCode) {
 		clog.Warn("res code %d", resp.StatusCode)
@@ -213,15 +218,16 @@ func checkUpdatedCronjobStatus(user string) framework.TestResp {
 
 	eventList := corev1.EventList{}
 	err = json.Unmarshal(body, &eventList)
-	framework.ExpectNoError(err)
-	framework.ExpectNotEqual(len(eventList.Items), 0)
+	tc.ExpectNoError(err)
+	tc.ExpectNotEqual(len(eventList.Items), 0)
 	return framework.SucceedResp
 }
 
-func deleteCronjob(user string) framework.TestResp {
-	url := BuildK8sProxyUrl(framework.KubecubeHost, framework.TargetClusterName, "apis/batch/v1beta1", framework.NamespaceName, "cronjobs", cronJobNameWithUser)
-	resp, err := httpHelper.RequestByUser(http.MethodDelete, url, "", user, nil)
-	framework.ExpectNoError(err)
+func deleteCronjob(tc *framework.TestContext) framework.TestResp {
+	cronJobNameWithUser := tc.NameWithUser(cronJobName)
+	url := BuildK8sProxyUrl(tc.KubecubeHost, tc.TargetClusterName, "apis/batch/v1beta1", tc.NamespaceName, "cronjobs", cronJobNameWithUser)
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodDelete, url, "", tc.User, nil)
+	tc.ExpectNoError(err)
 	defer resp.Body.Close()
 
 	if !framework.IsSuccess(resp.StatusCode) {
@@ -234,8 +240,8 @@ func deleteCronjob(user string) framework.TestResp {
 var multiUserCronjobTest = framework.MultiUserTest{
 	TestName:        "[工作负载][9478777]CronJob检查",
 	ContinueIfError: false,
-	Skipfunc: func() bool {
-		return !framework.CronJobEnable
+	Skipfunc: func(tc *framework.TestContext) bool {
+		return !tc.CronJobEnable
 	},
 	ErrorFunc:  framework.PermissionErrorFunc,
 	AfterEach:  nil,
@@ -288,6 +294,8 @@ var multiUserCronjobTest = framework.MultiUserTest{
 			Name:        "Job列表中有新增Job且状态为执行完成",
 			Description: "Job列表中有新增Job且状态为执行完成",
 			StepFunc:    checkCronjobStatus,
+			// 每分钟触发一次，等待 Job 执行完成需要的时间长于默认的 waitTimeout
+			Timeout: 3 * time.Minute,
 			ExpectPass: map[string]bool{
 				framework.UserAdmin:        true,
 				framework.UserTenantAdmin:  true,
@@ -31,21 +31,20 @@ import (
 	"k8s.io/apimachinery/pkg/util/wait"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/kubecube-io/kubecube-e2e/e2e/fixtures"
 	"github.com/kubecube-io/kubecube-e2e/e2e/framework"
 	"github.com/kubecube-io/kubecube/pkg/clog"
 )
 
-func createDs(user string) framework.TestResp {
-	initParam()
-	daemonSetNameWithUser = framework.NameWithUser(daemonSetName, user)
-	dsJson := `{"apiVersion":"apps/v1","kind":"DaemonSet","metadata":{"name":"%s","annotations":{},"labels":{"kubecube.io/app":"%s","system/tenant":"netease.share"}},"spec":{"selector":{"matchLabels":{"kubecube.io/app":"%s"}},"template":{"metadata":{"annotations":{},"labels":{"kubecube.io/app":"%s"}},"spec":{"containers":[{"name":"%s","args":[],"command":[],"env":[],"image":"%s","imagePullPolicy":"IfNotPresent","lifecycle":{"postStart":null,"preStop":null},"livenessProbe":null,"readinessProbe":null,"ports":null,"resources":{"limits":{"cpu":"100m","memory":"128Mi"},"requests":{"cpu":"100m","memory":"128Mi"}},"volumeMounts":[]}],"initContainers":[],"imagePullSecrets":[{"name":"%s"}],"volumes":[],"affinity":{},"restartPolicy":"Always"}}}}`
-	dsJson = fmt.Sprintf(dsJson, daemonSetNameWithUser, daemonSetNameWithUser, daemonSetNameWithUser, daemonSetNameWithUser, daemonSetNameWithUser, framework.TestImage, framework.ImagePullSecret)
-	url := BuildK8sProxyUrl(framework.KubecubeHost, framework.TargetClusterName, "apis/apps/v1", framework.NamespaceName, "daemonsets", "")
-	resp, err := httpHelper.RequestByUser(http.MethodPost, url, dsJson, user, nil)
-	framework.ExpectNoError(err)
+func createDs(tc *framework.TestContext) framework.TestResp {
+	daemonSetNameWithUser := tc.NameWithUser(daemonSetName)
+	dsJson := fixtures.MustRender(tc, "daemonset.yaml", fixtures.For(tc, daemonSetNameWithUser))
+	url := BuildK8sProxyUrl(tc.KubecubeHost, tc.TargetClusterName, "apis/apps/v1", tc.NamespaceName, "daemonsets", "")
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodPost, url, dsJson, tc.User, nil)
+	tc.ExpectNoError(err)
 	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	clog.Info("create daemonSet %v, %v", daemonSetNameWithUser, string(body))
 
 	if !framework.IsSuccess(resp.StatusCode) {
@@ -56,13 +55,14 @@ func createDs(user string) framework.TestResp {
 	return framework.SucceedResp
 }
 
-func checkDs(user string) framework.TestResp {
+func checkDs(tc *framework.TestContext) framework.TestResp {
+	daemonSetNameWithUser := tc.NameWithUser(daemonSetName)
 	ds := v1.DaemonSet{}
-	err := wait.Poll(framework.WaitInterval, framework.WaitTimeout,
+	err := wait.Poll(tc.WaitInterval, tc.WaitTimeout,
 		func() (bool, error) {
-			err := targetClient.Cache().Get(context.TODO(), types.NamespacedName{
+			err := tc.TargetClusterClient.Cache().Get(context.TODO(), types.NamespacedName{
 				Name:      daemonSetNameWithUser,
-				Namespace: framework.NamespaceName,
+				Namespace: tc.NamespaceName,
 			}, &ds)
 			if err != nil {
 				return false, nil
@@ -70,29 +70,31 @@ func checkDs(user string) framework.TestResp {
 				return true, nil
 			}
 		})
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	clog.Info("daemonset status: %v", ds.Status)
 	return framework.SucceedResp
 }
 
-func checkDsList(user string) framework.TestResp {
+func checkDsList(tc *framework.TestContext) framework.TestResp {
+	daemonSetNameWithUser := tc.NameWithUser(daemonSetName)
 	dsList := v1.DaemonSetList{}
-	err := targetClient.Cache().List(context.TODO(), &dsList, &client.ListOptions{
-		Namespace:     framework.NamespaceName,
+	err := tc.TargetClusterClient.Cache().List(context.TODO(), &dsList, &client.ListOptions{
+		Namespace:     tc.NamespaceName,
 		LabelSelector: labels.Set{"kubecube.io/app": daemonSetNameWithUser}.AsSelector(),
 	})
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	clog.Info("ds list: %v", dsList.Items)
-	framework.ExpectEqual(len(dsList.Items), 1)
+	tc.ExpectEqual(len(dsList.Items), 1)
 	return framework.SucceedResp
 }
 
-func checkDsStatus(user string) framework.TestResp {
+func checkDsStatus(tc *framework.TestContext) framework.TestResp {
+	daemonSetNameWithUser := tc.NameWithUser(daemonSetName)
 	podList := corev1.PodList{}
-	err := wait.Poll(framework.WaitInterval, framework.WaitTimeout,
+	err := wait.Poll(tc.WaitInterval, tc.WaitTimeout,
 		func() (bool, error) {
-			err := targetClient.Cache().List(context.TODO(), &podList, &client.ListOptions{
-				Namespace:     framework.NamespaceName,
+			err := tc.TargetClusterClient.Cache().List(context.TODO(), &podList, &client.ListOptions{
+				Namespace:     tc.NamespaceName,
 				LabelSelector: labels.Set{"kubecube.io/app": daemonSetNameWithUser}.AsSelector(),
 			})
 			if err != nil {
@@ -101,39 +103,40 @@ func checkDsStatus(user string) framework.TestResp {
 				return true, nil
 			}
 		})
-	framework.ExpectNoError(err)
-	framework.ExpectNotEqual(len(podList.Items), 0)
+	tc.ExpectNoError(err)
+	tc.ExpectNotEqual(len(podList.Items), 0)
 	ginkgo.By("查看容器详情")
 	pod := podList.Items[0]
-	framework.ExpectEqual(len(pod.Spec.Containers), 1)
+	tc.ExpectEqual(len(pod.Spec.Containers), 1)
 	container := pod.Spec.Containers[0]
-	framework.ExpectEqual(container.Name, daemonSetNameWithUser)
-	framework.ExpectEqual(container.Image, framework.TestImage)
+	tc.ExpectEqual(container.Name, daemonSetNameWithUser)
+	tc.ExpectEqual(container.Image, tc.TestImage)
 	ginkgo.By("查看容器日志")
-	url := BuildLogUrl(framework.KubecubeHost, framework.TargetClusterName, framework.NamespaceName, pod.Name, container.Name)
-	logResp, err := httpHelper.RequestByUser(http.MethodGet, url, "", user, nil)
-	framework.ExpectNoError(err)
+	url := BuildLogUrl(tc.KubecubeHost, tc.TargetClusterName, tc.NamespaceName, pod.Name, container.Name)
+	logResp, err := tc.HttpHelper.RequestByUser(http.MethodGet, url, "", tc.User, nil)
+	tc.ExpectNoError(err)
 	if !framework.IsSuccess(logResp.StatusCode) {
 		clog.Warn("res code %d", logResp.StatusCode)
 		return framework.NewTestResp(fmt.Errorf("fail to get pod %s log", pod.Name), logResp.StatusCode)
 	}
 
-	framework.ExpectEqual(logResp.StatusCode, 200)
+	tc.ExpectEqual(logResp.StatusCode, 200)
 	return framework.SucceedResp
 }
 
-func checkDsEvent(user string) framework.TestResp {
+func checkDsEvent(tc *framework.TestContext) framework.TestResp {
+	daemonSetNameWithUser := tc.NameWithUser(daemonSetName)
 	ds := v1.DaemonSet{}
-	err := targetClient.Cache().Get(context.TODO(), types.NamespacedName{
+	err := tc.TargetClusterClient.Cache().Get(context.TODO(), types.NamespacedName{
 		Name:      daemonSetNameWithUser,
-		Namespace: framework.NamespaceName,
+		Namespace: tc.NamespaceName,
 	}, &ds)
-	framework.ExpectNoError(err)
-	url := BuildEventUrl(framework.KubecubeHost, framework.TargetClusterName, framework.NamespaceName, string(ds.UID))
-	resp, err := httpHelper.RequestByUser(http.MethodGet, url, "", user, nil)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
+	url := BuildEventUrl(tc.KubecubeHost, tc.TargetClusterName, tc.NamespaceName, string(ds.UID))
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodGet, url, "", tc.User, nil)
+	tc.ExpectNoError(err)
 	body, err := io.ReadAll(resp.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 
 	if !framework.IsSuccess(resp.StatusCode) {
 		clog.Warn("res code %d", resp.StatusCode)
@@ -142,16 +145,17 @@ func checkDsEvent(user string) framework.TestResp {
 
 	eventList := corev1.EventList{}
 	err = json.Unmarshal(body, &eventList)
-	framework.ExpectNoError(err)
-	framework.ExpectNotEqual(len(eventList.Items), 0)
+	tc.ExpectNoError(err)
+	tc.ExpectNotEqual(len(eventList.Items), 0)
 	return framework.SucceedResp
 }
 
-func checkDsPodEvent(user string) framework.TestResp {
+func checkDsPodEvent(tc *framework.TestContext) framework.TestResp {
+	daemonSetNameWithUser := tc.NameWithUser(daemonSetName)
 	podList := corev1.PodList{}
-	err := wait.Poll(framework.WaitInterval, framework.WaitTimeout, func() (done bool, err error) {
-		err = targetClient.Direct().List(context.TODO(), &podList, &client.ListOptions{
-			Namespace:     framework.NamespaceName,
+	err := wait.Poll(tc.WaitInterval, tc.WaitTimeout, func() (done bool, err error) {
+		err = tc.TargetClusterClient.Direct().List(context.TODO(), &podList, &client.ListOptions{
+			Namespace:     tc.NamespaceName,
 			LabelSelector: labels.Set{"kubecube.io/app": daemonSetNameWithUser}.AsSelector(),
 		})
 		if err != nil {
@@ -163,14 +167,14 @@ func checkDsPodEvent(user string) framework.TestResp {
 		return true, nil
 	})
 
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 
 	pod := podList.Items[0]
-	url := BuildEventUrl(framework.KubecubeHost, framework.TargetClusterName, framework.NamespaceName, string(pod.UID))
-	resp, err := httpHelper.RequestByUser(http.MethodGet, url, "", user, nil)
-	framework.ExpectNoError(err)
+	url := BuildEventUrl(tc.KubecubeHost, tc.TargetClusterName, tc.NamespaceName, string(pod.UID))
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodGet, url, "", tc.User, nil)
+	tc.ExpectNoError(err)
 	body, err := io.ReadAll(resp.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 
 	if !framework.IsSuccess(resp.StatusCode) {
 		clog.Warn("res code %d", resp.StatusCode)
@@ -179,20 +183,21 @@ func checkDsPodEvent(user string) framework.TestResp {
 
 	eventList := corev1.EventList{}
 	err = json.Unmarshal(body, &eventList)
-	framework.ExpectNotEqual(len(eventList.Items), 0)
+	tc.ExpectNotEqual(len(eventList.Items), 0)
 	return framework.SucceedResp
 }
 
-func checkDsPerformance(user string) framework.TestResp {
+func checkDsPerformance(tc *framework.TestContext) framework.TestResp {
 	// TODO
 	return framework.SucceedResp
 }
 
-func checkDsCondition(user string) framework.TestResp {
-	err := wait.Poll(framework.WaitInterval, framework.WaitTimeout, func() (done bool, err error) {
+func checkDsCondition(tc *framework.TestContext) framework.TestResp {
+	daemonSetNameWithUser := tc.NameWithUser(daemonSetName)
+	err := wait.Poll(tc.WaitInterval, tc.WaitTimeout, func() (done bool, err error) {
 		podList := corev1.PodList{}
-		err = targetClient.Direct().List(context.TODO(), &podList, &client.ListOptions{
-			Namespace:     framework.NamespaceName,
+		err = tc.TargetClusterClient.Direct().List(context.TODO(), &podList, &client.ListOptions{
+			Namespace:     tc.NamespaceName,
 			LabelSelector: labels.Set{"kubecube.io/app": daemonSetNameWithUser}.AsSelector(),
 		})
 		if err != nil {
@@ -207,18 +212,18 @@ func checkDsCondition(user string) framework.TestResp {
 		}
 		return true, nil
 	})
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	return framework.SucceedResp
 }
 
-func checkDsUpdate(user string) framework.TestResp {
-	updateJson := `{"apiVersion":"apps/v1","kind":"DaemonSet","metadata":{"labels":{"kubecube.io/app":"%s","system/tenant":"netease.share"},"name":"%s","namespace":"%s"},"spec":{"minReadySeconds":30,"revisionHistoryLimit":10,"selector":{"matchLabels":{"kubecube.io/app":"%s"}},"template":{"metadata":{"annotations":{"annotation1":"annotation1"},"creationTimestamp":null,"labels":{"kubecube.io/app":"%s","label1":"label1"}},"spec":{"affinity":{},"containers":[{"name":"%s","args":[],"command":[],"env":[],"image":"%s","imagePullPolicy":"IfNotPresent","lifecycle":{"postStart":null,"preStop":null},"livenessProbe":null,"readinessProbe":null,"ports":null,"resources":{"limits":{"cpu":"500m","memory":"512Mi"},"requests":{"cpu":"500m","memory":"512Mi"}},"volumeMounts":[]}],"dnsPolicy":"ClusterFirst","restartPolicy":"Always","schedulerName":"default-scheduler","securityContext":{},"terminationGracePeriodSeconds":30,"initContainers":[],"imagePullSecrets":[{"name":"%s"}],"tolerations":[{"key":"example-key","operator":"Equal","value":"example-value","effect":"NoExecute","tolerationSeconds":30}],"volumes":[]}},"updateStrategy":{"rollingUpdate":{"maxUnavailable":10},"type":"RollingUpdate"}}}`
-	updateJson = fmt.Sprintf(updateJson, daemonSetNameWithUser, daemonSetNameWithUser, framework.NamespaceName, daemonSetNameWithUser, daemonSetNameWithUser, daemonSetNameWithUser, framework.TestImage, framework.ImagePullSecret)
-	url := BuildK8sProxyUrl(framework.KubecubeHost, framework.TargetClusterName, "apis/apps/v1", framework.NamespaceName, "daemonsets", daemonSetNameWithUser)
-	updateResp, err := httpHelper.RequestByUser(http.MethodPut, url, updateJson, user, nil)
-	framework.ExpectNoError(err)
+func checkDsUpdate(tc *framework.TestContext) framework.TestResp {
+	daemonSetNameWithUser := tc.NameWithUser(daemonSetName)
+	updateJson := fixtures.MustRender(tc, "daemonset-update.yaml", fixtures.For(tc, daemonSetNameWithUser))
+	url := BuildK8sProxyUrl(tc.KubecubeHost, tc.TargetClusterName, "apis/apps/v1", tc.NamespaceName, "daemonsets", daemonSetNameWithUser)
+	updateResp, err := tc.HttpHelper.RequestByUser(http.MethodPut, url, updateJson, tc.User, nil)
+	tc.ExpectNoError(err)
 	_, err = io.ReadAll(updateResp.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 
 	if !framework.IsSuccess(updateResp.StatusCode) {
 		clog.Warn("res code %d", updateResp.StatusCode)
@@ -227,17 +232,17 @@ func checkDsUpdate(user string) framework.TestResp {
 
 	podList := corev1.PodList{}
 	ds := v1.DaemonSet{}
-	err = wait.Poll(framework.WaitInterval, framework.WaitTimeout, func() (done bool, err error) {
-		err = targetClient.Direct().Get(context.TODO(), types.NamespacedName{
+	err = wait.Poll(tc.WaitInterval, tc.WaitTimeout, func() (done bool, err error) {
+		err = tc.TargetClusterClient.Direct().Get(context.TODO(), types.NamespacedName{
 			Name:      daemonSetNameWithUser,
-			Namespace: framework.NamespaceName,
+			Namespace: tc.NamespaceName,
 		}, &ds)
 		if err != nil {
 			return false, err
 		}
 
-		err = targetClient.Direct().List(context.TODO(), &podList, &client.ListOptions{
-			Namespace:     framework.NamespaceName,
+		err = tc.TargetClusterClient.Direct().List(context.TODO(), &podList, &client.ListOptions{
+			Namespace:     tc.NamespaceName,
 			LabelSelector: labels.Set{"kubecube.io/app": daemonSetNameWithUser}.AsSelector(),
 		})
 		if err != nil {
@@ -253,9 +258,9 @@ func checkDsUpdate(user string) framework.TestResp {
 		tolerationCheck := false
 		for _, toleration := range pod.Spec.Tolerations {
 			if toleration.Key == "example-key" {
-				framework.ExpectEqual(toleration.Effect, corev1.TaintEffectNoExecute)
-				framework.ExpectEqual(toleration.Operator, corev1.TolerationOpEqual)
-				framework.ExpectEqual(toleration.Value, "example-value")
+				tc.ExpectEqual(toleration.Effect, corev1.TaintEffectNoExecute)
+				tc.ExpectEqual(toleration.Operator, corev1.TolerationOpEqual)
+				tc.ExpectEqual(toleration.Value, "example-value")
 				tolerationCheck = true
 				break
 			}
@@ -298,14 +303,15 @@ func checkDsUpdate(user string) framework.TestResp {
 
 		return true, nil
 	})
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 
 	return framework.SucceedResp
 }
 
-func deleteDs(user string) framework.TestResp {
-	url := BuildK8sProxyUrl(framework.KubecubeHost, framework.TargetClusterName, "apis/apps/v1", framework.NamespaceName, "daemonsets", daemonSetNameWithUser)
-	resp, err := httpHelper.RequestByUser(http.MethodDelete, url, "", user, nil)
+func deleteDs(tc *framework.TestContext) framework.TestResp {
+	daemonSetNameWithUser := tc.NameWithUser(daemonSetName)
+	url := BuildK8sProxyUrl(tc.KubecubeHost, tc.TargetClusterName, "apis/apps/v1", tc.NamespaceName, "daemonsets", daemonSetNameWithUser)
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodDelete, url, "", tc.User, nil)
 	defer resp.Body.Close()
 
 	if !framework.IsSuccess(resp.StatusCode) {
@@ -313,15 +319,21 @@ func deleteDs(user string) framework.TestResp {
 		return framework.NewTestResp(fmt.Errorf("fail to delete ds %s", daemonSetNameWithUser), resp.StatusCode)
 	}
 
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	return framework.SucceedResp
 }
 
+// 依赖关系：检查与修改依赖创建成功，清理只依赖创建
+const (
+	stepCreateDs = "创建DaemonSet"
+	stepCheckDs  = "创建DaemonSet成功"
+)
+
 var multiUserDsTest = framework.MultiUserTest{
 	TestName:        "[工作负载][9478780]创建DaemonSet",
 	ContinueIfError: false,
-	Skipfunc: func() bool {
-		return !framework.DaemonSetEnable
+	Skipfunc: func(tc *framework.TestContext) bool {
+		return !tc.DaemonSetEnable
 	},
 	ErrorFunc:  framework.PermissionErrorFunc,
 	AfterEach:  nil,
@@ -330,7 +342,7 @@ var multiUserDsTest = framework.MultiUserTest{
 	FinalStep:  nil,
 	Steps: []framework.MultiUserTestStep{
 		{
-			Name: "创建DaemonSet",
+			Name: stepCreateDs,
 			Description: "1、进入工作负载》Daemonsets菜单，点击部署" +
 				"2、填写正确的负载名称、容器名称、镜像名称，点击立即创建",
 			StepFunc: createDs,
@@ -342,9 +354,10 @@ var multiUserDsTest = framework.MultiUserTest{
 			},
 		},
 		{
-			Name:        "创建DaemonSet成功",
+			Name:        stepCheckDs,
 			Description: "创建成功",
 			StepFunc:    checkDs,
+			DependsOn:   []string{stepCreateDs},
 			ExpectPass: map[string]bool{
 				framework.UserAdmin:        true,
 				framework.UserTenantAdmin:  true,
@@ -353,8 +366,9 @@ var multiUserDsTest = framework.MultiUserTest{
 			},
 		},
 		{
-			Name:     "列表中展示创建的DaemonSet",
-			StepFunc: checkDsList,
+			Name:      "列表中展示创建的DaemonSet",
+			StepFunc:  checkDsList,
+			DependsOn: []string{stepCheckDs},
 			ExpectPass: map[string]bool{
 				framework.UserAdmin:        true,
 				framework.UserTenantAdmin:  true,
@@ -366,6 +380,7 @@ var multiUserDsTest = framework.MultiUserTest{
 			Name:        "都可以查看到准确的对应信息",
 			Description: "查看副本基本信息",
 			StepFunc:    checkDsStatus,
+			DependsOn:   []string{stepCheckDs},
 			ExpectPass: map[string]bool{
 				framework.UserAdmin:        true,
 				framework.UserTenantAdmin:  true,
@@ -377,6 +392,7 @@ var multiUserDsTest = framework.MultiUserTest{
 			Name:        "DaemonSet事件前端页面和后台k8s命令显示一致",
 			Description: "查看DaemonSet事件",
 			StepFunc:    checkDsEvent,
+			DependsOn:   []string{stepCheckDs},
 			ExpectPass: map[string]bool{
 				framework.UserAdmin:        true,
 				framework.UserTenantAdmin:  true,
@@ -388,6 +404,7 @@ var multiUserDsTest = framework.MultiUserTest{
 			Name:        "副本事件前端页面和后台k8s命令显示一致",
 			Description: "查看副本事件",
 			StepFunc:    checkDsPodEvent,
+			DependsOn:   []string{stepCheckDs},
 			ExpectPass: map[string]bool{
 				framework.UserAdmin:        true,
 				framework.UserTenantAdmin:  true,
@@ -399,6 +416,7 @@ var multiUserDsTest = framework.MultiUserTest{
 			Name:        "正确返回负载各项性能指标",
 			Description: "查看副本的性能指标",
 			StepFunc:    checkDsPerformance,
+			DependsOn:   []string{stepCheckDs},
 			ExpectPass: map[string]bool{
 				framework.UserAdmin:        true,
 				framework.UserTenantAdmin:  true,
@@ -410,6 +428,7 @@ var multiUserDsTest = framework.MultiUserTest{
 			Name:        "DaemonSet和副本的conditions与k8s查询一致",
 			Description: "查看副本的condition详情与k8s的是否一致",
 			StepFunc:    checkDsCondition,
+			DependsOn:   []string{stepCheckDs},
 			ExpectPass: map[string]bool{
 				framework.UserAdmin:        true,
 				framework.UserTenantAdmin:  true,
@@ -421,6 +440,7 @@ var multiUserDsTest = framework.MultiUserTest{
 			Name:        "修改DaemonSet",
 			Description: "以上修改均能成功",
 			StepFunc:    checkDsUpdate,
+			DependsOn:   []string{stepCheckDs},
 			ExpectPass: map[string]bool{
 				framework.UserAdmin:        true,
 				framework.UserTenantAdmin:  true,
@@ -432,6 +452,7 @@ var multiUserDsTest = framework.MultiUserTest{
 			Name:        "clean DaemonSet",
 			Description: "clean DaemonSet",
 			StepFunc:    deleteDs,
+			DependsOn:   []string{stepCreateDs},
 			ExpectPass: map[string]bool{
 				framework.UserAdmin:        true,
 				framework.UserTenantAdmin:  true,
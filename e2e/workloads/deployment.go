@@ -32,19 +32,23 @@ import (
 	"k8s.io/apimachinery/pkg/util/wait"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/kubecube-io/kubecube-e2e/e2e/fixtures"
 	"github.com/kubecube-io/kubecube-e2e/e2e/framework"
 	"github.com/kubecube-io/kubecube/pkg/clog"
 )
 
-func createDeployWithPvc(user string) framework.TestResp {
+func createDeployWithPvc(tc *framework.TestContext) framework.TestResp {
+	deployNameWithUser := tc.NameWithUser(deployName)
+	pv1NameWithUser := tc.NameWithUser(pv1Name)
+	pv2NameWithUser := tc.NameWithUser(pv2Name)
 	ginkgo.By("创建存储声明pv1，容量100Mi、创建方式动态持久化存储、独占读写模式")
-	pv1Json := "{\"apiVersion\":\"v1\",\"kind\":\"PersistentVolumeClaim\",\"metadata\":{\"name\":\"" + pv1NameWithUser + "\",\"annotations\":{},\"labels\":{}},\"spec\":{\"storageClassName\":\"" + framework.StorageClass + "\",\"accessModes\":[\"ReadWriteOnce\"],\"resources\":{\"requests\":{\"storage\":\"100Mi\"}}}}"
-	url := BuildK8sProxyUrl(framework.KubecubeHost, framework.TargetClusterName, "api/v1", framework.NamespaceName, "persistentvolumeclaims", "")
-	pv1Response, err := httpHelper.RequestByUser(http.MethodPost, url, pv1Json, user, nil)
-	framework.ExpectNoError(err)
+	pv1Json := fixtures.MustRender(tc, "pvc.yaml", fixtures.For(tc, pv1NameWithUser).With("accessMode", "ReadWriteOnce").With("storage", "100Mi"))
+	url := BuildK8sProxyUrl(tc.KubecubeHost, tc.TargetClusterName, "api/v1", tc.NamespaceName, "persistentvolumeclaims", "")
+	pv1Response, err := tc.HttpHelper.RequestByUser(http.MethodPost, url, pv1Json, tc.User, nil)
+	tc.ExpectNoError(err)
 	defer pv1Response.Body.Close()
 	body, err := io.ReadAll(pv1Response.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	clog.Info("get pvc pv1, %v", string(body))
 
 	if !framework.IsSuccess(pv1Response.StatusCode) {
@@ -53,12 +57,12 @@ func createDeployWithPvc(user string) framework.TestResp {
 	}
 
 	ginkgo.By("创建存储声明pv2，容量200Mi、创建方式动态持久化存储、只读共享")
-	pv2Json := "{\"apiVersion\":\"v1\",\"kind\":\"PersistentVolumeClaim\",\"metadata\":{\"name\":\"" + pv2NameWithUser + "\",\"annotations\":{},\"labels\":{}},\"spec\":{\"storageClassName\":\"" + framework.StorageClass + "\",\"accessModes\":[\"ReadOnlyMany\"],\"resources\":{\"requests\":{\"storage\":\"200Mi\"}}}}"
-	pv2Response, err := httpHelper.RequestByUser(http.MethodPost, url, pv2Json, user, nil)
-	framework.ExpectNoError(err)
+	pv2Json := fixtures.MustRender(tc, "pvc.yaml", fixtures.For(tc, pv2NameWithUser).With("accessMode", "ReadOnlyMany").With("storage", "200Mi"))
+	pv2Response, err := tc.HttpHelper.RequestByUser(http.MethodPost, url, pv2Json, tc.User, nil)
+	tc.ExpectNoError(err)
 	defer pv2Response.Body.Close()
 	body, err = io.ReadAll(pv2Response.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	clog.Info("get pvc pv2, %v", string(body))
 
 	if !framework.IsSuccess(pv2Response.StatusCode) {
@@ -72,14 +76,13 @@ func createDeployWithPvc(user string) framework.TestResp {
 	ginkgo.By("4. 选择高级模式，pvc1挂载/mnt1/目录；挂载pvc2到/mnt2/目录")
 	ginkgo.By("5. 添加启动命令 sh\n启动命令参数\n-c\nwhile true;do echo hello;sleep 1;done")
 	ginkgo.By("6. 设置标签label1=label1")
-	deployJson := `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"%s","annotations":{},"labels":{"kubecube.io/app":"%s"}},"spec":{"selector":{"matchLabels":{"kubecube.io/app":"%s"}},"template":{"metadata":{"annotations":{},"labels":{"label1":"label1","kubecube.io/app":"%s"}},"spec":{"containers":[{"name":"%s","args":["-c","while true;do echo hello;sleep 1;done"],"command":["sh"],"env":[{"name":"NCE_PORT","value":"18080"},{"name":"NCE_JAVA_OPTS","value":"-Dstock_provider_url=http://demo-data.ns2:8088"}],"image":"%s","imagePullPolicy":"IfNotPresent","lifecycle":{"postStart":null,"preStop":null},"livenessProbe":null,"readinessProbe":null,"ports":null,"resources":{"limits":{"cpu":"50m","memory":"50Mi"},"requests":{"cpu":"50m","memory":"50Mi"}},"volumeMounts":[{"name":"data-volume-0-0","readOnly":false,"mountPath":"/mnt1/","subPath":""},{"name":"data-volume-0-1","readOnly":false,"mountPath":"/mnt2/","subPath":""}]}],"initContainers":[],"imagePullSecrets":[{"name":"%s"}],"volumes":[{"name":"data-volume-0-0","persistentVolumeClaim":{"claimName":"%s","readOnly":false}},{"name":"data-volume-0-1","persistentVolumeClaim":{"claimName":"%s","readOnly":false}}],"affinity":{},"restartPolicy":"Always"}},"replicas":1}}`
-	deployJson = fmt.Sprintf(deployJson, deployNameWithUser, deployNameWithUser, deployNameWithUser, deployNameWithUser, deployNameWithUser, framework.TestImage, framework.ImagePullSecret, pv1NameWithUser, pv2NameWithUser)
-	deployUrl := BuildK8sProxyUrl(framework.KubecubeHost, framework.TargetClusterName, "apis/apps/v1", framework.NamespaceName, "deployments", "")
-	deployResponse, err := httpHelper.RequestByUser(http.MethodPost, deployUrl, deployJson, user, nil)
-	framework.ExpectNoError(err)
+	deployJson := fixtures.MustRender(tc, "deployment-with-pvc.yaml", fixtures.For(tc, deployNameWithUser).With("claim1", pv1NameWithUser).With("claim2", pv2NameWithUser))
+	deployUrl := BuildK8sProxyUrl(tc.KubecubeHost, tc.TargetClusterName, "apis/apps/v1", tc.NamespaceName, "deployments", "")
+	deployResponse, err := tc.HttpHelper.RequestByUser(http.MethodPost, deployUrl, deployJson, tc.User, nil)
+	tc.ExpectNoError(err)
 	defer deployResponse.Body.Close()
 	body, err = io.ReadAll(deployResponse.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	clog.Info("create deploy %v, %v", deployNameWithUser, string(body))
 
 	if !framework.IsSuccess(deployResponse.StatusCode) {
@@ -88,11 +91,11 @@ func createDeployWithPvc(user string) framework.TestResp {
 	}
 
 	deploy := v1.Deployment{}
-	err = wait.Poll(framework.WaitInterval, framework.WaitTimeout,
+	err = wait.Poll(tc.WaitInterval, tc.WaitTimeout,
 		func() (bool, error) {
-			err := targetClient.Cache().Get(context.TODO(), types.NamespacedName{
+			err := tc.TargetClusterClient.Cache().Get(context.TODO(), types.NamespacedName{
 				Name:      deployNameWithUser,
-				Namespace: framework.NamespaceName,
+				Namespace: tc.NamespaceName,
 			}, &deploy)
 			if err != nil {
 				return false, nil
@@ -100,24 +103,24 @@ func createDeployWithPvc(user string) framework.TestResp {
 				return true, nil
 			}
 		})
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	return framework.SucceedResp
 }
 
-func createDeployWithoutPvc(user string) framework.TestResp {
+func createDeployWithoutPvc(tc *framework.TestContext) framework.TestResp {
+	deployNameWithUser := tc.NameWithUser(deployName)
 	ginkgo.By("1.在空间内创建工作负载使用demo镜像：hub.c.163.com/qingzhou/nsf-demo-stock-viewer:online-20180921-181512-b09dbe78,填写副本数1")
 	ginkgo.By("2. 环境变量NCE_PORT=18080 NCE_JAVA_OPTS=-Dstock_provider_url=http://demo-data.ns2:8088（供空间内服务发现case使用）")
 	ginkgo.By("3. 规格选低性能基础配置*5 （50M Cores/50MiB）")
 	ginkgo.By("5. 添加启动命令 sh\n启动命令参数\n-c\nwhile true;do echo hello;sleep 1;done")
 	ginkgo.By("6. 设置标签label1=label1")
-	deployJson := `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"%s","annotations":{},"labels":{"kubecube.io/app":"%s"}},"spec":{"selector":{"matchLabels":{"kubecube.io/app":"%s"}},"template":{"metadata":{"annotations":{},"labels":{"label1":"label1","kubecube.io/app":"%s"}},"spec":{"containers":[{"name":"%s","args":["-c","while true;do echo hello;sleep 1;done"],"command":["sh"],"env":[{"name":"NCE_PORT","value":"18080"},{"name":"NCE_JAVA_OPTS","value":"-Dstock_provider_url=http://demo-data.ns2:8088"}],"image":"%s","imagePullPolicy":"IfNotPresent","lifecycle":{"postStart":null,"preStop":null},"livenessProbe":null,"readinessProbe":null,"ports":null,"resources":{"limits":{"cpu":"50m","memory":"50Mi"},"requests":{"cpu":"50m","memory":"50Mi"}}}],"initContainers":[],"imagePullSecrets":[{"name":"%s"}],"affinity":{},"restartPolicy":"Always"}},"replicas":1}}`
-	deployJson = fmt.Sprintf(deployJson, deployNameWithUser, deployNameWithUser, deployNameWithUser, deployNameWithUser, deployNameWithUser, framework.TestImage, framework.ImagePullSecret)
-	deployUrl := BuildK8sProxyUrl(framework.KubecubeHost, framework.TargetClusterName, "apis/apps/v1", framework.NamespaceName, "deployments", "")
-	deployResponse, err := httpHelper.RequestByUser(http.MethodPost, deployUrl, deployJson, user, nil)
-	framework.ExpectNoError(err)
+	deployJson := fixtures.MustRender(tc, "deployment.yaml", fixtures.For(tc, deployNameWithUser))
+	deployUrl := BuildK8sProxyUrl(tc.KubecubeHost, tc.TargetClusterName, "apis/apps/v1", tc.NamespaceName, "deployments", "")
+	deployResponse, err := tc.HttpHelper.RequestByUser(http.MethodPost, deployUrl, deployJson, tc.User, nil)
+	tc.ExpectNoError(err)
 	defer deployResponse.Body.Close()
 	body, err := io.ReadAll(deployResponse.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	clog.Info("create deploy %v, %v", deployNameWithUser, string(body))
 
 	if !framework.IsSuccess(deployResponse.StatusCode) {
@@ -126,11 +129,11 @@ func createDeployWithoutPvc(user string) framework.TestResp {
 	}
 
 	deploy := v1.Deployment{}
-	err = wait.Poll(framework.WaitInterval, framework.WaitTimeout,
+	err = wait.Poll(tc.WaitInterval, tc.WaitTimeout,
 		func() (bool, error) {
-			err := targetClient.Cache().Get(context.TODO(), types.NamespacedName{
+			err := tc.TargetClusterClient.Cache().Get(context.TODO(), types.NamespacedName{
 				Name:      deployNameWithUser,
-				Namespace: framework.NamespaceName,
+				Namespace: tc.NamespaceName,
 			}, &deploy)
 			if err != nil {
 				return false, nil
@@ -138,35 +141,32 @@ func createDeployWithoutPvc(user string) framework.TestResp {
 				return true, nil
 			}
 		})
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	return framework.SucceedResp
 }
 
-func createDeploy(user string) framework.TestResp {
-	initParam()
-	pv1NameWithUser = framework.NameWithUser(pv1Name, user)
-	pv2NameWithUser = framework.NameWithUser(pv2Name, user)
-	deployNameWithUser = framework.NameWithUser(deployName, user)
-	if framework.PVEnabled {
+func createDeploy(tc *framework.TestContext) framework.TestResp {
+	if tc.HasCapability(framework.CapabilityPV) {
 		clog.Info("createDeployWithPv")
-		return createDeployWithPvc(user)
+		return createDeployWithPvc(tc)
 	} else {
 		clog.Info("createDeployWithoutPv")
-		return createDeployWithoutPvc(user)
+		return createDeployWithoutPvc(tc)
 	}
 }
 
-func checkDeploy(user string) framework.TestResp {
-	err := wait.Poll(framework.WaitInterval, framework.WaitTimeout, func() (done bool, err error) {
+func checkDeploy(tc *framework.TestContext) framework.TestResp {
+	deployNameWithUser := tc.NameWithUser(deployName)
+	err := wait.Poll(tc.WaitInterval, tc.WaitTimeout, func() (done bool, err error) {
 		deploy := v1.Deployment{}
-		err = targetClient.Direct().Get(context.TODO(), types.NamespacedName{
+		err = tc.TargetClusterClient.Direct().Get(context.TODO(), types.NamespacedName{
 			Name:      deployNameWithUser,
-			Namespace: framework.NamespaceName,
+			Namespace: tc.NamespaceName,
 		}, &deploy)
 		if err != nil {
 			return false, err
 		}
-		framework.ExpectEqual(deploy.Name, deployNameWithUser)
+		tc.ExpectEqual(deploy.Name, deployNameWithUser)
 		var i int32
 		i = 1
 		if deploy.Status.AvailableReplicas != i {
@@ -175,16 +175,17 @@ func checkDeploy(user string) framework.TestResp {
 		return true, nil
 	})
 
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 
 	return framework.SucceedResp
 }
 
-func checkDeployLog(user string) framework.TestResp {
+func checkDeployLog(tc *framework.TestContext) framework.TestResp {
+	deployNameWithUser := tc.NameWithUser(deployName)
 	podList := corev1.PodList{}
-	err := wait.Poll(framework.WaitInterval, framework.WaitTimeout, func() (done bool, err error) {
-		err = targetClient.Direct().List(context.TODO(), &podList, &client.ListOptions{
-			Namespace:     framework.NamespaceName,
+	err := wait.Poll(tc.WaitInterval, tc.WaitTimeout, func() (done bool, err error) {
+		err = tc.TargetClusterClient.Direct().List(context.TODO(), &podList, &client.ListOptions{
+			Namespace:     tc.NamespaceName,
 			LabelSelector: labels.Set{"kubecube.io/app": deployNameWithUser}.AsSelector(),
 		})
 		if err != nil {
@@ -196,16 +197,16 @@ func checkDeployLog(user string) framework.TestResp {
 		return true, nil
 	})
 
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	pod := podList.Items[0]
-	framework.ExpectEqual(len(pod.Spec.Containers), 1)
+	tc.ExpectEqual(len(pod.Spec.Containers), 1)
 
-	url := BuildLogUrl(framework.KubecubeHost, framework.TargetClusterName, framework.NamespaceName, pod.Name, pod.Spec.Containers[0].Name)
-	logResp, err := httpHelper.RequestByUser(http.MethodGet, url, "", user, nil)
-	framework.ExpectNoError(err)
+	url := BuildLogUrl(tc.KubecubeHost, tc.TargetClusterName, tc.NamespaceName, pod.Name, pod.Spec.Containers[0].Name)
+	logResp, err := tc.HttpHelper.RequestByUser(http.MethodGet, url, "", tc.User, nil)
+	tc.ExpectNoError(err)
 	defer logResp.Body.Close()
 	body, err := io.ReadAll(logResp.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 
 	if !framework.IsSuccess(logResp.StatusCode) {
 		clog.Warn("res code %d", logResp.StatusCode)
@@ -213,38 +214,41 @@ func checkDeployLog(user string) framework.TestResp {
 	}
 
 	if gjson.Get(string(body), "logs.0.content").Str != "hello" {
-		err = wait.Poll(framework.WaitInterval, framework.WaitTimeout, func() (done bool, err error) {
-			logResp, err := httpHelper.RequestByUser(http.MethodGet, url, "", user, nil)
-			framework.ExpectNoError(err)
+		err = wait.Poll(tc.WaitInterval, tc.WaitTimeout, func() (done bool, err error) {
+			logResp, err := tc.HttpHelper.RequestByUser(http.MethodGet, url, "", tc.User, nil)
+			tc.ExpectNoError(err)
 			defer logResp.Body.Close()
 			body, err := io.ReadAll(logResp.Body)
-			framework.ExpectNoError(err)
-			framework.ExpectEqual(framework.IsSuccess(logResp.StatusCode), true)
+			tc.ExpectNoError(err)
+			tc.ExpectEqual(framework.IsSuccess(logResp.StatusCode), true)
 			if gjson.Get(string(body), "logs.0.content").Str != "hello" {
 				return false, nil
 			}
 			return true, nil
 		})
-		framework.ExpectNoError(err)
+		tc.ExpectNoError(err)
 	}
 
 	return framework.SucceedResp
 }
 
-func checkDeployPv(user string) framework.TestResp {
-	if !framework.PVEnabled {
+func checkDeployPv(tc *framework.TestContext) framework.TestResp {
+	deployNameWithUser := tc.NameWithUser(deployName)
+	pv1NameWithUser := tc.NameWithUser(pv1Name)
+	pv2NameWithUser := tc.NameWithUser(pv2Name)
+	if !tc.HasCapability(framework.CapabilityPV) {
 		return framework.SucceedResp
 	}
 
 	podList := corev1.PodList{}
-	err := targetClient.Cache().List(context.TODO(), &podList, &client.ListOptions{
-		Namespace:     framework.NamespaceName,
+	err := tc.TargetClusterClient.Cache().List(context.TODO(), &podList, &client.ListOptions{
+		Namespace:     tc.NamespaceName,
 		LabelSelector: labels.Set{"kubecube.io/app": deployNameWithUser}.AsSelector(),
 	})
-	framework.ExpectNoError(err)
-	framework.ExpectEqual(len(podList.Items), 1)
+	tc.ExpectNoError(err)
+	tc.ExpectEqual(len(podList.Items), 1)
 	pod := podList.Items[0]
-	framework.ExpectEqual(len(pod.Spec.Containers), 1)
+	tc.ExpectEqual(len(pod.Spec.Containers), 1)
 	checkPv1Name := ""
 	checkPv2Name := ""
 	for _, volume := range pod.Spec.Volumes {
@@ -257,126 +261,130 @@ func checkDeployPv(user string) framework.TestResp {
 			continue
 		}
 	}
-	framework.ExpectNotEqual(checkPv1Name, "")
-	framework.ExpectNotEqual(checkPv2Name, "")
+	tc.ExpectNotEqual(checkPv1Name, "")
+	tc.ExpectNotEqual(checkPv2Name, "")
 	pv1Check := false
 	pv2Check := false
 	for _, volumeMount := range pod.Spec.Containers[0].VolumeMounts {
 		if volumeMount.Name == checkPv1Name {
-			framework.ExpectEqual(volumeMount.MountPath, "/mnt1/")
+			tc.ExpectEqual(volumeMount.MountPath, "/mnt1/")
 			pv1Check = true
 			continue
 		}
 		if volumeMount.Name == checkPv2Name {
-			framework.ExpectEqual(volumeMount.MountPath, "/mnt2/")
+			tc.ExpectEqual(volumeMount.MountPath, "/mnt2/")
 			pv2Check = true
 			continue
 		}
 	}
-	framework.ExpectEqual(pv1Check, true)
-	framework.ExpectEqual(pv2Check, true)
+	tc.ExpectEqual(pv1Check, true)
+	tc.ExpectEqual(pv2Check, true)
 	return framework.SucceedResp
 }
 
-func checkDeployInfo(user string) framework.TestResp {
+func checkDeployInfo(tc *framework.TestContext) framework.TestResp {
+	deployNameWithUser := tc.NameWithUser(deployName)
 	deploy := v1.Deployment{}
-	err := targetClient.Cache().Get(context.TODO(), types.NamespacedName{
+	err := tc.TargetClusterClient.Cache().Get(context.TODO(), types.NamespacedName{
 		Name:      deployNameWithUser,
-		Namespace: framework.NamespaceName,
+		Namespace: tc.NamespaceName,
 	}, &deploy)
-	framework.ExpectNoError(err)
-	framework.ExpectEqual(deploy.Name, deployNameWithUser)
+	tc.ExpectNoError(err)
+	tc.ExpectEqual(deploy.Name, deployNameWithUser)
 	var i int32
 	i = 1
-	framework.ExpectEqual(*deploy.Spec.Replicas, i)
+	tc.ExpectEqual(*deploy.Spec.Replicas, i)
 	return framework.SucceedResp
 }
 
-func checkDeployDetails(user string) framework.TestResp {
+func checkDeployDetails(tc *framework.TestContext) framework.TestResp {
+	deployNameWithUser := tc.NameWithUser(deployName)
 	deploy := v1.Deployment{}
-	err := targetClient.Cache().Get(context.TODO(), types.NamespacedName{
+	err := tc.TargetClusterClient.Cache().Get(context.TODO(), types.NamespacedName{
 		Name:      deployNameWithUser,
-		Namespace: framework.NamespaceName,
+		Namespace: tc.NamespaceName,
 	}, &deploy)
-	framework.ExpectNoError(err)
-	framework.ExpectEqual(deploy.Name, deployNameWithUser)
+	tc.ExpectNoError(err)
+	tc.ExpectEqual(deploy.Name, deployNameWithUser)
 	var i int32
 	i = 1
-	framework.ExpectEqual(*deploy.Spec.Replicas, i)
+	tc.ExpectEqual(*deploy.Spec.Replicas, i)
 	ginkgo.By("查看容器详情")
 	podList := corev1.PodList{}
-	err = targetClient.Cache().List(context.TODO(), &podList, &client.ListOptions{
-		Namespace:     framework.NamespaceName,
+	err = tc.TargetClusterClient.Cache().List(context.TODO(), &podList, &client.ListOptions{
+		Namespace:     tc.NamespaceName,
 		LabelSelector: labels.Set{"kubecube.io/app": deployNameWithUser}.AsSelector(),
 	})
-	framework.ExpectNoError(err)
-	framework.ExpectEqual(len(podList.Items), 1)
+	tc.ExpectNoError(err)
+	tc.ExpectEqual(len(podList.Items), 1)
 	pod := podList.Items[0]
-	framework.ExpectEqual(len(pod.Spec.Containers), 1)
+	tc.ExpectEqual(len(pod.Spec.Containers), 1)
 	container := pod.Spec.Containers[0]
-	framework.ExpectEqual(container.Image, framework.TestImage)
+	tc.ExpectEqual(container.Image, tc.TestImage)
 	ncePortCheck := false
 	nceOptsCheck := false
 	for _, env := range container.Env {
 		if env.Name == "NCE_PORT" {
-			framework.ExpectEqual(env.Value, "18080")
+			tc.ExpectEqual(env.Value, "18080")
 			ncePortCheck = true
 			continue
 		}
 		if env.Name == "NCE_JAVA_OPTS" {
-			framework.ExpectEqual(env.Value, "-Dstock_provider_url=http://demo-data.ns2:8088")
+			tc.ExpectEqual(env.Value, "-Dstock_provider_url=http://demo-data.ns2:8088")
 			nceOptsCheck = true
 			continue
 		}
 	}
-	framework.ExpectEqual(ncePortCheck, true)
-	framework.ExpectEqual(nceOptsCheck, true)
-	framework.ExpectEqual(container.Resources.Requests.Cpu().String(), "50m")
-	framework.ExpectEqual(container.Resources.Requests.Memory().String(), "50Mi")
-	framework.ExpectEqual(container.Command[0], "sh")
-	framework.ExpectEqual(container.Args[0], "-c")
-	framework.ExpectEqual(container.Args[1], "while true;do echo hello;sleep 1;done")
-	framework.ExpectHaveKey(pod.Labels, "label1")
+	tc.ExpectEqual(ncePortCheck, true)
+	tc.ExpectEqual(nceOptsCheck, true)
+	tc.ExpectEqual(container.Resources.Requests.Cpu().String(), "50m")
+	tc.ExpectEqual(container.Resources.Requests.Memory().String(), "50Mi")
+	tc.ExpectEqual(container.Command[0], "sh")
+	tc.ExpectEqual(container.Args[0], "-c")
+	tc.ExpectEqual(container.Args[1], "while true;do echo hello;sleep 1;done")
+	tc.ExpectHaveKey(pod.Labels, "label1")
 	return framework.SucceedResp
 }
 
-func checkDeployPerformance(user string) framework.TestResp {
+func checkDeployPerformance(tc *framework.TestContext) framework.TestResp {
 	// TODO
 	return framework.SucceedResp
 }
 
-func checkDeployConditions(user string) framework.TestResp {
+func checkDeployConditions(tc *framework.TestContext) framework.TestResp {
+	deployNameWithUser := tc.NameWithUser(deployName)
 	deploy := v1.Deployment{}
-	err := targetClient.Cache().Get(context.TODO(), types.NamespacedName{
+	err := tc.TargetClusterClient.Cache().Get(context.TODO(), types.NamespacedName{
 		Name:      deployNameWithUser,
-		Namespace: framework.NamespaceName,
+		Namespace: tc.NamespaceName,
 	}, &deploy)
-	framework.ExpectNoError(err)
-	framework.ExpectNotEqual(len(deploy.Status.Conditions), 0)
+	tc.ExpectNoError(err)
+	tc.ExpectNotEqual(len(deploy.Status.Conditions), 0)
 	podList := corev1.PodList{}
-	err = targetClient.Cache().List(context.TODO(), &podList, &client.ListOptions{
-		Namespace:     framework.NamespaceName,
+	err = tc.TargetClusterClient.Cache().List(context.TODO(), &podList, &client.ListOptions{
+		Namespace:     tc.NamespaceName,
 		LabelSelector: labels.Set{"kubecube.io/app": deployNameWithUser}.AsSelector(),
 	})
-	framework.ExpectNoError(err)
-	framework.ExpectEqual(len(podList.Items), 1)
+	tc.ExpectNoError(err)
+	tc.ExpectEqual(len(podList.Items), 1)
 	pod := podList.Items[0]
-	framework.ExpectNotEqual(len(pod.Status.Conditions), 0)
+	tc.ExpectNotEqual(len(pod.Status.Conditions), 0)
 	return framework.SucceedResp
 }
 
-func checkDeployEvents(user string) framework.TestResp {
+func checkDeployEvents(tc *framework.TestContext) framework.TestResp {
+	deployNameWithUser := tc.NameWithUser(deployName)
 	deploy := v1.Deployment{}
-	err := targetClient.Cache().Get(context.TODO(), types.NamespacedName{
+	err := tc.TargetClusterClient.Cache().Get(context.TODO(), types.NamespacedName{
 		Name:      deployNameWithUser,
-		Namespace: framework.NamespaceName,
+		Namespace: tc.NamespaceName,
 	}, &deploy)
-	framework.ExpectNoError(err)
-	url := BuildEventUrl(framework.KubecubeHost, framework.TargetClusterName, framework.NamespaceName, string(deploy.UID))
-	resp, err := httpHelper.RequestByUser(http.MethodGet, url, "", user, nil)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
+	url := BuildEventUrl(tc.KubecubeHost, tc.TargetClusterName, tc.NamespaceName, string(deploy.UID))
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodGet, url, "", tc.User, nil)
+	tc.ExpectNoError(err)
 	body, err := io.ReadAll(resp.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 
 	if !framework.IsSuccess(resp.StatusCode) {
 		clog.Warn("res code %d", resp.StatusCode)
@@ -385,25 +393,26 @@ func checkDeployEvents(user string) framework.TestResp {
 
 	eventList := corev1.EventList{}
 	err = json.Unmarshal(body, &eventList)
-	framework.ExpectNoError(err)
-	framework.ExpectNotEqual(len(eventList.Items), 0)
+	tc.ExpectNoError(err)
+	tc.ExpectNotEqual(len(eventList.Items), 0)
 	return framework.SucceedResp
 }
 
-func checkDeployPodEvents(user string) framework.TestResp {
+func checkDeployPodEvents(tc *framework.TestContext) framework.TestResp {
+	deployNameWithUser := tc.NameWithUser(deployName)
 	podList := corev1.PodList{}
-	err := targetClient.Direct().List(context.TODO(), &podList, &client.ListOptions{
-		Namespace:     framework.NamespaceName,
+	err := tc.TargetClusterClient.Direct().List(context.TODO(), &podList, &client.ListOptions{
+		Namespace:     tc.NamespaceName,
 		LabelSelector: labels.Set{"kubecube.io/app": deployNameWithUser}.AsSelector(),
 	})
-	framework.ExpectNoError(err)
-	framework.ExpectEqual(len(podList.Items), 1)
+	tc.ExpectNoError(err)
+	tc.ExpectEqual(len(podList.Items), 1)
 	pod := podList.Items[0]
-	url := BuildEventUrl(framework.KubecubeHost, framework.TargetClusterName, framework.NamespaceName, string(pod.UID))
-	resp, err := httpHelper.RequestByUser(http.MethodGet, url, "", user, nil)
-	framework.ExpectNoError(err)
+	url := BuildEventUrl(tc.KubecubeHost, tc.TargetClusterName, tc.NamespaceName, string(pod.UID))
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodGet, url, "", tc.User, nil)
+	tc.ExpectNoError(err)
 	body, err := io.ReadAll(resp.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 
 	if !framework.IsSuccess(resp.StatusCode) {
 		clog.Warn("res code %d", resp.StatusCode)
@@ -412,23 +421,25 @@ func checkDeployPodEvents(user string) framework.TestResp {
 
 	eventList := corev1.EventList{}
 	err = json.Unmarshal(body, &eventList)
-	framework.ExpectNotEqual(len(eventList.Items), 0)
+	tc.ExpectNotEqual(len(eventList.Items), 0)
 	return framework.SucceedResp
 }
 
-func updateDeployConfig(user string) framework.TestResp {
-	if !framework.PVEnabled {
+func updateDeployConfig(tc *framework.TestContext) framework.TestResp {
+	deployNameWithUser := tc.NameWithUser(deployName)
+	pv1NameWithUser := tc.NameWithUser(pv1Name)
+	pv2NameWithUser := tc.NameWithUser(pv2Name)
+	if !tc.HasCapability(framework.CapabilityPV) {
 		return framework.SucceedResp
 	}
 
-	updateDeployJson := `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"labels":{"kubecube.io/app":"%s"},"name":"%s","namespace":"%s"},"spec":{"progressDeadlineSeconds":600,"replicas":1,"revisionHistoryLimit":10,"selector":{"matchLabels":{"kubecube.io/app":"%s"}},"strategy":{"rollingUpdate":{},"type":"RollingUpdate"},"template":{"metadata":{"creationTimestamp":null,"labels":{"kubecube.io/app":"%s","label1":"label1"},"annotations":{}},"spec":{"affinity":{"nodeAffinity":{"requiredDuringSchedulingIgnoredDuringExecution":{"nodeSelectorTerms":[{"matchExpressions":[{"key":"node.kubecube.io/tenant","operator":"In","values":["share"]}]}]}}},"containers":[{"name":"%s","args":["-c","while true;do echo hello;sleep 1;done"],"command":["sh"],"env":[{"name":"NCE_PORT","value":"18080"},{"name":"NCE_JAVA_OPTS","value":"-Dstock_provider_url=http://demo-data.ns2:8088"}],"image":"%s","imagePullPolicy":"IfNotPresent","lifecycle":{"postStart":null,"preStop":null},"livenessProbe":null,"readinessProbe":null,"ports":null,"resources":{"limits":{"cpu":"50m","memory":"50Mi"},"requests":{"cpu":"50m","memory":"50Mi"}},"volumeMounts":[{"name":"data-volume-0-0","readOnly":false,"mountPath":"/mnt1"}]}],"dnsPolicy":"ClusterFirst","restartPolicy":"Always","schedulerName":"default-scheduler","securityContext":{},"terminationGracePeriodSeconds":30,"tolerations":[{"key":"node.kubecube.io","operator":"Exists","effect":"NoSchedule"}],"volumes":[{"name":"data-volume-0-0","persistentVolumeClaim":{"claimName":"%s","readOnly":false}}],"initContainers":[],"imagePullSecrets":[{"name":"%s"}]}}}}`
-	updateDeployJson = fmt.Sprintf(updateDeployJson, deployNameWithUser, deployNameWithUser, framework.NamespaceName, deployNameWithUser, deployNameWithUser, deployNameWithUser, framework.TestImage, pv1NameWithUser, framework.ImagePullSecret)
-	deployUrl := BuildK8sProxyUrl(framework.KubecubeHost, framework.TargetClusterName, "apis/apps/v1", framework.NamespaceName, "deployments", deployNameWithUser)
-	resp, err := httpHelper.RequestByUser(http.MethodPut, deployUrl, updateDeployJson, user, nil)
-	framework.ExpectNoError(err)
+	updateDeployJson := fixtures.MustRender(tc, "deployment-update.yaml", fixtures.For(tc, deployNameWithUser).With("claim", pv1NameWithUser))
+	deployUrl := BuildK8sProxyUrl(tc.KubecubeHost, tc.TargetClusterName, "apis/apps/v1", tc.NamespaceName, "deployments", deployNameWithUser)
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodPut, deployUrl, updateDeployJson, tc.User, nil)
+	tc.ExpectNoError(err)
 	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	clog.Info("update deploy resp: %s", string(body))
 
 	if !framework.IsSuccess(resp.StatusCode) {
@@ -437,10 +448,10 @@ func updateDeployConfig(user string) framework.TestResp {
 	}
 
 	podList := corev1.PodList{}
-	err = wait.Poll(framework.WaitInterval, framework.WaitTimeout,
+	err = wait.Poll(tc.WaitInterval, tc.WaitTimeout,
 		func() (bool, error) {
-			err = targetClient.Direct().List(context.TODO(), &podList, &client.ListOptions{
-				Namespace:     framework.NamespaceName,
+			err = tc.TargetClusterClient.Direct().List(context.TODO(), &podList, &client.ListOptions{
+				Namespace:     tc.NamespaceName,
 				LabelSelector: labels.Set{"kubecube.io/app": deployNameWithUser}.AsSelector(),
 			})
 			if err != nil {
@@ -449,10 +460,10 @@ func updateDeployConfig(user string) framework.TestResp {
 				return true, nil
 			}
 		})
-	framework.ExpectNoError(err)
-	framework.ExpectEqual(len(podList.Items), 1)
+	tc.ExpectNoError(err)
+	tc.ExpectEqual(len(podList.Items), 1)
 	pod := podList.Items[0]
-	framework.ExpectEqual(len(pod.Spec.Containers), 1)
+	tc.ExpectEqual(len(pod.Spec.Containers), 1)
 	checkPv1Name := ""
 	checkPv2Name := ""
 	for _, volume := range pod.Spec.Volumes {
@@ -465,35 +476,36 @@ func updateDeployConfig(user string) framework.TestResp {
 			continue
 		}
 	}
-	framework.ExpectNotEqual(checkPv1Name, "")
-	framework.ExpectEqual(checkPv2Name, "")
+	tc.ExpectNotEqual(checkPv1Name, "")
+	tc.ExpectEqual(checkPv2Name, "")
 	pv1Check := false
 	pv2Check := false
 	for _, volumeMount := range pod.Spec.Containers[0].VolumeMounts {
 		if volumeMount.Name == checkPv1Name {
-			framework.ExpectEqual(volumeMount.MountPath, "/mnt1")
+			tc.ExpectEqual(volumeMount.MountPath, "/mnt1")
 			pv1Check = true
 			continue
 		}
 		if volumeMount.Name == checkPv2Name {
-			framework.ExpectEqual(volumeMount.MountPath, "/mnt2")
+			tc.ExpectEqual(volumeMount.MountPath, "/mnt2")
 			pv2Check = true
 			continue
 		}
 	}
-	framework.ExpectEqual(pv1Check, true)
-	framework.ExpectEqual(pv2Check, false)
+	tc.ExpectEqual(pv1Check, true)
+	tc.ExpectEqual(pv2Check, false)
 	return framework.SucceedResp
 }
 
-func updateDeployReplica(user string) framework.TestResp {
+func updateDeployReplica(tc *framework.TestContext) framework.TestResp {
+	deployNameWithUser := tc.NameWithUser(deployName)
 	patchJson := "{\"spec\":{\"replicas\":4}}"
 	header := map[string]string{
 		"Content-Type": "application/strategic-merge-patch+json",
 	}
-	deployUrl := BuildK8sProxyUrl(framework.KubecubeHost, framework.TargetClusterName, "apis/apps/v1", framework.NamespaceName, "deployments", deployNameWithUser)
-	resp, err := httpHelper.RequestByUser(http.MethodPatch, deployUrl, patchJson, user, header)
-	framework.ExpectNoError(err)
+	deployUrl := BuildK8sProxyUrl(tc.KubecubeHost, tc.TargetClusterName, "apis/apps/v1", tc.NamespaceName, "deployments", deployNameWithUser)
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodPatch, deployUrl, patchJson, tc.User, header)
+	tc.ExpectNoError(err)
 	defer resp.Body.Close()
 
 	if !framework.IsSuccess(resp.StatusCode) {
@@ -503,11 +515,11 @@ func updateDeployReplica(user string) framework.TestResp {
 
 	ginkgo.By("检查副本详情页信息")
 	deploy := v1.Deployment{}
-	err = wait.Poll(framework.WaitInterval, framework.WaitTimeout,
+	err = wait.Poll(tc.WaitInterval, tc.WaitTimeout,
 		func() (bool, error) {
-			err = targetClient.Cache().Get(context.TODO(), types.NamespacedName{
+			err = tc.TargetClusterClient.Cache().Get(context.TODO(), types.NamespacedName{
 				Name:      deployNameWithUser,
-				Namespace: framework.NamespaceName,
+				Namespace: tc.NamespaceName,
 			}, &deploy)
 			if err != nil || *deploy.Spec.Replicas != int32(4) {
 				return false, err
@@ -515,19 +527,20 @@ func updateDeployReplica(user string) framework.TestResp {
 				return true, nil
 			}
 		})
-	framework.ExpectNoError(err)
-	framework.ExpectEqual(*deploy.Spec.Replicas, int32(4))
+	tc.ExpectNoError(err)
+	tc.ExpectEqual(*deploy.Spec.Replicas, int32(4))
 	return framework.SucceedResp
 }
 
-func resetDeployReplica(user string) framework.TestResp {
+func resetDeployReplica(tc *framework.TestContext) framework.TestResp {
+	deployNameWithUser := tc.NameWithUser(deployName)
 	patchJson := "{\"spec\":{\"replicas\":1}}"
 	header := map[string]string{
 		"Content-Type": "application/strategic-merge-patch+json",
 	}
-	deployUrl := BuildK8sProxyUrl(framework.KubecubeHost, framework.TargetClusterName, "apis/apps/v1", framework.NamespaceName, "deployments", deployNameWithUser)
-	resp, err := httpHelper.RequestByUser(http.MethodPatch, deployUrl, patchJson, user, header)
-	framework.ExpectNoError(err)
+	deployUrl := BuildK8sProxyUrl(tc.KubecubeHost, tc.TargetClusterName, "apis/apps/v1", tc.NamespaceName, "deployments", deployNameWithUser)
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodPatch, deployUrl, patchJson, tc.User, header)
+	tc.ExpectNoError(err)
 	defer resp.Body.Close()
 
 	if !framework.IsSuccess(resp.StatusCode) {
@@ -536,11 +549,11 @@ func resetDeployReplica(user string) framework.TestResp {
 	}
 
 	deploy := v1.Deployment{}
-	err = wait.Poll(framework.WaitInterval, framework.WaitTimeout,
+	err = wait.Poll(tc.WaitInterval, tc.WaitTimeout,
 		func() (bool, error) {
-			err := targetClient.Cache().Get(context.TODO(), types.NamespacedName{
+			err := tc.TargetClusterClient.Cache().Get(context.TODO(), types.NamespacedName{
 				Name:      deployNameWithUser,
-				Namespace: framework.NamespaceName,
+				Namespace: tc.NamespaceName,
 			}, &deploy)
 			if err != nil || deploy.Status.Replicas != int32(1) {
 				return false, err
@@ -548,21 +561,21 @@ func resetDeployReplica(user string) framework.TestResp {
 				return true, nil
 			}
 		})
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	clog.Info("update deployment status: %v", deploy.Status)
-	framework.ExpectEqual(deploy.Status.Replicas, int32(1))
+	tc.ExpectEqual(deploy.Status.Replicas, int32(1))
 	return framework.SucceedResp
 }
 
-func setDeployHpa(user string) framework.TestResp {
-	hpaJson := `{"apiVersion":"autoscaling/v2beta1","kind":"HorizontalPodAutoscaler","metadata":{"annotations":{},"labels":{},"name":"%s"},"spec":{"maxReplicas":2,"minReplicas":1,"metrics":[{"type":"Resource","resource":{"name":"memory","targetAverageValue":"1024"}}],"scaleTargetRef":{"apiVersion":"apps/v1","kind":"Deployment","name":"%s"}}}`
-	postJson := fmt.Sprintf(hpaJson, deployNameWithUser, deployNameWithUser)
-	hpaUrl := BuildK8sProxyUrl(framework.KubecubeHost, framework.TargetClusterName, "apis/autoscaling/v2beta1", framework.NamespaceName, "horizontalpodautoscalers", "")
-	resp, err := httpHelper.RequestByUser(http.MethodPost, hpaUrl, postJson, user, nil)
-	framework.ExpectNoError(err)
+func setDeployHpa(tc *framework.TestContext) framework.TestResp {
+	deployNameWithUser := tc.NameWithUser(deployName)
+	postJson := fixtures.MustRender(tc, "hpa.yaml", fixtures.For(tc, deployNameWithUser).With("targetKind", "Deployment"))
+	hpaUrl := BuildK8sProxyUrl(tc.KubecubeHost, tc.TargetClusterName, "apis/autoscaling/v2beta1", tc.NamespaceName, "horizontalpodautoscalers", "")
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodPost, hpaUrl, postJson, tc.User, nil)
+	tc.ExpectNoError(err)
 	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	clog.Info("get hap response, %v", string(body))
 
 	if !framework.IsSuccess(resp.StatusCode) {
@@ -573,13 +586,14 @@ func setDeployHpa(user string) framework.TestResp {
 	return framework.SucceedResp
 }
 
-func checkDeployHpa(user string) framework.TestResp {
+func checkDeployHpa(tc *framework.TestContext) framework.TestResp {
+	deployNameWithUser := tc.NameWithUser(deployName)
 	deploy := v1.Deployment{}
-	err := wait.Poll(framework.WaitInterval, framework.WaitTimeout*2,
+	err := wait.Poll(tc.WaitInterval, tc.WaitTimeout*2,
 		func() (bool, error) {
-			err := targetClient.Cache().Get(context.TODO(), types.NamespacedName{
+			err := tc.TargetClusterClient.Cache().Get(context.TODO(), types.NamespacedName{
 				Name:      deployNameWithUser,
-				Namespace: framework.NamespaceName,
+				Namespace: tc.NamespaceName,
 			}, &deploy)
 			if err != nil || deploy.Status.Replicas != int32(2) {
 				return false, err
@@ -587,16 +601,17 @@ func checkDeployHpa(user string) framework.TestResp {
 				return true, nil
 			}
 		})
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	clog.Info("hpa deployment status: %v", deploy.Status)
-	framework.ExpectEqual(deploy.Status.Replicas, int32(2))
+	tc.ExpectEqual(deploy.Status.Replicas, int32(2))
 	return framework.SucceedResp
 }
 
-func deleteDeployHpa(user string) framework.TestResp {
-	hpaUrl := BuildK8sProxyUrl(framework.KubecubeHost, framework.TargetClusterName, "apis/autoscaling/v2beta1", framework.NamespaceName, "horizontalpodautoscalers", deployNameWithUser)
-	resp, err := httpHelper.RequestByUser(http.MethodDelete, hpaUrl, "", user, nil)
-	framework.ExpectNoError(err)
+func deleteDeployHpa(tc *framework.TestContext) framework.TestResp {
+	deployNameWithUser := tc.NameWithUser(deployName)
+	hpaUrl := BuildK8sProxyUrl(tc.KubecubeHost, tc.TargetClusterName, "apis/autoscaling/v2beta1", tc.NamespaceName, "horizontalpodautoscalers", deployNameWithUser)
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodDelete, hpaUrl, "", tc.User, nil)
+	tc.ExpectNoError(err)
 	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	clog.Info("delete hpa: %+v", string(body))
@@ -608,10 +623,13 @@ func deleteDeployHpa(user string) framework.TestResp {
 	return framework.SucceedResp
 }
 
-func deleteDeploy(user string) framework.TestResp {
-	deployUrl := BuildK8sProxyUrl(framework.KubecubeHost, framework.TargetClusterName, "apis/apps/v1", framework.NamespaceName, "deployments", deployNameWithUser)
-	resp, err := httpHelper.RequestByUser(http.MethodDelete, deployUrl, "", user, nil)
-	framework.ExpectNoError(err)
+func deleteDeploy(tc *framework.TestContext) framework.TestResp {
+	deployNameWithUser := tc.NameWithUser(deployName)
+	pv1NameWithUser := tc.NameWithUser(pv1Name)
+	pv2NameWithUser := tc.NameWithUser(pv2Name)
+	deployUrl := BuildK8sProxyUrl(tc.KubecubeHost, tc.TargetClusterName, "apis/apps/v1", tc.NamespaceName, "deployments", deployNameWithUser)
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodDelete, deployUrl, "", tc.User, nil)
+	tc.ExpectNoError(err)
 	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	clog.Info("delete deploy: %+v", string(body))
@@ -621,10 +639,10 @@ func deleteDeploy(user string) framework.TestResp {
 		return framework.NewTestResp(fmt.Errorf("fail to delete deploy %s", deployNameWithUser), resp.StatusCode)
 	}
 
-	if framework.PVEnabled {
-		pv1Url := BuildK8sProxyUrl(framework.KubecubeHost, framework.TargetClusterName, "api/v1", framework.NamespaceName, "persistentvolumeclaims", pv1NameWithUser)
-		resp, err = httpHelper.RequestByUser(http.MethodDelete, pv1Url, "", user, nil)
-		framework.ExpectNoError(err)
+	if tc.HasCapability(framework.CapabilityPV) {
+		pv1Url := BuildK8sProxyUrl(tc.KubecubeHost, tc.TargetClusterName, "api/v1", tc.NamespaceName, "persistentvolumeclaims", pv1NameWithUser)
+		resp, err = tc.HttpHelper.RequestByUser(http.MethodDelete, pv1Url, "", tc.User, nil)
+		tc.ExpectNoError(err)
 		defer resp.Body.Close()
 		body, err = io.ReadAll(resp.Body)
 		clog.Info("delete pv1: %+v", string(body))
@@ -634,9 +652,9 @@ func deleteDeploy(user string) framework.TestResp {
 			return framework.NewTestResp(fmt.Errorf("fail to delete pv1 %s", pv1NameWithUser), resp.StatusCode)
 		}
 
-		pv2Url := BuildK8sProxyUrl(framework.KubecubeHost, framework.TargetClusterName, "api/v1", framework.NamespaceName, "persistentvolumeclaims", pv2NameWithUser)
-		resp, err = httpHelper.RequestByUser(http.MethodDelete, pv2Url, "", user, nil)
-		framework.ExpectNoError(err)
+		pv2Url := BuildK8sProxyUrl(tc.KubecubeHost, tc.TargetClusterName, "api/v1", tc.NamespaceName, "persistentvolumeclaims", pv2NameWithUser)
+		resp, err = tc.HttpHelper.RequestByUser(http.MethodDelete, pv2Url, "", tc.User, nil)
+		tc.ExpectNoError(err)
 		defer resp.Body.Close()
 		body, err = io.ReadAll(resp.Body)
 		clog.Info("delete pv2: %+v", string(body))
@@ -653,8 +671,8 @@ func deleteDeploy(user string) framework.TestResp {
 var multiUserDeployTest = framework.MultiUserTest{
 	TestName:        "[工作负载][9386601]创建Deployment工作负载挂载卷",
 	ContinueIfError: false,
-	Skipfunc: func() bool {
-		return !framework.DeploymentEnable
+	Skipfunc: func(tc *framework.TestContext) bool {
+		return !tc.DeploymentEnable
 	},
 	ErrorFunc:  framework.PermissionErrorFunc,
 	AfterEach:  nil,
@@ -20,9 +20,11 @@ import (
 	"fmt"
 	"net/url"
 	"strings"
+
+	"github.com/kubecube-io/kubecube-e2e/e2e/framework"
 )
 
-const ProxyUrlPrefix = "/api/v1/cube/proxy"
+const ProxyUrlPrefix = framework.ProxyUrlPrefix
 
 func BuildK8sProxyUrl(host string, cluster string, gv string, namespace string, resource string, name string) string {
 	var builder strings.Builder
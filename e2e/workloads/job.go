@@ -32,20 +32,19 @@ import (
 	"k8s.io/apimachinery/pkg/util/wait"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/kubecube-io/kubecube-e2e/e2e/fixtures"
 	"github.com/kubecube-io/kubecube-e2e/e2e/framework"
 )
 
-func createJob(user string) framework.TestResp {
-	initParam()
-	jobNameWithUser = framework.NameWithUser(jobName, user)
-	jobJson := `{"apiVersion":"batch/v1","kind":"Job","metadata":{"name":"%s","annotations":{},"labels":{"kubecube.io/app":"%s"}},"spec":{"selector":{},"template":{"metadata":{"annotations":{},"labels":{"kubecube.io/app":"%s"}},"spec":{"containers":[{"name":"%s","args":["Hello from the Kubernetes cluste"],"command":["echo"],"env":[],"image":"%s","imagePullPolicy":"IfNotPresent","lifecycle":{"postStart":null,"preStop":null},"livenessProbe":null,"readinessProbe":null,"ports":null,"resources":{"limits":{"cpu":"100m","memory":"128Mi"},"requests":{"cpu":"100m","memory":"128Mi"}},"volumeMounts":[]}],"initContainers":[],"imagePullSecrets":[{"name":"%s"}],"volumes":[],"affinity":{},"restartPolicy":"OnFailure"}},"completions":1,"parallelism":1,"backoffLimit":6}}`
-	jobJson = fmt.Sprintf(jobJson, jobNameWithUser, jobNameWithUser, jobNameWithUser, jobNameWithUser, framework.TestImage, framework.ImagePullSecret)
-	url := BuildK8sProxyUrl(framework.KubecubeHost, framework.TargetClusterName, "apis/batch/v1", framework.NamespaceName, "jobs", "")
-	jobResp, err := httpHelper.RequestByUser(http.MethodPost, url, jobJson, user, nil)
-	framework.ExpectNoError(err)
+func createJob(tc *framework.TestContext) framework.TestResp {
+	jobNameWithUser := tc.NameWithUser(jobName)
+	jobJson := fixtures.MustRender(tc, "job.yaml", fixtures.For(tc, jobNameWithUser))
+	url := BuildK8sProxyUrl(tc.KubecubeHost, tc.TargetClusterName, "apis/batch/v1", tc.NamespaceName, "jobs", "")
+	jobResp, err := tc.HttpHelper.RequestByUser(http.MethodPost, url, jobJson, tc.User, nil)
+	tc.ExpectNoError(err)
 	defer jobResp.Body.Close()
 	body, err := io.ReadAll(jobResp.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	clog.Info("create job %v, %v", jobNameWithUser, string(body))
 
 	if !framework.IsSuccess(jobResp.StatusCode) {
@@ -56,22 +55,24 @@ func createJob(user string) framework.TestResp {
 	return framework.SucceedResp
 }
 
-func checkJob(user string) framework.TestResp {
+func checkJob(tc *framework.TestContext) framework.TestResp {
+	jobNameWithUser := tc.NameWithUser(jobName)
 	job := v1.Job{}
-	err := targetClient.Direct().Get(context.TODO(), types.NamespacedName{
+	err := tc.TargetClusterClient.Direct().Get(context.TODO(), types.NamespacedName{
 		Name:      jobNameWithUser,
-		Namespace: framework.NamespaceName,
+		Namespace: tc.NamespaceName,
 	}, &job)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	clog.Info("create job status: %v", job.Status)
 	return framework.SucceedResp
 }
 
-func checkJobList(user string) framework.TestResp {
-	err := wait.Poll(framework.WaitInterval, framework.WaitTimeout, func() (done bool, err error) {
+func checkJobList(tc *framework.TestContext) framework.TestResp {
+	jobNameWithUser := tc.NameWithUser(jobName)
+	err := wait.Poll(tc.WaitInterval, tc.WaitTimeout, func() (done bool, err error) {
 		jobList := v1.JobList{}
-		err = targetClient.Direct().List(context.TODO(), &jobList, &client.ListOptions{
-			Namespace:     framework.NamespaceName,
+		err = tc.TargetClusterClient.Direct().List(context.TODO(), &jobList, &client.ListOptions{
+			Namespace:     tc.NamespaceName,
 			LabelSelector: labels.Set{"kubecube.io/app": jobNameWithUser}.AsSelector(),
 		})
 		if err != nil {
@@ -81,7 +82,7 @@ func checkJobList(user string) framework.TestResp {
 			return false, nil
 		}
 		job := jobList.Items[0]
-		framework.ExpectEqual(job.Name, jobNameWithUser)
+		tc.ExpectEqual(job.Name, jobNameWithUser)
 		if len(job.Status.Conditions) == 0 {
 			return false, nil
 		}
@@ -91,46 +92,48 @@ func checkJobList(user string) framework.TestResp {
 		return true, nil
 	})
 
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 
 	return framework.SucceedResp
 }
 
-func checkJobInfo(user string) framework.TestResp {
+func checkJobInfo(tc *framework.TestContext) framework.TestResp {
+	jobNameWithUser := tc.NameWithUser(jobName)
 	podList := corev1.PodList{}
-	err := targetClient.Direct().List(context.TODO(), &podList, &client.ListOptions{
-		Namespace:     framework.NamespaceName,
+	err := tc.TargetClusterClient.Direct().List(context.TODO(), &podList, &client.ListOptions{
+		Namespace:     tc.NamespaceName,
 		LabelSelector: labels.Set{"kubecube.io/app": jobNameWithUser}.AsSelector(),
 	})
-	framework.ExpectNoError(err)
-	framework.ExpectEqual(len(podList.Items), 1)
+	tc.ExpectNoError(err)
+	tc.ExpectEqual(len(podList.Items), 1)
 	pod := podList.Items[0]
-	framework.ExpectEqual(len(pod.Spec.Containers), 1)
+	tc.ExpectEqual(len(pod.Spec.Containers), 1)
 	container := pod.Spec.Containers[0]
-	framework.ExpectEqual(container.Image, framework.TestImage)
-	framework.ExpectEqual(container.Command[0], "echo")
-	framework.ExpectEqual(container.Args[0], "Hello from the Kubernetes cluste")
+	tc.ExpectEqual(container.Image, tc.TestImage)
+	tc.ExpectEqual(container.Command[0], "echo")
+	tc.ExpectEqual(container.Args[0], "Hello from the Kubernetes cluste")
 	return framework.SucceedResp
 }
 
-func checkJobDetail(user string) framework.TestResp {
+func checkJobDetail(tc *framework.TestContext) framework.TestResp {
+	jobNameWithUser := tc.NameWithUser(jobName)
 	podList := corev1.PodList{}
-	err := targetClient.Direct().List(context.TODO(), &podList, &client.ListOptions{
-		Namespace:     framework.NamespaceName,
+	err := tc.TargetClusterClient.Direct().List(context.TODO(), &podList, &client.ListOptions{
+		Namespace:     tc.NamespaceName,
 		LabelSelector: labels.Set{"kubecube.io/app": jobNameWithUser}.AsSelector(),
 	})
-	framework.ExpectNoError(err)
-	framework.ExpectEqual(len(podList.Items), 1)
+	tc.ExpectNoError(err)
+	tc.ExpectEqual(len(podList.Items), 1)
 	pod := podList.Items[0]
 	ginkgo.By("查看容器详情")
-	framework.ExpectEqual(len(pod.Spec.Containers), 1)
+	tc.ExpectEqual(len(pod.Spec.Containers), 1)
 	container := pod.Spec.Containers[0]
-	framework.ExpectEqual(container.Image, framework.TestImage)
+	tc.ExpectEqual(container.Image, tc.TestImage)
 
 	ginkgo.By("查看容器日志")
-	url := BuildLogUrl(framework.KubecubeHost, framework.TargetClusterName, framework.NamespaceName, pod.Name, container.Name)
-	logResp, err := httpHelper.RequestByUser(http.MethodGet, url, "", user, nil)
-	framework.ExpectNoError(err)
+	url := BuildLogUrl(tc.KubecubeHost, tc.TargetClusterName, tc.NamespaceName, pod.Name, container.Name)
+	logResp, err := tc.HttpHelper.RequestByUser(http.MethodGet, url, "", tc.User, nil)
+	tc.ExpectNoError(err)
 	defer logResp.Body.Close()
 
 	if !framework.IsSuccess(logResp.StatusCode) {
@@ -138,21 +141,22 @@ func checkJobDetail(user string) framework.TestResp {
 		return framework.NewTestResp(fmt.Errorf("fail to get pod %s logs", pod.Name), logResp.StatusCode)
 	}
 
-	framework.ExpectEqual(logResp.StatusCode, 200)
+	tc.ExpectEqual(logResp.StatusCode, 200)
 	return framework.SucceedResp
 }
 
-func checkJobPerformance(user string) framework.TestResp {
+func checkJobPerformance(tc *framework.TestContext) framework.TestResp {
 	// TODO
 	return framework.SucceedResp
 }
 
-func checkJobCondition(user string) framework.TestResp {
-	err := wait.Poll(framework.WaitInterval, framework.WaitTimeout, func() (done bool, err error) {
+func checkJobCondition(tc *framework.TestContext) framework.TestResp {
+	jobNameWithUser := tc.NameWithUser(jobName)
+	err := wait.Poll(tc.WaitInterval, tc.WaitTimeout, func() (done bool, err error) {
 		job := v1.Job{}
-		err = targetClient.Direct().Get(context.TODO(), types.NamespacedName{
+		err = tc.TargetClusterClient.Direct().Get(context.TODO(), types.NamespacedName{
 			Name:      jobNameWithUser,
-			Namespace: framework.NamespaceName,
+			Namespace: tc.NamespaceName,
 		}, &job)
 		if err != nil {
 			return false, err
@@ -162,12 +166,12 @@ func checkJobCondition(user string) framework.TestResp {
 		}
 		return true, nil
 	})
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 
-	err = wait.Poll(framework.WaitInterval, framework.WaitTimeout, func() (done bool, err error) {
+	err = wait.Poll(tc.WaitInterval, tc.WaitTimeout, func() (done bool, err error) {
 		podList := corev1.PodList{}
-		err = targetClient.Direct().List(context.TODO(), &podList, &client.ListOptions{
-			Namespace:     framework.NamespaceName,
+		err = tc.TargetClusterClient.Direct().List(context.TODO(), &podList, &client.ListOptions{
+			Namespace:     tc.NamespaceName,
 			LabelSelector: labels.Set{"kubecube.io/app": jobNameWithUser}.AsSelector(),
 		})
 		if err != nil {
@@ -182,22 +186,23 @@ func checkJobCondition(user string) framework.TestResp {
 		}
 		return true, nil
 	})
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	return framework.SucceedResp
 }
 
-func checkJobEvents(user string) framework.TestResp {
+func checkJobEvents(tc *framework.TestContext) framework.TestResp {
+	jobNameWithUser := tc.NameWithUser(jobName)
 	job := v1.Job{}
-	err := targetClient.Cache().Get(context.TODO(), types.NamespacedName{
+	err := tc.TargetClusterClient.Cache().Get(context.TODO(), types.NamespacedName{
 		Name:      jobNameWithUser,
-		Namespace: framework.NamespaceName,
+		Namespace: tc.NamespaceName,
 	}, &job)
-	framework.ExpectNoError(err)
-	url := BuildEventUrl(framework.KubecubeHost, framework.TargetClusterName, framework.NamespaceName, string(job.UID))
-	resp, err := httpHelper.RequestByUser(http.MethodGet, url, "", user, nil)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
+	url := BuildEventUrl(tc.KubecubeHost, tc.TargetClusterName, tc.NamespaceName, string(job.UID))
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodGet, url, "", tc.User, nil)
+	tc.ExpectNoError(err)
 	body, err := io.ReadAll(resp.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	defer resp.Body.Close()
 
 	if !framework.IsSuccess(resp.StatusCode) {
@@ -207,25 +212,26 @@ func checkJobEvents(user string) framework.TestResp {
 
 	eventList := corev1.EventList{}
 	err = json.Unmarshal(body, &eventList)
-	framework.ExpectNoError(err)
-	framework.ExpectNotEqual(len(eventList.Items), 0)
+	tc.ExpectNoError(err)
+	tc.ExpectNotEqual(len(eventList.Items), 0)
 	return framework.SucceedResp
 }
 
-func checkJobPodEvents(user string) framework.TestResp {
+func checkJobPodEvents(tc *framework.TestContext) framework.TestResp {
+	jobNameWithUser := tc.NameWithUser(jobName)
 	podList := corev1.PodList{}
-	err := targetClient.Cache().List(context.TODO(), &podList, &client.ListOptions{
-		Namespace:     framework.NamespaceName,
+	err := tc.TargetClusterClient.Cache().List(context.TODO(), &podList, &client.ListOptions{
+		Namespace:     tc.NamespaceName,
 		LabelSelector: labels.Set{"kubecube.io/app": jobNameWithUser}.AsSelector(),
 	})
-	framework.ExpectNoError(err)
-	framework.ExpectEqual(len(podList.Items), 1)
+	tc.ExpectNoError(err)
+	tc.ExpectEqual(len(podList.Items), 1)
 	pod := podList.Items[0]
-	url := BuildEventUrl(framework.KubecubeHost, framework.TargetClusterName, framework.NamespaceName, string(pod.UID))
-	resp, err := httpHelper.Get(url, nil)
-	framework.ExpectNoError(err)
+	url := BuildEventUrl(tc.KubecubeHost, tc.TargetClusterName, tc.NamespaceName, string(pod.UID))
+	resp, err := tc.HttpHelper.Get(url, nil)
+	tc.ExpectNoError(err)
 	body, err := io.ReadAll(resp.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	defer resp.Body.Close()
 
 	if !framework.IsSuccess(resp.StatusCode) {
@@ -235,14 +241,15 @@ func checkJobPodEvents(user string) framework.TestResp {
 
 	eventList := corev1.EventList{}
 	err = json.Unmarshal(body, &eventList)
-	framework.ExpectNotEqual(len(eventList.Items), 0)
+	tc.ExpectNotEqual(len(eventList.Items), 0)
 	return framework.SucceedResp
 }
 
-func deleteJob(user string) framework.TestResp {
-	url := BuildK8sProxyUrl(framework.KubecubeHost, framework.TargetClusterName, "apis/batch/v1", framework.NamespaceName, "jobs", jobNameWithUser)
-	resp, err := httpHelper.Delete(url)
-	framework.ExpectNoError(err)
+func deleteJob(tc *framework.TestContext) framework.TestResp {
+	jobNameWithUser := tc.NameWithUser(jobName)
+	url := BuildK8sProxyUrl(tc.KubecubeHost, tc.TargetClusterName, "apis/batch/v1", tc.NamespaceName, "jobs", jobNameWithUser)
+	resp, err := tc.HttpHelper.Delete(url)
+	tc.ExpectNoError(err)
 	defer resp.Body.Close()
 
 	if !framework.IsSuccess(resp.StatusCode) {
@@ -256,8 +263,8 @@ func deleteJob(user string) framework.TestResp {
 var multiUserJobTest = framework.MultiUserTest{
 	TestName:        "[工作负载][9478778]Job检查",
 	ContinueIfError: false,
-	Skipfunc: func() bool {
-		return !framework.JobEnable
+	Skipfunc: func(tc *framework.TestContext) bool {
+		return !tc.JobEnable
 	},
 	ErrorFunc:  framework.PermissionErrorFunc,
 	AfterEach:  nil,
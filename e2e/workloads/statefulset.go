@@ -32,23 +32,24 @@ import (
 	"k8s.io/apimachinery/pkg/util/wait"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/kubecube-io/kubecube-e2e/e2e/fixtures"
 	"github.com/kubecube-io/kubecube-e2e/e2e/framework"
 )
 
-func createStatefulsetWithPvc(user string) framework.TestResp {
+func createStatefulsetWithPvc(tc *framework.TestContext) framework.TestResp {
+	stsNameWithUser := tc.NameWithUser(stsName)
 	ginkgo.By("1.创建Statefulset，名称sts1 》副本数为2》" +
 		"2.开启存储声明pvc1，存储选择StorageClass1》" +
 		"3.镜像填写tomcat或选择tomcat》容器配置，基础配置选择高性能 》" +
 		"4.点击 高级模式，容器挂载存储模板pvc1" +
 		"5.启用容器运行探针 》脚本方式设置执行脚本为命令行，如：echo test 》" +
 		"6.打开部署策略 》节点亲和性Key填写“kubernetes.io/hostname”，values填写某个节点ipxx.xx 》提交设置")
-	stsJson := `{"apiVersion":"apps/v1","kind":"StatefulSet","metadata":{"name":"%s","annotations":{},"labels":{"kubecube.io/app":"%s"}},"spec":{"selector":{"matchLabels":{"kubecube.io/app":"%s"}},"template":{"metadata":{"annotations":{},"labels":{"kubecube.io/app":"%s"}},"spec":{"containers":[{"name":"%s","args":[],"command":[],"env":[],"image":"%s","imagePullPolicy":"IfNotPresent","lifecycle":{"postStart":null,"preStop":null},"livenessProbe":{"exec":{"command":["/bin/echo","test"]},"failureThreshold":1,"initialDelaySeconds":0,"periodSeconds":10,"successThreshold":1,"timeoutSeconds":1},"readinessProbe":null,"ports":null,"resources":{"limits":{"cpu":"100m","memory":"128Mi"},"requests":{"cpu":"100m","memory":"128Mi"}},"volumeMounts":[{"name":"pv1","mountPath":"/mnt1"}]}],"initContainers":[],"imagePullSecrets":[{"name":"%s"}],"volumes":[],"affinity":{"nodeAffinity":{"requiredDuringSchedulingIgnoredDuringExecution":{"nodeSelectorTerms":[{"matchExpressions":[{"key":"kubernetes.io/hostname","operator":"In","values":["%s"]}]}]}}},"restartPolicy":"Always","tolerations":[]}},"replicas":2,"serviceName":"sts-svc","volumeClaimTemplates":[{"metadata":{"name":"pv1"},"spec":{"storageClassName":"%s","resources":{"requests":{"storage":"100Mi"}},"accessModes":["ReadWriteOnce"]}}]}}`
-	stsJson = fmt.Sprintf(stsJson, stsNameWithUser, stsNameWithUser, stsNameWithUser, stsNameWithUser, stsNameWithUser, framework.TestImage, framework.ImagePullSecret, framework.NodeHostName, framework.StorageClass)
-	url := BuildK8sProxyUrl(framework.KubecubeHost, framework.TargetClusterName, "apis/apps/v1", framework.NamespaceName, "statefulsets", "")
-	resp, err := httpHelper.RequestByUser(http.MethodPost, url, stsJson, user, nil)
-	framework.ExpectNoError(err)
+	stsJson := fixtures.MustRender(tc, "statefulset-with-pvc.yaml", fixtures.For(tc, stsNameWithUser))
+	url := BuildK8sProxyUrl(tc.KubecubeHost, tc.TargetClusterName, "apis/apps/v1", tc.NamespaceName, "statefulsets", "")
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodPost, url, stsJson, tc.User, nil)
+	tc.ExpectNoError(err)
 	body, err := io.ReadAll(resp.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	clog.Info("create statefulsets %v, %v", stsNameWithUser, string(body))
 	defer resp.Body.Close()
 	if !framework.IsSuccess(resp.StatusCode) {
@@ -59,20 +60,20 @@ func createStatefulsetWithPvc(user string) framework.TestResp {
 	return framework.SucceedResp
 }
 
-func createStatefulsetWithoutPvc(user string) framework.TestResp {
+func createStatefulsetWithoutPvc(tc *framework.TestContext) framework.TestResp {
+	stsNameWithUser := tc.NameWithUser(stsName)
 	ginkgo.By("1.创建Statefulset，名称sts1 》副本数为2》" +
 		"2.开启存储声明pvc1，存储选择StorageClass1》" +
 		"3.镜像填写tomcat或选择tomcat》容器配置，基础配置选择高性能 》" +
 		"4.点击 高级模式，容器挂载存储模板pvc1" +
 		"5.启用容器运行探针 》脚本方式设置执行脚本为命令行，如：echo test 》" +
 		"6.打开部署策略 》节点亲和性Key填写“kubernetes.io/hostname”，values填写某个节点ipxx.xx 》提交设置")
-	stsJson := `{"apiVersion":"apps/v1","kind":"StatefulSet","metadata":{"name":"%s","annotations":{},"labels":{"kubecube.io/app":"%s"}},"spec":{"selector":{"matchLabels":{"kubecube.io/app":"%s"}},"template":{"metadata":{"annotations":{},"labels":{"kubecube.io/app":"%s"}},"spec":{"containers":[{"name":"%s","args":[],"command":[],"env":[],"image":"%s","imagePullPolicy":"IfNotPresent","lifecycle":{"postStart":null,"preStop":null},"livenessProbe":{"exec":{"command":["/bin/echo","test"]},"failureThreshold":1,"initialDelaySeconds":0,"periodSeconds":10,"successThreshold":1,"timeoutSeconds":1},"readinessProbe":null,"ports":null,"resources":{"limits":{"cpu":"100m","memory":"128Mi"},"requests":{"cpu":"100m","memory":"128Mi"}}}],"initContainers":[],"imagePullSecrets":[{"name":"%s"}],"volumes":[],"affinity":{"nodeAffinity":{"requiredDuringSchedulingIgnoredDuringExecution":{"nodeSelectorTerms":[{"matchExpressions":[{"key":"kubernetes.io/hostname","operator":"In","values":["%s"]}]}]}}},"restartPolicy":"Always","tolerations":[]}},"replicas":2,"serviceName":"sts-svc"}}`
-	stsJson = fmt.Sprintf(stsJson, stsNameWithUser, stsNameWithUser, stsNameWithUser, stsNameWithUser, stsNameWithUser, framework.TestImage, framework.ImagePullSecret, framework.NodeHostName)
-	url := BuildK8sProxyUrl(framework.KubecubeHost, framework.TargetClusterName, "apis/apps/v1", framework.NamespaceName, "statefulsets", "")
-	resp, err := httpHelper.RequestByUser(http.MethodPost, url, stsJson, user, nil)
-	framework.ExpectNoError(err)
+	stsJson := fixtures.MustRender(tc, "statefulset.yaml", fixtures.For(tc, stsNameWithUser))
+	url := BuildK8sProxyUrl(tc.KubecubeHost, tc.TargetClusterName, "apis/apps/v1", tc.NamespaceName, "statefulsets", "")
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodPost, url, stsJson, tc.User, nil)
+	tc.ExpectNoError(err)
 	body, err := io.ReadAll(resp.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	clog.Info("create statefulsets %v, %v", stsNameWithUser, string(body))
 	defer resp.Body.Close()
 	if !framework.IsSuccess(resp.StatusCode) {
@@ -82,28 +83,27 @@ func createStatefulsetWithoutPvc(user string) framework.TestResp {
 	return framework.SucceedResp
 }
 
-func createStatefulset(user string) framework.TestResp {
-	initParam()
-	stsNameWithUser = framework.NameWithUser(stsName, user)
-	if framework.PVEnabled {
+func createStatefulset(tc *framework.TestContext) framework.TestResp {
+	if tc.HasCapability(framework.CapabilityPV) {
 		clog.Info("createStatefulsetWithPv")
-		return createStatefulsetWithPvc(user)
+		return createStatefulsetWithPvc(tc)
 	} else {
 		clog.Info("createStatefulsetWithoutPv")
-		return createStatefulsetWithoutPvc(user)
+		return createStatefulsetWithoutPvc(tc)
 	}
 }
 
-func checkStatefulsetVolume(user string) framework.TestResp {
-	if !framework.PVEnabled {
+func checkStatefulsetVolume(tc *framework.TestContext) framework.TestResp {
+	stsNameWithUser := tc.NameWithUser(stsName)
+	if !tc.HasCapability(framework.CapabilityPV) {
 		return framework.SucceedResp
 	}
 
 	sts := v1.StatefulSet{}
-	err := wait.Poll(framework.WaitTimeout, framework.WaitInterval, func() (done bool, err error) {
-		err = targetClient.Direct().Get(context.TODO(), types.NamespacedName{
+	err := wait.Poll(tc.WaitTimeout, tc.WaitInterval, func() (done bool, err error) {
+		err = tc.TargetClusterClient.Direct().Get(context.TODO(), types.NamespacedName{
 			Name:      stsNameWithUser,
-			Namespace: framework.NamespaceName,
+			Namespace: tc.NamespaceName,
 		}, &sts)
 		if err != nil {
 			return false, err
@@ -127,15 +127,16 @@ func checkStatefulsetVolume(user string) framework.TestResp {
 		}
 		return true, nil
 	})
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	return framework.SucceedResp
 }
 
-func checkStatefulsetRunning(user string) framework.TestResp {
-	err := wait.Poll(framework.WaitInterval, framework.WaitTimeout, func() (done bool, err error) {
+func checkStatefulsetRunning(tc *framework.TestContext) framework.TestResp {
+	stsNameWithUser := tc.NameWithUser(stsName)
+	err := wait.Poll(tc.WaitInterval, tc.WaitTimeout, func() (done bool, err error) {
 		podList := corev1.PodList{}
-		err = targetClient.Direct().List(context.TODO(), &podList, &client.ListOptions{
-			Namespace:     framework.NamespaceName,
+		err = tc.TargetClusterClient.Direct().List(context.TODO(), &podList, &client.ListOptions{
+			Namespace:     tc.NamespaceName,
 			LabelSelector: labels.Set{"kubecube.io/app": stsNameWithUser}.AsSelector(),
 		})
 		if err != nil {
@@ -148,14 +149,14 @@ func checkStatefulsetRunning(user string) framework.TestResp {
 		pod2Check := false
 		for _, pod := range podList.Items {
 			if pod.Name == stsNameWithUser+"-0" {
-				if pod.Status.Phase != corev1.PodRunning || pod.Status.HostIP != framework.NodeHostIp {
+				if pod.Status.Phase != corev1.PodRunning || pod.Status.HostIP != tc.NodeHostIp {
 					clog.Info("[DEBUG] pod %v not running, status: %v", pod.Name, pod.Status)
 					return false, nil
 				}
 				pod1Check = true
 			}
 			if pod.Name == stsNameWithUser+"-1" {
-				if pod.Status.Phase != corev1.PodRunning || pod.Status.HostIP != framework.NodeHostIp {
+				if pod.Status.Phase != corev1.PodRunning || pod.Status.HostIP != tc.NodeHostIp {
 					clog.Info("[DEBUG] pod %v not running, status: %v", pod.Name, pod.Status)
 					return false, nil
 				}
@@ -167,15 +168,16 @@ func checkStatefulsetRunning(user string) framework.TestResp {
 		}
 		return true, nil
 	})
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	return framework.SucceedResp
 }
 
-func checkStatefulsetDetail(user string) framework.TestResp {
+func checkStatefulsetDetail(tc *framework.TestContext) framework.TestResp {
+	stsNameWithUser := tc.NameWithUser(stsName)
 	podList := corev1.PodList{}
-	err := wait.Poll(framework.WaitInterval, framework.WaitTimeout, func() (done bool, err error) {
-		err = targetClient.Direct().List(context.TODO(), &podList, &client.ListOptions{
-			Namespace:     framework.NamespaceName,
+	err := wait.Poll(tc.WaitInterval, tc.WaitTimeout, func() (done bool, err error) {
+		err = tc.TargetClusterClient.Direct().List(context.TODO(), &podList, &client.ListOptions{
+			Namespace:     tc.NamespaceName,
 			LabelSelector: labels.Set{"kubecube.io/app": stsNameWithUser}.AsSelector(),
 		})
 		if err != nil {
@@ -189,15 +191,15 @@ func checkStatefulsetDetail(user string) framework.TestResp {
 	})
 
 	pod := podList.Items[0]
-	framework.ExpectEqual(len(pod.Spec.Containers), 1)
+	tc.ExpectEqual(len(pod.Spec.Containers), 1)
 	container := pod.Spec.Containers[0]
-	framework.ExpectEqual(container.Name, stsNameWithUser)
-	framework.ExpectEqual(container.Image, framework.TestImage)
+	tc.ExpectEqual(container.Name, stsNameWithUser)
+	tc.ExpectEqual(container.Image, tc.TestImage)
 
 	ginkgo.By("查看容器日志")
-	url := BuildLogUrl(framework.KubecubeHost, framework.TargetClusterName, framework.NamespaceName, pod.Name, container.Name)
-	logResp, err := httpHelper.RequestByUser(http.MethodGet, url, "", user, nil)
-	framework.ExpectNoError(err)
+	url := BuildLogUrl(tc.KubecubeHost, tc.TargetClusterName, tc.NamespaceName, pod.Name, container.Name)
+	logResp, err := tc.HttpHelper.RequestByUser(http.MethodGet, url, "", tc.User, nil)
+	tc.ExpectNoError(err)
 
 	defer logResp.Body.Close()
 	if !framework.IsSuccess(logResp.StatusCode) {
@@ -205,25 +207,26 @@ func checkStatefulsetDetail(user string) framework.TestResp {
 		return framework.NewTestResp(fmt.Errorf("fail to get pod %s log", pod.Name), logResp.StatusCode)
 	}
 
-	framework.ExpectEqual(logResp.StatusCode, 200)
+	tc.ExpectEqual(logResp.StatusCode, 200)
 	return framework.SucceedResp
 }
 
-func checkStatefulsetInfo(user string) framework.TestResp {
+func checkStatefulsetInfo(tc *framework.TestContext) framework.TestResp {
 	// TODO
 	return framework.SucceedResp
 }
 
-func checkStatefulsetPerformance(user string) framework.TestResp {
+func checkStatefulsetPerformance(tc *framework.TestContext) framework.TestResp {
 	// TODO
 	return framework.SucceedResp
 }
 
-func checkStatefulsetCondition(user string) framework.TestResp {
-	err := wait.Poll(framework.WaitInterval, framework.WaitTimeout, func() (done bool, err error) {
+func checkStatefulsetCondition(tc *framework.TestContext) framework.TestResp {
+	stsNameWithUser := tc.NameWithUser(stsName)
+	err := wait.Poll(tc.WaitInterval, tc.WaitTimeout, func() (done bool, err error) {
 		podList := corev1.PodList{}
-		err = targetClient.Direct().List(context.TODO(), &podList, &client.ListOptions{
-			Namespace:     framework.NamespaceName,
+		err = tc.TargetClusterClient.Direct().List(context.TODO(), &podList, &client.ListOptions{
+			Namespace:     tc.NamespaceName,
 			LabelSelector: labels.Set{"kubecube.io/app": stsNameWithUser}.AsSelector(),
 		})
 		if err != nil {
@@ -233,26 +236,27 @@ func checkStatefulsetCondition(user string) framework.TestResp {
 			return false, nil
 		}
 		pod := podList.Items[0]
-		framework.ExpectNotEqual(len(pod.Status.Conditions), 0)
+		tc.ExpectNotEqual(len(pod.Status.Conditions), 0)
 		return true, nil
 	})
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 
 	return framework.SucceedResp
 }
 
-func checkStatefulsetEvents(user string) framework.TestResp {
+func checkStatefulsetEvents(tc *framework.TestContext) framework.TestResp {
+	stsNameWithUser := tc.NameWithUser(stsName)
 	sts := v1.StatefulSet{}
-	err := targetClient.Cache().Get(context.TODO(), types.NamespacedName{
+	err := tc.TargetClusterClient.Cache().Get(context.TODO(), types.NamespacedName{
 		Name:      stsNameWithUser,
-		Namespace: framework.NamespaceName,
+		Namespace: tc.NamespaceName,
 	}, &sts)
-	framework.ExpectNoError(err)
-	url := BuildEventUrl(framework.KubecubeHost, framework.TargetClusterName, framework.NamespaceName, string(sts.UID))
-	resp, err := httpHelper.RequestByUser(http.MethodGet, url, "", user, nil)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
+	url := BuildEventUrl(tc.KubecubeHost, tc.TargetClusterName, tc.NamespaceName, string(sts.UID))
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodGet, url, "", tc.User, nil)
+	tc.ExpectNoError(err)
 	body, err := io.ReadAll(resp.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 
 	defer resp.Body.Close()
 	if !framework.IsSuccess(resp.StatusCode) {
@@ -262,16 +266,17 @@ func checkStatefulsetEvents(user string) framework.TestResp {
 
 	eventList := corev1.EventList{}
 	err = json.Unmarshal(body, &eventList)
-	framework.ExpectNoError(err)
-	framework.ExpectNotEqual(len(eventList.Items), 0)
+	tc.ExpectNoError(err)
+	tc.ExpectNotEqual(len(eventList.Items), 0)
 	return framework.SucceedResp
 }
 
-func checkStatefulsetPodEvents(user string) framework.TestResp {
+func checkStatefulsetPodEvents(tc *framework.TestContext) framework.TestResp {
+	stsNameWithUser := tc.NameWithUser(stsName)
 	podList := corev1.PodList{}
-	err := wait.Poll(framework.WaitInterval, framework.WaitTimeout, func() (done bool, err error) {
-		err = targetClient.Direct().List(context.TODO(), &podList, &client.ListOptions{
-			Namespace:     framework.NamespaceName,
+	err := wait.Poll(tc.WaitInterval, tc.WaitTimeout, func() (done bool, err error) {
+		err = tc.TargetClusterClient.Direct().List(context.TODO(), &podList, &client.ListOptions{
+			Namespace:     tc.NamespaceName,
 			LabelSelector: labels.Set{"kubecube.io/app": stsNameWithUser}.AsSelector(),
 		})
 		if err != nil {
@@ -282,14 +287,14 @@ func checkStatefulsetPodEvents(user string) framework.TestResp {
 		}
 		return true, nil
 	})
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 
 	pod := podList.Items[0]
-	url := BuildEventUrl(framework.KubecubeHost, framework.TargetClusterName, framework.NamespaceName, string(pod.UID))
-	resp, err := httpHelper.Get(url, nil)
-	framework.ExpectNoError(err)
+	url := BuildEventUrl(tc.KubecubeHost, tc.TargetClusterName, tc.NamespaceName, string(pod.UID))
+	resp, err := tc.HttpHelper.Get(url, nil)
+	tc.ExpectNoError(err)
 	body, err := io.ReadAll(resp.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 
 	defer resp.Body.Close()
 	if !framework.IsSuccess(resp.StatusCode) {
@@ -299,18 +304,19 @@ func checkStatefulsetPodEvents(user string) framework.TestResp {
 
 	eventList := corev1.EventList{}
 	err = json.Unmarshal(body, &eventList)
-	framework.ExpectNotEqual(len(eventList.Items), 0)
+	tc.ExpectNotEqual(len(eventList.Items), 0)
 	return framework.SucceedResp
 }
 
-func restStatefulsetReplica(user string) framework.TestResp {
+func restStatefulsetReplica(tc *framework.TestContext) framework.TestResp {
+	stsNameWithUser := tc.NameWithUser(stsName)
 	patchJson := "{\"spec\":{\"replicas\":1}}"
 	header := map[string]string{
 		"Content-Type": "application/strategic-merge-patch+json",
 	}
-	deployUrl := BuildK8sProxyUrl(framework.KubecubeHost, framework.TargetClusterName, "apis/apps/v1", framework.NamespaceName, "statefulsets", stsNameWithUser)
-	resp, err := httpHelper.RequestByUser(http.MethodPatch, deployUrl, patchJson, user, header)
-	framework.ExpectNoError(err)
+	deployUrl := BuildK8sProxyUrl(tc.KubecubeHost, tc.TargetClusterName, "apis/apps/v1", tc.NamespaceName, "statefulsets", stsNameWithUser)
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodPatch, deployUrl, patchJson, tc.User, header)
+	tc.ExpectNoError(err)
 
 	defer resp.Body.Close()
 	if !framework.IsSuccess(resp.StatusCode) {
@@ -319,11 +325,11 @@ func restStatefulsetReplica(user string) framework.TestResp {
 	}
 
 	sts := v1.StatefulSet{}
-	err = wait.Poll(framework.WaitInterval, framework.WaitTimeout,
+	err = wait.Poll(tc.WaitInterval, tc.WaitTimeout,
 		func() (bool, error) {
-			err := targetClient.Cache().Get(context.TODO(), types.NamespacedName{
+			err := tc.TargetClusterClient.Cache().Get(context.TODO(), types.NamespacedName{
 				Name:      stsNameWithUser,
-				Namespace: framework.NamespaceName,
+				Namespace: tc.NamespaceName,
 			}, &sts)
 			if err != nil || sts.Status.Replicas != int32(1) {
 				return false, err
@@ -331,22 +337,22 @@ func restStatefulsetReplica(user string) framework.TestResp {
 				return true, nil
 			}
 		})
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	clog.Info("update statefulset  status: %v", sts.Status)
-	framework.ExpectEqual(sts.Status.Replicas, int32(1))
+	tc.ExpectEqual(sts.Status.Replicas, int32(1))
 	return framework.SucceedResp
 }
 
-func createStatefulsetHpa(user string) framework.TestResp {
-	hpaJson := `{"apiVersion":"autoscaling/v2beta1","kind":"HorizontalPodAutoscaler","metadata":{"annotations":{},"labels":{},"name":"%s"},"spec":{"maxReplicas":2,"minReplicas":1,"metrics":[{"type":"Resource","resource":{"name":"memory","targetAverageValue":"1024"}}],"scaleTargetRef":{"apiVersion":"apps/v1","kind":"StatefulSet","name":"%s"}}}`
-	postJson := fmt.Sprintf(hpaJson, stsNameWithUser, stsNameWithUser)
-	hpaUrl := BuildK8sProxyUrl(framework.KubecubeHost, framework.TargetClusterName, "apis/autoscaling/v2beta1", framework.NamespaceName, "horizontalpodautoscalers", "")
-	resp, err := httpHelper.RequestByUser(http.MethodPost, hpaUrl, postJson, user, nil)
-	framework.ExpectNoError(err)
+func createStatefulsetHpa(tc *framework.TestContext) framework.TestResp {
+	stsNameWithUser := tc.NameWithUser(stsName)
+	postJson := fixtures.MustRender(tc, "hpa.yaml", fixtures.For(tc, stsNameWithUser).With("targetKind", "StatefulSet"))
+	hpaUrl := BuildK8sProxyUrl(tc.KubecubeHost, tc.TargetClusterName, "apis/autoscaling/v2beta1", tc.NamespaceName, "horizontalpodautoscalers", "")
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodPost, hpaUrl, postJson, tc.User, nil)
+	tc.ExpectNoError(err)
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	clog.Info("get hap response, %v", string(body))
 
 	if !framework.IsSuccess(resp.StatusCode) {
@@ -357,13 +363,14 @@ func createStatefulsetHpa(user string) framework.TestResp {
 	return framework.SucceedResp
 }
 
-func checkStatefulsetHpa(user string) framework.TestResp {
+func checkStatefulsetHpa(tc *framework.TestContext) framework.TestResp {
+	stsNameWithUser := tc.NameWithUser(stsName)
 	sts := v1.StatefulSet{}
-	err := wait.Poll(framework.WaitInterval, framework.WaitTimeout,
+	err := wait.Poll(tc.WaitInterval, tc.WaitTimeout,
 		func() (bool, error) {
-			err := targetClient.Cache().Get(context.TODO(), types.NamespacedName{
+			err := tc.TargetClusterClient.Cache().Get(context.TODO(), types.NamespacedName{
 				Name:      stsNameWithUser,
-				Namespace: framework.NamespaceName,
+				Namespace: tc.NamespaceName,
 			}, &sts)
 			if err != nil || sts.Status.Replicas != int32(2) {
 				return false, err
@@ -371,16 +378,17 @@ func checkStatefulsetHpa(user string) framework.TestResp {
 				return true, nil
 			}
 		})
-	framework.ExpectNoError(err)
+	tc.ExpectNoError(err)
 	clog.Info("hpa statefulset  status: %v", sts.Status)
-	framework.ExpectEqual(sts.Status.Replicas, int32(2))
+	tc.ExpectEqual(sts.Status.Replicas, int32(2))
 	return framework.SucceedResp
 }
 
-func deleteStatefulsetHpa(user string) framework.TestResp {
-	hpaUrl := BuildK8sProxyUrl(framework.KubecubeHost, framework.TargetClusterName, "apis/autoscaling/v2beta1", framework.NamespaceName, "horizontalpodautoscalers", stsNameWithUser)
-	resp, err := httpHelper.RequestByUser(http.MethodDelete, hpaUrl, "", user, nil)
-	framework.ExpectNoError(err)
+func deleteStatefulsetHpa(tc *framework.TestContext) framework.TestResp {
+	stsNameWithUser := tc.NameWithUser(stsName)
+	hpaUrl := BuildK8sProxyUrl(tc.KubecubeHost, tc.TargetClusterName, "apis/autoscaling/v2beta1", tc.NamespaceName, "horizontalpodautoscalers", stsNameWithUser)
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodDelete, hpaUrl, "", tc.User, nil)
+	tc.ExpectNoError(err)
 	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	clog.Info("delete hpa: %+v", string(body))
@@ -394,10 +402,11 @@ func deleteStatefulsetHpa(user string) framework.TestResp {
 	return framework.SucceedResp
 }
 
-func deleteStatefulset(user string) framework.TestResp {
-	url := BuildK8sProxyUrl(framework.KubecubeHost, framework.TargetClusterName, "apis/apps/v1", framework.NamespaceName, "statefulsets", stsNameWithUser)
-	resp, err := httpHelper.RequestByUser(http.MethodDelete, url, "", user, nil)
-	framework.ExpectNoError(err)
+func deleteStatefulset(tc *framework.TestContext) framework.TestResp {
+	stsNameWithUser := tc.NameWithUser(stsName)
+	url := BuildK8sProxyUrl(tc.KubecubeHost, tc.TargetClusterName, "apis/apps/v1", tc.NamespaceName, "statefulsets", stsNameWithUser)
+	resp, err := tc.HttpHelper.RequestByUser(http.MethodDelete, url, "", tc.User, nil)
+	tc.ExpectNoError(err)
 	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	clog.Info("delete sts: %+v", string(body))
@@ -406,10 +415,10 @@ func deleteStatefulset(user string) framework.TestResp {
 		return framework.NewTestResp(fmt.Errorf("fail to delete sts %s", stsNameWithUser), resp.StatusCode)
 	}
 
-	if framework.PVEnabled {
-		pv1Url := BuildK8sProxyUrl(framework.KubecubeHost, framework.TargetClusterName, "api/v1", framework.NamespaceName, "persistentvolumeclaims", "pv1-"+stsNameWithUser+"-0")
-		resp, err = httpHelper.RequestByUser(http.MethodDelete, pv1Url, "", user, nil)
-		framework.ExpectNoError(err)
+	if tc.HasCapability(framework.CapabilityPV) {
+		pv1Url := BuildK8sProxyUrl(tc.KubecubeHost, tc.TargetClusterName, "api/v1", tc.NamespaceName, "persistentvolumeclaims", "pv1-"+stsNameWithUser+"-0")
+		resp, err = tc.HttpHelper.RequestByUser(http.MethodDelete, pv1Url, "", tc.User, nil)
+		tc.ExpectNoError(err)
 		defer resp.Body.Close()
 		body, err = io.ReadAll(resp.Body)
 		clog.Info("delete pv1: %+v", string(body))
@@ -417,9 +426,9 @@ func deleteStatefulset(user string) framework.TestResp {
 			clog.Warn("res code %d", resp.StatusCode)
 			return framework.NewTestResp(fmt.Errorf("fail to delete sts pv1 %s", stsNameWithUser), resp.StatusCode)
 		}
-		pv2Url := BuildK8sProxyUrl(framework.KubecubeHost, framework.TargetClusterName, "api/v1", framework.NamespaceName, "persistentvolumeclaims", "pv1-"+stsNameWithUser+"-1")
-		resp, err = httpHelper.RequestByUser(http.MethodDelete, pv2Url, "", user, nil)
-		framework.ExpectNoError(err)
+		pv2Url := BuildK8sProxyUrl(tc.KubecubeHost, tc.TargetClusterName, "api/v1", tc.NamespaceName, "persistentvolumeclaims", "pv1-"+stsNameWithUser+"-1")
+		resp, err = tc.HttpHelper.RequestByUser(http.MethodDelete, pv2Url, "", tc.User, nil)
+		tc.ExpectNoError(err)
 		defer resp.Body.Close()
 		body, err = io.ReadAll(resp.Body)
 		clog.Info("delete pv2: %+v", string(body))
@@ -435,8 +444,8 @@ func deleteStatefulset(user string) framework.TestResp {
 var multiUserStsTest = framework.MultiUserTest{
 	TestName:        "[工作负载][9478763]创建StatefulSet工作负载挂载卷",
 	ContinueIfError: false,
-	Skipfunc: func() bool {
-		return !framework.StatefulSetEnable
+	Skipfunc: func(tc *framework.TestContext) bool {
+		return !tc.StatefulSetEnable
 	},
 	ErrorFunc:  framework.PermissionErrorFunc,
 	AfterEach:  nil,
@@ -17,41 +17,21 @@ limitations under the License.
 package workloads
 
 import (
-	"github.com/kubecube-io/kubecube/pkg/multicluster/client"
-
 	"github.com/kubecube-io/kubecube-e2e/e2e/framework"
 )
 
-var (
-	httpHelper   *framework.HttpHelper
-	targetClient client.Client
-
-	cronJobName         = "hellocronjob"
-	cronJobNameWithUser string
-
-	daemonSetName         = "hellods"
-	daemonSetNameWithUser string
+const (
+	cronJobName   = "hellocronjob"
+	daemonSetName = "hellods"
 
 	deployName = "e2e-test-deploy"
 	pv1Name    = "pv1"
 	pv2Name    = "pv2"
 
-	deployNameWithUser string
-	pv1NameWithUser    string
-	pv2NameWithUser    string
-
-	jobName         = "hellojob"
-	jobNameWithUser string
-
-	stsName         = "sts1"
-	stsNameWithUser string
+	jobName = "hellojob"
+	stsName = "sts1"
 )
 
-func initParam() {
-	httpHelper = framework.NewSingleHttpHelper()
-	targetClient = framework.TargetClusterClient
-}
-
 func init() {
 	framework.RegisterByDefault(multiUserCronjobTest)
 	framework.RegisterByDefault(multiUserJobTest)
@@ -18,5 +18,8 @@ package constants
 
 const (
 	GeneralLoginType    = "GeneralLogin"
+	KeyLoginType        = "KeyLogin"
+	LDAPLoginType       = "LDAPLogin"
+	OIDCLoginType       = "OIDCLogin"
 	AuthorizationHeader = "Authorization"
 )
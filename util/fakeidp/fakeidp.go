@@ -0,0 +1,378 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fakeidp 一个仅用于测试的 OAuth2/OIDC 身份提供方，
+// 授权端点以 GET 返回 HTML 登录页，登录页以 POST 提交用户名密码后重定向回客户端
+package fakeidp
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const keyID = "fakeidp"
+
+// User 身份提供方中的用户
+type User struct {
+	Username string
+	Password string
+	Email    string
+}
+
+// Config 身份提供方配置
+type Config struct {
+	// Issuer 对外的访问地址，需可被 KubeCube 访问
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Users        []User
+	// TokenTTL access token 与 id token 的有效期，默认 1 小时
+	TokenTTL time.Duration
+}
+
+type grant struct {
+	user        User
+	clientID    string
+	redirectURI string
+	nonce       string
+	expire      time.Time
+}
+
+type session struct {
+	user   User
+	expire time.Time
+}
+
+// Server OIDC 身份提供方
+type Server struct {
+	cfg      Config
+	key      *rsa.PrivateKey
+	listener net.Listener
+	server   *http.Server
+
+	mu     sync.Mutex
+	codes  map[string]grant
+	tokens map[string]session
+}
+
+// New 创建身份提供方，生成签发 id token 使用的 RSA 密钥
+func New(cfg Config) (*Server, error) {
+	if len(cfg.Issuer) == 0 || len(cfg.ClientID) == 0 {
+		return nil, errors.New("issuer and client id can not be empty")
+	}
+	if cfg.TokenTTL == 0 {
+		cfg.TokenTTL = time.Hour
+	}
+	cfg.Issuer = strings.TrimSuffix(cfg.Issuer, "/")
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{
+		cfg:    cfg,
+		key:    key,
+		codes:  make(map[string]grant),
+		tokens: make(map[string]session),
+	}, nil
+}
+
+// Start 在 addr 上监听并在后台提供服务
+func (s *Server) Start(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.listener = l
+	s.server = &http.Server{Handler: s.Handler()}
+	go func() {
+		_ = s.server.Serve(l)
+	}()
+	return nil
+}
+
+// Addr 实际监听的地址
+func (s *Server) Addr() string {
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// Stop 停止服务
+func (s *Server) Stop() error {
+	if s.server == nil {
+		return nil
+	}
+	return s.server.Close()
+}
+
+// Handler 身份提供方的 http 处理器
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("/authorize", s.authorize)
+	mux.HandleFunc("/token", s.token)
+	mux.HandleFunc("/userinfo", s.userinfo)
+	mux.HandleFunc("/keys", s.keys)
+	return mux
+}
+
+func (s *Server) discovery(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                s.cfg.Issuer,
+		"authorization_endpoint":                s.cfg.Issuer + "/authorize",
+		"token_endpoint":                        s.cfg.Issuer + "/token",
+		"userinfo_endpoint":                     s.cfg.Issuer + "/userinfo",
+		"jwks_uri":                              s.cfg.Issuer + "/keys",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"scopes_supported":                      []string{"openid", "profile", "email"},
+	})
+}
+
+// authorize GET 时返回登录页，POST 时校验登录页提交的用户名密码，通过后携带 code 重定向回 redirect_uri
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.Form.Get("response_type") != "code" {
+		http.Error(w, "unsupported response_type", http.StatusBadRequest)
+		return
+	}
+	if r.Form.Get("client_id") != s.cfg.ClientID {
+		http.Error(w, "unknown client", http.StatusBadRequest)
+		return
+	}
+	redirectURI, err := url.Parse(r.Form.Get("redirect_uri"))
+	if err != nil || !redirectURI.IsAbs() {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		s.loginPage(w, r.Form)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	username, password := r.PostForm.Get("username"), r.PostForm.Get("password")
+	user, ok := s.lookup(username, password)
+	if !ok {
+		q := redirectURI.Query()
+		q.Set("error", "access_denied")
+		q.Set("state", r.Form.Get("state"))
+		redirectURI.RawQuery = q.Encode()
+		http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+		return
+	}
+
+	code := randomString()
+	s.mu.Lock()
+	s.codes[code] = grant{
+		user:        user,
+		clientID:    s.cfg.ClientID,
+		redirectURI: r.Form.Get("redirect_uri"),
+		nonce:       r.Form.Get("nonce"),
+		expire:      time.Now().Add(time.Minute),
+	}
+	s.mu.Unlock()
+
+	q := redirectURI.Query()
+	q.Set("code", code)
+	q.Set("state", r.Form.Get("state"))
+	redirectURI.RawQuery = q.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+var loginTemplate = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html>
+<head><title>fakeidp login</title></head>
+<body>
+<form method="post" action="/authorize">
+{{range $name, $values := .}}{{range $values}}<input type="hidden" name="{{$name}}" value="{{.}}">
+{{end}}{{end}}<input type="text" name="username">
+<input type="password" name="password">
+<button type="submit">Sign in</button>
+</form>
+</body>
+</html>
+`))
+
+// loginPage 返回登录页，授权请求参数以隐藏字段随用户名密码一起提交
+func (s *Server) loginPage(w http.ResponseWriter, params url.Values) {
+	hidden := url.Values{}
+	for _, name := range []string{"response_type", "client_id", "redirect_uri", "scope", "state", "nonce"} {
+		if v, ok := params[name]; ok {
+			hidden[name] = v
+		}
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = loginTemplate.Execute(w, hidden)
+}
+
+// token 以 code 换取 access token 与 id token
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.Form.Get("client_id"), r.Form.Get("client_secret")
+	}
+	if clientID != s.cfg.ClientID || clientSecret != s.cfg.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	if r.Form.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	code := r.Form.Get("code")
+	s.mu.Lock()
+	g, ok := s.codes[code]
+	delete(s.codes, code)
+	s.mu.Unlock()
+	if !ok || time.Now().After(g.expire) || g.redirectURI != r.Form.Get("redirect_uri") {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	idToken, err := s.sign(map[string]interface{}{
+		"iss":                s.cfg.Issuer,
+		"sub":                g.user.Username,
+		"aud":                g.clientID,
+		"iat":                now.Unix(),
+		"exp":                now.Add(s.cfg.TokenTTL).Unix(),
+		"nonce":              g.nonce,
+		"name":               g.user.Username,
+		"preferred_username": g.user.Username,
+		"email":              g.user.Email,
+	})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	accessToken := randomString()
+	s.mu.Lock()
+	s.tokens[accessToken] = session{user: g.user, expire: now.Add(s.cfg.TokenTTL)}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int64(s.cfg.TokenTTL.Seconds()),
+		"id_token":     idToken,
+	})
+}
+
+func (s *Server) userinfo(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	s.mu.Lock()
+	sess, ok := s.tokens[token]
+	s.mu.Unlock()
+	if !ok || time.Now().After(sess.expire) {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_token"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"sub":                sess.user.Username,
+		"name":               sess.user.Username,
+		"preferred_username": sess.user.Username,
+		"email":              sess.user.Email,
+	})
+}
+
+func (s *Server) keys(w http.ResponseWriter, _ *http.Request) {
+	pub := s.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"kid": keyID,
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+func (s *Server) lookup(username, password string) (User, bool) {
+	for _, u := range s.cfg.Users {
+		if u.Username == username && u.Password == password {
+			return u, true
+		}
+	}
+	return User{}, false
+}
+
+// sign 使用 RS256 签发 jwt
+func (s *Server) sign(claims map[string]interface{}) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": keyID})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signing := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signing))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signing + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
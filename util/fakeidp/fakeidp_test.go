@@ -0,0 +1,363 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fakeidp
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const (
+	testClientID     = "kubecube"
+	testClientSecret = "kubecube-secret"
+	testRedirectURI  = "https://cube.example.com/api/v1/cube/oauth/redirect"
+)
+
+func startTestServer(t *testing.T) (*Server, *httptest.Server) {
+	t.Helper()
+	s, err := New(Config{
+		Issuer:       "https://idp.example.com/",
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		Users:        []User{{Username: "alice", Password: "alice-pw", Email: "alice@e2e.kubecube.io"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(ts.Close)
+	return s, ts
+}
+
+// noRedirect 不跟随重定向的客户端，便于检查 authorize 返回的 Location
+var noRedirect = &http.Client{
+	CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+}
+
+func authorizeParams() url.Values {
+	return url.Values{
+		"response_type": {"code"},
+		"client_id":     {testClientID},
+		"redirect_uri":  {testRedirectURI},
+		"scope":         {"openid email"},
+		"state":         {"state-1"},
+		"nonce":         {"nonce-1"},
+	}
+}
+
+func decodeJSON(t *testing.T, resp *http.Response, v interface{}) {
+	t.Helper()
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewValidatesConfig(t *testing.T) {
+	if _, err := New(Config{ClientID: testClientID}); err == nil {
+		t.Fatal("expected error for empty issuer")
+	}
+	if _, err := New(Config{Issuer: "https://idp.example.com"}); err == nil {
+		t.Fatal("expected error for empty client id")
+	}
+}
+
+func TestDiscovery(t *testing.T) {
+	_, ts := startTestServer(t)
+	resp, err := http.Get(ts.URL + "/.well-known/openid-configuration")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := map[string]interface{}{}
+	decodeJSON(t, resp, &doc)
+	want := map[string]string{
+		"issuer":                 "https://idp.example.com",
+		"authorization_endpoint": "https://idp.example.com/authorize",
+		"token_endpoint":         "https://idp.example.com/token",
+		"userinfo_endpoint":      "https://idp.example.com/userinfo",
+		"jwks_uri":               "https://idp.example.com/keys",
+	}
+	for k, v := range want {
+		if doc[k] != v {
+			t.Errorf("%s = %v, want %s", k, doc[k], v)
+		}
+	}
+}
+
+func TestAuthorizeLoginPage(t *testing.T) {
+	_, ts := startTestServer(t)
+	cases := []struct {
+		name   string
+		mutate func(url.Values)
+		code   int
+	}{
+		{name: "valid request", mutate: func(url.Values) {}, code: http.StatusOK},
+		{name: "credentials in query are ignored", mutate: func(v url.Values) {
+			v.Set("username", "alice")
+			v.Set("password", "alice-pw")
+		}, code: http.StatusOK},
+		{name: "unsupported response type", mutate: func(v url.Values) { v.Set("response_type", "token") }, code: http.StatusBadRequest},
+		{name: "unknown client", mutate: func(v url.Values) { v.Set("client_id", "other") }, code: http.StatusBadRequest},
+		{name: "relative redirect uri", mutate: func(v url.Values) { v.Set("redirect_uri", "/callback") }, code: http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			params := authorizeParams()
+			c.mutate(params)
+			resp, err := noRedirect.Get(ts.URL + "/authorize?" + params.Encode())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != c.code {
+				t.Fatalf("status %d, want %d", resp.StatusCode, c.code)
+			}
+			if c.code != http.StatusOK {
+				return
+			}
+			body, _ := io.ReadAll(resp.Body)
+			page := string(body)
+			for _, s := range []string{
+				`<form method="post" action="/authorize">`,
+				`name="state" value="state-1"`,
+				`name="nonce" value="nonce-1"`,
+				`name="redirect_uri" value="` + testRedirectURI + `"`,
+				`type="password" name="password"`,
+			} {
+				if !strings.Contains(page, s) {
+					t.Errorf("login page does not contain %s:\n%s", s, page)
+				}
+			}
+			if strings.Contains(page, "alice-pw") {
+				t.Error("login page echoes the password")
+			}
+		})
+	}
+}
+
+func TestAuthorizeSubmit(t *testing.T) {
+	_, ts := startTestServer(t)
+	cases := []struct {
+		name     string
+		password string
+		wantCode bool
+	}{
+		{name: "valid credentials", password: "alice-pw", wantCode: true},
+		{name: "wrong password", password: "bob-pw"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			form := authorizeParams()
+			form.Set("username", "alice")
+			form.Set("password", c.password)
+			resp, err := noRedirect.PostForm(ts.URL+"/authorize", form)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusFound {
+				t.Fatalf("status %d, want %d", resp.StatusCode, http.StatusFound)
+			}
+			location, err := url.Parse(resp.Header.Get("Location"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(location.String(), testRedirectURI+"?") {
+				t.Fatalf("redirected to %s", location)
+			}
+			q := location.Query()
+			if q.Get("state") != "state-1" {
+				t.Errorf("state = %s", q.Get("state"))
+			}
+			if got := len(q.Get("code")) > 0; got != c.wantCode {
+				t.Errorf("has code = %v, want %v", got, c.wantCode)
+			}
+			if !c.wantCode && q.Get("error") != "access_denied" {
+				t.Errorf("error = %s, want access_denied", q.Get("error"))
+			}
+		})
+	}
+}
+
+// authorizationCode 提交登录表单取得授权码
+func authorizationCode(t *testing.T, ts *httptest.Server) string {
+	t.Helper()
+	form := authorizeParams()
+	form.Set("username", "alice")
+	form.Set("password", "alice-pw")
+	resp, err := noRedirect.PostForm(ts.URL+"/authorize", form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return location.Query().Get("code")
+}
+
+func TestToken(t *testing.T) {
+	_, ts := startTestServer(t)
+	exchange := func(code, secret, redirectURI string) *http.Response {
+		resp, err := http.PostForm(ts.URL+"/token", url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {code},
+			"client_id":     {testClientID},
+			"client_secret": {secret},
+			"redirect_uri":  {redirectURI},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	cases := []struct {
+		name        string
+		secret      string
+		redirectURI string
+		status      int
+		errCode     string
+	}{
+		{name: "wrong client secret", secret: "wrong", redirectURI: testRedirectURI, status: http.StatusUnauthorized, errCode: "invalid_client"},
+		{name: "redirect uri mismatch", secret: testClientSecret, redirectURI: "https://other.example.com/cb", status: http.StatusBadRequest, errCode: "invalid_grant"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			resp := exchange(authorizationCode(t, ts), c.secret, c.redirectURI)
+			if resp.StatusCode != c.status {
+				t.Fatalf("status %d, want %d", resp.StatusCode, c.status)
+			}
+			body := map[string]string{}
+			decodeJSON(t, resp, &body)
+			if body["error"] != c.errCode {
+				t.Fatalf("error = %s, want %s", body["error"], c.errCode)
+			}
+		})
+	}
+
+	code := authorizationCode(t, ts)
+	resp := exchange(code, testClientSecret, testRedirectURI)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+	tokens := struct {
+		AccessToken string `json:"access_token"`
+		IDToken     string `json:"id_token"`
+	}{}
+	decodeJSON(t, resp, &tokens)
+
+	claims := verifyIDToken(t, ts, tokens.IDToken)
+	for k, v := range map[string]string{
+		"iss":   "https://idp.example.com",
+		"aud":   testClientID,
+		"sub":   "alice",
+		"email": "alice@e2e.kubecube.io",
+		"nonce": "nonce-1",
+	} {
+		if claims[k] != v {
+			t.Errorf("claim %s = %v, want %s", k, claims[k], v)
+		}
+	}
+
+	// 授权码只能使用一次
+	resp = exchange(code, testClientSecret, testRedirectURI)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("reused code status %d", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/userinfo", nil)
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := map[string]string{}
+	decodeJSON(t, resp, &info)
+	if info["preferred_username"] != "alice" {
+		t.Fatalf("userinfo = %v", info)
+	}
+
+	req.Header.Set("Authorization", "Bearer unknown")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("unknown token status %d", resp.StatusCode)
+	}
+}
+
+// verifyIDToken 使用 jwks 中的公钥校验 id token 签名并返回其中的 claims
+func verifyIDToken(t *testing.T, ts *httptest.Server, token string) map[string]interface{} {
+	t.Helper()
+	resp, err := http.Get(ts.URL + "/keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := struct {
+		Keys []map[string]string `json:"keys"`
+	}{}
+	decodeJSON(t, resp, &jwks)
+	if len(jwks.Keys) != 1 || jwks.Keys[0]["kid"] != keyID {
+		t.Fatalf("unexpected jwks %v", jwks)
+	}
+	n, err := base64.RawURLEncoding.DecodeString(jwks.Keys[0]["n"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(jwks.Keys[0]["e"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("malformed id token %s", token)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err = rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig); err != nil {
+		t.Fatalf("invalid id token signature: %v", err)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := map[string]interface{}{}
+	if err = json.Unmarshal(payload, &claims); err != nil {
+		t.Fatal(err)
+	}
+	return claims
+}
@@ -0,0 +1,219 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fakeldap
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	classUniversal   = 0x00
+	classApplication = 0x40
+	classContext     = 0x80
+
+	tagBoolean     = 0x01
+	tagInteger     = 0x02
+	tagOctetString = 0x04
+	tagNull        = 0x05
+	tagEnumerated  = 0x0a
+	tagSequence    = 0x10
+	tagSet         = 0x11
+
+	// maxPacketSize 单个 LDAP 消息的最大长度
+	maxPacketSize = 1 << 20
+)
+
+// packet BER 编码的一个元素，constructed 元素的内容解析到 children
+type packet struct {
+	class       byte
+	constructed bool
+	tag         byte
+	value       []byte
+	children    []*packet
+}
+
+// readPacket 从 r 中读取一个完整的 BER 元素
+func readPacket(r *bufio.Reader) (*packet, error) {
+	identifier, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	length, err := readLength(r)
+	if err != nil {
+		return nil, err
+	}
+	if length > maxPacketSize {
+		return nil, fmt.Errorf("packet too large: %d", length)
+	}
+	value := make([]byte, length)
+	if _, err = io.ReadFull(r, value); err != nil {
+		return nil, err
+	}
+	return decode(identifier, value)
+}
+
+func readLength(r io.ByteReader) (int, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if b&0x80 == 0 {
+		return int(b), nil
+	}
+	n := int(b & 0x7f)
+	if n == 0 || n > 4 {
+		return 0, errors.New("unsupported ber length")
+	}
+	length := 0
+	for i := 0; i < n; i++ {
+		b, err = r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		length = length<<8 | int(b)
+	}
+	return length, nil
+}
+
+func decode(identifier byte, value []byte) (*packet, error) {
+	p := &packet{
+		class:       identifier & 0xc0,
+		constructed: identifier&0x20 != 0,
+		tag:         identifier & 0x1f,
+		value:       value,
+	}
+	if p.tag == 0x1f {
+		return nil, errors.New("high tag number is not supported")
+	}
+	if !p.constructed {
+		return p, nil
+	}
+	for len(value) > 0 {
+		if len(value) < 2 {
+			return nil, errors.New("truncated ber element")
+		}
+		r := &sliceReader{b: value[1:]}
+		length, err := readLength(r)
+		if err != nil {
+			return nil, err
+		}
+		start := 1 + r.off
+		if start+length > len(value) {
+			return nil, errors.New("truncated ber element")
+		}
+		child, err := decode(value[0], value[start:start+length])
+		if err != nil {
+			return nil, err
+		}
+		p.children = append(p.children, child)
+		value = value[start+length:]
+	}
+	return p, nil
+}
+
+type sliceReader struct {
+	b   []byte
+	off int
+}
+
+func (s *sliceReader) ReadByte() (byte, error) {
+	if s.off >= len(s.b) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	b := s.b[s.off]
+	s.off++
+	return b, nil
+}
+
+func (p *packet) is(class, tag byte) bool {
+	return p.class == class && p.tag == tag
+}
+
+func (p *packet) child(i int) (*packet, error) {
+	if i >= len(p.children) {
+		return nil, fmt.Errorf("missing element %d", i)
+	}
+	return p.children[i], nil
+}
+
+func (p *packet) int() int64 {
+	var v int64
+	for i, b := range p.value {
+		if i == 0 && b&0x80 != 0 {
+			v = -1
+		}
+		v = v<<8 | int64(b)
+	}
+	return v
+}
+
+func (p *packet) string() string {
+	return string(p.value)
+}
+
+// encode 将元素编码为 BER，constructed 元素由 children 生成内容
+func (p *packet) encode() []byte {
+	value := p.value
+	if p.constructed {
+		value = nil
+		for _, c := range p.children {
+			value = append(value, c.encode()...)
+		}
+	}
+	identifier := p.class | p.tag
+	if p.constructed {
+		identifier |= 0x20
+	}
+	out := []byte{identifier}
+	out = append(out, encodeLength(len(value))...)
+	return append(out, value...)
+}
+
+func encodeLength(n int) []byte {
+	if n < 0x80 {
+		return []byte{byte(n)}
+	}
+	var b []byte
+	for n > 0 {
+		b = append([]byte{byte(n)}, b...)
+		n >>= 8
+	}
+	return append([]byte{0x80 | byte(len(b))}, b...)
+}
+
+func newSequence(class, tag byte, children ...*packet) *packet {
+	return &packet{class: class, constructed: true, tag: tag, children: children}
+}
+
+func newString(class, tag byte, s string) *packet {
+	return &packet{class: class, tag: tag, value: []byte(s)}
+}
+
+func newInt(tag byte, v int64) *packet {
+	var b []byte
+	for {
+		b = append([]byte{byte(v)}, b...)
+		v >>= 8
+		if (v == 0 && b[0]&0x80 == 0) || (v == -1 && b[0]&0x80 != 0) {
+			break
+		}
+	}
+	return &packet{class: classUniversal, tag: tag, value: b}
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fakeldap
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPacketRoundTrip(t *testing.T) {
+	cases := []struct {
+		name   string
+		packet *packet
+	}{
+		{
+			name:   "string",
+			packet: newString(classUniversal, tagOctetString, "uid=admin,dc=kubecube,dc=io"),
+		},
+		{
+			name:   "empty string",
+			packet: newString(classContext, 0, ""),
+		},
+		{
+			name:   "long string",
+			packet: newString(classUniversal, tagOctetString, strings.Repeat("a", 300)),
+		},
+		{
+			name: "nested sequence",
+			packet: newSequence(classUniversal, tagSequence,
+				newInt(tagInteger, 1),
+				newSequence(classApplication, appBindRequest,
+					newInt(tagInteger, 3),
+					newString(classUniversal, tagOctetString, "admin"),
+					newString(classContext, 0, "secret"))),
+		},
+		{
+			name:   "empty sequence",
+			packet: newSequence(classUniversal, tagSequence),
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := readPacket(bufio.NewReader(bytes.NewReader(c.packet.encode())))
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if !reflect.DeepEqual(got.encode(), c.packet.encode()) {
+				t.Fatalf("re-encoded packet differs: %x != %x", got.encode(), c.packet.encode())
+			}
+			if got.class != c.packet.class || got.tag != c.packet.tag || got.constructed != c.packet.constructed {
+				t.Fatalf("identifier mismatch: got %+v", got)
+			}
+			if len(got.children) != len(c.packet.children) {
+				t.Fatalf("got %d children, want %d", len(got.children), len(c.packet.children))
+			}
+		})
+	}
+}
+
+func TestIntRoundTrip(t *testing.T) {
+	for _, v := range []int64{0, 1, 127, 128, 255, 256, 65535, 1 << 30, -1, -128, -129, -65536} {
+		p := newInt(tagInteger, v)
+		got, err := readPacket(bufio.NewReader(bytes.NewReader(p.encode())))
+		if err != nil {
+			t.Fatalf("decode %d: %v", v, err)
+		}
+		if got.int() != v {
+			t.Errorf("int round trip: got %d, want %d", got.int(), v)
+		}
+	}
+}
+
+func TestLengthRoundTrip(t *testing.T) {
+	for _, n := range []int{0, 1, 0x7f, 0x80, 0xff, 0x100, 0xffff, 0x10000, maxPacketSize} {
+		got, err := readLength(&sliceReader{b: encodeLength(n)})
+		if err != nil {
+			t.Fatalf("read length %d: %v", n, err)
+		}
+		if got != n {
+			t.Errorf("length round trip: got %d, want %d", got, n)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		data []byte
+	}{
+		{name: "truncated value", data: []byte{0x04, 0x05, 'a'}},
+		{name: "truncated child", data: []byte{0x30, 0x03, 0x04, 0x05, 'a'}},
+		{name: "high tag number", data: []byte{0x1f, 0x00}},
+		{name: "indefinite length", data: []byte{0x30, 0x80}},
+		{name: "too large", data: []byte{0x04, 0x84, 0x7f, 0xff, 0xff, 0xff}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := readPacket(bufio.NewReader(bytes.NewReader(c.data))); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
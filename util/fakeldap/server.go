@@ -0,0 +1,412 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fakeldap 一个仅用于测试的 LDAP v3 服务，只支持 simple bind、search 与 unbind，
+// 所有用户位于 BaseDN 下，DN 为 uid=<username>,<BaseDN>
+package fakeldap
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/kubecube-io/kubecube/pkg/clog"
+)
+
+const (
+	appBindRequest     = 0
+	appBindResponse    = 1
+	appUnbindRequest   = 2
+	appSearchRequest   = 3
+	appSearchEntry     = 4
+	appSearchDone      = 5
+	appAbandonRequest  = 16
+	appExtendedRequest = 23
+	appExtendedResp    = 24
+
+	resultSuccess            = 0
+	resultProtocolError      = 2
+	resultNoSuchObject       = 32
+	resultInvalidCredentials = 49
+	resultUnwillingToPerform = 53
+
+	scopeBaseObject   = 0
+	scopeSingleLevel  = 1
+	scopeWholeSubtree = 2
+
+	filterAnd        = 0
+	filterOr         = 1
+	filterNot        = 2
+	filterEquality   = 3
+	filterSubstrings = 4
+	filterPresent    = 7
+)
+
+// User LDAP 中的用户
+type User struct {
+	Username string
+	Password string
+	Email    string
+}
+
+// Config LDAP 服务配置
+type Config struct {
+	// BaseDN 用户所在的目录，如 dc=kubecube,dc=io
+	BaseDN string
+	// BindDN/BindPassword KubeCube 查询用户时使用的管理账号，为空时允许匿名查询
+	BindDN       string
+	BindPassword string
+	Users        []User
+}
+
+type entry struct {
+	dn         string
+	password   string
+	attributes map[string][]string
+}
+
+// Server LDAP 服务
+type Server struct {
+	cfg      Config
+	entries  []entry
+	listener net.Listener
+
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+}
+
+// New 创建 LDAP 服务
+func New(cfg Config) (*Server, error) {
+	if len(cfg.BaseDN) == 0 {
+		return nil, errors.New("base dn can not be empty")
+	}
+	s := &Server{cfg: cfg, conns: make(map[net.Conn]struct{})}
+	for _, u := range cfg.Users {
+		s.entries = append(s.entries, entry{
+			dn:       "uid=" + u.Username + "," + cfg.BaseDN,
+			password: u.Password,
+			attributes: map[string][]string{
+				"objectClass": {"top", "person", "organizationalPerson", "inetOrgPerson"},
+				"uid":         {u.Username},
+				"cn":          {u.Username},
+				"sn":          {u.Username},
+				"mail":        {u.Email},
+			},
+		})
+	}
+	return s, nil
+}
+
+// Start 在 addr 上监听并在后台提供服务
+func (s *Server) Start(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.listener = l
+	go s.serve()
+	return nil
+}
+
+// Addr 实际监听的地址
+func (s *Server) Addr() string {
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// Stop 停止监听并断开所有连接
+func (s *Server) Stop() error {
+	s.mu.Lock()
+	s.closed = true
+	for c := range s.conns {
+		_ = c.Close()
+	}
+	s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if !closed {
+				clog.Warn("fake ldap accept error: %v", err)
+			}
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		_ = conn.Close()
+	}()
+
+	r := bufio.NewReader(conn)
+	for {
+		msg, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		if len(msg.children) < 2 {
+			return
+		}
+		id := msg.children[0].int()
+		op := msg.children[1]
+		if op.class != classApplication {
+			return
+		}
+
+		var responses []*packet
+		switch op.tag {
+		case appBindRequest:
+			responses = []*packet{s.bind(op)}
+		case appSearchRequest:
+			responses = s.search(op)
+		case appUnbindRequest:
+			return
+		case appAbandonRequest:
+			continue
+		case appExtendedRequest:
+			responses = []*packet{result(appExtendedResp, resultProtocolError, "extended operation is not supported")}
+		default:
+			clog.Warn("fake ldap unsupported operation %d", op.tag)
+			return
+		}
+
+		for _, resp := range responses {
+			out := newSequence(classUniversal, tagSequence, newInt(tagInteger, id), resp)
+			if _, err = conn.Write(out.encode()); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *Server) bind(op *packet) *packet {
+	if len(op.children) < 3 {
+		return result(appBindResponse, resultProtocolError, "malformed bind request")
+	}
+	name := op.children[1].string()
+	auth := op.children[2]
+	if !auth.is(classContext, 0) {
+		return result(appBindResponse, resultUnwillingToPerform, "only simple bind is supported")
+	}
+	password := auth.string()
+
+	if len(name) == 0 && len(password) == 0 && len(s.cfg.BindDN) == 0 {
+		return result(appBindResponse, resultSuccess, "")
+	}
+	if len(s.cfg.BindDN) > 0 && strings.EqualFold(name, s.cfg.BindDN) && password == s.cfg.BindPassword {
+		return result(appBindResponse, resultSuccess, "")
+	}
+	for _, e := range s.entries {
+		if (strings.EqualFold(name, e.dn) || name == e.attributes["uid"][0]) && password == e.password && len(password) > 0 {
+			return result(appBindResponse, resultSuccess, "")
+		}
+	}
+	return result(appBindResponse, resultInvalidCredentials, "invalid credentials")
+}
+
+func (s *Server) search(op *packet) []*packet {
+	if len(op.children) < 8 {
+		return []*packet{result(appSearchDone, resultProtocolError, "malformed search request")}
+	}
+	base := strings.ToLower(op.children[0].string())
+	scope := op.children[1].int()
+	filter := op.children[6]
+	var wanted []string
+	for _, a := range op.children[7].children {
+		wanted = append(wanted, a.string())
+	}
+
+	baseDN := strings.ToLower(s.cfg.BaseDN)
+	if base != baseDN && !strings.HasSuffix(base, ","+baseDN) {
+		return []*packet{result(appSearchDone, resultNoSuchObject, "")}
+	}
+
+	var out []*packet
+	for _, e := range s.entries {
+		dn := strings.ToLower(e.dn)
+		switch scope {
+		case scopeBaseObject:
+			if dn != base {
+				continue
+			}
+		case scopeSingleLevel, scopeWholeSubtree:
+			if base != baseDN {
+				continue
+			}
+		}
+		ok, err := match(filter, e)
+		if err != nil {
+			return []*packet{result(appSearchDone, resultProtocolError, err.Error())}
+		}
+		if ok {
+			out = append(out, searchEntry(e, wanted))
+		}
+	}
+	return append(out, result(appSearchDone, resultSuccess, ""))
+}
+
+func searchEntry(e entry, wanted []string) *packet {
+	all := len(wanted) == 0
+	for _, w := range wanted {
+		if w == "*" {
+			all = true
+		}
+	}
+	attrs := newSequence(classUniversal, tagSequence)
+	for name, values := range e.attributes {
+		if !all && !containsFold(wanted, name) {
+			continue
+		}
+		vals := newSequence(classUniversal, tagSet)
+		for _, v := range values {
+			vals.children = append(vals.children, newString(classUniversal, tagOctetString, v))
+		}
+		attrs.children = append(attrs.children, newSequence(classUniversal, tagSequence,
+			newString(classUniversal, tagOctetString, name), vals))
+	}
+	return newSequence(classApplication, appSearchEntry,
+		newString(classUniversal, tagOctetString, e.dn), attrs)
+}
+
+// match 判断条目是否满足过滤条件，支持 and、or、not、等值、子串与存在性过滤
+func match(f *packet, e entry) (bool, error) {
+	if f.class != classContext {
+		return false, errors.New("invalid filter")
+	}
+	switch f.tag {
+	case filterAnd:
+		for _, c := range f.children {
+			ok, err := match(c, e)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+	case filterOr:
+		for _, c := range f.children {
+			ok, err := match(c, e)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				return true, nil
+			}
+		}
+		return false, nil
+	case filterNot:
+		c, err := f.child(0)
+		if err != nil {
+			return false, err
+		}
+		ok, err := match(c, e)
+		return !ok, err
+	case filterEquality:
+		if len(f.children) < 2 {
+			return false, errors.New("malformed equality filter")
+		}
+		for _, v := range values(e, f.children[0].string()) {
+			if strings.EqualFold(v, f.children[1].string()) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case filterSubstrings:
+		if len(f.children) < 2 {
+			return false, errors.New("malformed substrings filter")
+		}
+		for _, v := range values(e, f.children[0].string()) {
+			if matchSubstrings(strings.ToLower(v), f.children[1].children) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case filterPresent:
+		return len(values(e, f.string())) > 0, nil
+	default:
+		return false, nil
+	}
+}
+
+func matchSubstrings(v string, parts []*packet) bool {
+	for _, p := range parts {
+		sub := strings.ToLower(p.string())
+		switch p.tag {
+		case 0:
+			if !strings.HasPrefix(v, sub) {
+				return false
+			}
+			v = v[len(sub):]
+		case 1:
+			i := strings.Index(v, sub)
+			if i < 0 {
+				return false
+			}
+			v = v[i+len(sub):]
+		case 2:
+			if !strings.HasSuffix(v, sub) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func values(e entry, attr string) []string {
+	for name, vals := range e.attributes {
+		if strings.EqualFold(name, attr) {
+			return vals
+		}
+	}
+	return nil
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func result(tag byte, code int64, message string) *packet {
+	return newSequence(classApplication, tag,
+		newInt(tagEnumerated, code),
+		newString(classUniversal, tagOctetString, ""),
+		newString(classUniversal, tagOctetString, message))
+}
@@ -0,0 +1,253 @@
+/*
+Copyright 2023 KubeCube Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fakeldap
+
+import (
+	"bufio"
+	"net"
+	"sort"
+	"testing"
+	"time"
+)
+
+const testBaseDN = "dc=kubecube,dc=io"
+
+func startTestServer(t *testing.T) *Server {
+	t.Helper()
+	s, err := New(Config{
+		BaseDN:       testBaseDN,
+		BindDN:       "cn=admin," + testBaseDN,
+		BindPassword: "admin-secret",
+		Users: []User{
+			{Username: "alice", Password: "alice-pw", Email: "alice@e2e.kubecube.io"},
+			{Username: "bob", Password: "bob-pw", Email: "bob@e2e.kubecube.io"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Start("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Stop() })
+	return s
+}
+
+// testClient 按 LDAP 消息格式收发请求的最小客户端
+type testClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+	id   int64
+}
+
+func dial(t *testing.T, s *Server) *testClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+	return &testClient{t: t, conn: conn, r: bufio.NewReader(conn)}
+}
+
+func (c *testClient) send(op *packet) {
+	c.t.Helper()
+	c.id++
+	msg := newSequence(classUniversal, tagSequence, newInt(tagInteger, c.id), op)
+	if _, err := c.conn.Write(msg.encode()); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *testClient) receive() *packet {
+	c.t.Helper()
+	msg, err := readPacket(c.r)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if len(msg.children) < 2 || msg.children[0].int() != c.id {
+		c.t.Fatalf("unexpected response message %+v", msg)
+	}
+	return msg.children[1]
+}
+
+func (c *testClient) bind(name, password string) int64 {
+	c.t.Helper()
+	c.send(newSequence(classApplication, appBindRequest,
+		newInt(tagInteger, 3),
+		newString(classUniversal, tagOctetString, name),
+		newString(classContext, 0, password)))
+	resp := c.receive()
+	if !resp.is(classApplication, appBindResponse) {
+		c.t.Fatalf("expected bind response, got tag %d", resp.tag)
+	}
+	return resp.children[0].int()
+}
+
+// search 返回命中条目的 dn 与结果码
+func (c *testClient) search(base string, scope int64, filter *packet, attrs ...string) ([]string, int64) {
+	c.t.Helper()
+	wanted := newSequence(classUniversal, tagSequence)
+	for _, a := range attrs {
+		wanted.children = append(wanted.children, newString(classUniversal, tagOctetString, a))
+	}
+	c.send(newSequence(classApplication, appSearchRequest,
+		newString(classUniversal, tagOctetString, base),
+		newInt(tagEnumerated, scope),
+		newInt(tagEnumerated, 0),
+		newInt(tagInteger, 0),
+		newInt(tagInteger, 0),
+		&packet{class: classUniversal, tag: tagBoolean, value: []byte{0}},
+		filter,
+		wanted))
+	var dns []string
+	for {
+		resp := c.receive()
+		switch {
+		case resp.is(classApplication, appSearchEntry):
+			dns = append(dns, resp.children[0].string())
+		case resp.is(classApplication, appSearchDone):
+			sort.Strings(dns)
+			return dns, resp.children[0].int()
+		default:
+			c.t.Fatalf("unexpected search response tag %d", resp.tag)
+		}
+	}
+}
+
+func equality(attr, value string) *packet {
+	return newSequence(classContext, filterEquality,
+		newString(classUniversal, tagOctetString, attr),
+		newString(classUniversal, tagOctetString, value))
+}
+
+func present(attr string) *packet {
+	return newString(classContext, filterPresent, attr)
+}
+
+func TestBind(t *testing.T) {
+	s := startTestServer(t)
+	cases := []struct {
+		name     string
+		dn       string
+		password string
+		want     int64
+	}{
+		{name: "bind dn", dn: "cn=admin," + testBaseDN, password: "admin-secret", want: resultSuccess},
+		{name: "user dn", dn: "uid=alice," + testBaseDN, password: "alice-pw", want: resultSuccess},
+		{name: "user dn case insensitive", dn: "UID=alice,DC=kubecube,DC=io", password: "alice-pw", want: resultSuccess},
+		{name: "bare username", dn: "bob", password: "bob-pw", want: resultSuccess},
+		{name: "wrong password", dn: "uid=alice," + testBaseDN, password: "bob-pw", want: resultInvalidCredentials},
+		{name: "empty password", dn: "uid=alice," + testBaseDN, password: "", want: resultInvalidCredentials},
+		{name: "anonymous with bind dn configured", dn: "", password: "", want: resultInvalidCredentials},
+		{name: "unknown user", dn: "uid=carol," + testBaseDN, password: "carol-pw", want: resultInvalidCredentials},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := dial(t, s).bind(c.dn, c.password); got != c.want {
+				t.Fatalf("bind result %d, want %d", got, c.want)
+			}
+		})
+	}
+}
+
+func TestSearch(t *testing.T) {
+	s := startTestServer(t)
+	cli := dial(t, s)
+	if code := cli.bind("cn=admin,"+testBaseDN, "admin-secret"); code != resultSuccess {
+		t.Fatalf("bind result %d", code)
+	}
+
+	alice, bob := "uid=alice,"+testBaseDN, "uid=bob,"+testBaseDN
+	cases := []struct {
+		name   string
+		base   string
+		scope  int64
+		filter *packet
+		want   []string
+		code   int64
+	}{
+		{name: "equality", base: testBaseDN, scope: scopeWholeSubtree, filter: equality("uid", "alice"), want: []string{alice}},
+		{name: "equality case insensitive", base: testBaseDN, scope: scopeWholeSubtree, filter: equality("MAIL", "BOB@e2e.kubecube.io"), want: []string{bob}},
+		{name: "present", base: testBaseDN, scope: scopeSingleLevel, filter: present("objectClass"), want: []string{alice, bob}},
+		{name: "and", base: testBaseDN, scope: scopeWholeSubtree,
+			filter: newSequence(classContext, filterAnd, equality("objectClass", "person"), equality("uid", "bob")), want: []string{bob}},
+		{name: "or", base: testBaseDN, scope: scopeWholeSubtree,
+			filter: newSequence(classContext, filterOr, equality("uid", "alice"), equality("uid", "bob")), want: []string{alice, bob}},
+		{name: "not", base: testBaseDN, scope: scopeWholeSubtree,
+			filter: newSequence(classContext, filterNot, equality("uid", "alice")), want: []string{bob}},
+		{name: "substrings", base: testBaseDN, scope: scopeWholeSubtree,
+			filter: newSequence(classContext, filterSubstrings,
+				newString(classUniversal, tagOctetString, "mail"),
+				newSequence(classUniversal, tagSequence,
+					newString(classContext, 0, "ali"),
+					newString(classContext, 2, "kubecube.io"))),
+			want: []string{alice}},
+		{name: "base object", base: bob, scope: scopeBaseObject, filter: present("uid"), want: []string{bob}},
+		{name: "no match", base: testBaseDN, scope: scopeWholeSubtree, filter: equality("uid", "carol")},
+		{name: "outside base dn", base: "dc=example,dc=com", scope: scopeWholeSubtree, filter: present("uid"), code: resultNoSuchObject},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, code := cli.search(c.base, c.scope, c.filter, "uid", "mail")
+			if code != c.code {
+				t.Fatalf("search result %d, want %d", code, c.code)
+			}
+			if len(got) != len(c.want) {
+				t.Fatalf("got entries %v, want %v", got, c.want)
+			}
+			for i := range got {
+				if got[i] != c.want[i] {
+					t.Fatalf("got entries %v, want %v", got, c.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchAttributes(t *testing.T) {
+	s := startTestServer(t)
+	cli := dial(t, s)
+	cli.bind("cn=admin,"+testBaseDN, "admin-secret")
+
+	wanted := newSequence(classUniversal, tagSequence, newString(classUniversal, tagOctetString, "mail"))
+	cli.send(newSequence(classApplication, appSearchRequest,
+		newString(classUniversal, tagOctetString, testBaseDN),
+		newInt(tagEnumerated, scopeWholeSubtree),
+		newInt(tagEnumerated, 0),
+		newInt(tagInteger, 0),
+		newInt(tagInteger, 0),
+		&packet{class: classUniversal, tag: tagBoolean, value: []byte{0}},
+		equality("uid", "alice"),
+		wanted))
+	entry := cli.receive()
+	if !entry.is(classApplication, appSearchEntry) {
+		t.Fatalf("expected search entry, got tag %d", entry.tag)
+	}
+	attrs := entry.children[1].children
+	if len(attrs) != 1 || attrs[0].children[0].string() != "mail" {
+		t.Fatalf("expected only mail attribute, got %d attributes", len(attrs))
+	}
+	if v := attrs[0].children[1].children[0].string(); v != "alice@e2e.kubecube.io" {
+		t.Fatalf("mail = %s", v)
+	}
+	if done := cli.receive(); !done.is(classApplication, appSearchDone) {
+		t.Fatalf("expected search done, got tag %d", done.tag)
+	}
+}
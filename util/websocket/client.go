@@ -22,6 +22,7 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"time"
 )
 
 type Client struct {
@@ -35,7 +36,7 @@ type Client struct {
 	Reconnected chan struct{}
 }
 
-func NewClient(address string, sessionId string, stop chan struct{}) (*Client, error) {
+func NewClient(address string, sessionId string, stop chan struct{}, retryInterval, retryTimeout time.Duration) (*Client, error) {
 	client := &Client{}
 
 	client.Address = address
@@ -52,7 +53,7 @@ func NewClient(address string, sessionId string, stop chan struct{}) (*Client, e
 		a2 := strings.Replace(address, "https", "wss", 1)
 		a2 = strings.Replace(a2, "http", "ws", 1)
 
-		ws, err := NewWebSocket(a2, sessionId, stop)
+		ws, err := NewWebSocket(a2, sessionId, stop, retryInterval, retryTimeout)
 		if err != nil {
 			return nil, err
 		}
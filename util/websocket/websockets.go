@@ -22,11 +22,11 @@ import (
 	"errors"
 	"github.com/dchest/uniuri"
 	"github.com/gorilla/websocket"
-	"github.com/kubecube-io/kubecube-e2e/e2e/framework"
 	backoff2 "github.com/kubecube-io/kubecube-e2e/util/retry"
 	"github.com/kubecube-io/kubecube/pkg/clog"
 	"net/http"
 	"sync"
+	"time"
 )
 
 type WebSocket struct {
@@ -39,16 +39,22 @@ type WebSocket struct {
 	Inbound          chan []byte
 	Reconnected      chan struct{}
 	Stop             chan struct{}
+
+	// RetryInterval and RetryTimeout bound the reconnect backoff
+	RetryInterval time.Duration
+	RetryTimeout  time.Duration
 }
 
-func NewWebSocket(address string, sessionId string, stop chan struct{}) (*WebSocket, error) {
+func NewWebSocket(address string, sessionId string, stop chan struct{}, retryInterval, retryTimeout time.Duration) (*WebSocket, error) {
 	ws := &WebSocket{
-		Address:     address,
-		ServerID:    paddedRandomIntn(999),
-		SessionID:   uniuri.NewLen(8),
-		Inbound:     make(chan []byte),
-		Reconnected: make(chan struct{}, 32),
-		Stop:        stop,
+		Address:       address,
+		ServerID:      paddedRandomIntn(999),
+		SessionID:     uniuri.NewLen(8),
+		Inbound:       make(chan []byte),
+		Reconnected:   make(chan struct{}, 32),
+		Stop:          stop,
+		RetryInterval: retryInterval,
+		RetryTimeout:  retryTimeout,
 	}
 
 	ws.TransportAddress = address + "/" + ws.ServerID + "/" + ws.SessionID + "/websocket"
@@ -61,11 +67,11 @@ func NewWebSocket(address string, sessionId string, stop chan struct{}) (*WebSoc
 func (w *WebSocket) Loop(sessionId string) {
 	go func() {
 		b := &backoff2.ExponentialBackOff{
-			InitialInterval:     framework.WaitInterval,
+			InitialInterval:     w.RetryInterval,
 			RandomizationFactor: backoff2.DefaultRandomizationFactor,
 			Multiplier:          backoff2.DefaultMultiplier,
 			MaxInterval:         backoff2.DefaultMaxInterval,
-			MaxElapsedTime:      framework.WaitTimeout,
+			MaxElapsedTime:      w.RetryTimeout,
 			Clock:               backoff2.SystemClock,
 		}
 		b.Reset()